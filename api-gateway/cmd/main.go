@@ -1,21 +1,44 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+
 	"api-gateway/config"
 	"api-gateway/internal/server"
+
+	"golang/pkg/logger"
+	"golang/pkg/telemetry"
 )
 
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		logger.Fatal("failed to load config", "error", err)
+	}
+
+	// Configure structured logging
+	logger.Init("api-gateway", cfg.LogLevel, cfg.LogFormat)
+
+	// Configure the meter provider and the Prometheus exporter
+	metricsHandler, shutdownMetrics, err := telemetry.InitMetrics("api-gateway")
+	if err != nil {
+		logger.Fatal("failed to initialize metrics", "error", err)
+	}
+	defer shutdownMetrics(context.Background())
+
+	// Configure tracing and trace context propagation
+	shutdownTracing, err := telemetry.InitTracing(context.Background(), "api-gateway", cfg.TracesExporter, cfg.OTLPEndpoint)
+	if err != nil {
+		logger.Fatal("failed to initialize tracing", "error", err)
 	}
+	defer shutdownTracing(context.Background())
 
 	// Initialize and start the server
-	srv := server.NewServer(cfg)
+	srv := server.NewServer(cfg, metricsHandler)
+	slog.Info("api gateway listening", "port", cfg.Port)
 	if err := srv.Start(); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+		logger.Fatal("failed to start server", "error", err)
 	}
 }
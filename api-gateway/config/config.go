@@ -2,8 +2,9 @@ package config
 
 import (
 	"errors"
-	"log"
+	"log/slog"
 	"os"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -14,16 +15,27 @@ type Config struct {
 	InventoryService string
 	OrderService     string
 	UserService      string
+	PaymentService   string
 	JWTSecret        string
+	TracesExporter   string
+	OTLPEndpoint     string
+	LogLevel         string
+	LogFormat        string
+	RedisAddr        string
+	RedisPassword    string
+	// TrustedProxies are the addresses or CIDRs of the proxies whose
+	// X-Forwarded-For header is believed. Empty trusts none, so the client
+	// IP is the address of the connection.
+	TrustedProxies []string
 }
 
 // Load loads configuration from environment variables or .env file
 func Load() (*Config, error) {
 	// Try to load .env file, continue if not found
 	if err := godotenv.Load(".env"); err != nil {
-		log.Println("No .env file found, using environment variables")
+		slog.Info("no .env file found, using environment variables")
 	} else {
-		log.Println("Using configuration from .env file")
+		slog.Info("using configuration from .env file")
 	}
 
 	// Create config with values from environment
@@ -32,7 +44,15 @@ func Load() (*Config, error) {
 		InventoryService: getEnvWithDefault("INVENTORY_SERVICE", "localhost:50051"),
 		OrderService:     getEnvWithDefault("ORDER_SERVICE", "localhost:50052"),
 		UserService:      getEnvWithDefault("USER_SERVICE", "localhost:50053"),
+		PaymentService:   getEnvWithDefault("PAYMENT_SERVICE", "localhost:50054"),
 		JWTSecret:        os.Getenv("JWT_SECRET"),
+		TracesExporter:   getEnvWithDefault("TRACES_EXPORTER", "none"),
+		OTLPEndpoint:     getEnvWithDefault("OTLP_ENDPOINT", "localhost:4317"),
+		LogLevel:         getEnvWithDefault("LOG_LEVEL", "info"),
+		LogFormat:        getEnvWithDefault("LOG_FORMAT", "json"),
+		RedisAddr:        getEnvWithDefault("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:    os.Getenv("REDIS_PASSWORD"),
+		TrustedProxies:   getEnvList("TRUSTED_PROXIES"),
 	}
 
 	// Validate JWT secret
@@ -51,3 +71,15 @@ func getEnvWithDefault(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvList splits a comma-separated environment variable, dropping empty
+// entries.
+func getEnvList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	pbaddress "api-gateway/internal/pb/address"
+	"api-gateway/internal/pb/user"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/status"
+)
+
+// Address book handlers. Every route works on the address book of the
+// logged-in user. Bodies are {"address": {...}, "is_default": true}.
+
+// addressBody is what CreateAddress and UpdateAddress read.
+type addressBody struct {
+	Address   *pbaddress.Address `json:"address" binding:"required"`
+	IsDefault bool               `json:"is_default"`
+}
+
+func (h *Handler) ListAddresses(c *gin.Context) {
+	req := &user.ListAddressesRequest{UserId: fmt.Sprint(c.MustGet("user_id"))}
+	resp, err := h.addressClient.ListAddresses(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": status.Convert(err).Message()})
+		return
+	}
+	addresses := resp.Addresses
+	if addresses == nil {
+		addresses = []*user.SavedAddress{}
+	}
+	c.JSON(http.StatusOK, addresses)
+}
+
+// CreateAddress adds an address; the user's first address becomes the
+// default.
+func (h *Handler) CreateAddress(c *gin.Context) {
+	var body addressBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.addressClient.CreateAddress(c.Request.Context(), &user.CreateAddressRequest{
+		UserId:    fmt.Sprint(c.MustGet("user_id")),
+		Address:   body.Address,
+		IsDefault: body.IsDefault,
+	})
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *Handler) GetAddress(c *gin.Context) {
+	resp, err := h.addressClient.GetAddress(c.Request.Context(), &user.GetAddressRequest{
+		UserId: fmt.Sprint(c.MustGet("user_id")),
+		Id:     c.Param("id"),
+	})
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateAddress replaces an address. Orders already placed keep the address
+// they were placed with.
+func (h *Handler) UpdateAddress(c *gin.Context) {
+	var body addressBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.addressClient.UpdateAddress(c.Request.Context(), &user.UpdateAddressRequest{
+		UserId:    fmt.Sprint(c.MustGet("user_id")),
+		Id:        c.Param("id"),
+		Address:   body.Address,
+		IsDefault: body.IsDefault,
+	})
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) DeleteAddress(c *gin.Context) {
+	resp, err := h.addressClient.DeleteAddress(c.Request.Context(), &user.DeleteAddressRequest{
+		UserId: fmt.Sprint(c.MustGet("user_id")),
+		Id:     c.Param("id"),
+	})
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": resp.Message})
+}
+
+func (h *Handler) SetDefaultAddress(c *gin.Context) {
+	resp, err := h.addressClient.SetDefaultAddress(c.Request.Context(), &user.SetDefaultAddressRequest{
+		UserId: fmt.Sprint(c.MustGet("user_id")),
+		Id:     c.Param("id"),
+	})
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"api-gateway/internal/middleware"
+	"api-gateway/internal/pb/order"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// cartIDHeader carries a guest's cart ID. The gateway makes one up on the
+// guest's first change to the cart and returns it in the same header; the
+// client sends it back on later cart requests and on login, when the guest
+// cart is merged into the user's.
+const cartIDHeader = "X-Cart-ID"
+
+// cartOwner returns the owner of the request's cart: the logged-in user, or
+// else the guest named in X-Cart-ID. A guest without a cart gets a new cart
+// ID if create is set, and nil otherwise.
+func cartOwner(c *gin.Context, create bool) (*order.CartOwner, error) {
+	if userID, ok := c.Get("user_id"); ok {
+		return &order.CartOwner{UserId: fmt.Sprint(userID)}, nil
+	}
+	guestID := c.GetHeader(cartIDHeader)
+	if guestID == "" {
+		if !create {
+			return nil, nil
+		}
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		guestID = hex.EncodeToString(b)
+	}
+	c.Header(cartIDHeader, guestID)
+	return &order.CartOwner{GuestId: guestID}, nil
+}
+
+// GetCart returns the cart with current prices and stock. Items that cannot
+// be ordered as they are carry a problem.
+func (h *Handler) GetCart(c *gin.Context) {
+	owner, err := cartOwner(c, false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if owner == nil {
+		c.JSON(http.StatusOK, &order.Cart{})
+		return
+	}
+	resp, err := h.cartClient.GetCart(c.Request.Context(), &order.GetCartRequest{Owner: owner})
+	if err != nil {
+		cartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// AddCartItem adds quantity units of a variant, on top of any already in the
+// cart.
+func (h *Handler) AddCartItem(c *gin.Context) {
+	var req order.AddCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var err error
+	if req.Owner, err = cartOwner(c, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.cartClient.AddCartItem(c.Request.Context(), &req)
+	if err != nil {
+		cartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateCartItem sets the quantity of the product in the path; zero removes
+// it. The body names the variant if the product is in the cart in several.
+func (h *Handler) UpdateCartItem(c *gin.Context) {
+	var req order.UpdateCartItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ProductId = c.Param("product_id")
+	var err error
+	if req.Owner, err = cartOwner(c, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.cartClient.UpdateCartItem(c.Request.Context(), &req)
+	if err != nil {
+		cartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RemoveCartItem removes the product in the path, or only its ?sku= variant.
+func (h *Handler) RemoveCartItem(c *gin.Context) {
+	owner, err := cartOwner(c, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.cartClient.RemoveCartItem(c.Request.Context(), &order.RemoveCartItemRequest{
+		Owner:     owner,
+		ProductId: c.Param("product_id"),
+		Sku:       c.Query("sku"),
+	})
+	if err != nil {
+		cartError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Checkout orders everything in the user's cart. In the optional body,
+// "total" is the total the client showed, and checkout fails if prices
+// changed since; "promo_code" is a coupon to apply; "region" is where the
+// order ships, for tax and shipping; "address_id" or "shipping_address" is
+// the address it ships to. Like order creation it honours an
+// Idempotency-Key.
+func (h *Handler) Checkout(c *gin.Context) {
+	var req order.CheckoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	req.UserId = fmt.Sprint(c.MustGet("user_id"))
+
+	ctx := c.Request.Context()
+	if key := c.GetHeader("Idempotency-Key"); key != "" {
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, "idempotency-key", key)
+	}
+
+	var header metadata.MD
+	resp, err := h.cartClient.Checkout(ctx, &req, grpc.Header(&header))
+	if err != nil {
+		cartError(c, err)
+		return
+	}
+	if replayed := header.Get("idempotent-replayed"); len(replayed) > 0 {
+		c.Header("Idempotent-Replayed", replayed[0])
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+// mergeGuestCart moves a guest's cart into the cart of the user who just
+// logged in with token. Failures are logged; the login itself stands.
+func (h *Handler) mergeGuestCart(c *gin.Context, guestID, token string) {
+	ctx := c.Request.Context()
+	claims, err := middleware.ParseToken(h.cfg, token)
+	if err != nil {
+		slog.WarnContext(ctx, "cannot read token issued at login", "error", err)
+		return
+	}
+	_, err = h.cartClient.MergeCart(ctx, &order.MergeCartRequest{
+		GuestId: guestID,
+		UserId:  fmt.Sprint(claims["sub"]),
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "failed to merge guest cart", "error", err)
+	}
+}
+
+func cartError(c *gin.Context, err error) {
+	msg := status.Convert(err).Message()
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	case codes.FailedPrecondition, codes.Aborted:
+		c.JSON(http.StatusConflict, gin.H{"error": msg})
+	case codes.Unavailable:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Products cannot be checked right now, try again later"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
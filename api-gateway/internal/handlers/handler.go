@@ -1,53 +1,96 @@
 package handler
 
 import (
-	"context"
-	"log"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 
 	"api-gateway/config"
 	"api-gateway/internal/pb/inventory"
+	pbmoney "api-gateway/internal/pb/money"
 	"api-gateway/internal/pb/order"
+	"api-gateway/internal/pb/payment"
 	"api-gateway/internal/pb/user"
 
+	"golang/pkg/logger"
+
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 )
 
 // Handler manages REST handlers and gRPC clients
 type Handler struct {
+	cfg             *config.Config
 	inventoryClient inventory.InventoryServiceClient
 	orderClient     order.OrderServiceClient
+	cartClient      order.CartServiceClient
+	promotionClient order.PromotionServiceClient
+	returnClient    order.ReturnServiceClient
+	invoiceClient   order.InvoiceServiceClient
 	userClient      user.UserServiceClient
+	addressClient   user.AddressServiceClient
+	paymentClient   payment.PaymentServiceClient
 }
 
 // NewHandler initializes gRPC clients and returns a Handler
 func NewHandler(cfg *config.Config) (*Handler, error) {
-	inventoryConn, err := grpc.Dial(cfg.InventoryService, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	inventoryConn, err := grpc.Dial(cfg.InventoryService, dialOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	orderConn, err := grpc.Dial(cfg.OrderService, dialOptions()...)
 	if err != nil {
 		return nil, err
 	}
 
-	orderConn, err := grpc.Dial(cfg.OrderService, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	userConn, err := grpc.Dial(cfg.UserService, dialOptions()...)
 	if err != nil {
 		return nil, err
 	}
 
-	userConn, err := grpc.Dial(cfg.UserService, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	paymentConn, err := grpc.Dial(cfg.PaymentService, dialOptions()...)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Handler{
+		cfg:             cfg,
 		inventoryClient: inventory.NewInventoryServiceClient(inventoryConn),
 		orderClient:     order.NewOrderServiceClient(orderConn),
+		cartClient:      order.NewCartServiceClient(orderConn),
+		promotionClient: order.NewPromotionServiceClient(orderConn),
+		returnClient:    order.NewReturnServiceClient(orderConn),
+		invoiceClient:   order.NewInvoiceServiceClient(orderConn),
 		userClient:      user.NewUserServiceClient(userConn),
+		addressClient:   user.NewAddressServiceClient(userConn),
+		paymentClient:   payment.NewPaymentServiceClient(paymentConn),
 	}, nil
 }
 
+// dialOptions returns the options shared by all backend connections. The
+// stats handler starts a client span per call and propagates trace context;
+// the interceptor forwards the request ID.
+func dialOptions() []grpc.DialOption {
+	return []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(logger.UnaryClientInterceptor()),
+	}
+}
+
 // Inventory Handlers
 func (h *Handler) CreateProduct(c *gin.Context) {
 	var req inventory.CreateProductRequest
@@ -56,9 +99,9 @@ func (h *Handler) CreateProduct(c *gin.Context) {
 		return
 	}
 
-	resp, err := h.inventoryClient.CreateProduct(context.Background(), &req)
+	resp, err := h.inventoryClient.CreateProduct(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		productWriteError(c, err)
 		return
 	}
 
@@ -68,7 +111,7 @@ func (h *Handler) CreateProduct(c *gin.Context) {
 func (h *Handler) GetProduct(c *gin.Context) {
 	id := c.Param("id")
 	req := &inventory.GetProductRequest{Id: id}
-	resp, err := h.inventoryClient.GetProduct(context.Background(), req)
+	resp, err := h.inventoryClient.GetProduct(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -82,18 +125,100 @@ func (h *Handler) UpdateProduct(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	resp, err := h.inventoryClient.UpdateProduct(context.Background(), &req)
+	req.Id = c.Param("id")
+	resp, err := h.inventoryClient.UpdateProduct(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		productWriteError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, resp.Product)
 }
 
+// PatchProduct applies a JSON merge patch to a product: only the fields present
+// in the body are written. "version" may be included to make the update
+// conditional on the version the client read.
+func (h *Handler) PatchProduct(c *gin.Context) {
+	var patch map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := &inventory.UpdateProductRequest{Id: c.Param("id")}
+	var paths []string
+	for field, raw := range patch {
+		// In a merge patch null removes a field, but every product field is required.
+		if string(raw) == "null" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s cannot be removed", field)})
+			return
+		}
+
+		var err error
+		switch field {
+		case "name":
+			err = json.Unmarshal(raw, &req.Name)
+		case "description":
+			err = json.Unmarshal(raw, &req.Description)
+		case "category_id":
+			err = json.Unmarshal(raw, &req.CategoryId)
+		case "stock":
+			err = json.Unmarshal(raw, &req.Stock)
+		case "price":
+			err = json.Unmarshal(raw, &req.Price)
+		case "reorder_level":
+			err = json.Unmarshal(raw, &req.ReorderLevel)
+		case "version":
+			err = json.Unmarshal(raw, &req.Version)
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown field %q", field)})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid value for %s", field)})
+			return
+		}
+		if field != "version" {
+			paths = append(paths, field)
+		}
+	}
+	if len(paths) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
+		return
+	}
+	sort.Strings(paths)
+	req.UpdateMask = &fieldmaskpb.FieldMask{Paths: paths}
+
+	resp, err := h.inventoryClient.UpdateProduct(c.Request.Context(), req)
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp.Product)
+}
+
+// productWriteError maps a failed product write to an HTTP response.
+func productWriteError(c *gin.Context, err error) {
+	switch status.Code(err) {
+	case codes.Aborted:
+		// The client must re-read the product and retry with its new version.
+		c.JSON(http.StatusConflict, gin.H{"error": status.Convert(err).Message()})
+	case codes.FailedPrecondition, codes.AlreadyExists:
+		c.JSON(http.StatusConflict, gin.H{"error": status.Convert(err).Message()})
+	case codes.NotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": status.Convert(err).Message()})
+	case codes.PermissionDenied:
+		c.JSON(http.StatusForbidden, gin.H{"error": status.Convert(err).Message()})
+	case codes.InvalidArgument:
+		c.JSON(http.StatusBadRequest, gin.H{"error": status.Convert(err).Message()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
 func (h *Handler) DeleteProduct(c *gin.Context) {
 	id := c.Param("id")
 	req := &inventory.DeleteProductRequest{Id: id}
-	resp, err := h.inventoryClient.DeleteProduct(context.Background(), req)
+	resp, err := h.inventoryClient.DeleteProduct(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -101,6 +226,114 @@ func (h *Handler) DeleteProduct(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": resp.Message})
 }
 
+// variantRequest is the body of PUT /inventory/:id/variants/:sku.
+type variantRequest struct {
+	Attributes    map[string]string `json:"attributes"`
+	PriceOverride *pbmoney.Money    `json:"price_override"`
+	WeightGrams   int32             `json:"weight_grams"`
+}
+
+// UpsertVariant adds the variant named in the path to a product, or updates
+// its attributes, price override and weight. Stock is changed through stock
+// adjustments only.
+func (h *Handler) UpsertVariant(c *gin.Context) {
+	var body variantRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := &inventory.UpsertVariantRequest{
+		ProductId: c.Param("id"),
+		Variant: &inventory.Variant{
+			Sku:           c.Param("sku"),
+			Attributes:    body.Attributes,
+			PriceOverride: body.PriceOverride,
+			WeightGrams:   body.WeightGrams,
+		},
+	}
+	resp, err := h.inventoryClient.UpsertVariant(c.Request.Context(), req)
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp.Product)
+}
+
+// maxImageUpload mirrors inventory-service's limit so that oversized uploads
+// are rejected before being read in full.
+const maxImageUpload = 5 << 20
+
+// imageTypes are the declared types accepted for product images; the
+// inventory service checks the bytes themselves as well.
+var imageTypes = map[string]bool{"image/jpeg": true, "image/png": true, "image/gif": true}
+
+// AddProductImage takes a multipart/form-data upload with the file in the
+// "image" field and optional "alt" text, and returns the updated product.
+func (h *Handler) AddProductImage(c *gin.Context) {
+	// Leave room for the multipart framing and the alt field.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImageUpload+64<<10)
+	file, header, err := c.Request.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("image must be at most %d MiB", maxImageUpload>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "multipart field image is required"})
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxImageUpload {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("image must be at most %d MiB", maxImageUpload>>20)})
+		return
+	}
+	if ct := header.Header.Get("Content-Type"); !imageTypes[ct] {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("image must be a JPEG, PNG or GIF, got %q", ct)})
+		return
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := &inventory.AddProductImageRequest{
+		ProductId: c.Param("id"),
+		Data:      data,
+		Alt:       c.PostForm("alt"),
+	}
+	resp, err := h.inventoryClient.AddProductImage(c.Request.Context(), req)
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp.Product)
+}
+
+// DeleteProductImage removes an image and its thumbnail from a product.
+func (h *Handler) DeleteProductImage(c *gin.Context) {
+	req := &inventory.DeleteProductImageRequest{ProductId: c.Param("id"), ImageId: c.Param("image_id")}
+	resp, err := h.inventoryClient.DeleteProductImage(c.Request.Context(), req)
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp.Product)
+}
+
+// RestoreProduct undoes a product deletion.
+func (h *Handler) RestoreProduct(c *gin.Context) {
+	req := &inventory.RestoreProductRequest{Id: c.Param("id")}
+	resp, err := h.inventoryClient.RestoreProduct(c.Request.Context(), req)
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp.Product)
+}
+
 func (h *Handler) ListProducts(c *gin.Context) {
 	category := c.Query("category")
 	var page, limit int32
@@ -119,16 +352,188 @@ func (h *Handler) ListProducts(c *gin.Context) {
 		Category: category,
 		Page:     page,
 		Limit:    limit,
+		Sort:     c.Query("sort"),
+		InStock:  c.Query("in_stock") == "true",
 	}
 
-	resp, err := h.inventoryClient.ListProducts(context.Background(), req)
+	resp, err := h.inventoryClient.ListProducts(c.Request.Context(), req)
 	if err != nil {
+		switch status.Code(err) {
+		case codes.InvalidArgument:
+			c.JSON(http.StatusBadRequest, gin.H{"error": status.Convert(err).Message()})
+			return
+		case codes.NotFound:
+			// Unknown category.
+			c.JSON(http.StatusNotFound, gin.H{"error": status.Convert(err).Message()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, resp.Products)
 }
 
+// stockAdjustmentRequest is the body of POST /inventory/:id/stock-adjustments.
+type stockAdjustmentRequest struct {
+	SKU       string `json:"sku"`
+	Delta     int32  `json:"delta" binding:"required"`
+	Reason    string `json:"reason" binding:"required"`
+	Reference string `json:"reference"`
+	Note      string `json:"note"`
+}
+
+// AdjustStock changes a product's stock by a relative delta and records the
+// movement in the stock ledger. The acting user is taken from the token.
+func (h *Handler) AdjustStock(c *gin.Context) {
+	var body stockAdjustmentRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := &inventory.AdjustStockRequest{
+		ProductId: c.Param("id"),
+		Sku:       body.SKU,
+		Delta:     body.Delta,
+		Reason:    body.Reason,
+		Reference: body.Reference,
+		Note:      body.Note,
+		ActorId:   fmt.Sprint(c.MustGet("user_id")),
+	}
+	resp, err := h.inventoryClient.AdjustStock(c.Request.Context(), req)
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
+
+func (h *Handler) GetStockMovements(c *gin.Context) {
+	var page, limit int32
+	if p := c.Query("page"); p != "" {
+		if val, err := strconv.Atoi(p); err == nil {
+			page = int32(val)
+		}
+	}
+	if l := c.Query("limit"); l != "" {
+		if val, err := strconv.Atoi(l); err == nil {
+			limit = int32(val)
+		}
+	}
+
+	req := &inventory.GetStockMovementsRequest{ProductId: c.Param("id"), Page: page, Limit: limit}
+	resp, err := h.inventoryClient.GetStockMovements(c.Request.Context(), req)
+	if err != nil {
+		if status.Code(err) == codes.InvalidArgument {
+			c.JSON(http.StatusBadRequest, gin.H{"error": status.Convert(err).Message()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp.Movements)
+}
+
+// ListLowStock lists products at or below their reorder level.
+func (h *Handler) ListLowStock(c *gin.Context) {
+	var page, limit int32
+	if p := c.Query("page"); p != "" {
+		if val, err := strconv.Atoi(p); err == nil {
+			page = int32(val)
+		}
+	}
+	if l := c.Query("limit"); l != "" {
+		if val, err := strconv.Atoi(l); err == nil {
+			limit = int32(val)
+		}
+	}
+
+	req := &inventory.ListLowStockRequest{Page: page, Limit: limit}
+	resp, err := h.inventoryClient.ListLowStock(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp.Products)
+}
+
+// Category Handlers
+
+// categoryRequest is the body of POST /categories and PUT /categories/:id.
+type categoryRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Slug     string `json:"slug"`
+	ParentID string `json:"parent_id"`
+}
+
+// ListCategories lists the children of ?parent_id, or the whole tree with
+// parents before children.
+func (h *Handler) ListCategories(c *gin.Context) {
+	req := &inventory.ListCategoriesRequest{ParentId: c.Query("parent_id")}
+	resp, err := h.inventoryClient.ListCategories(c.Request.Context(), req)
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp.Categories)
+}
+
+// GetCategory returns a category by ID or slug.
+func (h *Handler) GetCategory(c *gin.Context) {
+	req := &inventory.GetCategoryRequest{Id: c.Param("id")}
+	resp, err := h.inventoryClient.GetCategory(c.Request.Context(), req)
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp.Category)
+}
+
+func (h *Handler) CreateCategory(c *gin.Context) {
+	var body categoryRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := &inventory.CreateCategoryRequest{Name: body.Name, Slug: body.Slug, ParentId: body.ParentID}
+	resp, err := h.inventoryClient.CreateCategory(c.Request.Context(), req)
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp.Category)
+}
+
+// UpdateCategory renames or moves a category; moving it takes its whole
+// subtree along.
+func (h *Handler) UpdateCategory(c *gin.Context) {
+	var body categoryRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req := &inventory.UpdateCategoryRequest{Id: c.Param("id"), Name: body.Name, Slug: body.Slug, ParentId: body.ParentID}
+	resp, err := h.inventoryClient.UpdateCategory(c.Request.Context(), req)
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp.Category)
+}
+
+// DeleteCategory removes a category without subcategories or products.
+func (h *Handler) DeleteCategory(c *gin.Context) {
+	req := &inventory.DeleteCategoryRequest{Id: c.Param("id")}
+	resp, err := h.inventoryClient.DeleteCategory(c.Request.Context(), req)
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": resp.Message})
+}
+
 // Order Handlers
 func (h *Handler) CreateOrder(c *gin.Context) {
 	var req order.CreateOrderRequest
@@ -136,18 +541,45 @@ func (h *Handler) CreateOrder(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	resp, err := h.orderClient.CreateOrder(context.Background(), &req)
+
+	// Forward the client's Idempotency-Key so that retries do not create duplicate orders.
+	ctx := c.Request.Context()
+	if key := c.GetHeader("Idempotency-Key"); key != "" {
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, "idempotency-key", key)
+	}
+
+	var header metadata.MD
+	resp, err := h.orderClient.CreateOrder(ctx, &req, grpc.Header(&header))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch status.Code(err) {
+		case codes.FailedPrecondition:
+			// A reused Idempotency-Key or a total that no longer matches.
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": status.Convert(err).Message()})
+		case codes.Aborted:
+			c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+		case codes.InvalidArgument:
+			c.JSON(http.StatusBadRequest, gin.H{"error": status.Convert(err).Message()})
+		case codes.Unavailable:
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The order cannot be checked right now, try again later"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
+	if replayed := header.Get("idempotent-replayed"); len(replayed) > 0 {
+		c.Header("Idempotent-Replayed", replayed[0])
+	}
 	c.JSON(http.StatusCreated, gin.H{"id": resp.Id, "message": resp.Message})
 }
 
 func (h *Handler) GetOrder(c *gin.Context) {
 	id := c.Param("id")
 	req := &order.GetOrderRequest{Id: id}
-	resp, err := h.orderClient.GetOrder(context.Background(), req)
+	resp, err := h.orderClient.GetOrder(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -161,9 +593,25 @@ func (h *Handler) UpdateOrderStatus(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	resp, err := h.orderClient.UpdateOrderStatus(context.Background(), &req)
+	// Staff may change any order.
+	req.Id = c.Param("id")
+	req.UserId = ""
+	h.updateOrderStatus(c, &req)
+}
+
+// CancelOrder cancels one of the logged-in user's orders that is not paid.
+func (h *Handler) CancelOrder(c *gin.Context) {
+	h.updateOrderStatus(c, &order.UpdateOrderStatusRequest{
+		Id:     c.Param("id"),
+		Status: "CANCELLED",
+		UserId: fmt.Sprint(c.MustGet("user_id")),
+	})
+}
+
+func (h *Handler) updateOrderStatus(c *gin.Context, req *order.UpdateOrderStatusRequest) {
+	resp, err := h.orderClient.UpdateOrderStatus(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		productWriteError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"id": resp.Id, "message": resp.Message})
@@ -172,7 +620,7 @@ func (h *Handler) UpdateOrderStatus(c *gin.Context) {
 func (h *Handler) ListUserOrders(c *gin.Context) {
 	userID := c.Query("user_id")
 	req := &order.ListUserOrdersRequest{UserId: userID}
-	resp, err := h.orderClient.ListUserOrders(context.Background(), req)
+	resp, err := h.orderClient.ListUserOrders(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -187,9 +635,9 @@ func (h *Handler) RegisterUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
-	resp, err := h.userClient.RegisterUser(context.Background(), &req)
+	resp, err := h.userClient.RegisterUser(c.Request.Context(), &req)
 	if err != nil {
-		log.Printf("RegisterUser failed: %v", err)
+		slog.WarnContext(c.Request.Context(), "register user failed", "error", err)
 		errMsg := err.Error()
 		switch {
 		case strings.Contains(errMsg, "username already exists"):
@@ -212,9 +660,18 @@ func (h *Handler) AuthenticateUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid login request"})
 		return
 	}
-	resp, err := h.userClient.AuthenticateUser(context.Background(), &req)
+	var header metadata.MD
+	resp, err := h.userClient.AuthenticateUser(c.Request.Context(), &req, grpc.Header(&header))
 	if err != nil {
-		log.Printf("AuthenticateUser failed: %v", err)
+		slog.WarnContext(c.Request.Context(), "authenticate user failed", "error", err)
+		if status.Code(err) == codes.ResourceExhausted {
+			// The account is locked out after repeated failed logins.
+			if retryAfter := header.Get("retry-after"); len(retryAfter) > 0 {
+				c.Header("Retry-After", retryAfter[0])
+			}
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, try again later"})
+			return
+		}
 		errMsg := err.Error()
 		if strings.Contains(errMsg, "user not found") || strings.Contains(errMsg, "incorrect password") {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
@@ -223,13 +680,16 @@ func (h *Handler) AuthenticateUser(c *gin.Context) {
 		}
 		return
 	}
+	if guestID := c.GetHeader(cartIDHeader); guestID != "" {
+		h.mergeGuestCart(c, guestID, resp.Token)
+	}
 	c.JSON(http.StatusOK, gin.H{"token": resp.Token, "message": resp.Message})
 }
 
 func (h *Handler) GetUserProfile(c *gin.Context) {
 	id := c.Param("id")
 	req := &user.UserID{Id: id}
-	resp, err := h.userClient.GetUserProfile(context.Background(), req)
+	resp, err := h.userClient.GetUserProfile(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
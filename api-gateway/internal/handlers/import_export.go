@@ -0,0 +1,404 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"api-gateway/internal/pb/inventory"
+	pbmoney "api-gateway/internal/pb/money"
+
+	"golang/pkg/money"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Bulk files are CSV with a header row, or NDJSON with one object per line.
+// Both carry one variant per row; attributes are written in CSV as a query
+// string, e.g. "color=red&size=M".
+var csvColumns = []string{"sku", "name", "description", "category", "price", "stock", "reorder_level", "price_override", "attributes", "currency", "weight_grams"}
+
+// maxImportErrors matches the cap of the inventory service's import report.
+const maxImportErrors = 1000
+
+// importRow is one NDJSON line of an import or export. Prices are decimal
+// amounts, as numbers or strings, kept as written so they convert to minor
+// units exactly.
+type importRow struct {
+	SKU           string            `json:"sku"`
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	Category      string            `json:"category"`
+	Price         json.Number       `json:"price"`
+	Stock         *int32            `json:"stock,omitempty"`
+	ReorderLevel  *int32            `json:"reorder_level,omitempty"`
+	PriceOverride json.Number       `json:"price_override,omitempty"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+	Currency      string            `json:"currency,omitempty"`
+	WeightGrams   *int32            `json:"weight_grams,omitempty"`
+}
+
+type importError struct {
+	Line  int32  `json:"line"`
+	SKU   string `json:"sku,omitempty"`
+	Error string `json:"error"`
+}
+
+// importReport is the response of POST /inventory/import.
+type importReport struct {
+	DryRun  bool          `json:"dry_run"`
+	Created int32         `json:"created"`
+	Updated int32         `json:"updated"`
+	Failed  int32         `json:"failed"`
+	Errors  []importError `json:"errors"`
+}
+
+// ImportProducts streams a CSV (text/csv) or NDJSON (application/x-ndjson)
+// body to the inventory service row by row. Every row upserts a variant by
+// SKU; rows that fail to parse or apply are listed in the report and the
+// others are applied. With ?dry_run=true nothing is written.
+func (h *Handler) ImportProducts(c *gin.Context) {
+	var parse func(io.Reader, func(int32, *inventory.ImportRow, error) error) error
+	switch c.ContentType() {
+	case "text/csv":
+		parse = parseCSVRows
+	case "application/x-ndjson", "application/jsonl":
+		parse = parseNDJSONRows
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "body must be text/csv or application/x-ndjson"})
+		return
+	}
+
+	stream, err := h.inventoryClient.ImportProducts(c.Request.Context())
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	// The first message carries the options even when the file has no rows.
+	first := &inventory.ImportProductsRequest{
+		DryRun:  c.Query("dry_run") == "true",
+		ActorId: fmt.Sprint(c.MustGet("user_id")),
+	}
+	if err := stream.Send(first); err != nil && !errors.Is(err, io.EOF) {
+		productWriteError(c, err)
+		return
+	}
+
+	// Rows that do not parse never reach the service; they are merged into
+	// its report.
+	var local []importError
+	err = parse(c.Request.Body, func(line int32, row *inventory.ImportRow, err error) error {
+		if err != nil {
+			local = append(local, importError{Line: line, SKU: row.GetSku(), Error: err.Error()})
+			return nil
+		}
+		row.Line = line
+		return stream.Send(&inventory.ImportProductsRequest{Row: row})
+	})
+	// io.EOF from Send means the service ended the stream; CloseAndRecv
+	// returns its status.
+	if err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+
+	report := importReport{
+		DryRun:  resp.DryRun,
+		Created: resp.Created,
+		Updated: resp.Updated,
+		Failed:  resp.Failed + int32(len(local)),
+		Errors:  local,
+	}
+	for _, e := range resp.Errors {
+		report.Errors = append(report.Errors, importError{Line: e.Line, SKU: e.Sku, Error: e.Error})
+	}
+	sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Line < report.Errors[j].Line })
+	if len(report.Errors) > maxImportErrors {
+		report.Errors = report.Errors[:maxImportErrors]
+	}
+	if report.Errors == nil {
+		report.Errors = []importError{}
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// parseCSVRows calls fn for every record after the header, with its line
+// number and either the row or why it could not be parsed. The header names
+// the columns in any order; sku and name are required.
+func parseCSVRows(r io.Reader, fn func(int32, *inventory.ImportRow, error) error) error {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !slices.Contains(csvColumns, name) {
+			return fmt.Errorf("unknown column %q, expected some of %s", name, strings.Join(csvColumns, ", "))
+		}
+		if _, ok := columns[name]; ok {
+			return fmt.Errorf("duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range []string{"sku", "name"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("column %q is required", name)
+		}
+	}
+
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if err := fn(int32(parseErr.StartLine), nil, parseErr.Err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		line, _ := cr.FieldPos(0)
+
+		get := func(name string) string {
+			if i, ok := columns[name]; ok {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row, err := csvRow(get)
+		if err := fn(int32(line), row, err); err != nil {
+			return err
+		}
+	}
+}
+
+func csvRow(get func(string) string) (*inventory.ImportRow, error) {
+	row := &inventory.ImportRow{
+		Sku:         get("sku"),
+		Name:        get("name"),
+		Description: get("description"),
+		Category:    get("category"),
+		// Prices are checked by the inventory service, which parses them
+		// in the row's currency.
+		Price:         get("price"),
+		PriceOverride: get("price_override"),
+		Currency:      get("currency"),
+	}
+	var err error
+	if row.Stock, err = parseOptionalInt(get("stock"), "stock"); err != nil {
+		return row, err
+	}
+	if row.ReorderLevel, err = parseOptionalInt(get("reorder_level"), "reorder_level"); err != nil {
+		return row, err
+	}
+	if row.WeightGrams, err = parseOptionalInt(get("weight_grams"), "weight_grams"); err != nil {
+		return row, err
+	}
+	if s := get("attributes"); s != "" {
+		values, err := url.ParseQuery(s)
+		if err != nil {
+			return row, fmt.Errorf("attributes must look like color=red&size=M: %v", err)
+		}
+		row.Attributes = make(map[string]string, len(values))
+		for k, v := range values {
+			row.Attributes[k] = v[len(v)-1]
+		}
+	}
+	return row, nil
+}
+
+// parseOptionalInt returns nil for an empty cell.
+func parseOptionalInt(s, name string) (*int32, error) {
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a whole number", name)
+	}
+	n := int32(v)
+	return &n, nil
+}
+
+// parseNDJSONRows calls fn for every non-blank line with its line number and
+// either the row or why it could not be parsed.
+func parseNDJSONRows(r io.Reader, fn func(int32, *inventory.ImportRow, error) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	var line int32
+	for sc.Scan() {
+		line++
+		data := bytes.TrimSpace(sc.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		var in importRow
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err := dec.Decode(&in)
+		row := &inventory.ImportRow{
+			Sku:           in.SKU,
+			Name:          in.Name,
+			Description:   in.Description,
+			Category:      in.Category,
+			Price:         in.Price.String(),
+			Stock:         in.Stock,
+			ReorderLevel:  in.ReorderLevel,
+			PriceOverride: in.PriceOverride.String(),
+			Attributes:    in.Attributes,
+			Currency:      in.Currency,
+			WeightGrams:   in.WeightGrams,
+		}
+		if err := fn(line, row, err); err != nil {
+			return err
+		}
+	}
+	if errors.Is(sc.Err(), bufio.ErrTooLong) {
+		return fmt.Errorf("line %d is longer than 1 MiB", line+1)
+	}
+	return sc.Err()
+}
+
+// ExportProducts streams every live product, or those in ?category= and its
+// subcategories, as CSV (?format=csv, the default) or NDJSON
+// (?format=ndjson), one row per variant. The output can be imported again.
+func (h *Handler) ExportProducts(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "ndjson" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or ndjson"})
+		return
+	}
+
+	stream, err := h.inventoryClient.ExportProducts(c.Request.Context(), &inventory.ExportProductsRequest{Category: c.Query("category")})
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	// Errors such as an unknown category arrive with the first message, so
+	// read it before committing to a status.
+	p, err := stream.Recv()
+	if err != nil && !errors.Is(err, io.EOF) {
+		productWriteError(c, err)
+		return
+	}
+
+	var write func(*inventory.Product) error
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", `attachment; filename="products.csv"`)
+		w := csv.NewWriter(c.Writer)
+		if err := w.Write(csvColumns); err != nil {
+			return
+		}
+		write = func(p *inventory.Product) error {
+			for _, v := range p.Variants {
+				if err := w.Write(csvRecord(p, v)); err != nil {
+					return err
+				}
+			}
+			w.Flush()
+			return w.Error()
+		}
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="products.ndjson"`)
+		enc := json.NewEncoder(c.Writer)
+		write = func(p *inventory.Product) error {
+			for _, v := range p.Variants {
+				if err := enc.Encode(exportRow(p, v)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	c.Status(http.StatusOK)
+
+	for p != nil {
+		if err := write(p); err != nil {
+			// The client went away.
+			return
+		}
+		c.Writer.Flush()
+		if p, err = stream.Recv(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				// The status is already sent; the truncated body is all the
+				// client will see.
+				slog.ErrorContext(c.Request.Context(), "product export failed", "error", err)
+			}
+			return
+		}
+	}
+}
+
+func exportRow(p *inventory.Product, v *inventory.Variant) importRow {
+	stock, reorderLevel, weight := v.Stock, p.ReorderLevel, v.WeightGrams
+	return importRow{
+		SKU:           v.Sku,
+		Name:          p.Name,
+		Description:   p.Description,
+		Category:      p.CategoryId,
+		Price:         json.Number(decimal(p.Price)),
+		Stock:         &stock,
+		ReorderLevel:  &reorderLevel,
+		PriceOverride: json.Number(decimal(v.PriceOverride)),
+		Attributes:    v.Attributes,
+		Currency:      p.Price.GetCurrency(),
+		WeightGrams:   &weight,
+	}
+}
+
+// decimal formats an amount in major units, e.g. 19.99, or returns "" if it
+// is unset.
+func decimal(m *pbmoney.Money) string {
+	if m == nil {
+		return ""
+	}
+	return money.New(m.Amount, m.Currency).Decimal()
+}
+
+// csvRecord renders a variant in the order of csvColumns.
+func csvRecord(p *inventory.Product, v *inventory.Variant) []string {
+	attrs := url.Values{}
+	for k, val := range v.Attributes {
+		attrs.Set(k, val)
+	}
+	return []string{
+		v.Sku,
+		p.Name,
+		p.Description,
+		p.CategoryId,
+		decimal(p.Price),
+		strconv.Itoa(int(v.Stock)),
+		strconv.Itoa(int(p.ReorderLevel)),
+		decimal(v.PriceOverride),
+		attrs.Encode(),
+		p.Price.GetCurrency(),
+		strconv.Itoa(int(v.WeightGrams)),
+	}
+}
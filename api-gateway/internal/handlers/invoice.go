@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"api-gateway/internal/pb/order"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GetInvoice downloads the PDF invoice of one of the user's paid orders.
+// Orders that are not paid answer 409.
+func (h *Handler) GetInvoice(c *gin.Context) {
+	resp, err := h.invoiceClient.GetInvoice(c.Request.Context(), &order.GetInvoiceRequest{
+		OrderId: c.Param("id"),
+		UserId:  fmt.Sprint(c.MustGet("user_id")),
+	})
+	if err != nil {
+		if status.Code(err) == codes.Unavailable {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Invoices are unavailable right now, try again later"})
+			return
+		}
+		productWriteError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, resp.Number))
+	c.Data(http.StatusOK, "application/pdf", resp.Pdf)
+}
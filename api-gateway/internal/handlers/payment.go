@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"api-gateway/internal/pb/payment"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Payment handlers. Paying an order takes two calls: POST
+// /orders/:id/payment creates the payment for its total, and POST
+// /payments/:id/confirm {"payment_method": "..."} starts charging it. The
+// payment is PROCESSING until the provider reports back; the order is then
+// COMPLETED or CANCELLED.
+
+// CreatePayment returns the payment of one of the user's pending orders,
+// creating it unless there is one that has not failed.
+func (h *Handler) CreatePayment(c *gin.Context) {
+	resp, err := h.paymentClient.CreatePaymentIntent(c.Request.Context(), &payment.CreatePaymentIntentRequest{
+		OrderId: c.Param("id"),
+		UserId:  fmt.Sprint(c.MustGet("user_id")),
+	})
+	if err != nil {
+		paymentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) GetOrderPayment(c *gin.Context) {
+	resp, err := h.paymentClient.GetOrderPayment(c.Request.Context(), &payment.GetOrderPaymentRequest{
+		OrderId: c.Param("id"),
+		UserId:  fmt.Sprint(c.MustGet("user_id")),
+	})
+	if err != nil {
+		paymentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) GetPayment(c *gin.Context) {
+	resp, err := h.paymentClient.GetPayment(c.Request.Context(), &payment.GetPaymentRequest{
+		Id:     c.Param("id"),
+		UserId: fmt.Sprint(c.MustGet("user_id")),
+	})
+	if err != nil {
+		paymentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) ConfirmPayment(c *gin.Context) {
+	var body struct {
+		PaymentMethod string `json:"payment_method" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.paymentClient.ConfirmPayment(c.Request.Context(), &payment.ConfirmPaymentRequest{
+		Id:            c.Param("id"),
+		UserId:        fmt.Sprint(c.MustGet("user_id")),
+		PaymentMethod: body.PaymentMethod,
+	})
+	if err != nil {
+		paymentError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, resp)
+}
+
+func paymentError(c *gin.Context, err error) {
+	if status.Code(err) == codes.Unavailable {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payments are unavailable right now, try again later"})
+		return
+	}
+	productWriteError(c, err)
+}
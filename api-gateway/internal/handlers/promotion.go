@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	pbmoney "api-gateway/internal/pb/money"
+	"api-gateway/internal/pb/order"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// promotionBody is a promotion as the REST API reads and writes it, with
+// RFC 3339 times in place of protobuf timestamps.
+type promotionBody struct {
+	ID             string         `json:"id,omitempty"`
+	Code           string         `json:"code,omitempty"`
+	Description    string         `json:"description" binding:"required"`
+	Type           string         `json:"type" binding:"required"`
+	PercentOff     int32          `json:"percent_off,omitempty"`
+	AmountOff      *pbmoney.Money `json:"amount_off,omitempty"`
+	ProductIDs     []string       `json:"product_ids,omitempty"`
+	CategoryIDs    []string       `json:"category_ids,omitempty"`
+	MinSubtotal    *pbmoney.Money `json:"min_subtotal,omitempty"`
+	StartsAt       *time.Time     `json:"starts_at,omitempty"`
+	EndsAt         *time.Time     `json:"ends_at,omitempty"`
+	MaxUses        int64          `json:"max_uses"`
+	MaxUsesPerUser int64          `json:"max_uses_per_user"`
+	Uses           int64          `json:"uses"`
+	Active         bool           `json:"active"`
+}
+
+func (b *promotionBody) toProto() *order.Promotion {
+	p := &order.Promotion{
+		Id:             b.ID,
+		Code:           b.Code,
+		Description:    b.Description,
+		Type:           b.Type,
+		PercentOff:     b.PercentOff,
+		AmountOff:      b.AmountOff,
+		ProductIds:     b.ProductIDs,
+		CategoryIds:    b.CategoryIDs,
+		MinSubtotal:    b.MinSubtotal,
+		MaxUses:        b.MaxUses,
+		MaxUsesPerUser: b.MaxUsesPerUser,
+		Active:         b.Active,
+	}
+	if b.StartsAt != nil {
+		p.StartsAt = timestamppb.New(*b.StartsAt)
+	}
+	if b.EndsAt != nil {
+		p.EndsAt = timestamppb.New(*b.EndsAt)
+	}
+	return p
+}
+
+func promotionFromProto(p *order.Promotion) promotionBody {
+	b := promotionBody{
+		ID:             p.Id,
+		Code:           p.Code,
+		Description:    p.Description,
+		Type:           p.Type,
+		PercentOff:     p.PercentOff,
+		AmountOff:      p.AmountOff,
+		ProductIDs:     p.ProductIds,
+		CategoryIDs:    p.CategoryIds,
+		MinSubtotal:    p.MinSubtotal,
+		MaxUses:        p.MaxUses,
+		MaxUsesPerUser: p.MaxUsesPerUser,
+		Uses:           p.Uses,
+		Active:         p.Active,
+	}
+	if p.StartsAt != nil {
+		t := p.StartsAt.AsTime()
+		b.StartsAt = &t
+	}
+	if p.EndsAt != nil {
+		t := p.EndsAt.AsTime()
+		b.EndsAt = &t
+	}
+	return b
+}
+
+// CreatePromotion adds a coupon, or with no code an automatic promotion.
+func (h *Handler) CreatePromotion(c *gin.Context) {
+	var body promotionBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.promotionClient.CreatePromotion(c.Request.Context(), &order.CreatePromotionRequest{Promotion: body.toProto()})
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, promotionFromProto(resp))
+}
+
+func (h *Handler) GetPromotion(c *gin.Context) {
+	resp, err := h.promotionClient.GetPromotion(c.Request.Context(), &order.GetPromotionRequest{Id: c.Param("id")})
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, promotionFromProto(resp))
+}
+
+// UpdatePromotion replaces a promotion; its usage count is kept.
+func (h *Handler) UpdatePromotion(c *gin.Context) {
+	var body promotionBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	body.ID = c.Param("id")
+	resp, err := h.promotionClient.UpdatePromotion(c.Request.Context(), &order.UpdatePromotionRequest{Promotion: body.toProto()})
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, promotionFromProto(resp))
+}
+
+// ListPromotions lists every promotion, or with ?current=true only those
+// that apply right now.
+func (h *Handler) ListPromotions(c *gin.Context) {
+	req := &order.ListPromotionsRequest{CurrentOnly: c.Query("current") == "true"}
+	resp, err := h.promotionClient.ListPromotions(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": status.Convert(err).Message()})
+		return
+	}
+	promotions := make([]promotionBody, 0, len(resp.Promotions))
+	for _, p := range resp.Promotions {
+		promotions = append(promotions, promotionFromProto(p))
+	}
+	c.JSON(http.StatusOK, promotions)
+}
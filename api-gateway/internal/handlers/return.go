@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"api-gateway/internal/pb/order"
+
+	"github.com/gin-gonic/gin"
+)
+
+// returnBody is what CreateReturn reads: the lines of the order to return,
+// by product and SKU, and how many of their units.
+type returnBody struct {
+	Items []struct {
+		ProductID string `json:"product_id" binding:"required"`
+		SKU       string `json:"sku"`
+		Quantity  int32  `json:"quantity" binding:"required,min=1"`
+	} `json:"items" binding:"required,min=1,dive"`
+	Reason string `json:"reason"`
+}
+
+// CreateReturn returns units of one of the user's completed orders. The
+// response is the return with its refund, which is REFUNDED once made
+// through the order's payment, or PENDING while it is retried.
+func (h *Handler) CreateReturn(c *gin.Context) {
+	var body returnBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := &order.CreateReturnRequest{
+		OrderId: c.Param("id"),
+		UserId:  fmt.Sprint(c.MustGet("user_id")),
+		Reason:  body.Reason,
+	}
+	for _, item := range body.Items {
+		req.Items = append(req.Items, &order.ReturnItem{
+			ProductId: item.ProductID,
+			Sku:       item.SKU,
+			Quantity:  item.Quantity,
+		})
+	}
+	resp, err := h.returnClient.CreateReturn(c.Request.Context(), req)
+	if err != nil {
+		productWriteError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, resp)
+}
@@ -1,26 +1,48 @@
 package middleware
 
 import (
+	"strconv"
+	"time"
+
+	"golang/pkg/telemetry"
+
 	"github.com/gin-gonic/gin"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 )
 
-// TelemetryMiddleware adds OpenTelemetry metrics
+// TelemetryMiddleware records request count and latency for every route.
+// Requests are labeled by the route template (e.g. /api/inventory/:id) rather
+// than the raw path so that IDs do not blow up metric cardinality.
 func TelemetryMiddleware() gin.HandlerFunc {
 	meter := otel.Meter("api-gateway")
 	requestCounter, _ := meter.Int64Counter(
-		"http_requests_total",
+		"http.server.requests",
 		metric.WithDescription("Total number of HTTP requests"),
+		metric.WithUnit("{request}"),
+	)
+	requestDuration, _ := meter.Float64Histogram(
+		"http.server.request.duration",
+		metric.WithDescription("Duration of HTTP requests"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(telemetry.DurationBuckets...),
 	)
 
 	return func(c *gin.Context) {
-		requestCounter.Add(c.Request.Context(), 1, metric.WithAttributes(
-			attribute.String("method", c.Request.Method),
-			attribute.String("path", c.Request.URL.Path),
-		))
-
+		start := time.Now()
 		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		attrs := metric.WithAttributes(
+			attribute.String("method", c.Request.Method),
+			attribute.String("route", route),
+			attribute.String("status", strconv.Itoa(c.Writer.Status())),
+		)
+		requestCounter.Add(c.Request.Context(), 1, attrs)
+		requestDuration.Record(c.Request.Context(), time.Since(start).Seconds(), attrs)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"api-gateway/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitKey derives the bucket key for a request. An empty key skips limiting.
+type RateLimitKey func(c *gin.Context) string
+
+// ByClientIP keys buckets on the caller's IP address.
+func ByClientIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser keys buckets on the authenticated user and must run after AuthMiddleware.
+func ByUser(c *gin.Context) string {
+	userID, ok := c.Get("user_id")
+	if !ok || userID == nil {
+		return ""
+	}
+	return fmt.Sprintf("user:%v", userID)
+}
+
+// RateLimitMiddleware takes a token from the named bucket for every request and
+// rejects the request with 429 once the bucket is empty. It sets the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers on every
+// response and Retry-After on rejections. If the limiter is unavailable the
+// request is let through so that a Redis outage does not take the API down.
+func RateLimitMiddleware(limiter ratelimit.Limiter, name string, limit ratelimit.Limit, key RateLimitKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+
+		res, err := limiter.Allow(c.Request.Context(), "ratelimit:"+name+":"+k, limit)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "rate limiter unavailable, allowing request", "limit", name, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.ResetAfter)))
+
+		if !res.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			slog.WarnContext(c.Request.Context(), "rate limit exceeded", "limit", name, "key", k)
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"golang/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDMiddleware accepts the caller's X-Request-ID or generates a new one,
+// echoes it in the response and stores it in the request context so that it
+// is attached to log lines and forwarded to the backends.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(logger.RequestIDHeader)
+		if !logger.ValidRequestID(id) {
+			id = logger.NewRequestID()
+		}
+
+		c.Header(logger.RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
@@ -0,0 +1,227 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v6.30.2
+// source: proto/invoice.proto
+
+package order
+
+import (
+	money "api-gateway/internal/pb/money"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetInvoiceRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	OrderId string                 `protobuf:"bytes,1,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	// When set, orders of other users are not found.
+	UserId        string `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetInvoiceRequest) Reset() {
+	*x = GetInvoiceRequest{}
+	mi := &file_proto_invoice_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetInvoiceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetInvoiceRequest) ProtoMessage() {}
+
+func (x *GetInvoiceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_invoice_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetInvoiceRequest.ProtoReflect.Descriptor instead.
+func (*GetInvoiceRequest) Descriptor() ([]byte, []int) {
+	return file_proto_invoice_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetInvoiceRequest) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *GetInvoiceRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+type Invoice struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Number   string                 `protobuf:"bytes,1,opt,name=number,proto3" json:"number,omitempty"`
+	OrderId  string                 `protobuf:"bytes,2,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	Total    *money.Money           `protobuf:"bytes,3,opt,name=total,proto3" json:"total,omitempty"`
+	IssuedAt *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=issued_at,json=issuedAt,proto3" json:"issued_at,omitempty"`
+	// The invoice as a PDF document.
+	Pdf           []byte `protobuf:"bytes,5,opt,name=pdf,proto3" json:"pdf,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Invoice) Reset() {
+	*x = Invoice{}
+	mi := &file_proto_invoice_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Invoice) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Invoice) ProtoMessage() {}
+
+func (x *Invoice) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_invoice_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Invoice.ProtoReflect.Descriptor instead.
+func (*Invoice) Descriptor() ([]byte, []int) {
+	return file_proto_invoice_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Invoice) GetNumber() string {
+	if x != nil {
+		return x.Number
+	}
+	return ""
+}
+
+func (x *Invoice) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *Invoice) GetTotal() *money.Money {
+	if x != nil {
+		return x.Total
+	}
+	return nil
+}
+
+func (x *Invoice) GetIssuedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.IssuedAt
+	}
+	return nil
+}
+
+func (x *Invoice) GetPdf() []byte {
+	if x != nil {
+		return x.Pdf
+	}
+	return nil
+}
+
+var File_proto_invoice_proto protoreflect.FileDescriptor
+
+const file_proto_invoice_proto_rawDesc = "" +
+	"\n" +
+	"\x13proto/invoice.proto\x12\x02pb\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x11proto/money.proto\"G\n" +
+	"\x11GetInvoiceRequest\x12\x19\n" +
+	"\border_id\x18\x01 \x01(\tR\aorderId\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\tR\x06userId\"\xa8\x01\n" +
+	"\aInvoice\x12\x16\n" +
+	"\x06number\x18\x01 \x01(\tR\x06number\x12\x19\n" +
+	"\border_id\x18\x02 \x01(\tR\aorderId\x12\x1f\n" +
+	"\x05total\x18\x03 \x01(\v2\t.pb.MoneyR\x05total\x127\n" +
+	"\tissued_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\bissuedAt\x12\x10\n" +
+	"\x03pdf\x18\x05 \x01(\fR\x03pdf2B\n" +
+	"\x0eInvoiceService\x120\n" +
+	"\n" +
+	"GetInvoice\x12\x15.pb.GetInvoiceRequest\x1a\v.pb.InvoiceB\x1fZ\x1dapi-gateway/internal/pb/orderb\x06proto3"
+
+var (
+	file_proto_invoice_proto_rawDescOnce sync.Once
+	file_proto_invoice_proto_rawDescData []byte
+)
+
+func file_proto_invoice_proto_rawDescGZIP() []byte {
+	file_proto_invoice_proto_rawDescOnce.Do(func() {
+		file_proto_invoice_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_invoice_proto_rawDesc), len(file_proto_invoice_proto_rawDesc)))
+	})
+	return file_proto_invoice_proto_rawDescData
+}
+
+var file_proto_invoice_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_proto_invoice_proto_goTypes = []any{
+	(*GetInvoiceRequest)(nil),     // 0: pb.GetInvoiceRequest
+	(*Invoice)(nil),               // 1: pb.Invoice
+	(*money.Money)(nil),           // 2: pb.Money
+	(*timestamppb.Timestamp)(nil), // 3: google.protobuf.Timestamp
+}
+var file_proto_invoice_proto_depIdxs = []int32{
+	2, // 0: pb.Invoice.total:type_name -> pb.Money
+	3, // 1: pb.Invoice.issued_at:type_name -> google.protobuf.Timestamp
+	0, // 2: pb.InvoiceService.GetInvoice:input_type -> pb.GetInvoiceRequest
+	1, // 3: pb.InvoiceService.GetInvoice:output_type -> pb.Invoice
+	3, // [3:4] is the sub-list for method output_type
+	2, // [2:3] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_proto_invoice_proto_init() }
+func file_proto_invoice_proto_init() {
+	if File_proto_invoice_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_invoice_proto_rawDesc), len(file_proto_invoice_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_invoice_proto_goTypes,
+		DependencyIndexes: file_proto_invoice_proto_depIdxs,
+		MessageInfos:      file_proto_invoice_proto_msgTypes,
+	}.Build()
+	File_proto_invoice_proto = out.File
+	file_proto_invoice_proto_goTypes = nil
+	file_proto_invoice_proto_depIdxs = nil
+}
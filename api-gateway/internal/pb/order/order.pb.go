@@ -7,8 +7,11 @@
 package order
 
 import (
+	address "api-gateway/internal/pb/address"
+	money "api-gateway/internal/pb/money"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -22,11 +25,17 @@ const (
 )
 
 type OrderItem struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	ProductId     string                 `protobuf:"bytes,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
-	Quantity      int32                  `protobuf:"varint,2,opt,name=quantity,proto3" json:"quantity,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	ProductId string                 `protobuf:"bytes,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	Quantity  int32                  `protobuf:"varint,2,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	// Variant ordered; may be empty for products with a single variant.
+	Sku string `protobuf:"bytes,3,opt,name=sku,proto3" json:"sku,omitempty"`
+	// Price of one unit when the order was placed; set by the service.
+	UnitPrice *money.Money `protobuf:"bytes,4,opt,name=unit_price,json=unitPrice,proto3" json:"unit_price,omitempty"`
+	// Units returned so far; set by the service.
+	ReturnedQuantity int32 `protobuf:"varint,5,opt,name=returned_quantity,json=returnedQuantity,proto3" json:"returned_quantity,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
 func (x *OrderItem) Reset() {
@@ -73,13 +82,46 @@ func (x *OrderItem) GetQuantity() int32 {
 	return 0
 }
 
+func (x *OrderItem) GetSku() string {
+	if x != nil {
+		return x.Sku
+	}
+	return ""
+}
+
+func (x *OrderItem) GetUnitPrice() *money.Money {
+	if x != nil {
+		return x.UnitPrice
+	}
+	return nil
+}
+
+func (x *OrderItem) GetReturnedQuantity() int32 {
+	if x != nil {
+		return x.ReturnedQuantity
+	}
+	return 0
+}
+
 type CreateOrderRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	Items         []*OrderItem           `protobuf:"bytes,2,rep,name=items,proto3" json:"items,omitempty"`
-	Total         float64                `protobuf:"fixed64,3,opt,name=total,proto3" json:"total,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Items  []*OrderItem           `protobuf:"bytes,2,rep,name=items,proto3" json:"items,omitempty"`
+	// The total the client expects to pay. The service computes the total from
+	// the current prices; when this is set and differs, the order is rejected.
+	Total *money.Money `protobuf:"bytes,3,opt,name=total,proto3" json:"total,omitempty"`
+	// Coupon code to apply, if any.
+	PromoCode string `protobuf:"bytes,4,opt,name=promo_code,json=promoCode,proto3" json:"promo_code,omitempty"`
+	// ISO 3166 region the order ships to, e.g. US or US-CA, which sets its tax
+	// and shipping; empty for the store's default region, or the region of the
+	// shipping address if there is one.
+	Region string `protobuf:"bytes,5,opt,name=region,proto3" json:"region,omitempty"`
+	// Where the order ships: an address from the user's address book, or one
+	// given inline. At most one may be set.
+	AddressId       string           `protobuf:"bytes,6,opt,name=address_id,json=addressId,proto3" json:"address_id,omitempty"`
+	ShippingAddress *address.Address `protobuf:"bytes,7,opt,name=shipping_address,json=shippingAddress,proto3" json:"shipping_address,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
 }
 
 func (x *CreateOrderRequest) Reset() {
@@ -126,11 +168,39 @@ func (x *CreateOrderRequest) GetItems() []*OrderItem {
 	return nil
 }
 
-func (x *CreateOrderRequest) GetTotal() float64 {
+func (x *CreateOrderRequest) GetTotal() *money.Money {
 	if x != nil {
 		return x.Total
 	}
-	return 0
+	return nil
+}
+
+func (x *CreateOrderRequest) GetPromoCode() string {
+	if x != nil {
+		return x.PromoCode
+	}
+	return ""
+}
+
+func (x *CreateOrderRequest) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+func (x *CreateOrderRequest) GetAddressId() string {
+	if x != nil {
+		return x.AddressId
+	}
+	return ""
+}
+
+func (x *CreateOrderRequest) GetShippingAddress() *address.Address {
+	if x != nil {
+		return x.ShippingAddress
+	}
+	return nil
 }
 
 type CreateOrderResponse struct {
@@ -230,12 +300,34 @@ func (x *GetOrderRequest) GetId() string {
 }
 
 type GetOrderResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	UserId        string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
-	Items         []*OrderItem           `protobuf:"bytes,3,rep,name=items,proto3" json:"items,omitempty"`
-	Total         float64                `protobuf:"fixed64,4,opt,name=total,proto3" json:"total,omitempty"`
-	Status        string                 `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Id     string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserId string                 `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Items  []*OrderItem           `protobuf:"bytes,3,rep,name=items,proto3" json:"items,omitempty"`
+	// subtotal plus the adjustments, shipping and tax.
+	Total  *money.Money `protobuf:"bytes,4,opt,name=total,proto3" json:"total,omitempty"`
+	Status string       `protobuf:"bytes,5,opt,name=status,proto3" json:"status,omitempty"`
+	// Sum of the items at their unit prices.
+	Subtotal *money.Money `protobuf:"bytes,6,opt,name=subtotal,proto3" json:"subtotal,omitempty"`
+	// Discounts applied to the order.
+	Adjustments []*Adjustment `protobuf:"bytes,7,rep,name=adjustments,proto3" json:"adjustments,omitempty"`
+	PromoCode   string        `protobuf:"bytes,8,opt,name=promo_code,json=promoCode,proto3" json:"promo_code,omitempty"`
+	// Tax on the items after discounts.
+	Tax      *money.Money `protobuf:"bytes,9,opt,name=tax,proto3" json:"tax,omitempty"`
+	Shipping *money.Money `protobuf:"bytes,10,opt,name=shipping,proto3" json:"shipping,omitempty"`
+	Region   string       `protobuf:"bytes,11,opt,name=region,proto3" json:"region,omitempty"`
+	// Copy of the address the order ships to, as it was when the order was
+	// placed; later changes to the address book do not alter it.
+	ShippingAddress *address.Address `protobuf:"bytes,12,opt,name=shipping_address,json=shippingAddress,proto3" json:"shipping_address,omitempty"`
+	// The address book entry shipping_address was copied from, if any.
+	AddressId string `protobuf:"bytes,13,opt,name=address_id,json=addressId,proto3" json:"address_id,omitempty"`
+	// The payment-service payment that settled the order, once it is paid or
+	// its payment has failed.
+	PaymentId string `protobuf:"bytes,14,opt,name=payment_id,json=paymentId,proto3" json:"payment_id,omitempty"`
+	// Returns of the order's items, oldest first.
+	Returns []*Return `protobuf:"bytes,15,rep,name=returns,proto3" json:"returns,omitempty"`
+	// The number of the order's invoice, once it is paid and invoiced.
+	InvoiceNumber string `protobuf:"bytes,16,opt,name=invoice_number,json=invoiceNumber,proto3" json:"invoice_number,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -291,11 +383,11 @@ func (x *GetOrderResponse) GetItems() []*OrderItem {
 	return nil
 }
 
-func (x *GetOrderResponse) GetTotal() float64 {
+func (x *GetOrderResponse) GetTotal() *money.Money {
 	if x != nil {
 		return x.Total
 	}
-	return 0
+	return nil
 }
 
 func (x *GetOrderResponse) GetStatus() string {
@@ -305,17 +397,342 @@ func (x *GetOrderResponse) GetStatus() string {
 	return ""
 }
 
+func (x *GetOrderResponse) GetSubtotal() *money.Money {
+	if x != nil {
+		return x.Subtotal
+	}
+	return nil
+}
+
+func (x *GetOrderResponse) GetAdjustments() []*Adjustment {
+	if x != nil {
+		return x.Adjustments
+	}
+	return nil
+}
+
+func (x *GetOrderResponse) GetPromoCode() string {
+	if x != nil {
+		return x.PromoCode
+	}
+	return ""
+}
+
+func (x *GetOrderResponse) GetTax() *money.Money {
+	if x != nil {
+		return x.Tax
+	}
+	return nil
+}
+
+func (x *GetOrderResponse) GetShipping() *money.Money {
+	if x != nil {
+		return x.Shipping
+	}
+	return nil
+}
+
+func (x *GetOrderResponse) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+func (x *GetOrderResponse) GetShippingAddress() *address.Address {
+	if x != nil {
+		return x.ShippingAddress
+	}
+	return nil
+}
+
+func (x *GetOrderResponse) GetAddressId() string {
+	if x != nil {
+		return x.AddressId
+	}
+	return ""
+}
+
+func (x *GetOrderResponse) GetPaymentId() string {
+	if x != nil {
+		return x.PaymentId
+	}
+	return ""
+}
+
+func (x *GetOrderResponse) GetReturns() []*Return {
+	if x != nil {
+		return x.Returns
+	}
+	return nil
+}
+
+func (x *GetOrderResponse) GetInvoiceNumber() string {
+	if x != nil {
+		return x.InvoiceNumber
+	}
+	return ""
+}
+
+// Return gives back some units of a completed order's items, and refunds
+// them.
+type Return struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Items []*ReturnItem          `protobuf:"bytes,2,rep,name=items,proto3" json:"items,omitempty"`
+	// Sum of the items' refunds.
+	Refund *money.Money `protobuf:"bytes,3,opt,name=refund,proto3" json:"refund,omitempty"`
+	Reason string       `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	// PENDING until the refund is made through the order's payment, then
+	// REFUNDED, or FAILED if the payment refused it.
+	RefundStatus  string                 `protobuf:"bytes,5,opt,name=refund_status,json=refundStatus,proto3" json:"refund_status,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Return) Reset() {
+	*x = Return{}
+	mi := &file_proto_order_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Return) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Return) ProtoMessage() {}
+
+func (x *Return) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_order_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Return.ProtoReflect.Descriptor instead.
+func (*Return) Descriptor() ([]byte, []int) {
+	return file_proto_order_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Return) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Return) GetItems() []*ReturnItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *Return) GetRefund() *money.Money {
+	if x != nil {
+		return x.Refund
+	}
+	return nil
+}
+
+func (x *Return) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Return) GetRefundStatus() string {
+	if x != nil {
+		return x.RefundStatus
+	}
+	return ""
+}
+
+func (x *Return) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+type ReturnItem struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	ProductId string                 `protobuf:"bytes,1,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	Sku       string                 `protobuf:"bytes,2,opt,name=sku,proto3" json:"sku,omitempty"`
+	Quantity  int32                  `protobuf:"varint,3,opt,name=quantity,proto3" json:"quantity,omitempty"`
+	// What the units cost after discounts, with their tax; set by the
+	// service. Shipping is not refunded.
+	Refund        *money.Money `protobuf:"bytes,4,opt,name=refund,proto3" json:"refund,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReturnItem) Reset() {
+	*x = ReturnItem{}
+	mi := &file_proto_order_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReturnItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReturnItem) ProtoMessage() {}
+
+func (x *ReturnItem) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_order_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReturnItem.ProtoReflect.Descriptor instead.
+func (*ReturnItem) Descriptor() ([]byte, []int) {
+	return file_proto_order_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ReturnItem) GetProductId() string {
+	if x != nil {
+		return x.ProductId
+	}
+	return ""
+}
+
+func (x *ReturnItem) GetSku() string {
+	if x != nil {
+		return x.Sku
+	}
+	return ""
+}
+
+func (x *ReturnItem) GetQuantity() int32 {
+	if x != nil {
+		return x.Quantity
+	}
+	return 0
+}
+
+func (x *ReturnItem) GetRefund() *money.Money {
+	if x != nil {
+		return x.Refund
+	}
+	return nil
+}
+
+// Adjustment is a discount applied to an order, by one promotion either to one
+// item or to the whole order.
+type Adjustment struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	PromotionId string                 `protobuf:"bytes,1,opt,name=promotion_id,json=promotionId,proto3" json:"promotion_id,omitempty"`
+	Code        string                 `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Description string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	// The item discounted; empty for discounts on the whole order.
+	ProductId string `protobuf:"bytes,4,opt,name=product_id,json=productId,proto3" json:"product_id,omitempty"`
+	Sku       string `protobuf:"bytes,5,opt,name=sku,proto3" json:"sku,omitempty"`
+	// Negative for discounts.
+	Amount        *money.Money `protobuf:"bytes,6,opt,name=amount,proto3" json:"amount,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Adjustment) Reset() {
+	*x = Adjustment{}
+	mi := &file_proto_order_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Adjustment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Adjustment) ProtoMessage() {}
+
+func (x *Adjustment) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_order_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Adjustment.ProtoReflect.Descriptor instead.
+func (*Adjustment) Descriptor() ([]byte, []int) {
+	return file_proto_order_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Adjustment) GetPromotionId() string {
+	if x != nil {
+		return x.PromotionId
+	}
+	return ""
+}
+
+func (x *Adjustment) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Adjustment) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Adjustment) GetProductId() string {
+	if x != nil {
+		return x.ProductId
+	}
+	return ""
+}
+
+func (x *Adjustment) GetSku() string {
+	if x != nil {
+		return x.Sku
+	}
+	return ""
+}
+
+func (x *Adjustment) GetAmount() *money.Money {
+	if x != nil {
+		return x.Amount
+	}
+	return nil
+}
+
 type UpdateOrderStatusRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	UserId        string                 `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"` // set when a customer cancels; the order must be theirs
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *UpdateOrderStatusRequest) Reset() {
 	*x = UpdateOrderStatusRequest{}
-	mi := &file_proto_order_proto_msgTypes[5]
+	mi := &file_proto_order_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -327,7 +744,7 @@ func (x *UpdateOrderStatusRequest) String() string {
 func (*UpdateOrderStatusRequest) ProtoMessage() {}
 
 func (x *UpdateOrderStatusRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_order_proto_msgTypes[5]
+	mi := &file_proto_order_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -340,7 +757,7 @@ func (x *UpdateOrderStatusRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateOrderStatusRequest.ProtoReflect.Descriptor instead.
 func (*UpdateOrderStatusRequest) Descriptor() ([]byte, []int) {
-	return file_proto_order_proto_rawDescGZIP(), []int{5}
+	return file_proto_order_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *UpdateOrderStatusRequest) GetId() string {
@@ -357,6 +774,13 @@ func (x *UpdateOrderStatusRequest) GetStatus() string {
 	return ""
 }
 
+func (x *UpdateOrderStatusRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
 type UpdateOrderStatusResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -367,7 +791,7 @@ type UpdateOrderStatusResponse struct {
 
 func (x *UpdateOrderStatusResponse) Reset() {
 	*x = UpdateOrderStatusResponse{}
-	mi := &file_proto_order_proto_msgTypes[6]
+	mi := &file_proto_order_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -379,7 +803,7 @@ func (x *UpdateOrderStatusResponse) String() string {
 func (*UpdateOrderStatusResponse) ProtoMessage() {}
 
 func (x *UpdateOrderStatusResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_order_proto_msgTypes[6]
+	mi := &file_proto_order_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -392,7 +816,7 @@ func (x *UpdateOrderStatusResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use UpdateOrderStatusResponse.ProtoReflect.Descriptor instead.
 func (*UpdateOrderStatusResponse) Descriptor() ([]byte, []int) {
-	return file_proto_order_proto_rawDescGZIP(), []int{6}
+	return file_proto_order_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *UpdateOrderStatusResponse) GetId() string {
@@ -418,7 +842,7 @@ type ListUserOrdersRequest struct {
 
 func (x *ListUserOrdersRequest) Reset() {
 	*x = ListUserOrdersRequest{}
-	mi := &file_proto_order_proto_msgTypes[7]
+	mi := &file_proto_order_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -430,7 +854,7 @@ func (x *ListUserOrdersRequest) String() string {
 func (*ListUserOrdersRequest) ProtoMessage() {}
 
 func (x *ListUserOrdersRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_order_proto_msgTypes[7]
+	mi := &file_proto_order_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -443,7 +867,7 @@ func (x *ListUserOrdersRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListUserOrdersRequest.ProtoReflect.Descriptor instead.
 func (*ListUserOrdersRequest) Descriptor() ([]byte, []int) {
-	return file_proto_order_proto_rawDescGZIP(), []int{7}
+	return file_proto_order_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *ListUserOrdersRequest) GetUserId() string {
@@ -462,7 +886,7 @@ type ListUserOrdersResponse struct {
 
 func (x *ListUserOrdersResponse) Reset() {
 	*x = ListUserOrdersResponse{}
-	mi := &file_proto_order_proto_msgTypes[8]
+	mi := &file_proto_order_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -474,7 +898,7 @@ func (x *ListUserOrdersResponse) String() string {
 func (*ListUserOrdersResponse) ProtoMessage() {}
 
 func (x *ListUserOrdersResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_order_proto_msgTypes[8]
+	mi := &file_proto_order_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -487,7 +911,7 @@ func (x *ListUserOrdersResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use ListUserOrdersResponse.ProtoReflect.Descriptor instead.
 func (*ListUserOrdersResponse) Descriptor() ([]byte, []int) {
-	return file_proto_order_proto_rawDescGZIP(), []int{8}
+	return file_proto_order_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *ListUserOrdersResponse) GetOrders() []*GetOrderResponse {
@@ -501,29 +925,80 @@ var File_proto_order_proto protoreflect.FileDescriptor
 
 const file_proto_order_proto_rawDesc = "" +
 	"\n" +
-	"\x11proto/order.proto\x12\x02pb\"F\n" +
+	"\x11proto/order.proto\x12\x02pb\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x13proto/address.proto\x1a\x11proto/money.proto\"\xaf\x01\n" +
 	"\tOrderItem\x12\x1d\n" +
 	"\n" +
 	"product_id\x18\x01 \x01(\tR\tproductId\x12\x1a\n" +
-	"\bquantity\x18\x02 \x01(\x05R\bquantity\"h\n" +
+	"\bquantity\x18\x02 \x01(\x05R\bquantity\x12\x10\n" +
+	"\x03sku\x18\x03 \x01(\tR\x03sku\x12(\n" +
+	"\n" +
+	"unit_price\x18\x04 \x01(\v2\t.pb.MoneyR\tunitPrice\x12+\n" +
+	"\x11returned_quantity\x18\x05 \x01(\x05R\x10returnedQuantity\"\x81\x02\n" +
 	"\x12CreateOrderRequest\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\tR\x06userId\x12#\n" +
-	"\x05items\x18\x02 \x03(\v2\r.pb.OrderItemR\x05items\x12\x14\n" +
-	"\x05total\x18\x03 \x01(\x01R\x05total\"?\n" +
+	"\x05items\x18\x02 \x03(\v2\r.pb.OrderItemR\x05items\x12\x1f\n" +
+	"\x05total\x18\x03 \x01(\v2\t.pb.MoneyR\x05total\x12\x1d\n" +
+	"\n" +
+	"promo_code\x18\x04 \x01(\tR\tpromoCode\x12\x16\n" +
+	"\x06region\x18\x05 \x01(\tR\x06region\x12\x1d\n" +
+	"\n" +
+	"address_id\x18\x06 \x01(\tR\taddressId\x126\n" +
+	"\x10shipping_address\x18\a \x01(\v2\v.pb.AddressR\x0fshippingAddress\"?\n" +
 	"\x13CreateOrderResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\"!\n" +
 	"\x0fGetOrderRequest\x12\x0e\n" +
-	"\x02id\x18\x01 \x01(\tR\x02id\"\x8e\x01\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xb0\x04\n" +
 	"\x10GetOrderResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x17\n" +
 	"\auser_id\x18\x02 \x01(\tR\x06userId\x12#\n" +
-	"\x05items\x18\x03 \x03(\v2\r.pb.OrderItemR\x05items\x12\x14\n" +
-	"\x05total\x18\x04 \x01(\x01R\x05total\x12\x16\n" +
-	"\x06status\x18\x05 \x01(\tR\x06status\"B\n" +
+	"\x05items\x18\x03 \x03(\v2\r.pb.OrderItemR\x05items\x12\x1f\n" +
+	"\x05total\x18\x04 \x01(\v2\t.pb.MoneyR\x05total\x12\x16\n" +
+	"\x06status\x18\x05 \x01(\tR\x06status\x12%\n" +
+	"\bsubtotal\x18\x06 \x01(\v2\t.pb.MoneyR\bsubtotal\x120\n" +
+	"\vadjustments\x18\a \x03(\v2\x0e.pb.AdjustmentR\vadjustments\x12\x1d\n" +
+	"\n" +
+	"promo_code\x18\b \x01(\tR\tpromoCode\x12\x1b\n" +
+	"\x03tax\x18\t \x01(\v2\t.pb.MoneyR\x03tax\x12%\n" +
+	"\bshipping\x18\n" +
+	" \x01(\v2\t.pb.MoneyR\bshipping\x12\x16\n" +
+	"\x06region\x18\v \x01(\tR\x06region\x126\n" +
+	"\x10shipping_address\x18\f \x01(\v2\v.pb.AddressR\x0fshippingAddress\x12\x1d\n" +
+	"\n" +
+	"address_id\x18\r \x01(\tR\taddressId\x12\x1d\n" +
+	"\n" +
+	"payment_id\x18\x0e \x01(\tR\tpaymentId\x12$\n" +
+	"\areturns\x18\x0f \x03(\v2\n" +
+	".pb.ReturnR\areturns\x12%\n" +
+	"\x0einvoice_number\x18\x10 \x01(\tR\rinvoiceNumber\"\xd9\x01\n" +
+	"\x06Return\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12$\n" +
+	"\x05items\x18\x02 \x03(\v2\x0e.pb.ReturnItemR\x05items\x12!\n" +
+	"\x06refund\x18\x03 \x01(\v2\t.pb.MoneyR\x06refund\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason\x12#\n" +
+	"\rrefund_status\x18\x05 \x01(\tR\frefundStatus\x129\n" +
+	"\n" +
+	"created_at\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"|\n" +
+	"\n" +
+	"ReturnItem\x12\x1d\n" +
+	"\n" +
+	"product_id\x18\x01 \x01(\tR\tproductId\x12\x10\n" +
+	"\x03sku\x18\x02 \x01(\tR\x03sku\x12\x1a\n" +
+	"\bquantity\x18\x03 \x01(\x05R\bquantity\x12!\n" +
+	"\x06refund\x18\x04 \x01(\v2\t.pb.MoneyR\x06refund\"\xb9\x01\n" +
+	"\n" +
+	"Adjustment\x12!\n" +
+	"\fpromotion_id\x18\x01 \x01(\tR\vpromotionId\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x1d\n" +
+	"\n" +
+	"product_id\x18\x04 \x01(\tR\tproductId\x12\x10\n" +
+	"\x03sku\x18\x05 \x01(\tR\x03sku\x12!\n" +
+	"\x06amount\x18\x06 \x01(\v2\t.pb.MoneyR\x06amount\"[\n" +
 	"\x18UpdateOrderStatusRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
-	"\x06status\x18\x02 \x01(\tR\x06status\"E\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\tR\x06userId\"E\n" +
 	"\x19UpdateOrderStatusResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\"0\n" +
@@ -535,7 +1010,7 @@ const file_proto_order_proto_rawDesc = "" +
 	"\vCreateOrder\x12\x16.pb.CreateOrderRequest\x1a\x17.pb.CreateOrderResponse\x125\n" +
 	"\bGetOrder\x12\x13.pb.GetOrderRequest\x1a\x14.pb.GetOrderResponse\x12P\n" +
 	"\x11UpdateOrderStatus\x12\x1c.pb.UpdateOrderStatusRequest\x1a\x1d.pb.UpdateOrderStatusResponse\x12G\n" +
-	"\x0eListUserOrders\x12\x19.pb.ListUserOrdersRequest\x1a\x1a.pb.ListUserOrdersResponseB\x13Z\x11internal/pb/orderb\x06proto3"
+	"\x0eListUserOrders\x12\x19.pb.ListUserOrdersRequest\x1a\x1a.pb.ListUserOrdersResponseB\x1fZ\x1dapi-gateway/internal/pb/orderb\x06proto3"
 
 var (
 	file_proto_order_proto_rawDescOnce sync.Once
@@ -549,35 +1024,56 @@ func file_proto_order_proto_rawDescGZIP() []byte {
 	return file_proto_order_proto_rawDescData
 }
 
-var file_proto_order_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_proto_order_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_proto_order_proto_goTypes = []any{
 	(*OrderItem)(nil),                 // 0: pb.OrderItem
 	(*CreateOrderRequest)(nil),        // 1: pb.CreateOrderRequest
 	(*CreateOrderResponse)(nil),       // 2: pb.CreateOrderResponse
 	(*GetOrderRequest)(nil),           // 3: pb.GetOrderRequest
 	(*GetOrderResponse)(nil),          // 4: pb.GetOrderResponse
-	(*UpdateOrderStatusRequest)(nil),  // 5: pb.UpdateOrderStatusRequest
-	(*UpdateOrderStatusResponse)(nil), // 6: pb.UpdateOrderStatusResponse
-	(*ListUserOrdersRequest)(nil),     // 7: pb.ListUserOrdersRequest
-	(*ListUserOrdersResponse)(nil),    // 8: pb.ListUserOrdersResponse
+	(*Return)(nil),                    // 5: pb.Return
+	(*ReturnItem)(nil),                // 6: pb.ReturnItem
+	(*Adjustment)(nil),                // 7: pb.Adjustment
+	(*UpdateOrderStatusRequest)(nil),  // 8: pb.UpdateOrderStatusRequest
+	(*UpdateOrderStatusResponse)(nil), // 9: pb.UpdateOrderStatusResponse
+	(*ListUserOrdersRequest)(nil),     // 10: pb.ListUserOrdersRequest
+	(*ListUserOrdersResponse)(nil),    // 11: pb.ListUserOrdersResponse
+	(*money.Money)(nil),               // 12: pb.Money
+	(*address.Address)(nil),           // 13: pb.Address
+	(*timestamppb.Timestamp)(nil),     // 14: google.protobuf.Timestamp
 }
 var file_proto_order_proto_depIdxs = []int32{
-	0, // 0: pb.CreateOrderRequest.items:type_name -> pb.OrderItem
-	0, // 1: pb.GetOrderResponse.items:type_name -> pb.OrderItem
-	4, // 2: pb.ListUserOrdersResponse.orders:type_name -> pb.GetOrderResponse
-	1, // 3: pb.OrderService.CreateOrder:input_type -> pb.CreateOrderRequest
-	3, // 4: pb.OrderService.GetOrder:input_type -> pb.GetOrderRequest
-	5, // 5: pb.OrderService.UpdateOrderStatus:input_type -> pb.UpdateOrderStatusRequest
-	7, // 6: pb.OrderService.ListUserOrders:input_type -> pb.ListUserOrdersRequest
-	2, // 7: pb.OrderService.CreateOrder:output_type -> pb.CreateOrderResponse
-	4, // 8: pb.OrderService.GetOrder:output_type -> pb.GetOrderResponse
-	6, // 9: pb.OrderService.UpdateOrderStatus:output_type -> pb.UpdateOrderStatusResponse
-	8, // 10: pb.OrderService.ListUserOrders:output_type -> pb.ListUserOrdersResponse
-	7, // [7:11] is the sub-list for method output_type
-	3, // [3:7] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	12, // 0: pb.OrderItem.unit_price:type_name -> pb.Money
+	0,  // 1: pb.CreateOrderRequest.items:type_name -> pb.OrderItem
+	12, // 2: pb.CreateOrderRequest.total:type_name -> pb.Money
+	13, // 3: pb.CreateOrderRequest.shipping_address:type_name -> pb.Address
+	0,  // 4: pb.GetOrderResponse.items:type_name -> pb.OrderItem
+	12, // 5: pb.GetOrderResponse.total:type_name -> pb.Money
+	12, // 6: pb.GetOrderResponse.subtotal:type_name -> pb.Money
+	7,  // 7: pb.GetOrderResponse.adjustments:type_name -> pb.Adjustment
+	12, // 8: pb.GetOrderResponse.tax:type_name -> pb.Money
+	12, // 9: pb.GetOrderResponse.shipping:type_name -> pb.Money
+	13, // 10: pb.GetOrderResponse.shipping_address:type_name -> pb.Address
+	5,  // 11: pb.GetOrderResponse.returns:type_name -> pb.Return
+	6,  // 12: pb.Return.items:type_name -> pb.ReturnItem
+	12, // 13: pb.Return.refund:type_name -> pb.Money
+	14, // 14: pb.Return.created_at:type_name -> google.protobuf.Timestamp
+	12, // 15: pb.ReturnItem.refund:type_name -> pb.Money
+	12, // 16: pb.Adjustment.amount:type_name -> pb.Money
+	4,  // 17: pb.ListUserOrdersResponse.orders:type_name -> pb.GetOrderResponse
+	1,  // 18: pb.OrderService.CreateOrder:input_type -> pb.CreateOrderRequest
+	3,  // 19: pb.OrderService.GetOrder:input_type -> pb.GetOrderRequest
+	8,  // 20: pb.OrderService.UpdateOrderStatus:input_type -> pb.UpdateOrderStatusRequest
+	10, // 21: pb.OrderService.ListUserOrders:input_type -> pb.ListUserOrdersRequest
+	2,  // 22: pb.OrderService.CreateOrder:output_type -> pb.CreateOrderResponse
+	4,  // 23: pb.OrderService.GetOrder:output_type -> pb.GetOrderResponse
+	9,  // 24: pb.OrderService.UpdateOrderStatus:output_type -> pb.UpdateOrderStatusResponse
+	11, // 25: pb.OrderService.ListUserOrders:output_type -> pb.ListUserOrdersResponse
+	22, // [22:26] is the sub-list for method output_type
+	18, // [18:22] is the sub-list for method input_type
+	18, // [18:18] is the sub-list for extension type_name
+	18, // [18:18] is the sub-list for extension extendee
+	0,  // [0:18] is the sub-list for field type_name
 }
 
 func init() { file_proto_order_proto_init() }
@@ -591,7 +1087,7 @@ func file_proto_order_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_order_proto_rawDesc), len(file_proto_order_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   9,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -4,12 +4,26 @@ import (
 	"api-gateway/config"
 	handler "api-gateway/internal/handlers"
 	"api-gateway/internal/middleware"
+	"api-gateway/internal/ratelimit"
 	"fmt"
+	"net/http"
 
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// Rate limits are token buckets shared by all gateway replicas through Redis.
+// The unauthenticated auth routes are kept tight to make password guessing
+// expensive; everything else gets a generous per-IP and per-user budget.
+var (
+	globalIPLimit     = ratelimit.Limit{Requests: 300, Period: time.Minute}
+	authenticateLimit = ratelimit.Limit{Requests: 5, Period: time.Minute}
+	registerLimit     = ratelimit.Limit{Requests: 10, Period: time.Hour}
+	userLimit         = ratelimit.Limit{Requests: 120, Period: time.Minute}
 )
 
 // Server encapsulates the Gin router
@@ -18,16 +32,22 @@ type Server struct {
 	cfg    *config.Config
 }
 
-// NewServer initializes the server with routes and middleware
-func NewServer(cfg *config.Config) *Server {
+// NewServer initializes the server with routes and middleware.
+// metricsHandler is exposed unauthenticated on /metrics for Prometheus.
+func NewServer(cfg *config.Config, metricsHandler http.Handler) *Server {
 	r := gin.New()
+	// The client IP keys the per-IP rate limits, so X-Forwarded-For is only
+	// believed when set by a configured proxy.
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("Invalid trusted proxies: %v", err))
+	}
 
 	// ✅ Custom CORS config that allows Authorization headers and local frontend
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://127.0.0.1:5500"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Authorization", "Content-Type", "X-Request-ID", "Idempotency-Key", "X-Cart-ID"},
+		ExposeHeaders:    []string{"Content-Length", "X-Request-ID", "X-Cart-ID", "Idempotent-Replayed", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -39,35 +59,110 @@ func NewServer(cfg *config.Config) *Server {
 	}
 
 	// Apply global middleware
+	r.Use(otelgin.Middleware("api-gateway"))
+	r.Use(middleware.RequestIDMiddleware())
 	r.Use(middleware.LoggingMiddleware())
 	r.Use(middleware.TelemetryMiddleware())
 
+	r.GET("/metrics", gin.WrapH(metricsHandler))
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+	})
+	limiter := ratelimit.NewRedisLimiter(rdb)
+
 	// Define routes
 	api := r.Group("/api")
+	api.Use(middleware.RateLimitMiddleware(limiter, "global", globalIPLimit, middleware.ByClientIP))
 
 	// Unprotected routes (no authentication required)
-	api.POST("/users/register", h.RegisterUser)
-	api.POST("/users/authenticate", h.AuthenticateUser)
+	api.POST("/users/register",
+		middleware.RateLimitMiddleware(limiter, "register", registerLimit, middleware.ByClientIP),
+		h.RegisterUser)
+	api.POST("/users/authenticate",
+		middleware.RateLimitMiddleware(limiter, "authenticate", authenticateLimit, middleware.ByClientIP),
+		h.AuthenticateUser)
+
+	// Cart routes, for guests and logged-in users alike
+	cart := api.Group("/cart", middleware.OptionalAuthMiddleware(cfg))
+	cart.GET("", h.GetCart)
+	cart.POST("/items", h.AddCartItem)
+	cart.PUT("/items/:product_id", h.UpdateCartItem)
+	cart.DELETE("/items/:product_id", h.RemoveCartItem)
 
 	// Protected routes (require authentication)
 	protected := api.Group("")
 	protected.Use(middleware.AuthMiddleware(cfg))
+	protected.Use(middleware.RateLimitMiddleware(limiter, "user", userLimit, middleware.ByUser))
 	{
 		// Inventory routes
-		protected.POST("/inventory", h.CreateProduct)
 		protected.GET("/inventory/:id", h.GetProduct)
-		protected.PUT("/inventory/:id", h.UpdateProduct)
-		protected.DELETE("/inventory/:id", h.DeleteProduct)
 		protected.GET("/inventory", h.ListProducts)
 
+		// Category routes
+		protected.GET("/categories", h.ListCategories)
+		protected.GET("/categories/:id", h.GetCategory)
+
+		// Product changes, staff only
+		staff := protected.Group("", middleware.RequireRole("staff"))
+		staff.POST("/inventory", h.CreateProduct)
+		staff.PUT("/inventory/:id", h.UpdateProduct)
+		staff.PATCH("/inventory/:id", h.PatchProduct)
+		staff.DELETE("/inventory/:id", h.DeleteProduct)
+		staff.POST("/inventory/:id/restore", h.RestoreProduct)
+		staff.PUT("/inventory/:id/variants/:sku", h.UpsertVariant)
+		staff.POST("/inventory/:id/images", h.AddProductImage)
+		staff.DELETE("/inventory/:id/images/:image_id", h.DeleteProductImage)
+
+		// Stock ledger, staff only
+		staff.POST("/inventory/:id/stock-adjustments", h.AdjustStock)
+		staff.GET("/inventory/:id/stock-movements", h.GetStockMovements)
+		staff.GET("/inventory/low-stock", h.ListLowStock)
+
+		// Bulk import and export, staff only
+		staff.POST("/inventory/import", h.ImportProducts)
+		staff.GET("/inventory/export", h.ExportProducts)
+
+		// Category tree, staff only
+		staff.POST("/categories", h.CreateCategory)
+		staff.PUT("/categories/:id", h.UpdateCategory)
+		staff.DELETE("/categories/:id", h.DeleteCategory)
+
+		// Promotions, staff only
+		staff.POST("/promotions", h.CreatePromotion)
+		staff.GET("/promotions", h.ListPromotions)
+		staff.GET("/promotions/:id", h.GetPromotion)
+		staff.PUT("/promotions/:id", h.UpdatePromotion)
+
+		// Order status, staff only; customers cancel their own orders
+		staff.PUT("/orders/:id/status", h.UpdateOrderStatus)
+
 		// Order routes
 		protected.POST("/orders", h.CreateOrder)
 		protected.GET("/orders/:id", h.GetOrder)
-		protected.PUT("/orders/:id/status", h.UpdateOrderStatus)
+		protected.POST("/orders/:id/cancel", h.CancelOrder)
 		protected.GET("/orders", h.ListUserOrders)
+		protected.POST("/cart/checkout", h.Checkout)
+		protected.POST("/orders/:id/returns", h.CreateReturn)
+		protected.GET("/orders/:id/invoice", h.GetInvoice)
+
+		// Payments of the logged-in user's orders
+		protected.POST("/orders/:id/payment", h.CreatePayment)
+		protected.GET("/orders/:id/payment", h.GetOrderPayment)
+		protected.GET("/payments/:id", h.GetPayment)
+		protected.POST("/payments/:id/confirm", h.ConfirmPayment)
 
 		// User routes
 		protected.GET("/users/:id", h.GetUserProfile)
+
+		// Address book of the logged-in user
+		protected.GET("/addresses", h.ListAddresses)
+		protected.POST("/addresses", h.CreateAddress)
+		protected.GET("/addresses/:id", h.GetAddress)
+		protected.PUT("/addresses/:id", h.UpdateAddress)
+		protected.DELETE("/addresses/:id", h.DeleteAddress)
+		protected.POST("/addresses/:id/default", h.SetDefaultAddress)
 	}
 
 	return &Server{
@@ -76,6 +171,11 @@ func NewServer(cfg *config.Config) *Server {
 	}
 }
 
+// ServeHTTP serves a request through the router, e.g. from a test.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.router.ServeHTTP(w, req)
+}
+
 // Start runs the server
 func (s *Server) Start() error {
 	addr := fmt.Sprintf(":%s", s.cfg.Port)
@@ -0,0 +1,365 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"api-gateway/config"
+	"api-gateway/internal/pb/inventory"
+	"api-gateway/internal/pb/order"
+	"api-gateway/internal/pb/user"
+	"api-gateway/internal/server"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// newServer builds the gateway against a fresh Redis and backends that are
+// not running.
+func newServer(t *testing.T, trustedProxies ...string) *server.Server {
+	return newGateway(t, &config.Config{TrustedProxies: trustedProxies})
+}
+
+// newGateway builds the gateway of cfg against a fresh Redis. Backends cfg
+// leaves out are not running.
+func newGateway(t *testing.T, cfg *config.Config) *server.Server {
+	for _, addr := range []*string{&cfg.InventoryService, &cfg.OrderService, &cfg.UserService, &cfg.PaymentService} {
+		if *addr == "" {
+			*addr = "127.0.0.1:1"
+		}
+	}
+	cfg.JWTSecret = "secret"
+	cfg.RedisAddr = miniredis.RunT(t).Addr()
+	return server.NewServer(cfg, http.NotFoundHandler())
+}
+
+// serveGRPC runs a gRPC server with the services register adds and returns
+// its address.
+func serveGRPC(t *testing.T, register func(*grpc.Server), opts ...grpc.ServerOption) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(opts...)
+	register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+// signToken returns a token of userID with role, as user-service issues
+// them.
+func signToken(t *testing.T, userID, role string) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":  userID,
+		"role": role,
+		"exp":  time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// bearer returns the Authorization header of a user signed in with role.
+func bearer(t *testing.T, userID, role string) string {
+	return "Bearer " + signToken(t, userID, role)
+}
+
+// fakeOrders answers CreateOrder with err, or else with an order, reporting
+// it replayed if replayed is set. It records the Idempotency-Keys received.
+type fakeOrders struct {
+	order.UnimplementedOrderServiceServer
+	err      error
+	replayed bool
+	keys     []string
+}
+
+func (f *fakeOrders) CreateOrder(ctx context.Context, req *order.CreateOrderRequest) (*order.CreateOrderResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.keys = append(f.keys, md.Get("idempotency-key")...)
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.replayed {
+		_ = grpc.SetHeader(ctx, metadata.Pairs("idempotent-replayed", "true"))
+	}
+	return &order.CreateOrderResponse{Id: "order1", Message: "Order created"}, nil
+}
+
+// fakeCarts records the owners of the carts changed and merged.
+type fakeCarts struct {
+	order.UnimplementedCartServiceServer
+	owners []*order.CartOwner
+	merged []*order.MergeCartRequest
+}
+
+func (f *fakeCarts) AddCartItem(ctx context.Context, req *order.AddCartItemRequest) (*order.Cart, error) {
+	f.owners = append(f.owners, req.Owner)
+	return &order.Cart{Owner: req.Owner}, nil
+}
+
+func (f *fakeCarts) MergeCart(ctx context.Context, req *order.MergeCartRequest) (*order.Cart, error) {
+	f.merged = append(f.merged, req)
+	return &order.Cart{Owner: &order.CartOwner{UserId: req.UserId}}, nil
+}
+
+// fakeUsers lets everyone log in as user1.
+type fakeUsers struct {
+	user.UnimplementedUserServiceServer
+	token string
+}
+
+func (f *fakeUsers) AuthenticateUser(ctx context.Context, req *user.AuthRequest) (*user.AuthResponse, error) {
+	return &user.AuthResponse{Token: f.token, Message: "Authenticated"}, nil
+}
+
+// addCartItem adds a unit of p1 to the cart of the guest cartID, or of the
+// user signed in with authorization.
+func addCartItem(srv *server.Server, cartID, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/cart/items", strings.NewReader(`{"product_id":"p1","quantity":1}`))
+	req.Header.Set("Content-Type", "application/json")
+	if cartID != "" {
+		req.Header.Set("X-Cart-ID", cartID)
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+// fakeInventory records the sizes of the images uploaded.
+type fakeInventory struct {
+	inventory.UnimplementedInventoryServiceServer
+	sizes []int
+}
+
+func (f *fakeInventory) AddProductImage(ctx context.Context, req *inventory.AddProductImageRequest) (*inventory.ProductResponse, error) {
+	f.sizes = append(f.sizes, len(req.Data))
+	return &inventory.ProductResponse{Product: &inventory.Product{Id: req.ProductId}}, nil
+}
+
+// uploadImage uploads size bytes declared as contentType as an image of p1.
+func uploadImage(t *testing.T, srv *server.Server, size int, contentType string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="image"; filename="image"`},
+		"Content-Type":        {contentType},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(make([]byte, size))
+	mw.WriteField("alt", "A mug")
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/inventory/p1/images", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", bearer(t, "staff1", "staff"))
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+// createOrder posts an order with the Idempotency-Key key, unless it is
+// empty.
+func createOrder(t *testing.T, srv *server.Server, key string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/orders", strings.NewReader(`{"items":[{"product_id":"p1","quantity":1}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", bearer(t, "user1", "customer"))
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+// authenticate sends a login attempt claiming to come from forwardedFor.
+func authenticate(srv *server.Server, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/users/authenticate", strings.NewReader(`{"email":"a@example.com","password":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-For", forwardedFor)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit_IgnoresForwardedForFromUntrustedClients(t *testing.T) {
+	srv := newServer(t)
+
+	// A new X-Forwarded-For per attempt does not get a new bucket.
+	for i := range 5 {
+		assert.NotEqual(t, http.StatusTooManyRequests, authenticate(srv, fmt.Sprintf("203.0.113.%d", i)).Code)
+	}
+	w := authenticate(srv, "203.0.113.99")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestRateLimit_KeysOnForwardedForFromTrustedProxies(t *testing.T) {
+	// httptest requests come from 192.0.2.1.
+	srv := newServer(t, "192.0.2.1")
+
+	for i := range 5 {
+		assert.NotEqual(t, http.StatusTooManyRequests, authenticate(srv, "203.0.113.1").Code, i)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, authenticate(srv, "203.0.113.1").Code)
+	assert.NotEqual(t, http.StatusTooManyRequests, authenticate(srv, "203.0.113.2").Code, "another client behind the proxy")
+}
+
+func TestRateLimit_Headers(t *testing.T) {
+	srv := newServer(t)
+
+	// The tightest bucket a request passes through is the one reported.
+	w := authenticate(srv, "")
+	assert.Equal(t, "5", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "4", w.Header().Get("RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("RateLimit-Reset"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	for range 4 {
+		authenticate(srv, "")
+	}
+	w = authenticate(srv, "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.Positive(t, retryAfter)
+	assert.LessOrEqual(t, retryAfter, 60)
+}
+
+func TestCreateOrder_ForwardsIdempotencyKey(t *testing.T) {
+	orders := &fakeOrders{}
+	srv := newGateway(t, &config.Config{
+		OrderService: serveGRPC(t, func(s *grpc.Server) { order.RegisterOrderServiceServer(s, orders) }),
+	})
+
+	w := createOrder(t, srv, "key-1")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+
+	orders.replayed = true
+	w = createOrder(t, srv, "key-1")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+
+	// Orders without a key are created as before, and keys too long for the
+	// store are refused here.
+	assert.Equal(t, http.StatusCreated, createOrder(t, srv, "").Code)
+	assert.Equal(t, http.StatusBadRequest, createOrder(t, srv, strings.Repeat("k", 256)).Code)
+	assert.Equal(t, []string{"key-1", "key-1"}, orders.keys)
+}
+
+func TestCreateOrder_IdempotencyErrors(t *testing.T) {
+	orders := &fakeOrders{}
+	srv := newGateway(t, &config.Config{
+		OrderService: serveGRPC(t, func(s *grpc.Server) { order.RegisterOrderServiceServer(s, orders) }),
+	})
+
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{status.Error(codes.FailedPrecondition, "idempotency key reused with a different request"), http.StatusUnprocessableEntity},
+		{status.Error(codes.Aborted, "request with this idempotency key is in progress"), http.StatusConflict},
+		{status.Error(codes.InvalidArgument, "quantity must be positive"), http.StatusBadRequest},
+		{status.Error(codes.Unavailable, "inventory unavailable"), http.StatusServiceUnavailable},
+		{status.Error(codes.Internal, "boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		orders.err = tt.err
+		assert.Equal(t, tt.want, createOrder(t, srv, "key-1").Code, status.Code(tt.err).String())
+	}
+}
+
+func TestCart_GuestCartIDs(t *testing.T) {
+	carts := &fakeCarts{}
+	srv := newGateway(t, &config.Config{
+		OrderService: serveGRPC(t, func(s *grpc.Server) { order.RegisterCartServiceServer(s, carts) }),
+	})
+
+	// Reading a cart that does not exist makes none up.
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/cart", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-Cart-ID"))
+
+	// A guest's first change gets a new cart ID, which later changes name.
+	w = addCartItem(srv, "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	cartID := w.Header().Get("X-Cart-ID")
+	assert.Len(t, cartID, 32)
+	w = addCartItem(srv, cartID, "")
+	assert.Equal(t, cartID, w.Header().Get("X-Cart-ID"))
+
+	// A signed-in user's cart is theirs, whatever X-Cart-ID says.
+	w = addCartItem(srv, cartID, bearer(t, "user1", "customer"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-Cart-ID"))
+
+	if assert.Len(t, carts.owners, 3) {
+		assert.Equal(t, cartID, carts.owners[0].GuestId)
+		assert.Equal(t, cartID, carts.owners[1].GuestId)
+		assert.Equal(t, "user1", carts.owners[2].UserId)
+		assert.Empty(t, carts.owners[2].GuestId)
+	}
+}
+
+func TestCart_MergedOnLogin(t *testing.T) {
+	carts := &fakeCarts{}
+	users := &fakeUsers{token: signToken(t, "user1", "customer")}
+	srv := newGateway(t, &config.Config{
+		OrderService: serveGRPC(t, func(s *grpc.Server) { order.RegisterCartServiceServer(s, carts) }),
+		UserService:  serveGRPC(t, func(s *grpc.Server) { user.RegisterUserServiceServer(s, users) }),
+	})
+
+	// Logging in without a guest cart merges nothing.
+	assert.Equal(t, http.StatusOK, authenticate(srv, "").Code)
+	assert.Empty(t, carts.merged)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/users/authenticate", strings.NewReader(`{"username":"ann","password":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Cart-ID", "guest1")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	if assert.Len(t, carts.merged, 1) {
+		assert.Equal(t, "guest1", carts.merged[0].GuestId)
+		assert.Equal(t, "user1", carts.merged[0].UserId)
+	}
+}
+
+func TestAddProductImage_SizeLimit(t *testing.T) {
+	images := &fakeInventory{}
+	srv := newGateway(t, &config.Config{
+		InventoryService: serveGRPC(t, func(s *grpc.Server) { inventory.RegisterInventoryServiceServer(s, images) },
+			grpc.MaxRecvMsgSize(6<<20)),
+	})
+
+	assert.Equal(t, http.StatusCreated, uploadImage(t, srv, 5<<20, "image/png").Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, uploadImage(t, srv, 5<<20+1, "image/png").Code)
+	// Bodies past the limit are cut off rather than read.
+	assert.Equal(t, http.StatusRequestEntityTooLarge, uploadImage(t, srv, 8<<20, "image/png").Code)
+	assert.Equal(t, http.StatusUnsupportedMediaType, uploadImage(t, srv, 1<<10, "image/svg+xml").Code)
+	assert.Equal(t, []int{5 << 20}, images.sizes)
+}
@@ -1,28 +1,65 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
 
-	"golang/email-service/internal/email"
+	"golang/email-service/config"
+	queue "golang/email-service/internal/events"
 
-	"github.com/joho/godotenv"
+	"golang/pkg/blob"
+
+	"github.com/nats-io/nats.go"
 )
 
 func main() {
-	err := godotenv.Load()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, nil)).With("service", "email-service"))
+
+	cfg := config.Load()
+
+	nc, err := nats.Connect(cfg.NATSURL)
 	if err != nil {
-		log.Println("No .env file found, using system env variables")
+		slog.Error("NATS connection failed", "error", err)
+		os.Exit(1)
 	}
+	defer nc.Drain()
 
-	to := "dilara.galimkizi@gmail.com"
-	subject := "from Dilyara"
-	body := "Hello from Go Microservice! Dear Aruzhan Ali, Thank you for being the kindest and most wonderful teacher. Your support, patience, and encouragement meant so much. I truly appreciate all your hard work and dedication. Wishing you all the best! Sincerely, SE-2310"
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	err = email.SendEmail(to, subject, body)
+	consumer, err := queue.NewInvoiceConsumer(nc, newInvoiceStore(cfg))
 	if err != nil {
-		log.Fatalf("Error sending email: %v", err)
+		slog.Error("JetStream init failed", "error", err)
+		os.Exit(1)
 	}
+	if err := consumer.Subscribe(ctx); err != nil {
+		slog.Error("failed to subscribe", "subject", queue.OrderInvoiced, "error", err)
+		os.Exit(1)
+	}
+	slog.Info("email-service started", "subject", queue.OrderInvoiced)
 
-	fmt.Println("Email sent successfully!")
+	<-ctx.Done()
+	slog.Info("shutting down email-service")
+}
+
+// newInvoiceStore opens the blob store order-service keeps invoices in.
+func newInvoiceStore(cfg *config.Config) blob.BlobStore {
+	if cfg.InvoiceStore == "s3" {
+		return blob.NewS3Store(blob.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+		})
+	}
+	store, err := blob.NewFileStore(cfg.InvoiceDir, "")
+	if err != nil {
+		slog.Error("invoice store init failed", "dir", cfg.InvoiceDir, "error", err)
+		os.Exit(1)
+	}
+	return store
 }
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	NATSURL      string
+	InvoiceStore string // where order-service keeps invoice PDFs: local or s3
+	InvoiceDir   string // local: directory of the invoice store
+	S3           S3Config
+}
+
+// S3Config addresses the bucket invoices are read from when InvoiceStore is
+// s3. It must match order-service's.
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+func Load() *Config {
+	if err := godotenv.Load(); err != nil {
+		slog.Info("no .env file found, using system env variables")
+	}
+
+	invoiceStore := getEnvWithDefault("INVOICE_STORE", "local")
+	if invoiceStore != "local" && invoiceStore != "s3" {
+		slog.Error("invalid INVOICE_STORE, want local or s3", "value", invoiceStore)
+		os.Exit(1)
+	}
+
+	return &Config{
+		NATSURL:      getEnvWithDefault("NATS_URL", "nats://localhost:4222"),
+		InvoiceStore: invoiceStore,
+		InvoiceDir:   getEnvWithDefault("INVOICE_DIR", "./invoices"),
+		S3: S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    getEnvWithDefault("S3_REGION", "us-east-1"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		},
+	}
+}
+
+func getEnvWithDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -3,7 +3,22 @@ module golang/email-service
 go 1.24.3
 
 require (
-	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/joho/godotenv v1.5.1
+	github.com/nats-io/nats.go v1.42.0
+	golang/pkg/blob v0.0.0-00010101000000-000000000000
+	golang/pkg/money v0.0.0-00010101000000-000000000000
+	gopkg.in/gomail.v2 v2.0.0-20160411212932-81ebce5c23df
+)
+
+require (
+	github.com/klauspost/compress v1.18.0 // indirect
+	github.com/nats-io/nkeys v0.4.11 // indirect
+	github.com/nats-io/nuid v1.0.1 // indirect
+	golang.org/x/crypto v0.37.0 // indirect
+	golang.org/x/sys v0.32.0 // indirect
 	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
-	gopkg.in/gomail.v2 v2.0.0-20160411212932-81ebce5c23df // indirect
 )
+
+replace golang/pkg/blob => ../pkg/blob
+
+replace golang/pkg/money => ../pkg/money
@@ -2,13 +2,20 @@ package email
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 
 	"gopkg.in/gomail.v2"
 )
 
-func SendEmail(to, subject, body string) error {
+// Attachment is a file sent along with an email.
+type Attachment struct {
+	Name string
+	Data []byte
+}
+
+func SendEmail(to, subject, body string, attachments ...Attachment) error {
 	username := os.Getenv("SMTP_USERNAME")
 	password := os.Getenv("SMTP_PASSWORD")
 	host := os.Getenv("SMTP_HOST")
@@ -24,6 +31,13 @@ func SendEmail(to, subject, body string) error {
 	m.SetHeader("To", to)
 	m.SetHeader("Subject", subject)
 	m.SetBody("text/plain", body)
+	for _, a := range attachments {
+		data := a.Data
+		m.Attach(a.Name, gomail.SetCopyFunc(func(w io.Writer) error {
+			_, err := w.Write(data)
+			return err
+		}))
+	}
 
 	d := gomail.NewDialer(host, port, username, password)
 
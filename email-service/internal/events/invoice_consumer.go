@@ -0,0 +1,151 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"golang/email-service/internal/email"
+
+	"golang/pkg/blob"
+	"golang/pkg/money"
+
+	"github.com/nats-io/nats.go"
+)
+
+// OrderInvoiced is the subject order-service announces issued invoices on.
+const OrderInvoiced = "order.invoiced"
+
+// InvoiceSent is the subject email-service confirms on that it emailed an
+// invoice, so order-service records it as sent.
+const InvoiceSent = "invoice.sent"
+
+// Streams keeping the events until their consumers have handled them. Each
+// is created by whichever service starts first.
+const (
+	OrdersStream   = "ORDERS"
+	InvoicesStream = "INVOICES"
+)
+
+// invoicedDurable is the durable consumer of order.invoiced, shared by the
+// replicas of email-service.
+const invoicedDurable = "email-service-order-invoiced"
+
+// retryDelay is how long an invoice that could not be emailed waits before
+// it is delivered again.
+const retryDelay = 30 * time.Second
+
+// OrderInvoicedMessage is the body of order.invoiced events.
+type OrderInvoicedMessage struct {
+	OrderID       string      `json:"order_id"`
+	UserID        string      `json:"user_id"`
+	Email         string      `json:"email"`
+	InvoiceNumber string      `json:"invoice_number"`
+	InvoiceKey    string      `json:"invoice_key"`
+	Total         money.Money `json:"total"`
+}
+
+// InvoiceSentMessage is the body of invoice.sent events.
+type InvoiceSentMessage struct {
+	OrderID       string `json:"order_id"`
+	InvoiceNumber string `json:"invoice_number"`
+}
+
+// InvoiceConsumer sends customers the confirmation of their paid orders,
+// with the invoice attached.
+type InvoiceConsumer struct {
+	js    nats.JetStreamContext
+	blobs blob.BlobStore
+}
+
+// NewInvoiceConsumer creates the consumer and the orders and invoices
+// streams, unless they exist.
+func NewInvoiceConsumer(conn *nats.Conn, blobs blob.BlobStore) (*InvoiceConsumer, error) {
+	js, err := conn.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	streams := []*nats.StreamConfig{
+		{Name: OrdersStream, Subjects: []string{"order.>"}, Storage: nats.FileStorage},
+		{Name: InvoicesStream, Subjects: []string{"invoice.>"}, Storage: nats.FileStorage},
+	}
+	for _, cfg := range streams {
+		if _, err := js.AddStream(cfg); err != nil && !errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+			return nil, err
+		}
+	}
+	return &InvoiceConsumer{js: js, blobs: blobs}, nil
+}
+
+// Subscribe listens for issued invoices through a durable consumer, so
+// invoices issued while email-service is down are emailed when it is back.
+// Replicas share a queue group, and events are acknowledged only once the
+// invoice is emailed and that is confirmed on invoice.sent.
+func (c *InvoiceConsumer) Subscribe(ctx context.Context) error {
+	_, err := c.js.QueueSubscribe(OrderInvoiced, invoicedDurable, func(msg *nats.Msg) {
+		if c.handle(ctx, msg) {
+			_ = msg.Ack()
+		} else {
+			_ = msg.NakWithDelay(retryDelay)
+		}
+	}, nats.BindStream(OrdersStream), nats.Durable(invoicedDurable), nats.ManualAck())
+	return err
+}
+
+// handle emails an invoice and reports whether it is done with it.
+func (c *InvoiceConsumer) handle(ctx context.Context, msg *nats.Msg) bool {
+	var inv OrderInvoicedMessage
+	if err := json.Unmarshal(msg.Data, &inv); err != nil {
+		slog.ErrorContext(ctx, "failed to parse message", "subject", OrderInvoiced, "error", err)
+		return true
+	}
+	slog.InfoContext(ctx, "message received", "subject", OrderInvoiced, "order_id", inv.OrderID, "invoice_number", inv.InvoiceNumber)
+
+	if inv.Email == "" {
+		// Nothing to deliver; the invoice stays unsent.
+		slog.WarnContext(ctx, "user has no email, confirmation not sent", "order_id", inv.OrderID, "user_id", inv.UserID)
+		return true
+	}
+	pdf, err := c.blobs.Get(ctx, inv.InvoiceKey)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read invoice", "order_id", inv.OrderID, "key", inv.InvoiceKey, "error", err)
+		return false
+	}
+
+	subject := fmt.Sprintf("Order confirmation: invoice %s", inv.InvoiceNumber)
+	body := fmt.Sprintf("Thank you for your order %s.\n\nWe have received your payment of %s. Your invoice %s is attached.\n",
+		inv.OrderID, inv.Total, inv.InvoiceNumber)
+	err = email.SendEmail(inv.Email, subject, body, email.Attachment{Name: inv.InvoiceNumber + ".pdf", Data: pdf})
+	if err != nil {
+		slog.ErrorContext(ctx, "error sending email", "order_id", inv.OrderID, "error", err)
+		return false
+	}
+	slog.InfoContext(ctx, "email sent", "order_id", inv.OrderID, "invoice_number", inv.InvoiceNumber)
+
+	// Without the confirmation the invoice is never recorded as sent, so
+	// the event is delivered again, even though the email may go out twice.
+	if err := c.confirm(msg, inv); err != nil {
+		slog.ErrorContext(ctx, "failed to confirm invoice sent", "order_id", inv.OrderID, "invoice_number", inv.InvoiceNumber, "error", err)
+		return false
+	}
+	return true
+}
+
+// confirm publishes invoice.sent for inv, passing on the headers of the
+// order.invoiced event it answers, such as its request ID.
+func (c *InvoiceConsumer) confirm(in *nats.Msg, inv OrderInvoicedMessage) error {
+	data, err := json.Marshal(InvoiceSentMessage{OrderID: inv.OrderID, InvoiceNumber: inv.InvoiceNumber})
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(InvoiceSent)
+	msg.Data = data
+	for key, values := range in.Header {
+		msg.Header[key] = values
+	}
+	_, err = c.js.PublishMsg(msg, nats.AckWait(20*time.Second))
+	return err
+}
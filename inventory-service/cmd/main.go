@@ -1,19 +1,29 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"net"
+	"net/http"
+	"time"
 
 	"inventory-service/config"
 	"inventory-service/internal/db/migration"
 	queue "inventory-service/internal/events"
 	"inventory-service/internal/handler"
+	"inventory-service/internal/media"
 	"inventory-service/internal/pb"
-	"inventory-service/internal/redis"
 	"inventory-service/internal/repository"
 	"inventory-service/internal/usecase"
 
+	"golang/pkg/blob"
+	"golang/pkg/cache"
+	"golang/pkg/logger"
+	"golang/pkg/telemetry"
+
 	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 )
 
@@ -21,45 +31,145 @@ func main() {
 	cfg := config.Load()
 	defer cfg.Client.Disconnect(cfg.Ctx)
 
-	redis.InitRedis()
+	logger.Init("inventory-service", cfg.LogLevel, cfg.LogFormat)
+
+	metricsHandler, shutdownMetrics, err := telemetry.InitMetrics("inventory-service")
+	if err != nil {
+		logger.Fatal("metrics init failed", "error", err)
+	}
+	defer shutdownMetrics(cfg.Ctx)
+	telemetry.ServeMetrics(cfg.MetricsPort, metricsHandler)
+
+	shutdownTracing, err := telemetry.InitTracing(cfg.Ctx, "inventory-service", cfg.TracesExporter, cfg.OTLPEndpoint)
+	if err != nil {
+		logger.Fatal("tracing init failed", "error", err)
+	}
+	defer shutdownTracing(cfg.Ctx)
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+	})
+	defer rdb.Close()
+	if err := redisotel.InstrumentTracing(rdb); err != nil {
+		logger.Fatal("redis tracing instrumentation failed", "error", err)
+	}
+	if err := rdb.Ping(cfg.Ctx).Err(); err != nil {
+		logger.Fatal("redis connection failed", "addr", cfg.RedisAddr, "error", err)
+	}
+	slog.Info("redis connection established", "addr", cfg.RedisAddr)
 
 	dbInstance := cfg.Client.Database(cfg.MongoDBName)
 
 	// Выполнение миграции вручную
-	if err := migration.MigrateUp(dbInstance); err != nil {
-		log.Fatalf("Migration up failed: %v", err)
+	if err := migration.MigrateUp(dbInstance, cfg.Currency); err != nil {
+		logger.Fatal("migration up failed", "error", err)
 	}
-	log.Println("✅ Mongo migrations applied successfully")
+	slog.Info("mongo migrations applied")
 
 	coll := dbInstance.Collection("products")
-	repo := repository.NewMongoProductRepository(coll)
-	uc := usecase.NewProductUsecase(repo)
-	h := handler.NewProductHandler(uc)
-
+	repo := repository.NewMongoProductRepository(coll, dbInstance.Collection("stock_movements"))
+	categoryRepo := repository.NewMongoCategoryRepository(dbInstance.Collection("categories"))
+	// Product listings are invalidated on every replica through a version
+	// counter announced over Redis pub/sub.
+	listVersion := cache.NewRedisVersion(rdb, "products")
+	go func() {
+		if err := listVersion.Subscribe(cfg.Ctx); err != nil {
+			slog.Error("product list version subscription stopped", "error", err)
+		}
+	}()
 	natsConn, err := nats.Connect("nats://localhost:4222")
 	if err != nil {
-		log.Fatalf("❌ NATS connection failed: %v", err)
+		logger.Fatal("nats connection failed", "error", err)
 	}
 	defer natsConn.Close()
 
-	consumer := queue.NewConsumer(natsConn, "order.created", uc)
+	blobs := newBlobStore(cfg)
+	uc := usecase.NewProductUsecase(repo, categoryRepo, cache.NewRedis(rdb), listVersion, queue.NewNATSPublisher(natsConn), blobs, cfg.Currency)
+	h := handler.NewProductHandler(uc, usecase.NewCategoryUsecase(categoryRepo, repo, listVersion))
+
+	// Permanently remove products soft-deleted longer than the retention.
 	go func() {
-		if err := consumer.Subscribe(cfg.Ctx); err != nil {
-			log.Fatalf("❌ Failed to subscribe to order.created: %v", err)
+		ticker := time.NewTicker(cfg.PurgeInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := uc.PurgeDeleted(cfg.Ctx, cfg.PurgeRetention)
+			if err != nil {
+				slog.Error("purging deleted products failed", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.Info("purged deleted products", "count", n, "retention", cfg.PurgeRetention.String())
+			}
 		}
-		log.Println("📥 NATS subscription active on 'order.created'")
 	}()
 
+	// Orders take stock when placed and give it back when cancelled
+	consumer, err := queue.NewConsumer(natsConn, uc)
+	if err != nil {
+		logger.Fatal("failed to create order consumer", "error", err)
+	}
+	if err := consumer.Subscribe(cfg.Ctx); err != nil {
+		logger.Fatal("failed to subscribe to order events", "error", err)
+	}
+	slog.Info("nats subscription active", "subjects", []string{queue.OrderCreated, queue.OrderCancelled})
+
+	// Returned units go back in stock
+	returnConsumer, err := queue.NewReturnConsumer(natsConn, uc)
+	if err != nil {
+		logger.Fatal("failed to create return consumer", "error", err)
+	}
+	if err := returnConsumer.Subscribe(cfg.Ctx); err != nil {
+		logger.Fatal("failed to subscribe", "subject", queue.OrderReturned, "error", err)
+	}
+	slog.Info("nats subscription active", "subject", queue.OrderReturned)
+
 	lis, err := net.Listen("tcp", ":"+cfg.Port)
 	if err != nil {
-		log.Fatalf("listen error: %v", err)
+		logger.Fatal("listen failed", "error", err)
 	}
 
-	srv := grpc.NewServer()
+	srv := grpc.NewServer(
+		// Room for the largest image upload plus the rest of the request.
+		grpc.MaxRecvMsgSize(media.MaxImageSize+1<<20),
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			logger.UnaryServerInterceptor(),
+			telemetry.UnaryServerInterceptor("inventory-service"),
+		),
+	)
 	pb.RegisterInventoryServiceServer(srv, h)
 
-	log.Printf("🔆 InventoryService on port %s\n", cfg.Port)
+	slog.Info("inventory service listening", "port", cfg.Port)
 	if err := srv.Serve(lis); err != nil {
-		log.Fatalf("serve error: %v", err)
+		logger.Fatal("serve failed", "error", err)
+	}
+}
+
+// newBlobStore returns the store for product images. Local images are served
+// over HTTP on cfg.MediaPort.
+func newBlobStore(cfg *config.Config) blob.BlobStore {
+	if cfg.MediaStore == "s3" {
+		return blob.NewS3Store(blob.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			PublicURL: cfg.S3.PublicURL,
+		})
+	}
+
+	store, err := blob.NewFileStore(cfg.MediaDir, cfg.MediaBaseURL)
+	if err != nil {
+		logger.Fatal("media store init failed", "dir", cfg.MediaDir, "error", err)
 	}
+	mux := http.NewServeMux()
+	mux.Handle("/media/", http.StripPrefix("/media", store.Handler()))
+	go func() {
+		if err := http.ListenAndServe(":"+cfg.MediaPort, mux); err != nil && err != http.ErrServerClosed {
+			slog.Error("media server failed", "error", err)
+		}
+	}()
+	return store
 }
@@ -2,12 +2,15 @@ package config
 
 import (
     "context"
-    "log"
+    "log/slog"
     "os"
     "time"
 
+    "golang/pkg/money"
+
     "go.mongodb.org/mongo-driver/mongo"
     "go.mongodb.org/mongo-driver/mongo/options"
+    "go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
 type Config struct {
@@ -18,6 +21,36 @@ type Config struct {
     Client      *mongo.Client
     RedisAddr    string // Redis адресі
     RedisPassword string // Redis паролі
+    MetricsPort  string // Prometheus /metrics порты
+    TracesExporter string // otlp | stdout | none
+    OTLPEndpoint   string
+    LogLevel       string // debug | info | warn | error
+    LogFormat      string // json | text
+    PurgeRetention time.Duration // how long soft-deleted products are kept
+    PurgeInterval  time.Duration // how often the purge job runs
+    MediaStore     string // local | s3
+    MediaDir       string // local: where images are stored
+    MediaPort      string // local: port images are served on under /media
+    MediaBaseURL   string // local: public address of /media
+    S3             S3Config
+    Currency       string // ISO 4217 code all prices are in
+}
+
+// S3Config addresses the bucket images are stored in when MediaStore is s3.
+type S3Config struct {
+    Endpoint  string
+    Region    string
+    Bucket    string
+    AccessKey string
+    SecretKey string
+    PublicURL string // e.g. a CDN; defaults to Endpoint/Bucket
+}
+
+func getenv(key, def string) string {
+    if v := os.Getenv(key); v != "" {
+        return v
+    }
+    return def
 }
 
 func Load() *Config {
@@ -43,13 +76,69 @@ func Load() *Config {
         redisPassword = "" // Redis әдепкі пароль жоқ
     }
 
+    metricsPort := os.Getenv("METRICS_PORT")
+    if metricsPort == "" {
+        metricsPort = "9093"
+    }
+
+    tracesExporter := os.Getenv("TRACES_EXPORTER")
+    if tracesExporter == "" {
+        tracesExporter = "none"
+    }
+    otlpEndpoint := os.Getenv("OTLP_ENDPOINT")
+    if otlpEndpoint == "" {
+        otlpEndpoint = "localhost:4317"
+    }
+
+    logLevel := os.Getenv("LOG_LEVEL")
+    if logLevel == "" {
+        logLevel = "info"
+    }
+    logFormat := os.Getenv("LOG_FORMAT")
+    if logFormat == "" {
+        logFormat = "json"
+    }
+
+    purgeRetention := 30 * 24 * time.Hour
+    if v := os.Getenv("PURGE_RETENTION"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil {
+            slog.Error("invalid PURGE_RETENTION", "value", v, "error", err)
+            os.Exit(1)
+        }
+        purgeRetention = d
+    }
+    purgeInterval := time.Hour
+    if v := os.Getenv("PURGE_INTERVAL"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil {
+            slog.Error("invalid PURGE_INTERVAL", "value", v, "error", err)
+            os.Exit(1)
+        }
+        purgeInterval = d
+    }
+
+    mediaStore := getenv("MEDIA_STORE", "local")
+    if mediaStore != "local" && mediaStore != "s3" {
+        slog.Error("invalid MEDIA_STORE, want local or s3", "value", mediaStore)
+        os.Exit(1)
+    }
+    mediaPort := getenv("MEDIA_PORT", "8083")
+
+    currency := getenv("CURRENCY", "USD")
+    if !money.ValidCurrency(currency) {
+        slog.Error("invalid CURRENCY, want an ISO 4217 code such as USD", "value", currency)
+        os.Exit(1)
+    }
+
     // Контекст для подключения
     ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
     defer cancel()
 
-    client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+    client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri).SetMonitor(otelmongo.NewMonitor()))
     if err != nil {
-        log.Fatalf("❌ MongoDB connect error: %v", err)
+        slog.Error("mongodb connect failed", "error", err)
+        os.Exit(1)
     }
 
     return &Config{
@@ -60,5 +149,25 @@ func Load() *Config {
         Client:      client,
         RedisAddr:     redisAddr,
         RedisPassword: redisPassword,
+        MetricsPort:   metricsPort,
+        TracesExporter: tracesExporter,
+        OTLPEndpoint:   otlpEndpoint,
+        LogLevel:       logLevel,
+        LogFormat:      logFormat,
+        PurgeRetention: purgeRetention,
+        PurgeInterval:  purgeInterval,
+        MediaStore:     mediaStore,
+        MediaDir:       getenv("MEDIA_DIR", "./media"),
+        MediaPort:      mediaPort,
+        MediaBaseURL:   getenv("MEDIA_BASE_URL", "http://localhost:"+mediaPort+"/media"),
+        S3: S3Config{
+            Endpoint:  os.Getenv("S3_ENDPOINT"),
+            Region:    getenv("S3_REGION", "us-east-1"),
+            Bucket:    os.Getenv("S3_BUCKET"),
+            AccessKey: os.Getenv("S3_ACCESS_KEY"),
+            SecretKey: os.Getenv("S3_SECRET_KEY"),
+            PublicURL: os.Getenv("S3_PUBLIC_URL"),
+        },
+        Currency:       currency,
     }
 }
@@ -2,25 +2,250 @@ package migration
 
 import (
 	"context"
+	"fmt"
+	"inventory-service/internal/model"
+	"math"
+	"sort"
+	"strings"
+
+	"golang/pkg/money"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func MigrateUp(db *mongo.Database) error {
+// MigrateUp brings stored products up to date. currency is the store
+// currency legacy float prices are taken to be in.
+func MigrateUp(db *mongo.Database, currency string) error {
 	_, err := db.Collection("products").UpdateMany(
 		context.Background(),
 		bson.M{"quantity": bson.M{"$exists": false}},
 		bson.M{"$set": bson.M{"quantity": 0}},
 	)
-	return err
+	if err != nil {
+		return err
+	}
+
+	// Products created before optimistic concurrency start at version 1.
+	_, err = db.Collection("products").UpdateMany(
+		context.Background(),
+		bson.M{"version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"version": int64(1)}},
+	)
+	if err != nil {
+		return err
+	}
+
+	// Products created before variants become single-variant products, with
+	// the product ID as SKU holding all the stock.
+	_, err = db.Collection("products").UpdateMany(
+		context.Background(),
+		bson.M{"variants": bson.M{"$exists": false}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{
+				"variants": bson.A{bson.M{
+					"sku":   bson.M{"$toString": "$_id"},
+					"stock": bson.M{"$ifNull": bson.A{"$stock", 0}},
+				}},
+			}}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := normalizeCategories(db); err != nil {
+		return err
+	}
+	return convertPrices(db, currency)
+}
+
+// convertPrices turns the float prices of products stored before money
+// amounts into integer minor units of currency, rounding half away from zero
+// to the nearest minor unit, and records the currency.
+func convertPrices(db *mongo.Database, currency string) error {
+	ctx := context.Background()
+	products := db.Collection("products")
+
+	cursor, err := products.Find(ctx, bson.M{"price": bson.M{"$type": "double"}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Price    float64            `bson:"price"`
+			Variants []bson.M           `bson:"variants"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		price, err := money.FromFloat(doc.Price, currency)
+		if err != nil {
+			return fmt.Errorf("product %s: price %v: %w", doc.ID.Hex(), doc.Price, err)
+		}
+		for _, v := range doc.Variants {
+			override, ok := v["price_override"].(float64)
+			if !ok {
+				continue
+			}
+			m, err := money.FromFloat(override, currency)
+			if err != nil {
+				return fmt.Errorf("product %s: price_override %v: %w", doc.ID.Hex(), override, err)
+			}
+			v["price_override"] = m.Amount
+		}
+
+		// Matching the old price keeps a rerun from converting twice.
+		_, err = products.UpdateOne(ctx,
+			bson.M{"_id": doc.ID, "price": doc.Price},
+			bson.M{"$set": bson.M{"price": price.Amount, "currency": currency, "variants": doc.Variants}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// normalizeCategories turns the free-text categories of products created
+// before the category tree into root categories. Names that only differ in
+// case or punctuation share a slug and so end up in the same category;
+// anything finer, like nesting, is left to be done by hand.
+func normalizeCategories(db *mongo.Database) error {
+	ctx := context.Background()
+	products := db.Collection("products")
+	categories := db.Collection("categories")
+
+	legacy := bson.M{"category_id": bson.M{"$exists": false}, "category": bson.M{"$type": "string"}}
+	raw, err := products.Distinct(ctx, "category", legacy)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(raw))
+	for _, v := range raw {
+		names = append(names, v.(string))
+	}
+	// The first spelling of a slug, in sort order, names the category.
+	sort.Strings(names)
+
+	for _, name := range names {
+		display := strings.TrimSpace(name)
+		slug := model.Slugify(display)
+		if slug == "" {
+			display, slug = "Uncategorized", "uncategorized"
+		}
+
+		var cat struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		err := categories.FindOneAndUpdate(ctx,
+			bson.M{"slug": slug},
+			bson.M{"$setOnInsert": bson.M{"name": display, "slug": slug, "ancestors": bson.A{}}},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&cat)
+		if err != nil {
+			return err
+		}
+
+		_, err = products.UpdateMany(ctx,
+			bson.M{"category_id": bson.M{"$exists": false}, "category": name},
+			bson.M{"$set": bson.M{"category_id": cat.ID}, "$unset": bson.M{"category": ""}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func MigrateDown(db *mongo.Database) error {
+	if err := revertPrices(db); err != nil {
+		return err
+	}
+	if err := denormalizeCategories(db); err != nil {
+		return err
+	}
+
 	_, err := db.Collection("products").UpdateMany(
 		context.Background(),
 		bson.M{},
-		bson.M{"$unset": bson.M{"quantity": ""}},
+		bson.M{"$unset": bson.M{"quantity": "", "version": "", "variants": ""}},
 	)
 	return err
 }
+
+// revertPrices turns integer prices back into floats in major units and
+// drops the currency.
+func revertPrices(db *mongo.Database) error {
+	ctx := context.Background()
+	products := db.Collection("products")
+
+	cursor, err := products.Find(ctx, bson.M{"price": bson.M{"$type": "long"}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Price    int64              `bson:"price"`
+			Currency string             `bson:"currency"`
+			Variants []bson.M           `bson:"variants"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		scale := math.Pow10(money.Exponent(doc.Currency))
+		for _, v := range doc.Variants {
+			if override, ok := v["price_override"].(int64); ok {
+				v["price_override"] = float64(override) / scale
+			}
+		}
+		_, err = products.UpdateOne(ctx,
+			bson.M{"_id": doc.ID, "price": doc.Price},
+			bson.M{
+				"$set":   bson.M{"price": float64(doc.Price) / scale, "variants": doc.Variants},
+				"$unset": bson.M{"currency": ""},
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// denormalizeCategories puts the category name back on products as free
+// text. The categories collection itself is kept.
+func denormalizeCategories(db *mongo.Database) error {
+	ctx := context.Background()
+	cursor, err := db.Collection("categories").Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var cat struct {
+			ID   primitive.ObjectID `bson:"_id"`
+			Name string             `bson:"name"`
+		}
+		if err := cursor.Decode(&cat); err != nil {
+			return err
+		}
+		_, err := db.Collection("products").UpdateMany(ctx,
+			bson.M{"category_id": cat.ID},
+			bson.M{"$set": bson.M{"category": cat.Name}, "$unset": bson.M{"category_id": ""}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -2,7 +2,7 @@ package db
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -22,6 +22,6 @@ func NewMongoClient(uri string) (*mongo.Client, context.Context, error) {
 		return nil, nil, err
 	}
 
-	log.Println("✅ Connected to MongoDB (v1 driver)")
+	slog.Info("connected to mongodb")
 	return client, ctx, nil
 }
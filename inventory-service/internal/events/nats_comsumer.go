@@ -3,64 +3,228 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"inventory-service/internal/repository"
 	"inventory-service/internal/usecase"
-	"log"
+
+	"golang/pkg/logger"
 
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type Consumer struct {
-    conn     *nats.Conn
-    subject  string
-    usecase  *usecase.ProductUsecase
+var tracer = otel.Tracer("inventory-service/events")
+
+// Subjects order-service announces orders on. A cancelled order set PENDING
+// again is announced as created anew.
+const (
+	OrderCreated   = "order.created"
+	OrderCancelled = "order.cancelled"
+)
+
+// OrdersStream is the JetStream stream order-service publishes order events
+// to. It is created by whichever service starts first.
+const OrdersStream = "ORDERS"
+
+// retryDelay is how long an order event that could not be applied waits
+// before it is delivered again.
+const retryDelay = 10 * time.Second
+
+// ordersStream returns the JetStream context of nc, creating the orders
+// stream unless it exists.
+func ordersStream(nc *nats.Conn) (nats.JetStreamContext, error) {
+	js, err := nc.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     OrdersStream,
+		Subjects: []string{"order.>"},
+		Storage:  nats.FileStorage,
+	})
+	if err != nil && !errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		return nil, err
+	}
+	return js, nil
+}
+
+// durableName is the name of the durable consumer of subject.
+func durableName(subject string) string {
+	return "inventory-service-" + strings.ReplaceAll(subject, ".", "-")
 }
 
-func NewConsumer(conn *nats.Conn, subject string, uc *usecase.ProductUsecase) *Consumer {
-    return &Consumer{conn: conn, subject: subject, usecase: uc}
+// settled reports whether an order event that failed to apply with err is
+// done with: retrying cannot help once the stock is short, the product or
+// variant is gone, or the order's stock is where the event would take it.
+func settled(err error) bool {
+	return errors.Is(err, repository.ErrAlreadyApplied) ||
+		errors.Is(err, repository.ErrInsufficientStock) ||
+		errors.Is(err, repository.ErrProductNotFound) ||
+		errors.Is(err, repository.ErrVariantNotFound) ||
+		errors.Is(err, repository.ErrSKURequired)
 }
 
 type OrderCreatedMessage struct {
 	ID       string `json:"ID"`
 	UserID   string `json:"UserID"`
-	Total    float64 `json:"Total"`
 	Status   string `json:"Status"`
 	Products []struct {
 		ProductID string `json:"ProductID"`
+		SKU       string `json:"SKU"`
 		Quantity  int    `json:"Quantity"`
 	} `json:"Products"`
 }
 
+// OrderCancelledMessage is the body of order.cancelled events.
+type OrderCancelledMessage struct {
+	OrderID  string `json:"order_id"`
+	Products []struct {
+		ProductID string `json:"product_id"`
+		SKU       string `json:"sku"`
+	} `json:"products"`
+}
+
+// orderLine is a variant an order takes stock of.
+type orderLine struct {
+	productID, sku string
+}
+
+// Consumer takes the stock of orders when they are placed and gives it back
+// when they are cancelled.
+type Consumer struct {
+	js      nats.JetStreamContext
+	usecase *usecase.ProductUsecase
+}
+
+// NewConsumer creates the consumer and the orders stream, unless it exists.
+func NewConsumer(nc *nats.Conn, uc *usecase.ProductUsecase) (*Consumer, error) {
+	js, err := ordersStream(nc)
+	if err != nil {
+		return nil, err
+	}
+	return &Consumer{js: js, usecase: uc}, nil
+}
+
+// Subscribe listens for order events through durable consumers, so events
+// published while inventory-service is down are delivered when it is back.
+// Replicas share a queue group, and events are acknowledged only once
+// applied.
 func (c *Consumer) Subscribe(ctx context.Context) error {
-    _, err := c.conn.Subscribe(c.subject, func(msg *nats.Msg) {
-        var order OrderCreatedMessage
-        if err := json.Unmarshal(msg.Data, &order); err != nil {
-            log.Printf("❌ Failed to parse order: %v\n", err)
-            return
-        }
-
-        log.Printf("📨 Received message on %s: %+v\n", c.subject, order)
-
-        for _, item := range order.Products {
-            prod, err := c.usecase.GetProduct(ctx, item.ProductID)
-            if err != nil {
-                log.Printf("❌ Product not found: %s\n", item.ProductID)
-                continue
-            }
-
-            if prod.Stock < int32(item.Quantity) {
-                log.Printf("❌ Not enough stock for product %s: have %d, need %d\n", item.ProductID, prod.Stock, item.Quantity)
-                continue
-            }
-
-            prod.Stock -= int32(item.Quantity)
-            if err := c.usecase.UpdateProduct(ctx, prod); err != nil {
-                log.Printf("❌ Failed to update stock for product %s: %v\n", item.ProductID, err)
-                continue
-            }
-
-            log.Printf("✅ Stock updated for product %s: new stock = %d\n", item.ProductID, prod.Stock)
-        }
-    })
-
-    return err
-}
\ No newline at end of file
+	for _, subject := range []string{OrderCreated, OrderCancelled} {
+		durable := durableName(subject)
+		if _, err := c.js.QueueSubscribe(subject, durable, func(msg *nats.Msg) {
+			if c.handle(ctx, msg) {
+				_ = msg.Ack()
+			} else {
+				_ = msg.NakWithDelay(retryDelay)
+			}
+		}, nats.BindStream(OrdersStream), nats.Durable(durable), nats.ManualAck()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle applies an order event and reports whether it is done with it.
+func (c *Consumer) handle(ctx context.Context, msg *nats.Msg) bool {
+	// Continue the trace started by the publisher
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(msg.Header))
+	ctx, span := tracer.Start(ctx, msg.Subject+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination.name", msg.Subject),
+		),
+	)
+	defer span.End()
+
+	requestID := msg.Header.Get(logger.RequestIDHeader)
+	if !logger.ValidRequestID(requestID) {
+		requestID = logger.NewRequestID()
+	}
+	ctx = logger.WithRequestID(ctx, requestID)
+
+	if msg.Subject == OrderCancelled {
+		return c.releaseStock(ctx, msg)
+	}
+	return c.takeStock(ctx, msg)
+}
+
+// takeStock takes the stock of a placed order. Lines of the same variant are
+// taken together, since the order takes each variant's stock once.
+func (c *Consumer) takeStock(ctx context.Context, msg *nats.Msg) bool {
+	var order OrderCreatedMessage
+	if err := json.Unmarshal(msg.Data, &order); err != nil {
+		slog.ErrorContext(ctx, "failed to parse message", "subject", msg.Subject, "error", err)
+		return true
+	}
+
+	slog.InfoContext(ctx, "message received", "subject", msg.Subject, "order_id", order.ID, "items", len(order.Products))
+
+	var lines []orderLine
+	quantities := map[orderLine]int{}
+	for _, item := range order.Products {
+		line := orderLine{item.ProductID, item.SKU}
+		if _, ok := quantities[line]; !ok {
+			lines = append(lines, line)
+		}
+		quantities[line] += item.Quantity
+	}
+
+	done := true
+	for _, line := range lines {
+		if quantities[line] < 1 {
+			continue
+		}
+		// Decrement in a single atomic write so concurrent orders and
+		// stale cached reads cannot overwrite each other's stock.
+		prod, err := c.usecase.DecreaseStock(ctx, line.productID, line.sku, int32(quantities[line]), order.ID)
+		if errors.Is(err, repository.ErrAlreadyApplied) {
+			slog.InfoContext(ctx, "order stock already taken", "order_id", order.ID, "product_id", line.productID, "sku", line.sku)
+			continue
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "failed to decrease stock", "order_id", order.ID, "product_id", line.productID, "sku", line.sku, "requested", quantities[line], "error", err)
+			done = done && settled(err)
+			continue
+		}
+
+		slog.InfoContext(ctx, "stock updated", "order_id", order.ID, "product_id", line.productID, "stock", prod.Stock)
+	}
+	return done
+}
+
+// releaseStock gives back the stock a cancelled order took.
+func (c *Consumer) releaseStock(ctx context.Context, msg *nats.Msg) bool {
+	var order OrderCancelledMessage
+	if err := json.Unmarshal(msg.Data, &order); err != nil {
+		slog.ErrorContext(ctx, "failed to parse message", "subject", msg.Subject, "error", err)
+		return true
+	}
+
+	slog.InfoContext(ctx, "message received", "subject", msg.Subject, "order_id", order.OrderID, "items", len(order.Products))
+
+	done := true
+	for _, item := range order.Products {
+		prod, err := c.usecase.ReleaseStock(ctx, item.ProductID, item.SKU, order.OrderID)
+		if errors.Is(err, repository.ErrAlreadyApplied) {
+			slog.InfoContext(ctx, "no order stock to give back", "order_id", order.OrderID, "product_id", item.ProductID, "sku", item.SKU)
+			continue
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "failed to give back stock", "order_id", order.OrderID, "product_id", item.ProductID, "sku", item.SKU, "error", err)
+			done = done && settled(err)
+			continue
+		}
+
+		slog.InfoContext(ctx, "stock updated", "order_id", order.OrderID, "product_id", item.ProductID, "stock", prod.Stock)
+	}
+	return done
+}
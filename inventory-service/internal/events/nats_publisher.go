@@ -0,0 +1,58 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"inventory-service/internal/model"
+
+	"golang/pkg/logger"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type NATSPublisher struct {
+	conn *nats.Conn
+}
+
+func NewNATSPublisher(conn *nats.Conn) *NATSPublisher {
+	return &NATSPublisher{conn: conn}
+}
+
+// PublishStockAlert publishes the alert on "inventory.<kind>".
+func (p *NATSPublisher) PublishStockAlert(ctx context.Context, alert *model.StockAlert) error {
+	subject := "inventory." + alert.Kind
+	ctx, span := tracer.Start(ctx, subject+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination.name", subject),
+		),
+	)
+	defer span.End()
+
+	data, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(msg.Header))
+	if id := logger.RequestID(ctx); id != "" {
+		msg.Header.Set(logger.RequestIDHeader, id)
+	}
+
+	slog.InfoContext(ctx, "publishing event", "subject", subject, "product_id", alert.ProductID, "stock", alert.Stock)
+	if err := p.conn.PublishMsg(msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
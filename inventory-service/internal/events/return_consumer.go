@@ -0,0 +1,125 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"inventory-service/internal/repository"
+	"inventory-service/internal/usecase"
+
+	"golang/pkg/logger"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OrderReturned is the subject order-service announces returns on.
+const OrderReturned = "order.returned"
+
+// OrderReturnedMessage is the body of order.returned events.
+type OrderReturnedMessage struct {
+	OrderID  string `json:"order_id"`
+	ReturnID string `json:"return_id"`
+	Products []struct {
+		ProductID string `json:"product_id"`
+		SKU       string `json:"sku"`
+		Quantity  int    `json:"quantity"`
+	} `json:"products"`
+}
+
+// ReturnConsumer restocks the units customers return.
+type ReturnConsumer struct {
+	js      nats.JetStreamContext
+	usecase *usecase.ProductUsecase
+}
+
+// NewReturnConsumer creates the consumer and the orders stream, unless it
+// exists.
+func NewReturnConsumer(nc *nats.Conn, uc *usecase.ProductUsecase) (*ReturnConsumer, error) {
+	js, err := ordersStream(nc)
+	if err != nil {
+		return nil, err
+	}
+	return &ReturnConsumer{js: js, usecase: uc}, nil
+}
+
+// Subscribe listens for returns through a durable consumer, so returns
+// announced while inventory-service is down are restocked when it is back.
+// Replicas share a queue group, and events are acknowledged only once
+// applied.
+func (c *ReturnConsumer) Subscribe(ctx context.Context) error {
+	durable := durableName(OrderReturned)
+	_, err := c.js.QueueSubscribe(OrderReturned, durable, func(msg *nats.Msg) {
+		if c.handle(ctx, msg) {
+			_ = msg.Ack()
+		} else {
+			_ = msg.NakWithDelay(retryDelay)
+		}
+	}, nats.BindStream(OrdersStream), nats.Durable(durable), nats.ManualAck())
+	return err
+}
+
+// handle restocks a return and reports whether it is done with it. Units of
+// the same variant are restocked together, since each return restocks a
+// variant once.
+func (c *ReturnConsumer) handle(ctx context.Context, msg *nats.Msg) bool {
+	// Continue the trace started by the publisher
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(msg.Header))
+	ctx, span := tracer.Start(ctx, OrderReturned+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination.name", OrderReturned),
+		),
+	)
+	defer span.End()
+
+	requestID := msg.Header.Get(logger.RequestIDHeader)
+	if !logger.ValidRequestID(requestID) {
+		requestID = logger.NewRequestID()
+	}
+	ctx = logger.WithRequestID(ctx, requestID)
+
+	var ret OrderReturnedMessage
+	if err := json.Unmarshal(msg.Data, &ret); err != nil {
+		slog.ErrorContext(ctx, "failed to parse message", "subject", OrderReturned, "error", err)
+		return true
+	}
+
+	slog.InfoContext(ctx, "message received", "subject", OrderReturned, "order_id", ret.OrderID, "return_id", ret.ReturnID, "items", len(ret.Products))
+
+	var lines []orderLine
+	quantities := map[orderLine]int{}
+	for _, item := range ret.Products {
+		line := orderLine{item.ProductID, item.SKU}
+		if _, ok := quantities[line]; !ok {
+			lines = append(lines, line)
+		}
+		quantities[line] += item.Quantity
+	}
+
+	done := true
+	for _, line := range lines {
+		if quantities[line] < 1 {
+			continue
+		}
+		prod, err := c.usecase.RestockReturn(ctx, line.productID, line.sku, int32(quantities[line]), ret.OrderID, ret.ReturnID)
+		if errors.Is(err, repository.ErrAlreadyApplied) {
+			slog.InfoContext(ctx, "return already restocked", "order_id", ret.OrderID, "return_id", ret.ReturnID, "product_id", line.productID, "sku", line.sku)
+			continue
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "failed to restock returned units", "order_id", ret.OrderID, "return_id", ret.ReturnID, "product_id", line.productID, "sku", line.sku, "quantity", quantities[line], "error", err)
+			done = done && settled(err)
+			continue
+		}
+
+		slog.InfoContext(ctx, "stock updated", "order_id", ret.OrderID, "product_id", line.productID, "stock", prod.Stock)
+	}
+	return done
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"inventory-service/internal/model"
+	"inventory-service/internal/pb"
+)
+
+func (h *ProductHandler) CreateCategory(ctx context.Context, req *pb.CreateCategoryRequest) (*pb.CategoryResponse, error) {
+	c, err := h.categories.CreateCategory(ctx, &model.Category{
+		Name:     req.Name,
+		Slug:     req.Slug,
+		ParentID: req.ParentId,
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &pb.CategoryResponse{Category: categoryToProto(c)}, nil
+}
+
+func (h *ProductHandler) GetCategory(ctx context.Context, req *pb.GetCategoryRequest) (*pb.CategoryResponse, error) {
+	c, err := h.categories.GetCategory(ctx, req.Id)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &pb.CategoryResponse{Category: categoryToProto(c)}, nil
+}
+
+func (h *ProductHandler) UpdateCategory(ctx context.Context, req *pb.UpdateCategoryRequest) (*pb.CategoryResponse, error) {
+	c, err := h.categories.UpdateCategory(ctx, &model.Category{
+		ID:       req.Id,
+		Name:     req.Name,
+		Slug:     req.Slug,
+		ParentID: req.ParentId,
+	})
+	if err != nil {
+		return nil, mapError(err)
+	}
+	return &pb.CategoryResponse{Category: categoryToProto(c)}, nil
+}
+
+func (h *ProductHandler) DeleteCategory(ctx context.Context, req *pb.DeleteCategoryRequest) (*pb.DeleteCategoryResponse, error) {
+	if err := h.categories.DeleteCategory(ctx, req.Id); err != nil {
+		return nil, mapError(err)
+	}
+	return &pb.DeleteCategoryResponse{Message: "category deleted"}, nil
+}
+
+func (h *ProductHandler) ListCategories(ctx context.Context, req *pb.ListCategoriesRequest) (*pb.ListCategoriesResponse, error) {
+	list, err := h.categories.ListCategories(ctx, req.ParentId)
+	if err != nil {
+		return nil, mapError(err)
+	}
+	out := make([]*pb.Category, 0, len(list))
+	for _, c := range list {
+		out = append(out, categoryToProto(c))
+	}
+	return &pb.ListCategoriesResponse{Categories: out}, nil
+}
+
+func categoryToProto(c *model.Category) *pb.Category {
+	return &pb.Category{
+		Id:          c.ID,
+		Name:        c.Name,
+		Slug:        c.Slug,
+		ParentId:    c.ParentID,
+		AncestorIds: c.Ancestors,
+	}
+}
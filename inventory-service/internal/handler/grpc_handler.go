@@ -2,39 +2,48 @@ package handler
 
 import (
 	"context"
+	"errors"
 	"inventory-service/internal/model"
 	"inventory-service/internal/pb"
+	pbmoney "inventory-service/internal/pb/money"
+	"inventory-service/internal/repository"
 	"inventory-service/internal/usecase"
 
 	"strings"
 
+	"golang/pkg/money"
+
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type ProductHandler struct {
     pb.UnimplementedInventoryServiceServer
-    uc *usecase.ProductUsecase
+    uc         *usecase.ProductUsecase
+    categories *usecase.CategoryUsecase
 }
 
-func NewProductHandler(uc *usecase.ProductUsecase) *ProductHandler {
-    return &ProductHandler{uc: uc}
+func NewProductHandler(uc *usecase.ProductUsecase, categories *usecase.CategoryUsecase) *ProductHandler {
+    return &ProductHandler{uc: uc, categories: categories}
 }
 
 func (h *ProductHandler) CreateProduct(ctx context.Context, req *pb.CreateProductRequest) (*pb.ProductResponse, error) {
     prod := &model.Product{
-        Name:        req.Name,
-        Description: req.Description,
-        Category:    req.Category,
-        Stock:       req.Stock,
-        Price:       req.Price,
+        Name:         req.Name,
+        Description:  req.Description,
+        CategoryID:   req.CategoryId,
+        Stock:        req.Stock,
+        Price:        moneyFromProto(req.Price),
+        ReorderLevel: req.ReorderLevel,
+        Variants:     variantsFromProto(req.Variants),
     }
     id, err := h.uc.CreateProduct(ctx, prod)
     if err != nil {
         return nil, mapError(err)
     }
     prod.ID = id
-    return &pb.ProductResponse{Product: toProto(prod)}, nil
+    return &pb.ProductResponse{Product: h.toProto(prod)}, nil
 }
 
 func (h *ProductHandler) GetProduct(ctx context.Context, req *pb.GetProductRequest) (*pb.ProductResponse, error) {
@@ -42,23 +51,24 @@ func (h *ProductHandler) GetProduct(ctx context.Context, req *pb.GetProductReque
     if err != nil {
         return nil, mapError(err)
     }
-    return &pb.ProductResponse{Product: toProto(prod)}, nil
+    return &pb.ProductResponse{Product: h.toProto(prod)}, nil
 }
 
 func (h *ProductHandler) UpdateProduct(ctx context.Context, req *pb.UpdateProductRequest) (*pb.ProductResponse, error) {
     prod := &model.Product{
-        ID:          req.Id,
-        Name:        req.Name,
-        Description: req.Description,
-        Category:    req.Category,
-        Stock:       req.Stock,
-        Price:       req.Price,
-    }
-    if err := h.uc.UpdateProduct(ctx, prod); err != nil {
+        ID:           req.Id,
+        Name:         req.Name,
+        Description:  req.Description,
+        CategoryID:   req.CategoryId,
+        Price:        moneyFromProto(req.Price),
+        Version:      req.Version,
+        ReorderLevel: req.ReorderLevel,
+    }
+    updated, err := h.uc.UpdateProduct(ctx, prod, req.GetUpdateMask().GetPaths())
+    if err != nil {
         return nil, mapError(err)
     }
-    updated, _ := h.uc.GetProduct(ctx, req.Id)
-    return &pb.ProductResponse{Product: toProto(updated)}, nil
+    return &pb.ProductResponse{Product: h.toProto(updated)}, nil
 }
 
 func (h *ProductHandler) DeleteProduct(ctx context.Context, req *pb.DeleteProductRequest) (*pb.DeleteProductResponse, error) {
@@ -68,38 +78,193 @@ func (h *ProductHandler) DeleteProduct(ctx context.Context, req *pb.DeleteProduc
     return &pb.DeleteProductResponse{Message: "product deleted"}, nil
 }
 
+func (h *ProductHandler) UpsertVariant(ctx context.Context, req *pb.UpsertVariantRequest) (*pb.ProductResponse, error) {
+    if req.Variant == nil {
+        return nil, status.Error(codes.InvalidArgument, "variant is required")
+    }
+    prod, err := h.uc.UpsertVariant(ctx, req.ProductId, variantsFromProto([]*pb.Variant{req.Variant})[0])
+    if err != nil {
+        return nil, mapError(err)
+    }
+    return &pb.ProductResponse{Product: h.toProto(prod)}, nil
+}
+
+func (h *ProductHandler) AddProductImage(ctx context.Context, req *pb.AddProductImageRequest) (*pb.ProductResponse, error) {
+    prod, err := h.uc.AddImage(ctx, req.ProductId, req.Data, req.Alt)
+    if err != nil {
+        return nil, mapError(err)
+    }
+    return &pb.ProductResponse{Product: h.toProto(prod)}, nil
+}
+
+func (h *ProductHandler) DeleteProductImage(ctx context.Context, req *pb.DeleteProductImageRequest) (*pb.ProductResponse, error) {
+    prod, err := h.uc.DeleteImage(ctx, req.ProductId, req.ImageId)
+    if err != nil {
+        return nil, mapError(err)
+    }
+    return &pb.ProductResponse{Product: h.toProto(prod)}, nil
+}
+
+func (h *ProductHandler) RestoreProduct(ctx context.Context, req *pb.RestoreProductRequest) (*pb.ProductResponse, error) {
+    prod, err := h.uc.RestoreProduct(ctx, req.Id)
+    if err != nil {
+        return nil, mapError(err)
+    }
+    return &pb.ProductResponse{Product: h.toProto(prod)}, nil
+}
+
 func (h *ProductHandler) ListProducts(ctx context.Context, req *pb.ListProductsRequest) (*pb.ListProductsResponse, error) {
-    list, err := h.uc.ListProducts(ctx, req.Category, req.Page, req.Limit)
+    list, err := h.uc.ListProducts(ctx, req.Category, req.Page, req.Limit, req.Sort, req.InStock)
     if err != nil {
         return nil, mapError(err)
     }
     var out []*pb.Product
     for _, p := range list {
-        out = append(out, toProto(p))
+        out = append(out, h.toProto(p))
+    }
+    return &pb.ListProductsResponse{Products: out}, nil
+}
+
+func (h *ProductHandler) AdjustStock(ctx context.Context, req *pb.AdjustStockRequest) (*pb.AdjustStockResponse, error) {
+    m := &model.StockMovement{
+        ProductID: req.ProductId,
+        SKU:       req.Sku,
+        Delta:     req.Delta,
+        Reason:    req.Reason,
+        Reference: req.Reference,
+        Note:      req.Note,
+        ActorID:   req.ActorId,
+    }
+    updated, err := h.uc.AdjustStock(ctx, m)
+    if err != nil {
+        return nil, mapError(err)
+    }
+    return &pb.AdjustStockResponse{Product: h.toProto(updated), Movement: movementToProto(m)}, nil
+}
+
+func (h *ProductHandler) GetStockMovements(ctx context.Context, req *pb.GetStockMovementsRequest) (*pb.GetStockMovementsResponse, error) {
+    list, err := h.uc.GetStockMovements(ctx, req.ProductId, req.Page, req.Limit)
+    if err != nil {
+        return nil, mapError(err)
+    }
+    out := make([]*pb.StockMovement, 0, len(list))
+    for _, m := range list {
+        out = append(out, movementToProto(m))
+    }
+    return &pb.GetStockMovementsResponse{Movements: out}, nil
+}
+
+func (h *ProductHandler) ListLowStock(ctx context.Context, req *pb.ListLowStockRequest) (*pb.ListProductsResponse, error) {
+    list, err := h.uc.ListLowStock(ctx, req.Page, req.Limit)
+    if err != nil {
+        return nil, mapError(err)
+    }
+    out := make([]*pb.Product, 0, len(list))
+    for _, p := range list {
+        out = append(out, h.toProto(p))
     }
     return &pb.ListProductsResponse{Products: out}, nil
 }
 
-func toProto(p *model.Product) *pb.Product {
-    return &pb.Product{
-        Id:          p.ID,
-        Name:        p.Name,
-        Description: p.Description,
-        Category:    p.Category,
-        Stock:       p.Stock,
-        Price:       p.Price,
+func movementToProto(m *model.StockMovement) *pb.StockMovement {
+    return &pb.StockMovement{
+        Id:         m.ID,
+        ProductId:  m.ProductID,
+        Sku:        m.SKU,
+        Delta:      m.Delta,
+        Reason:     m.Reason,
+        StockAfter: m.StockAfter,
+        Reference:  m.Reference,
+        Note:       m.Note,
+        ActorId:    m.ActorID,
+        CreatedAt:  timestamppb.New(m.CreatedAt),
+    }
+}
+
+func (h *ProductHandler) toProto(p *model.Product) *pb.Product {
+    out := &pb.Product{
+        Id:           p.ID,
+        Name:         p.Name,
+        Description:  p.Description,
+        CategoryId:   p.CategoryID,
+        Stock:        p.Stock,
+        Price:        moneyToProto(p.Price),
+        Version:      p.Version,
+        ReorderLevel: p.ReorderLevel,
+    }
+    if p.DeletedAt != nil {
+        out.DeletedAt = timestamppb.New(*p.DeletedAt)
+    }
+    for _, v := range p.Variants {
+        out.Variants = append(out.Variants, &pb.Variant{
+            Sku:           v.SKU,
+            Attributes:    v.Attributes,
+            PriceOverride: moneyToProto(v.PriceOverride),
+            Stock:         v.Stock,
+            WeightGrams:   v.WeightGrams,
+        })
+    }
+    for _, img := range p.Images {
+        out.Images = append(out.Images, &pb.Image{
+            Id:           img.ID,
+            Url:          h.uc.ImageURL(img.Key),
+            ThumbnailUrl: h.uc.ImageURL(img.ThumbnailKey),
+            ContentType:  img.ContentType,
+            Width:        img.Width,
+            Height:       img.Height,
+            Size:         img.Size,
+            Alt:          img.Alt,
+        })
+    }
+    return out
+}
+
+func variantsFromProto(in []*pb.Variant) []model.Variant {
+    out := make([]model.Variant, 0, len(in))
+    for _, v := range in {
+        out = append(out, model.Variant{
+            SKU:           v.GetSku(),
+            Attributes:    v.GetAttributes(),
+            PriceOverride: moneyFromProto(v.GetPriceOverride()),
+            Stock:         v.GetStock(),
+            WeightGrams:   v.GetWeightGrams(),
+        })
     }
+    return out
+}
+
+func moneyFromProto(m *pbmoney.Money) money.Money {
+    return money.New(m.GetAmount(), m.GetCurrency())
+}
+
+// moneyToProto leaves zero amounts without a currency, such as unset price
+// overrides, unset.
+func moneyToProto(m money.Money) *pbmoney.Money {
+    if m == (money.Money{}) {
+        return nil
+    }
+    return &pbmoney.Money{Currency: m.Currency, Amount: m.Amount}
 }
 
 func mapError(err error) error {
     msg := err.Error()
     switch {
+    case errors.Is(err, repository.ErrVersionConflict):
+        return status.Error(codes.Aborted, msg)
+    // Sentinels first: their messages may contain the words matched below.
+    case errors.Is(err, repository.ErrInsufficientStock), errors.Is(err, repository.ErrNotDeleted),
+        errors.Is(err, usecase.ErrCategoryInUse), errors.Is(err, repository.ErrTooManyImages):
+        return status.Error(codes.FailedPrecondition, msg)
+    case errors.Is(err, usecase.ErrStockNotUpdatable):
+        return status.Error(codes.InvalidArgument, msg)
     case strings.Contains(msg, "required"), strings.Contains(msg, "must be"):
         return status.Error(codes.InvalidArgument, msg)
     case strings.Contains(msg, "not found"):
         return status.Error(codes.NotFound, msg)
     case strings.Contains(msg, "already exists"):
         return status.Error(codes.AlreadyExists, msg)
+    case strings.Contains(msg, "invalid"):
+        return status.Error(codes.InvalidArgument, msg)
     default:
         return status.Error(codes.Internal, msg)
     }
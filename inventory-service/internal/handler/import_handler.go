@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"errors"
+	"inventory-service/internal/model"
+	"inventory-service/internal/pb"
+	"inventory-service/internal/usecase"
+	"io"
+
+	"google.golang.org/grpc"
+)
+
+func (h *ProductHandler) ImportProducts(stream grpc.ClientStreamingServer[pb.ImportProductsRequest, pb.ImportProductsResponse]) error {
+	ctx := stream.Context()
+	var imp *usecase.ProductImport
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if imp == nil {
+			imp = h.uc.NewImport(req.DryRun, req.ActorId)
+		}
+		if req.Row != nil {
+			imp.Add(ctx, rowFromProto(req.Row))
+		}
+	}
+	if imp == nil {
+		return stream.SendAndClose(&pb.ImportProductsResponse{})
+	}
+
+	report := imp.Finish(ctx)
+	resp := &pb.ImportProductsResponse{
+		DryRun:  report.DryRun,
+		Created: report.Created,
+		Updated: report.Updated,
+		Failed:  report.Failed,
+	}
+	for _, e := range report.Errors {
+		resp.Errors = append(resp.Errors, &pb.ImportError{Line: e.Line, Sku: e.SKU, Error: e.Error})
+	}
+	return stream.SendAndClose(resp)
+}
+
+func (h *ProductHandler) ExportProducts(req *pb.ExportProductsRequest, stream grpc.ServerStreamingServer[pb.Product]) error {
+	err := h.uc.ExportProducts(stream.Context(), req.Category, func(p *model.Product) error {
+		return stream.Send(h.toProto(p))
+	})
+	if err != nil {
+		return mapError(err)
+	}
+	return nil
+}
+
+func rowFromProto(r *pb.ImportRow) model.ImportRow {
+	return model.ImportRow{
+		Line:          r.Line,
+		SKU:           r.Sku,
+		Name:          r.Name,
+		Description:   r.Description,
+		Category:      r.Category,
+		Price:         r.Price,
+		Stock:         r.Stock,
+		ReorderLevel:  r.ReorderLevel,
+		Attributes:    r.Attributes,
+		PriceOverride: r.PriceOverride,
+		Currency:      r.Currency,
+		WeightGrams:   r.WeightGrams,
+	}
+}
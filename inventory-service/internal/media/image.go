@@ -0,0 +1,139 @@
+// Package media validates uploaded product images and renders thumbnails.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // registers the GIF decoder
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxImageSize is the largest accepted upload, in bytes.
+	MaxImageSize = 5 << 20
+	// maxPixels guards against small files that decode to huge images.
+	maxPixels = 40_000_000
+	// ThumbnailSize bounds the longer side of thumbnails, in pixels.
+	ThumbnailSize = 320
+)
+
+// Supported upload types and the file extensions they are stored under.
+var extensions = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// Ext returns the file extension images of the given type are stored under.
+func Ext(contentType string) string {
+	return extensions[contentType]
+}
+
+// Image is a decoded upload.
+type Image struct {
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	img         image.Image
+}
+
+// Decode checks that data is a supported image within the size limits. The
+// type is sniffed from the bytes, not taken from the client.
+func Decode(data []byte) (*Image, error) {
+	if len(data) == 0 {
+		return nil, errors.New("image is required")
+	}
+	if len(data) > MaxImageSize {
+		return nil, fmt.Errorf("image must be at most %d MiB", MaxImageSize>>20)
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := extensions[contentType]
+	if !ok {
+		return nil, fmt.Errorf("image must be a JPEG, PNG or GIF, got %s", contentType)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("invalid image: " + err.Error())
+	}
+	if cfg.Width*cfg.Height > maxPixels {
+		return nil, fmt.Errorf("image must be at most %d megapixels", maxPixels/1_000_000)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("invalid image: " + err.Error())
+	}
+	return &Image{ContentType: contentType, Ext: ext, Width: cfg.Width, Height: cfg.Height, img: img}, nil
+}
+
+// Thumbnail scales the image down to fit in ThumbnailSize×ThumbnailSize,
+// keeping its aspect ratio, and encodes it as JPEG, or as PNG when the
+// original may have transparency. Smaller images are re-encoded unscaled.
+func (m *Image) Thumbnail() (data []byte, contentType string, err error) {
+	w, h := m.Width, m.Height
+	if w > ThumbnailSize || h > ThumbnailSize {
+		if w >= h {
+			w, h = ThumbnailSize, max(1, h*ThumbnailSize/w)
+		} else {
+			w, h = max(1, w*ThumbnailSize/h), ThumbnailSize
+		}
+	}
+	thumb := downscale(m.img, w, h)
+
+	var buf bytes.Buffer
+	if m.ContentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+		contentType = "image/jpeg"
+	} else {
+		err = png.Encode(&buf, thumb)
+		contentType = "image/png"
+	}
+	return buf.Bytes(), contentType, err
+}
+
+// downscale resizes src to w×h by averaging the source pixels covered by
+// each destination pixel (a box filter), which avoids the aliasing of
+// nearest-neighbour sampling when shrinking.
+func downscale(src image.Image, w, h int) *image.NRGBA {
+	b := src.Bounds()
+	rgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	sw, sh := b.Dx(), b.Dy()
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, max((y+1)*sh/h, y*sh/h+1)
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, max((x+1)*sw/w, x*sw/w+1)
+			var r, g, bl, a, n int
+			for sy := y0; sy < y1; sy++ {
+				i := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					// Weight colour by alpha so transparent pixels do not
+					// darken the edges.
+					pa := int(rgba.Pix[i+3])
+					r += int(rgba.Pix[i]) * pa
+					g += int(rgba.Pix[i+1]) * pa
+					bl += int(rgba.Pix[i+2]) * pa
+					a += pa
+					n++
+					i += 4
+				}
+			}
+			o := dst.PixOffset(x, y)
+			if a > 0 {
+				dst.Pix[o] = uint8(r / a)
+				dst.Pix[o+1] = uint8(g / a)
+				dst.Pix[o+2] = uint8(bl / a)
+			}
+			dst.Pix[o+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
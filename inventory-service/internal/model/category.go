@@ -0,0 +1,35 @@
+package model
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Category is a node in the product category tree.
+type Category struct {
+	ID        string
+	Name      string   // required
+	Slug      string   // unique, as produced by Slugify
+	ParentID  string   // empty for a root category
+	Ancestors []string // IDs from the root down to the parent
+}
+
+// Slugify turns a category name into a slug: lowercase letters and digits,
+// in any script, with every other run of characters replaced by a single
+// dash.
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
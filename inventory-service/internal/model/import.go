@@ -0,0 +1,33 @@
+package model
+
+// ImportRow is one variant in a bulk import.
+type ImportRow struct {
+	Line          int32 // position in the source file
+	SKU           string
+	Name          string
+	Description   string
+	Category      string // category ID or slug
+	Price         string // decimal in major units, e.g. "19.99"
+	Stock         *int32 // nil leaves an existing variant's stock as is
+	ReorderLevel  *int32 // nil leaves an existing product's level as is
+	Attributes    map[string]string
+	PriceOverride string // like Price; empty means the product price
+	Currency      string // empty means the store currency
+	WeightGrams   *int32 // nil leaves an existing variant's weight as is
+}
+
+// ImportReport sums up a bulk import.
+type ImportReport struct {
+	DryRun  bool
+	Created int32 // rows that created a product
+	Updated int32 // rows applied to an existing product
+	Failed  int32
+	Errors  []ImportError
+}
+
+// ImportError tells why a row was skipped.
+type ImportError struct {
+	Line  int32
+	SKU   string
+	Error string
+}
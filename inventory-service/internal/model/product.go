@@ -1,11 +1,44 @@
 package model
 
+import (
+    "time"
+
+    "golang/pkg/money"
+)
+
 // Чистая доменная модель — без bson-тегов.
 type Product struct {
-    ID          string  // auto-generated hex
-    Name        string  // required
-    Description string  // required
-    Category    string  // required
-    Stock       int32   // required, >=0
-    Price       float64 // required, >=0
+    ID           string     // auto-generated hex
+    Name         string     // required
+    Description  string     // required
+    CategoryID   string     // required, a category ID
+    Stock        int32      // >=0, sum of the variants' stock
+    Price        money.Money // required, >=0, in the store currency
+    Version      int64      // incremented on every write, checked on update
+    ReorderLevel int32      // >=0; stock at or below it needs replenishing
+    DeletedAt    *time.Time // set while soft-deleted
+    Variants     []Variant  // at least one
+    Images       []Image    // in upload order; the first is the main image
+}
+
+// Variant is a sellable version of a product with its own SKU and stock.
+type Variant struct {
+    SKU           string            // unique across all products
+    Attributes    map[string]string // e.g. size, color
+    PriceOverride money.Money       // zero means the product price
+    Stock         int32             // >=0
+    WeightGrams   int32             // shipping weight of one unit; 0 if unknown
+}
+
+// Image is an uploaded product image. Keys address the blob store; URLs are
+// derived from them when the product is served.
+type Image struct {
+    ID           string
+    Key          string // original upload
+    ThumbnailKey string
+    ContentType  string
+    Width        int32
+    Height       int32
+    Size         int64 // bytes of the original
+    Alt          string
 }
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Kinds of stock alert; each is published on "inventory.<kind>".
+const (
+	AlertLowStock   = "low_stock"
+	AlertOutOfStock = "out_of_stock"
+)
+
+// StockAlert reports that a stock decrease took a product down to its
+// reorder level or sold it out.
+type StockAlert struct {
+	Kind         string    `json:"kind"`
+	ProductID    string    `json:"product_id"`
+	SKU          string    `json:"sku"` // variant whose decrease raised the alert
+	Name         string    `json:"name"`
+	Stock        int32     `json:"stock"`
+	ReorderLevel int32     `json:"reorder_level"`
+	Reason       string    `json:"reason"`              // reason of the movement that crossed the threshold
+	Reference    string    `json:"reference,omitempty"` // e.g. the order ID
+	OccurredAt   time.Time `json:"occurred_at"`
+}
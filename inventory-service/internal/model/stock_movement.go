@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// Reasons a product's stock can change.
+const (
+	ReasonRestock      = "restock"
+	ReasonDamage       = "damage"
+	ReasonCorrection   = "correction"
+	ReasonOrder        = "order"
+	ReasonCancellation = "cancellation"
+	ReasonReturn       = "return"  // units a customer sent back
+	ReasonInitial      = "initial" // stock a product was created with
+)
+
+// StockMovement is an entry of the append-only stock ledger.
+type StockMovement struct {
+	ID         string
+	ProductID  string
+	SKU        string // variant whose stock changed
+	Delta      int32  // signed change applied to the stock
+	Reason     string // one of the Reason constants
+	StockAfter int32  // stock right after the change
+	Reference  string // e.g. the order ID for order, cancellation and return movements
+	Note       string
+	ActorID    string // user who made the change, empty for system changes
+	CreatedAt  time.Time
+}
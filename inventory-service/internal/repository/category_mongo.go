@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"inventory-service/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoCategoryRepository stores the category tree with materialized
+// ancestors, so a subtree is found with a single indexed query.
+type MongoCategoryRepository struct {
+	coll *mongo.Collection
+}
+
+type categoryDocument struct {
+	ID        primitive.ObjectID   `bson:"_id"`
+	Name      string               `bson:"name"`
+	Slug      string               `bson:"slug"`
+	ParentID  *primitive.ObjectID  `bson:"parent_id,omitempty"`
+	Ancestors []primitive.ObjectID `bson:"ancestors"`
+}
+
+func (d categoryDocument) toModel() *model.Category {
+	c := &model.Category{
+		ID:        d.ID.Hex(),
+		Name:      d.Name,
+		Slug:      d.Slug,
+		Ancestors: make([]string, 0, len(d.Ancestors)),
+	}
+	if d.ParentID != nil {
+		c.ParentID = d.ParentID.Hex()
+	}
+	for _, a := range d.Ancestors {
+		c.Ancestors = append(c.Ancestors, a.Hex())
+	}
+	return c
+}
+
+// toCategoryDocument converts c, failing on malformed IDs.
+func toCategoryDocument(c *model.Category) (categoryDocument, error) {
+	doc := categoryDocument{Name: c.Name, Slug: c.Slug, Ancestors: []primitive.ObjectID{}}
+	if c.ParentID != "" {
+		parent, err := primitive.ObjectIDFromHex(c.ParentID)
+		if err != nil {
+			return doc, errors.New("invalid parent_id")
+		}
+		doc.ParentID = &parent
+	}
+	for _, a := range c.Ancestors {
+		oid, err := primitive.ObjectIDFromHex(a)
+		if err != nil {
+			return doc, errors.New("invalid ancestor ID")
+		}
+		doc.Ancestors = append(doc.Ancestors, oid)
+	}
+	return doc, nil
+}
+
+func NewMongoCategoryRepository(coll *mongo.Collection) *MongoCategoryRepository {
+	coll.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "slug", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	)
+	coll.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{Keys: bson.D{{Key: "ancestors", Value: 1}}},
+	)
+	return &MongoCategoryRepository{coll: coll}
+}
+
+func (r *MongoCategoryRepository) Create(ctx context.Context, c *model.Category) (string, error) {
+	doc, err := toCategoryDocument(c)
+	if err != nil {
+		return "", err
+	}
+	doc.ID = primitive.NewObjectID()
+	if _, err := r.coll.InsertOne(ctx, doc); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return "", errors.New("category slug already exists")
+		}
+		return "", err
+	}
+	c.ID = doc.ID.Hex()
+	return c.ID, nil
+}
+
+func (r *MongoCategoryRepository) GetByID(ctx context.Context, id string) (*model.Category, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid category ID")
+	}
+	return r.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (r *MongoCategoryRepository) GetBySlug(ctx context.Context, slug string) (*model.Category, error) {
+	return r.findOne(ctx, bson.M{"slug": slug})
+}
+
+func (r *MongoCategoryRepository) findOne(ctx context.Context, filter bson.M) (*model.Category, error) {
+	var doc categoryDocument
+	err := r.coll.FindOne(ctx, filter).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("category not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toModel(), nil
+}
+
+func (r *MongoCategoryRepository) List(ctx context.Context, parentID string) ([]*model.Category, error) {
+	filter := bson.M{}
+	if parentID != "" {
+		oid, err := primitive.ObjectIDFromHex(parentID)
+		if err != nil {
+			return nil, errors.New("invalid parent_id")
+		}
+		filter["parent_id"] = oid
+	}
+
+	// Sort by depth so parents always come before their children.
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$addFields", Value: bson.M{"depth": bson.M{"$size": "$ancestors"}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "depth", Value: 1}, {Key: "name", Value: 1}, {Key: "_id", Value: 1}}}},
+	}
+	cursor, err := r.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var out []*model.Category
+	for cursor.Next(ctx) {
+		var doc categoryDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		out = append(out, doc.toModel())
+	}
+	return out, cursor.Err()
+}
+
+func (r *MongoCategoryRepository) Update(ctx context.Context, c *model.Category) (*model.Category, error) {
+	oid, err := primitive.ObjectIDFromHex(c.ID)
+	if err != nil {
+		return nil, errors.New("invalid category ID")
+	}
+	doc, err := toCategoryDocument(c)
+	if err != nil {
+		return nil, err
+	}
+	set := bson.M{"name": doc.Name, "slug": doc.Slug, "ancestors": doc.Ancestors}
+	update := bson.M{"$set": set}
+	if doc.ParentID != nil {
+		set["parent_id"] = doc.ParentID
+	} else {
+		update["$unset"] = bson.M{"parent_id": ""}
+	}
+
+	session, err := r.coll.Database().Client().StartSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.EndSession(ctx)
+
+	var before categoryDocument
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		err := r.coll.FindOneAndUpdate(sc, bson.M{"_id": oid}, update).Decode(&before)
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.New("category not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if sameIDs(before.Ancestors, doc.Ancestors) {
+			return nil, nil
+		}
+
+		// Descendants keep their path below this category and take its new
+		// path above it.
+		prefix := append(append(bson.A{}, toA(doc.Ancestors)...), oid)
+		_, err = r.coll.UpdateMany(sc, bson.M{"ancestors": oid}, mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{"ancestors": bson.M{"$concatArrays": bson.A{
+				prefix,
+				bson.M{"$slice": bson.A{
+					"$ancestors",
+					bson.M{"$add": bson.A{bson.M{"$indexOfArray": bson.A{"$ancestors", oid}}, 1}},
+					bson.M{"$size": "$ancestors"},
+				}},
+			}}}}},
+		})
+		return nil, err
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, errors.New("category slug already exists")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc.ID = oid
+	return doc.toModel(), nil
+}
+
+func sameIDs(a, b []primitive.ObjectID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func toA(ids []primitive.ObjectID) bson.A {
+	out := make(bson.A, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, id)
+	}
+	return out
+}
+
+func (r *MongoCategoryRepository) Delete(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return errors.New("invalid category ID")
+	}
+	res, err := r.coll.DeleteOne(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return errors.New("category not found")
+	}
+	return nil
+}
+
+func (r *MongoCategoryRepository) DescendantIDs(ctx context.Context, id string) ([]string, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid category ID")
+	}
+	cursor, err := r.coll.Find(ctx, bson.M{"ancestors": oid},
+		options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var out []string
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		out = append(out, doc.ID.Hex())
+	}
+	return out, cursor.Err()
+}
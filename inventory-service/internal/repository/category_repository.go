@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+	"inventory-service/internal/model"
+)
+
+type CategoryRepository interface {
+	// Create stores c, whose Ancestors must already be filled in, and
+	// returns its ID.
+	Create(ctx context.Context, c *model.Category) (string, error)
+	GetByID(ctx context.Context, id string) (*model.Category, error)
+	GetBySlug(ctx context.Context, slug string) (*model.Category, error)
+	// List returns the children of parentID, or every category when
+	// parentID is empty, shallowest first and by name within a level.
+	List(ctx context.Context, parentID string) ([]*model.Category, error)
+	// Update writes c's name, slug, parent and ancestors. When the ancestors
+	// change, those of every descendant are rewritten in the same
+	// transaction.
+	Update(ctx context.Context, c *model.Category) (*model.Category, error)
+	Delete(ctx context.Context, id string) error
+	// DescendantIDs returns the IDs of every category below id.
+	DescendantIDs(ctx context.Context, id string) ([]string, error)
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 	"inventory-service/internal/model"
-	"inventory-service/internal/redis"
+
+	"golang/pkg/money"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,36 +17,373 @@ import (
 )
 
 type MongoProductRepository struct {
-    coll *mongo.Collection
+    coll      *mongo.Collection
+    movements *mongo.Collection
 }
 
-func (r *MongoProductRepository) DecreaseStock(ctx context.Context, productID string, quantity int32) error {
-    objID, err := primitive.ObjectIDFromHex(productID)
-    if err != nil {
-        return errors.New("invalid product ID")
+// productDocument is the stored shape of a product.
+type productDocument struct {
+    ID           primitive.ObjectID `bson:"_id"`
+    Name         string             `bson:"name"`
+    Description  string             `bson:"description"`
+    CategoryID   primitive.ObjectID `bson:"category_id,omitempty"`
+    Stock        int32              `bson:"stock"`
+    Price        int64              `bson:"price"`    // in minor units of currency
+    Currency     string             `bson:"currency"`
+    Version      int64              `bson:"version"`
+    ReorderLevel int32              `bson:"reorder_level"`
+    DeletedAt    *time.Time         `bson:"deleted_at,omitempty"`
+    Variants     []variantDocument  `bson:"variants"`
+    Images       []imageDocument    `bson:"images,omitempty"`
+}
+
+type imageDocument struct {
+    ID           string `bson:"id"`
+    Key          string `bson:"key"`
+    ThumbnailKey string `bson:"thumbnail_key"`
+    ContentType  string `bson:"content_type"`
+    Width        int32  `bson:"width"`
+    Height       int32  `bson:"height"`
+    Size         int64  `bson:"size"`
+    Alt          string `bson:"alt,omitempty"`
+}
+
+func (d imageDocument) toModel() model.Image {
+    return model.Image{
+        ID:           d.ID,
+        Key:          d.Key,
+        ThumbnailKey: d.ThumbnailKey,
+        ContentType:  d.ContentType,
+        Width:        d.Width,
+        Height:       d.Height,
+        Size:         d.Size,
+        Alt:          d.Alt,
+    }
+}
+
+type variantDocument struct {
+    SKU           string            `bson:"sku"`
+    Attributes    map[string]string `bson:"attributes,omitempty"`
+    PriceOverride int64             `bson:"price_override,omitempty"` // in the product's currency
+    Stock         int32             `bson:"stock"`
+    WeightGrams   int32             `bson:"weight_grams,omitempty"`
+}
+
+func toVariantDocuments(variants []model.Variant) []variantDocument {
+    out := make([]variantDocument, 0, len(variants))
+    for _, v := range variants {
+        out = append(out, variantDocument{
+            SKU:           v.SKU,
+            Attributes:    v.Attributes,
+            PriceOverride: v.PriceOverride.Amount,
+            Stock:         v.Stock,
+            WeightGrams:   v.WeightGrams,
+        })
+    }
+    return out
+}
+
+// live matches products that are not soft-deleted.
+var live = bson.M{"$exists": false}
+
+func (d productDocument) toModel() *model.Product {
+    variants := make([]model.Variant, 0, len(d.Variants))
+    for _, v := range d.Variants {
+        var override money.Money
+        if v.PriceOverride != 0 {
+            override = money.New(v.PriceOverride, d.Currency)
+        }
+        variants = append(variants, model.Variant{
+            SKU:           v.SKU,
+            Attributes:    v.Attributes,
+            PriceOverride: override,
+            Stock:         v.Stock,
+            WeightGrams:   v.WeightGrams,
+        })
+    }
+    var images []model.Image
+    for _, img := range d.Images {
+        images = append(images, img.toModel())
+    }
+    var categoryID string
+    if !d.CategoryID.IsZero() {
+        categoryID = d.CategoryID.Hex()
+    }
+    return &model.Product{
+        ID:           d.ID.Hex(),
+        Name:         d.Name,
+        Description:  d.Description,
+        CategoryID:   categoryID,
+        Stock:        d.Stock,
+        Price:        money.New(d.Price, d.Currency),
+        Version:      d.Version,
+        ReorderLevel: d.ReorderLevel,
+        DeletedAt:    d.DeletedAt,
+        Variants:     variants,
+        Images:       images,
     }
+}
+
+// stockMovementDocument is the stored shape of a ledger entry.
+type stockMovementDocument struct {
+    ID         primitive.ObjectID `bson:"_id"`
+    ProductID  primitive.ObjectID `bson:"product_id"`
+    SKU        string             `bson:"sku"`
+    Delta      int32              `bson:"delta"`
+    Reason     string             `bson:"reason"`
+    StockAfter int32              `bson:"stock_after"`
+    Reference  string             `bson:"reference,omitempty"`
+    Note       string             `bson:"note,omitempty"`
+    ActorID    string             `bson:"actor_id,omitempty"`
+    CreatedAt  time.Time          `bson:"created_at"`
+}
 
-    filter := bson.M{"_id": objID, "stock": bson.M{"$gte": quantity}}
-    update := bson.M{"$inc": bson.M{"stock": -quantity}}
+func (d stockMovementDocument) toModel() *model.StockMovement {
+    return &model.StockMovement{
+        ID:         d.ID.Hex(),
+        ProductID:  d.ProductID.Hex(),
+        SKU:        d.SKU,
+        Delta:      d.Delta,
+        Reason:     d.Reason,
+        StockAfter: d.StockAfter,
+        Reference:  d.Reference,
+        Note:       d.Note,
+        ActorID:    d.ActorID,
+        CreatedAt:  d.CreatedAt,
+    }
+}
 
-    res, err := r.coll.UpdateOne(ctx, filter, update)
+// AdjustStock runs the $inc and the ledger insert in one transaction, so a
+// movement is recorded if and only if the stock changed. The variant's stock
+// and the product's total are incremented together. Transactions need
+// MongoDB running as a replica set.
+func (r *MongoProductRepository) AdjustStock(ctx context.Context, m *model.StockMovement) (*model.Product, error) {
+    return r.adjustStock(ctx, m, nil)
+}
+
+// AdjustOrderStock reads what the order holds in the same transaction as the
+// $inc. Concurrent deliveries of an event both increment the product, so one
+// of them conflicts, is retried, and then sees the other's movement.
+func (r *MongoProductRepository) AdjustOrderStock(ctx context.Context, m *model.StockMovement) (*model.Product, error) {
+    return r.adjustStock(ctx, m, func(sc mongo.SessionContext, productID primitive.ObjectID) error {
+        if m.Reason == model.ReasonReturn {
+            return r.checkNotRestocked(sc, productID, m)
+        }
+        held, err := r.heldByOrder(sc, productID, m.SKU, m.Reference)
+        if err != nil {
+            return err
+        }
+        switch {
+        case m.Reason == model.ReasonOrder && held == 0:
+            return nil
+        case m.Reason == model.ReasonCancellation && held > 0:
+            m.Delta = held
+            return nil
+        }
+        return ErrAlreadyApplied
+    })
+}
+
+// checkNotRestocked returns ErrAlreadyApplied if the return movement m was
+// recorded for the product's variant already.
+func (r *MongoProductRepository) checkNotRestocked(ctx context.Context, productID primitive.ObjectID, m *model.StockMovement) error {
+    filter := bson.M{
+        "product_id": productID,
+        "reference":  m.Reference,
+        "reason":     model.ReasonReturn,
+        "note":       m.Note,
+    }
+    if m.SKU != "" {
+        filter["sku"] = m.SKU
+    }
+    n, err := r.movements.CountDocuments(ctx, filter)
     if err != nil {
         return err
     }
-    if res.MatchedCount == 0 {
-        return errors.New("not enough stock or product not found")
+    if n > 0 {
+        return ErrAlreadyApplied
     }
+    return nil
+}
 
-    // Redis кэшін өшіру
-    cacheKey := fmt.Sprintf("product:%s", productID)
-    _ = redis.DeleteCache(cacheKey)
+// heldByOrder returns how many units of the product's variant sku (any
+// variant when empty) the order took and has not given back.
+func (r *MongoProductRepository) heldByOrder(ctx context.Context, productID primitive.ObjectID, sku, orderID string) (int32, error) {
+    match := bson.M{
+        "product_id": productID,
+        "reference":  orderID,
+        "reason":     bson.M{"$in": bson.A{model.ReasonOrder, model.ReasonCancellation}},
+    }
+    if sku != "" {
+        match["sku"] = sku
+    }
+    cursor, err := r.movements.Aggregate(ctx, mongo.Pipeline{
+        {{Key: "$match", Value: match}},
+        {{Key: "$group", Value: bson.M{"_id": nil, "net": bson.M{"$sum": "$delta"}}}},
+    })
+    if err != nil {
+        return 0, err
+    }
+    defer cursor.Close(ctx)
 
-    return nil
+    var sums []struct {
+        Net int32 `bson:"net"`
+    }
+    if err := cursor.All(ctx, &sums); err != nil {
+        return 0, err
+    }
+    if len(sums) == 0 {
+        return 0, nil
+    }
+    return -sums[0].Net, nil
+}
+
+// adjustStock applies m as AdjustStock does. check, if set, runs first in the
+// transaction and aborts it by returning an error; it may set m.Delta.
+func (r *MongoProductRepository) adjustStock(ctx context.Context, m *model.StockMovement, check func(mongo.SessionContext, primitive.ObjectID) error) (*model.Product, error) {
+    objID, err := primitive.ObjectIDFromHex(m.ProductID)
+    if err != nil {
+        return nil, errors.New("invalid product ID")
+    }
+
+    session, err := r.coll.Database().Client().StartSession()
+    if err != nil {
+        return nil, err
+    }
+    defer session.EndSession(ctx)
+
+    var doc productDocument
+    var entry stockMovementDocument
+    _, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+        if check != nil {
+            if err := check(sc, objID); err != nil {
+                return nil, err
+            }
+        }
+        filter, update := stockUpdate(objID, m)
+        err := r.coll.FindOneAndUpdate(sc, filter, update,
+            options.FindOneAndUpdate().SetReturnDocument(options.After),
+        ).Decode(&doc)
+        if err == mongo.ErrNoDocuments {
+            return nil, r.explainStockMiss(sc, objID, m.SKU)
+        }
+        if err != nil {
+            return nil, err
+        }
+
+        variant := doc.Variants[0]
+        for _, v := range doc.Variants {
+            if v.SKU == m.SKU {
+                variant = v
+            }
+        }
+        entry = stockMovementDocument{
+            ID:         primitive.NewObjectID(),
+            ProductID:  objID,
+            SKU:        variant.SKU,
+            Delta:      m.Delta,
+            Reason:     m.Reason,
+            StockAfter: variant.Stock,
+            Reference:  m.Reference,
+            Note:       m.Note,
+            ActorID:    m.ActorID,
+            CreatedAt:  time.Now().UTC().Truncate(time.Millisecond),
+        }
+        _, err = r.movements.InsertOne(sc, entry)
+        return nil, err
+    })
+    if err != nil {
+        return nil, err
+    }
+
+    m.ID = entry.ID.Hex()
+    m.SKU = entry.SKU
+    m.StockAfter = entry.StockAfter
+    m.CreatedAt = entry.CreatedAt
+    return doc.toModel(), nil
+}
+
+// stockUpdate returns the filter and update applying m.Delta to the variant
+// m.SKU of the product, matching nothing if the stock would go negative.
+func stockUpdate(objID primitive.ObjectID, m *model.StockMovement) (bson.M, bson.M) {
+    filter := bson.M{"_id": objID, "deleted_at": live}
+    var variantStock string
+    if m.SKU != "" {
+        match := bson.M{"sku": m.SKU}
+        if m.Delta < 0 {
+            match["stock"] = bson.M{"$gte": -m.Delta}
+        }
+        filter["variants"] = bson.M{"$elemMatch": match}
+        variantStock = "variants.$.stock"
+    } else {
+        // Without a SKU only a product with a single variant can be adjusted.
+        filter["variants"] = bson.M{"$size": 1}
+        if m.Delta < 0 {
+            filter["variants.0.stock"] = bson.M{"$gte": -m.Delta}
+        }
+        variantStock = "variants.0.stock"
+    }
+    return filter, bson.M{"$inc": bson.M{variantStock: m.Delta, "stock": m.Delta, "version": 1}}
 }
 
+// explainStockMiss tells why a stock update for the product and SKU matched
+// nothing.
+func (r *MongoProductRepository) explainStockMiss(ctx context.Context, objID primitive.ObjectID, sku string) error {
+    n, err := r.coll.CountDocuments(ctx, bson.M{"_id": objID, "deleted_at": live})
+    if err != nil {
+        return err
+    }
+    if n == 0 {
+        return ErrProductNotFound
+    }
+
+    if sku != "" {
+        n, err = r.coll.CountDocuments(ctx, bson.M{"_id": objID, "variants.sku": sku})
+        if err == nil && n == 0 {
+            err = ErrVariantNotFound
+        }
+    } else {
+        n, err = r.coll.CountDocuments(ctx, bson.M{"_id": objID, "variants": bson.M{"$size": 1}})
+        if err == nil && n == 0 {
+            err = ErrSKURequired
+        }
+    }
+    if err != nil {
+        return err
+    }
+    return ErrInsufficientStock
+}
+
+func (r *MongoProductRepository) ListStockMovements(ctx context.Context, productID string, page, limit int32) ([]*model.StockMovement, error) {
+    objID, err := primitive.ObjectIDFromHex(productID)
+    if err != nil {
+        return nil, errors.New("invalid product ID")
+    }
+
+    opts := options.Find().
+        SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+        SetSkip(int64((page-1)*limit)).
+        SetLimit(int64(limit))
+    cursor, err := r.movements.Find(ctx, bson.M{"product_id": objID}, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
 
+    var out []*model.StockMovement
+    for cursor.Next(ctx) {
+        var d stockMovementDocument
+        if err := cursor.Decode(&d); err != nil {
+            return nil, err
+        }
+        out = append(out, d.toModel())
+    }
+    return out, cursor.Err()
+}
 
-func NewMongoProductRepository(coll *mongo.Collection) *MongoProductRepository {
+// NewMongoProductRepository stores products in coll and the append-only
+// stock ledger in movements.
+func NewMongoProductRepository(coll, movements *mongo.Collection) *MongoProductRepository {
     // Убедимся, что есть уникальный индекс по name (опционально)
     coll.Indexes().CreateOne(
         context.Background(),
@@ -52,115 +392,513 @@ func NewMongoProductRepository(coll *mongo.Collection) *MongoProductRepository {
             Options: options.Index().SetUnique(true),
         },
     )
-    return &MongoProductRepository{coll: coll}
+    coll.Indexes().CreateOne(
+        context.Background(),
+        mongo.IndexModel{
+            Keys: bson.D{{Key: "variants.sku", Value: 1}},
+            Options: options.Index().SetUnique(true).
+                SetPartialFilterExpression(bson.M{"variants.sku": bson.M{"$exists": true}}),
+        },
+    )
+    coll.Indexes().CreateOne(
+        context.Background(),
+        mongo.IndexModel{
+            Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+            Options: options.Index().SetSparse(true),
+        },
+    )
+    coll.Indexes().CreateOne(
+        context.Background(),
+        mongo.IndexModel{Keys: bson.D{{Key: "category_id", Value: 1}}},
+    )
+    movements.Indexes().CreateOne(
+        context.Background(),
+        mongo.IndexModel{
+            Keys: bson.D{{Key: "product_id", Value: 1}, {Key: "created_at", Value: -1}},
+        },
+    )
+    // What an order holds is looked up by its ID.
+    movements.Indexes().CreateOne(
+        context.Background(),
+        mongo.IndexModel{
+            Keys: bson.D{{Key: "reference", Value: 1}, {Key: "product_id", Value: 1}},
+        },
+    )
+    return &MongoProductRepository{coll: coll, movements: movements}
 }
 
 func (r *MongoProductRepository) Create(ctx context.Context, p *model.Product) (string, error) {
-    doc := bson.M{
-        "name":        p.Name,
-        "description": p.Description,
-        "category":    p.Category,
-        "stock":       p.Stock,
-        "price":       p.Price,
+    categoryID, err := primitive.ObjectIDFromHex(p.CategoryID)
+    if err != nil {
+        return "", errors.New("invalid category_id")
+    }
+    oid := primitive.NewObjectID()
+    if len(p.Variants) == 0 {
+        // A product without variants is sold as a single variant.
+        p.Variants = []model.Variant{{SKU: oid.Hex(), Stock: p.Stock}}
+    }
+    p.Stock = 0
+    for _, v := range p.Variants {
+        p.Stock += v.Stock
+    }
+
+    doc := productDocument{
+        ID:           oid,
+        Name:         p.Name,
+        Description:  p.Description,
+        CategoryID:   categoryID,
+        Stock:        p.Stock,
+        Price:        p.Price.Amount,
+        Currency:     p.Price.Currency,
+        Version:      1,
+        ReorderLevel: p.ReorderLevel,
+        Variants:     toVariantDocuments(p.Variants),
+    }
+
+    // The stock a product starts with opens its ledger, so the movements of
+    // every variant add up to its stock.
+    now := time.Now().UTC().Truncate(time.Millisecond)
+    var entries []interface{}
+    for _, v := range p.Variants {
+        if v.Stock == 0 {
+            continue
+        }
+        entries = append(entries, stockMovementDocument{
+            ID:         primitive.NewObjectID(),
+            ProductID:  oid,
+            SKU:        v.SKU,
+            Delta:      v.Stock,
+            Reason:     model.ReasonInitial,
+            StockAfter: v.Stock,
+            CreatedAt:  now,
+        })
     }
-    res, err := r.coll.InsertOne(ctx, doc)
+
+    session, err := r.coll.Database().Client().StartSession()
+    if err != nil {
+        return "", err
+    }
+    defer session.EndSession(ctx)
+
+    _, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+        if _, err := r.coll.InsertOne(sc, doc); err != nil {
+            return nil, err
+        }
+        if len(entries) == 0 {
+            return nil, nil
+        }
+        _, err := r.movements.InsertMany(sc, entries)
+        return nil, err
+    })
     if err != nil {
         if we, ok := err.(mongo.WriteException); ok {
             for _, e := range we.WriteErrors {
                 if e.Code == 11000 {
-                    return "", errors.New("product already exists")
+                    return "", duplicateError(e.Message)
                 }
             }
         }
         return "", err
     }
-    oid := res.InsertedID.(primitive.ObjectID)
+    p.Version = 1
     return oid.Hex(), nil
 }
 
+// duplicateError names the unique index a write collided with.
+func duplicateError(msg string) error {
+    if strings.Contains(msg, "variants.sku") {
+        return errors.New("sku already exists")
+    }
+    return errors.New("product already exists")
+}
+
 func (r *MongoProductRepository) GetByID(ctx context.Context, id string) (*model.Product, error) {
     oid, err := primitive.ObjectIDFromHex(id)
     if err != nil {
         return nil, errors.New("invalid ID format")
     }
-    var doc bson.M
+    var doc productDocument
     err = r.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&doc)
     if err == mongo.ErrNoDocuments {
         return nil, errors.New("product not found")
     } else if err != nil {
         return nil, err
     }
-    return &model.Product{
-        ID:          id,
-        Name:        doc["name"].(string),
-        Description: doc["description"].(string),
-        Category:    doc["category"].(string),
-        Stock:       int32(doc["stock"].(int32)),
-        Price:       doc["price"].(float64),
-    }, nil
+    return doc.toModel(), nil
+}
+
+func (r *MongoProductRepository) GetBySKU(ctx context.Context, sku string) (*model.Product, error) {
+    return r.findOne(ctx, bson.M{"variants.sku": sku})
+}
+
+func (r *MongoProductRepository) GetByName(ctx context.Context, name string) (*model.Product, error) {
+    return r.findOne(ctx, bson.M{"name": name})
 }
 
-func (r *MongoProductRepository) Update(ctx context.Context, p *model.Product) error {
+func (r *MongoProductRepository) findOne(ctx context.Context, filter bson.M) (*model.Product, error) {
+    var doc productDocument
+    err := r.coll.FindOne(ctx, filter).Decode(&doc)
+    if err == mongo.ErrNoDocuments {
+        return nil, errors.New("product not found")
+    } else if err != nil {
+        return nil, err
+    }
+    return doc.toModel(), nil
+}
+
+// productFields maps updatable fields to their values in p.
+func productFields(p *model.Product) bson.M {
+    return bson.M{
+        "name":          p.Name,
+        "description":   p.Description,
+        "category_id":   p.CategoryID,
+        "price":         p.Price,
+        "reorder_level": p.ReorderLevel,
+    }
+}
+
+func (r *MongoProductRepository) Update(ctx context.Context, p *model.Product, fields []string) (*model.Product, error) {
     oid, err := primitive.ObjectIDFromHex(p.ID)
     if err != nil {
-        return errors.New("invalid ID format")
+        return nil, errors.New("invalid ID format")
+    }
+
+    set := productFields(p)
+    if len(fields) > 0 {
+        all := set
+        set = bson.M{}
+        for _, f := range fields {
+            v, ok := all[f]
+            if !ok {
+                return nil, fmt.Errorf("unknown product field %q", f)
+            }
+            set[f] = v
+        }
+    }
+    if id, ok := set["category_id"]; ok {
+        categoryID, err := primitive.ObjectIDFromHex(id.(string))
+        if err != nil {
+            return nil, errors.New("invalid category_id")
+        }
+        set["category_id"] = categoryID
+    }
+    if price, ok := set["price"]; ok {
+        set["price"] = price.(money.Money).Amount
+        set["currency"] = price.(money.Money).Currency
     }
     update := bson.M{
-        "name":        p.Name,
-        "description": p.Description,
-        "category":    p.Category,
-        "stock":       p.Stock,
-        "price":       p.Price,
+        "$set": set,
+        "$inc": bson.M{"version": 1},
+    }
+
+    filter := bson.M{"_id": oid, "deleted_at": live}
+    if p.Version > 0 {
+        filter["version"] = p.Version
+    }
+
+    var doc productDocument
+    err = r.coll.FindOneAndUpdate(ctx, filter, update,
+        options.FindOneAndUpdate().SetReturnDocument(options.After),
+    ).Decode(&doc)
+    if err == mongo.ErrNoDocuments {
+        // Tell a missing product apart from a stale version.
+        n, cerr := r.coll.CountDocuments(ctx, bson.M{"_id": oid, "deleted_at": live})
+        if cerr != nil {
+            return nil, cerr
+        }
+        if n == 0 {
+            return nil, errors.New("product not found")
+        }
+        return nil, ErrVersionConflict
+    }
+    if mongo.IsDuplicateKeyError(err) {
+        return nil, errors.New("product already exists")
+    }
+    if err != nil {
+        return nil, err
     }
-    res, err := r.coll.UpdateByID(ctx, oid, bson.M{"$set": update})
+    return doc.toModel(), nil
+}
+
+func (r *MongoProductRepository) Delete(ctx context.Context, id string) error {
+    objID, err := primitive.ObjectIDFromHex(id)
+    if err != nil {
+        return errors.New("invalid product ID")
+    }
+
+    // Soft delete: orders keep referencing the product until it is purged.
+    res, err := r.coll.UpdateOne(ctx,
+        bson.M{"_id": objID, "deleted_at": live},
+        bson.M{
+            "$set": bson.M{"deleted_at": time.Now().UTC().Truncate(time.Millisecond)},
+            "$inc": bson.M{"version": 1},
+        },
+    )
     if err != nil {
         return err
     }
     if res.MatchedCount == 0 {
         return errors.New("product not found")
     }
+
     return nil
 }
 
-func (r *MongoProductRepository) Delete(ctx context.Context, id string) error {
+func (r *MongoProductRepository) Restore(ctx context.Context, id string) (*model.Product, error) {
     objID, err := primitive.ObjectIDFromHex(id)
     if err != nil {
-        return errors.New("invalid product ID")
+        return nil, errors.New("invalid product ID")
     }
 
-    res, err := r.coll.DeleteOne(ctx, bson.M{"_id": objID})
+    var doc productDocument
+    err = r.coll.FindOneAndUpdate(ctx,
+        bson.M{"_id": objID, "deleted_at": bson.M{"$exists": true}},
+        bson.M{
+            "$unset": bson.M{"deleted_at": ""},
+            "$inc":   bson.M{"version": 1},
+        },
+        options.FindOneAndUpdate().SetReturnDocument(options.After),
+    ).Decode(&doc)
+    if err == mongo.ErrNoDocuments {
+        n, cerr := r.coll.CountDocuments(ctx, bson.M{"_id": objID})
+        if cerr != nil {
+            return nil, cerr
+        }
+        if n == 0 {
+            return nil, errors.New("product not found")
+        }
+        return nil, ErrNotDeleted
+    }
     if err != nil {
-        return err
+        return nil, err
     }
-    if res.DeletedCount == 0 {
-        return errors.New("product not found")
+    return doc.toModel(), nil
+}
+
+func (r *MongoProductRepository) UpsertVariant(ctx context.Context, productID string, v model.Variant) (*model.Product, error) {
+    objID, err := primitive.ObjectIDFromHex(productID)
+    if err != nil {
+        return nil, errors.New("invalid product ID")
+    }
+    after := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+    // Update an existing variant in place, leaving its stock alone.
+    var doc productDocument
+    err = r.coll.FindOneAndUpdate(ctx,
+        bson.M{"_id": objID, "deleted_at": live, "variants.sku": v.SKU},
+        bson.M{
+            "$set": bson.M{
+                "variants.$.attributes":     v.Attributes,
+                "variants.$.price_override": v.PriceOverride.Amount,
+                "variants.$.weight_grams":   v.WeightGrams,
+            },
+            "$inc": bson.M{"version": 1},
+        },
+        after,
+    ).Decode(&doc)
+    if err == nil {
+        return doc.toModel(), nil
+    }
+    if err != mongo.ErrNoDocuments {
+        return nil, err
     }
 
-    // Кэшті өшіру
-    cacheKey := fmt.Sprintf("product:%s", id)
-    _ = redis.DeleteCache(cacheKey)
+    // Otherwise add it without stock.
+    v.Stock = 0
+    err = r.coll.FindOneAndUpdate(ctx,
+        bson.M{"_id": objID, "deleted_at": live, "variants.sku": bson.M{"$ne": v.SKU}},
+        bson.M{
+            "$push": bson.M{"variants": toVariantDocuments([]model.Variant{v})[0]},
+            "$inc":  bson.M{"version": 1},
+        },
+        after,
+    ).Decode(&doc)
+    if err == mongo.ErrNoDocuments {
+        return nil, errors.New("product not found")
+    }
+    if mongo.IsDuplicateKeyError(err) {
+        return nil, errors.New("sku already exists")
+    }
+    if err != nil {
+        return nil, err
+    }
+    return doc.toModel(), nil
+}
 
-    return nil
+func (r *MongoProductRepository) CountByCategory(ctx context.Context, categoryID string) (int64, error) {
+    oid, err := primitive.ObjectIDFromHex(categoryID)
+    if err != nil {
+        return 0, errors.New("invalid category ID")
+    }
+    return r.coll.CountDocuments(ctx, bson.M{"category_id": oid})
 }
 
-func (r *MongoProductRepository) List(ctx context.Context, category string, page, limit int32) ([]*model.Product, error) {
-    filter := bson.M{}
-    if category != "" {
-        filter["category"] = category
+// PurgeDeleted removes products one at a time so that it returns exactly the
+// documents it deleted, even if one is restored meanwhile.
+func (r *MongoProductRepository) PurgeDeleted(ctx context.Context, before time.Time) ([]*model.Product, error) {
+    var purged []*model.Product
+    for {
+        var doc productDocument
+        err := r.coll.FindOneAndDelete(ctx, bson.M{"deleted_at": bson.M{"$lt": before}}).Decode(&doc)
+        if err == mongo.ErrNoDocuments {
+            return purged, nil
+        }
+        if err != nil {
+            return purged, err
+        }
+        purged = append(purged, doc.toModel())
+    }
+}
+
+// maxImages bounds how many images a product can have.
+const maxImages = 10
+
+func (r *MongoProductRepository) AddImage(ctx context.Context, productID string, img model.Image) (*model.Product, error) {
+    objID, err := primitive.ObjectIDFromHex(productID)
+    if err != nil {
+        return nil, errors.New("invalid product ID")
+    }
+
+    doc := imageDocument{
+        ID:           img.ID,
+        Key:          img.Key,
+        ThumbnailKey: img.ThumbnailKey,
+        ContentType:  img.ContentType,
+        Width:        img.Width,
+        Height:       img.Height,
+        Size:         img.Size,
+        Alt:          img.Alt,
+    }
+    filter := bson.M{
+        "_id":        objID,
+        "deleted_at": live,
+        fmt.Sprintf("images.%d", maxImages-1): bson.M{"$exists": false},
+    }
+    var updated productDocument
+    err = r.coll.FindOneAndUpdate(ctx, filter,
+        bson.M{"$push": bson.M{"images": doc}, "$inc": bson.M{"version": 1}},
+        options.FindOneAndUpdate().SetReturnDocument(options.After),
+    ).Decode(&updated)
+    if err == mongo.ErrNoDocuments {
+        n, cerr := r.coll.CountDocuments(ctx, bson.M{"_id": objID, "deleted_at": live})
+        if cerr != nil {
+            return nil, cerr
+        }
+        if n == 0 {
+            return nil, errors.New("product not found")
+        }
+        return nil, ErrTooManyImages
+    }
+    if err != nil {
+        return nil, err
+    }
+    return updated.toModel(), nil
+}
+
+func (r *MongoProductRepository) RemoveImage(ctx context.Context, productID, imageID string) (*model.Product, *model.Image, error) {
+    objID, err := primitive.ObjectIDFromHex(productID)
+    if err != nil {
+        return nil, nil, errors.New("invalid product ID")
+    }
+
+    // Matching on the image makes a missing one a no-op, and the document as
+    // it was before tells which image was removed.
+    var before productDocument
+    err = r.coll.FindOneAndUpdate(ctx,
+        bson.M{"_id": objID, "deleted_at": live, "images.id": imageID},
+        bson.M{"$pull": bson.M{"images": bson.M{"id": imageID}}, "$inc": bson.M{"version": 1}},
+    ).Decode(&before)
+    if err == mongo.ErrNoDocuments {
+        n, cerr := r.coll.CountDocuments(ctx, bson.M{"_id": objID, "deleted_at": live})
+        if cerr != nil {
+            return nil, nil, cerr
+        }
+        if n == 0 {
+            return nil, nil, errors.New("product not found")
+        }
+        return nil, nil, errors.New("image not found")
+    }
+    if err != nil {
+        return nil, nil, err
+    }
+
+    p := before.toModel()
+    p.Version++
+    var removed *model.Image
+    kept := p.Images[:0]
+    for _, img := range p.Images {
+        if img.ID == imageID {
+            img := img
+            removed = &img
+            continue
+        }
+        kept = append(kept, img)
+    }
+    p.Images = kept
+    return p, removed, nil
+}
+
+// categoryFilter matches live products in any of categoryIDs, or in any
+// category when it is empty.
+func categoryFilter(categoryIDs []string) (bson.M, error) {
+    filter := bson.M{"deleted_at": live}
+    if len(categoryIDs) > 0 {
+        oids := make([]primitive.ObjectID, 0, len(categoryIDs))
+        for _, id := range categoryIDs {
+            oid, err := primitive.ObjectIDFromHex(id)
+            if err != nil {
+                return nil, errors.New("invalid category ID")
+            }
+            oids = append(oids, oid)
+        }
+        filter["category_id"] = bson.M{"$in": oids}
+    }
+    return filter, nil
+}
+
+func (r *MongoProductRepository) Iterate(ctx context.Context, categoryIDs []string, fn func(*model.Product) error) error {
+    filter, err := categoryFilter(categoryIDs)
+    if err != nil {
+        return err
+    }
+    cursor, err := r.coll.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+    if err != nil {
+        return err
+    }
+    defer cursor.Close(ctx)
+
+    for cursor.Next(ctx) {
+        var doc productDocument
+        if err := cursor.Decode(&doc); err != nil {
+            return err
+        }
+        if err := fn(doc.toModel()); err != nil {
+            return err
+        }
+    }
+    return cursor.Err()
+}
+
+// List returns a page of products. sort is a field name, prefixed with "-"
+// for descending order; ties are broken by _id so that pages are stable.
+func (r *MongoProductRepository) List(ctx context.Context, categoryIDs []string, page, limit int32, sort string, inStock bool) ([]*model.Product, error) {
+    filter, err := categoryFilter(categoryIDs)
+    if err != nil {
+        return nil, err
+    }
+    if inStock {
+        filter["stock"] = bson.M{"$gt": 0}
     }
 
     opts := options.Find().
         SetSkip(int64((page-1)*limit)).
         SetLimit(int64(limit))
-
-    // Структура, точно соответствующая полям в БД
-    type dbProduct struct {
-        ID          primitive.ObjectID `bson:"_id"`
-        Name        string             `bson:"name"`
-        Description string             `bson:"description"`
-        Category    string             `bson:"category"`
-        Stock       int32              `bson:"stock"`
-        Price       float64            `bson:"price"`
+    if sort != "" {
+        field, dir := strings.TrimPrefix(sort, "-"), 1
+        if strings.HasPrefix(sort, "-") {
+            dir = -1
+        }
+        opts.SetSort(bson.D{{Key: field, Value: dir}, {Key: "_id", Value: 1}})
     }
 
     cursor, err := r.coll.Find(ctx, filter, opts)
@@ -171,22 +909,44 @@ func (r *MongoProductRepository) List(ctx context.Context, category string, page
 
     var out []*model.Product
     for cursor.Next(ctx) {
-        var dp dbProduct
+        var dp productDocument
         if err := cursor.Decode(&dp); err != nil {
             // пропускаем некорректный документ
             continue
         }
-        out = append(out, &model.Product{
-            ID:          dp.ID.Hex(),
-            Name:        dp.Name,
-            Description: dp.Description,
-            Category:    dp.Category,
-            Stock:       dp.Stock,
-            Price:       dp.Price,
-        })
+        out = append(out, dp.toModel())
     }
     if err := cursor.Err(); err != nil {
         return nil, err
     }
     return out, nil
+}
+
+// ListLowStock returns products whose stock is at or below their reorder
+// level, lowest stock first.
+func (r *MongoProductRepository) ListLowStock(ctx context.Context, page, limit int32) ([]*model.Product, error) {
+    filter := bson.M{
+        "deleted_at": live,
+        "$expr":      bson.M{"$lte": bson.A{"$stock", bson.M{"$ifNull": bson.A{"$reorder_level", 0}}}},
+    }
+    opts := options.Find().
+        SetSort(bson.D{{Key: "stock", Value: 1}, {Key: "_id", Value: 1}}).
+        SetSkip(int64((page-1)*limit)).
+        SetLimit(int64(limit))
+
+    cursor, err := r.coll.Find(ctx, filter, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var out []*model.Product
+    for cursor.Next(ctx) {
+        var dp productDocument
+        if err := cursor.Decode(&dp); err != nil {
+            return nil, err
+        }
+        out = append(out, dp.toModel())
+    }
+    return out, cursor.Err()
 }
\ No newline at end of file
@@ -2,15 +2,96 @@ package repository
 
 import (
     "context"
+    "errors"
+    "time"
     "inventory-service/internal/model"
 )
 
+// ErrVersionConflict is returned by Update when the product was changed since
+// the caller read the version it passed.
+var ErrVersionConflict = errors.New("product was modified concurrently, reload and retry")
+
+// ErrInsufficientStock is returned by AdjustStock when the delta would take
+// the stock below zero.
+var ErrInsufficientStock = errors.New("not enough stock")
+
+// ErrNotDeleted is returned by Restore for a product that is not deleted.
+var ErrNotDeleted = errors.New("product is not deleted")
+
+var (
+    // ErrProductNotFound is returned by AdjustStock for a product that does
+    // not exist or is deleted.
+    ErrProductNotFound = errors.New("product not found")
+    // ErrVariantNotFound is returned by AdjustStock for an unknown SKU.
+    ErrVariantNotFound = errors.New("variant not found")
+    // ErrSKURequired is returned by AdjustStock when no SKU is given for a
+    // product with several variants.
+    ErrSKURequired = errors.New("sku is required for a product with several variants")
+    // ErrAlreadyApplied is returned by AdjustOrderStock when the order
+    // already holds the stock an order movement would take, holds none for
+    // a cancellation to give back, or the return was restocked already.
+    ErrAlreadyApplied = errors.New("order stock already settled")
+)
+
+// ErrTooManyImages is returned by AddImage when the product already has the
+// maximum number of images.
+var ErrTooManyImages = errors.New("product has too many images")
+
 type ProductRepository interface {
     Create(ctx context.Context, p *model.Product) (string, error)
     GetByID(ctx context.Context, id string) (*model.Product, error)
-    Update(ctx context.Context, p *model.Product) error
+    // GetBySKU returns the product, deleted or not, with a variant with the
+    // given SKU.
+    GetBySKU(ctx context.Context, sku string) (*model.Product, error)
+    // GetByName returns the product, deleted or not, with the given name.
+    GetByName(ctx context.Context, name string) (*model.Product, error)
+    // Update writes the given fields of p (all fields when fields is empty)
+    // and returns the product as committed, with the new version. If
+    // p.Version is set the write only succeeds if the stored version still
+    // equals it.
+    Update(ctx context.Context, p *model.Product, fields []string) (*model.Product, error)
+    // Delete soft-deletes the product: it stays readable through GetByID but
+    // is excluded from listings, updates and stock changes.
     Delete(ctx context.Context, id string) error
-    List(ctx context.Context, category string, page, limit int32) ([]*model.Product, error)
-    DecreaseStock(ctx context.Context, productID string, quantity int32) error
+    // Restore undoes Delete and returns the product as committed.
+    Restore(ctx context.Context, id string) (*model.Product, error)
+    // PurgeDeleted permanently removes products deleted before the given time
+    // and returns them.
+    PurgeDeleted(ctx context.Context, before time.Time) ([]*model.Product, error)
+    // List returns a page of live products in any of categoryIDs (all
+    // categories when empty), only those in stock if inStock.
+    List(ctx context.Context, categoryIDs []string, page, limit int32, sort string, inStock bool) ([]*model.Product, error)
+    // Iterate calls fn for every live product in any of categoryIDs (all
+    // categories when empty), in ID order, until fn returns an error.
+    Iterate(ctx context.Context, categoryIDs []string, fn func(*model.Product) error) error
+    // CountByCategory counts the products, deleted ones included, that
+    // reference the category.
+    CountByCategory(ctx context.Context, categoryID string) (int64, error)
+    // UpsertVariant adds v to the product, with no stock, or updates the
+    // attributes and price override of the variant with v's SKU.
+    UpsertVariant(ctx context.Context, productID string, v model.Variant) (*model.Product, error)
+    // AddImage appends img to the product's images and returns the product
+    // as committed, or ErrTooManyImages.
+    AddImage(ctx context.Context, productID string, img model.Image) (*model.Product, error)
+    // RemoveImage removes an image and returns the product as committed and
+    // the removed image.
+    RemoveImage(ctx context.Context, productID, imageID string) (*model.Product, *model.Image, error)
+    // AdjustStock applies m.Delta to the stock of the variant m.SKU (which
+    // may be empty for a product with a single variant) and appends m to the
+    // stock ledger in one transaction. It fills in m's ID, SKU, StockAfter and
+    // CreatedAt and returns the product as committed.
+    AdjustStock(ctx context.Context, m *model.StockMovement) (*model.Product, error)
+    // AdjustOrderStock applies m, an order, cancellation or return movement
+    // referencing an order, at most once per taking of the stock: an order
+    // movement only while the order holds none of the variant's stock, a
+    // cancellation only while it holds some, all of which it gives back,
+    // setting m.Delta, and a return, whose Note names it, only once per
+    // variant. Otherwise it returns ErrAlreadyApplied.
+    AdjustOrderStock(ctx context.Context, m *model.StockMovement) (*model.Product, error)
+    // ListStockMovements returns a page of a product's ledger, newest first.
+    ListStockMovements(ctx context.Context, productID string, page, limit int32) ([]*model.StockMovement, error)
+    // ListLowStock returns products at or below their reorder level, lowest
+    // stock first.
+    ListLowStock(ctx context.Context, page, limit int32) ([]*model.Product, error)
 }
 
@@ -2,16 +2,20 @@ package testing
 
 import (
     "context"
+    "errors"
     "log"
     "os"
     "testing"
     "time"
 
     "inventory-service/internal/model"
-    "inventory-service/internal/redis"
     "inventory-service/internal/repository"
     "inventory-service/internal/usecase"
 
+    "golang/pkg/cache"
+    "golang/pkg/money"
+    "github.com/redis/go-redis/v9"
+
     "go.mongodb.org/mongo-driver/mongo"
     "go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -19,6 +23,7 @@ import (
 var (
     testRepo   repository.ProductRepository
     productUc  *usecase.ProductUsecase
+    categoryUc *usecase.CategoryUsecase
     mongoClient *mongo.Client
 )
 
@@ -34,8 +39,8 @@ func TestMain(m *testing.M) {
     mongoClient = client
 
     // Redis инициализациясы
-    err = redis.InitRedisWithParams("localhost:6379", "", 0)
-    if err != nil {
+    rdb := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+    if err := rdb.Ping(ctx).Err(); err != nil {
         log.Fatalf("Redis инициализациясы сәтсіз: %v", err)
     }
 
@@ -43,14 +48,19 @@ func TestMain(m *testing.M) {
     coll := db.Collection("products")
 
     // Репозиторий жасау
-    testRepo = repository.NewMongoProductRepository(coll)
-    productUc = usecase.NewProductUsecase(testRepo)
+    testRepo = repository.NewMongoProductRepository(coll, db.Collection("stock_movements"))
+    categoryRepo := repository.NewMongoCategoryRepository(db.Collection("categories"))
+    listVersion := cache.NewRedisVersion(rdb, "test_products")
+    productUc = usecase.NewProductUsecase(testRepo, categoryRepo, cache.NewRedis(rdb), listVersion, &recordingPublisher{}, nil, "USD")
+    categoryUc = usecase.NewCategoryUsecase(categoryRepo, testRepo, listVersion)
 
     // Тесттерді іске қосу
     code := m.Run()
 
     // Тазалау
     _ = coll.Drop(context.Background())
+    _ = db.Collection("stock_movements").Drop(context.Background())
+    _ = db.Collection("categories").Drop(context.Background())
     _ = client.Disconnect(context.Background())
 
     os.Exit(code)
@@ -59,13 +69,23 @@ func TestMain(m *testing.M) {
 func TestIntegrationProductUsecase(t *testing.T) {
     ctx := context.Background()
 
+    // 0. Санаттар ағашы: өнім ішкі санатта болады
+    parent, err := categoryUc.CreateCategory(ctx, &model.Category{Name: "Test Category"})
+    if err != nil {
+        t.Fatalf("Санат құру сәтсіз: %v", err)
+    }
+    child, err := categoryUc.CreateCategory(ctx, &model.Category{Name: "Test Subcategory", ParentID: parent.ID})
+    if err != nil || len(child.Ancestors) != 1 {
+        t.Fatalf("Ішкі санат құру сәтсіз: %+v, %v", child, err)
+    }
+
     // 1. Жаңа өнім құру
     prod := &model.Product{
         Name:        "Test Product",
         Description: "Test Description",
-        Category:    "TestCategory",
+        CategoryID:  child.ID,
         Stock:       100,
-        Price:       50.5,
+        Price:       money.New(5050, "USD"),
     }
 
     id, err := productUc.CreateProduct(ctx, prod)
@@ -97,11 +117,12 @@ func TestIntegrationProductUsecase(t *testing.T) {
         t.Fatalf("Redis-тен алынған өнім дұрыс емес: %+v", productFromCache)
     }
 
-    // 4. Өнімді жаңарту
+    // 4. Өнімді жаңарту (толық жаңарту қорды өзгертпейді)
     prod.Description = "Updated Description"
     prod.Stock = 90
-    prod.Price = 45.0
-    err = productUc.UpdateProduct(ctx, prod)
+    prod.Price = money.New(4500, "USD")
+    prod.Version = productFromRepo.Version
+    _, err = productUc.UpdateProduct(ctx, prod, nil)
     if err != nil {
         t.Fatalf("Өнімді жаңарту сәтсіз: %v", err)
     }
@@ -111,21 +132,24 @@ func TestIntegrationProductUsecase(t *testing.T) {
     if err != nil {
         t.Fatalf("Жаңартылған өнімді алу сәтсіз: %v", err)
     }
-    if updatedProduct.Description != "Updated Description" || updatedProduct.Stock != 90 || updatedProduct.Price != 45.0 {
+    if updatedProduct.Description != "Updated Description" || updatedProduct.Stock != 100 || updatedProduct.Price != money.New(4500, "USD") {
         t.Fatalf("Жаңартылған өнім дұрыс емес: %+v", updatedProduct)
     }
 
-    // 6. Тізімін алу
-    products, err := productUc.ListProducts(ctx, "TestCategory", 1, 10)
+    // 6. Тізімін алу: ата-санат бойынша ішкі санаттағы өнім де шығады
+    products, err := productUc.ListProducts(ctx, "test-category", 1, 10, "", false)
     if err != nil {
         t.Fatalf("Өнімдер тізімін алу сәтсіз: %v", err)
     }
     if len(products) == 0 {
         t.Fatal("Өнімдер тізімі бос")
     }
+    if err := categoryUc.DeleteCategory(ctx, child.ID); err != usecase.ErrCategoryInUse {
+        t.Fatalf("Өнімі бар санат өшірілмеуі тиіс: %v", err)
+    }
 
     // 7. Қойманы азайту
-    err = productUc.DecreaseStock(ctx, id, 10)
+    _, err = productUc.DecreaseStock(ctx, id, "", 10, "order-1")
     if err != nil {
         t.Fatalf("Қойманы азайту сәтсіз: %v", err)
     }
@@ -137,15 +161,85 @@ func TestIntegrationProductUsecase(t *testing.T) {
     if decreasedProduct.Stock != 80 {
         t.Fatalf("Қойма дұрыс азайтылмады, күтілген: 80, шыққан: %d", decreasedProduct.Stock)
     }
+    // Сол тапсырыс қорды екінші рет алмайды
+    if _, err := productUc.DecreaseStock(ctx, id, "", 10, "order-1"); !errors.Is(err, repository.ErrAlreadyApplied) {
+        t.Fatalf("Тапсырыс қорды қайта алмауы тиіс: %v", err)
+    }
 
-    // 8. Өнімді өшіру
+    // 8. Қойманы толықтыру және қозғалыстар журналын тексеру
+    _, err = productUc.AdjustStock(ctx, &model.StockMovement{ProductID: id, Delta: 5, Reason: model.ReasonRestock})
+    if err != nil {
+        t.Fatalf("Қойманы толықтыру сәтсіз: %v", err)
+    }
+    movements, err := productUc.GetStockMovements(ctx, id, 1, 10)
+    if err != nil {
+        t.Fatalf("Қойма қозғалыстарын алу сәтсіз: %v", err)
+    }
+    // Бастапқы қор журналды ашады
+    if len(movements) != 3 || movements[0].Reason != model.ReasonRestock || movements[0].StockAfter != 85 ||
+        movements[2].Reason != model.ReasonInitial || movements[2].Delta != 100 {
+        t.Fatalf("Қойма қозғалыстары дұрыс емес: %+v", movements)
+    }
+
+    // 9. Жаңа нұсқа қосу: қоры нөлден басталады, толықтыру SKU бойынша
+    withVariant, err := productUc.UpsertVariant(ctx, id, model.Variant{SKU: "TEST-L", Attributes: map[string]string{"size": "L"}})
+    if err != nil || len(withVariant.Variants) != 2 {
+        t.Fatalf("Нұсқа қосу сәтсіз: %+v, %v", withVariant, err)
+    }
+    restocked, err := productUc.AdjustStock(ctx, &model.StockMovement{ProductID: id, SKU: "TEST-L", Delta: 3, Reason: model.ReasonRestock})
+    if err != nil || restocked.Stock != 88 {
+        t.Fatalf("Нұсқаны толықтыру сәтсіз: %+v, %v", restocked, err)
+    }
+    if _, err := productUc.DecreaseStock(ctx, id, "", 1, "order-2"); err == nil {
+        t.Fatal("Бірнеше нұсқасы бар өнім үшін SKU талап етілуі тиіс")
+    }
+
+    // 10. Өнімді өшіру
     err = productUc.DeleteProduct(ctx, id)
     if err != nil {
         t.Fatalf("Өнімді өшіру сәтсіз: %v", err)
     }
 
+    // Өшірілген өнім тарихи тапсырыстар үшін ID бойынша қолжетімді, бірақ тізімде жоқ
     deletedProduct, err := productUc.GetProduct(ctx, id)
-    if err == nil && deletedProduct != nil {
-        t.Fatalf("Өшіру сәтті болған соң өнім әлі де табылады: %+v", deletedProduct)
+    if err != nil || deletedProduct.DeletedAt == nil {
+        t.Fatalf("Өшірілген өнім белгіленбеген: %+v, %v", deletedProduct, err)
+    }
+    products, err = productUc.ListProducts(ctx, "test-category", 1, 10, "", false)
+    if err != nil {
+        t.Fatalf("Өнімдер тізімін алу сәтсіз: %v", err)
+    }
+    if len(products) != 0 {
+        t.Fatalf("Өшірілген өнім тізімде әлі де бар: %+v", products)
+    }
+
+    // 11. Өнімді қалпына келтіру
+    restored, err := productUc.RestoreProduct(ctx, id)
+    if err != nil || restored.DeletedAt != nil {
+        t.Fatalf("Өнімді қалпына келтіру сәтсіз: %+v, %v", restored, err)
+    }
+
+    // 12. Жаппай импорт: бар нұсқа SKU бойынша жаңартылады, жаңа атау өнім құрады
+    stock := int32(10)
+    imp := productUc.NewImport(false, "staff-1")
+    imp.Add(ctx, model.ImportRow{Line: 2, SKU: "TEST-L", Name: "Test Product", Description: "Imported", Category: "test-subcategory", Price: "45", Stock: &stock})
+    imp.Add(ctx, model.ImportRow{Line: 3, SKU: "IMP-1", Name: "Imported Product", Description: "Imported", Category: "test-subcategory", Price: "5", Stock: &stock})
+    report := imp.Finish(ctx)
+    if report.Created != 1 || report.Updated != 1 || report.Failed != 0 {
+        t.Fatalf("Импорт есебі дұрыс емес: %+v", report)
+    }
+    imported, err := productUc.GetProduct(ctx, id)
+    if err != nil || imported.Description != "Imported" || imported.Stock != 95 {
+        t.Fatalf("Импорттан кейінгі өнім дұрыс емес: %+v, %v", imported, err)
+    }
+
+    // 13. Экспорт ата-санат бойынша екі өнімді де қайтарады
+    var exported int
+    err = productUc.ExportProducts(ctx, "test-category", func(p *model.Product) error {
+        exported++
+        return nil
+    })
+    if err != nil || exported != 2 {
+        t.Fatalf("Экспорт сәтсіз: %d өнім, %v", exported, err)
     }
 }
@@ -1,12 +1,23 @@
 package testing
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
 	"testing"
+	"time"
 
 	"inventory-service/internal/model"
+	"inventory-service/internal/repository"
 	"inventory-service/internal/usecase"
 
+	"golang/pkg/blob"
+	"golang/pkg/cache"
+	"golang/pkg/money"
+
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -29,9 +40,28 @@ func (m *MockProductRepo) GetByID(ctx context.Context, id string) (*model.Produc
 	return args.Get(0).(*model.Product), args.Error(1)
 }
 
-func (m *MockProductRepo) Update(ctx context.Context, p *model.Product) error {
-	args := m.Called(ctx, p)
-	return args.Error(0)
+func (m *MockProductRepo) GetBySKU(ctx context.Context, sku string) (*model.Product, error) {
+	args := m.Called(ctx, sku)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) GetByName(ctx context.Context, name string) (*model.Product, error) {
+	args := m.Called(ctx, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) Update(ctx context.Context, p *model.Product, fields []string) (*model.Product, error) {
+	args := m.Called(ctx, p, fields)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Product), args.Error(1)
 }
 
 func (m *MockProductRepo) Delete(ctx context.Context, id string) error {
@@ -39,28 +69,187 @@ func (m *MockProductRepo) Delete(ctx context.Context, id string) error {
 	return args.Error(0)
 }
 
-func (m *MockProductRepo) List(ctx context.Context, category string, page, limit int32) ([]*model.Product, error) {
-	args := m.Called(ctx, category, page, limit)
+func (m *MockProductRepo) UpsertVariant(ctx context.Context, productID string, v model.Variant) (*model.Product, error) {
+	args := m.Called(ctx, productID, v)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) Restore(ctx context.Context, id string) (*model.Product, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) PurgeDeleted(ctx context.Context, before time.Time) ([]*model.Product, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).([]*model.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) AddImage(ctx context.Context, productID string, img model.Image) (*model.Product, error) {
+	args := m.Called(ctx, productID, img)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) RemoveImage(ctx context.Context, productID, imageID string) (*model.Product, *model.Image, error) {
+	args := m.Called(ctx, productID, imageID)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(*model.Product), args.Get(1).(*model.Image), args.Error(2)
+}
+
+func (m *MockProductRepo) List(ctx context.Context, categoryIDs []string, page, limit int32, sort string, inStock bool) ([]*model.Product, error) {
+	args := m.Called(ctx, categoryIDs, page, limit, sort, inStock)
+	return args.Get(0).([]*model.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) Iterate(ctx context.Context, categoryIDs []string, fn func(*model.Product) error) error {
+	args := m.Called(ctx, categoryIDs, fn)
+	for _, p := range args.Get(0).([]*model.Product) {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockProductRepo) CountByCategory(ctx context.Context, categoryID string) (int64, error) {
+	args := m.Called(ctx, categoryID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockProductRepo) AdjustStock(ctx context.Context, sm *model.StockMovement) (*model.Product, error) {
+	args := m.Called(ctx, sm)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) AdjustOrderStock(ctx context.Context, sm *model.StockMovement) (*model.Product, error) {
+	args := m.Called(ctx, sm)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Product), args.Error(1)
+}
+
+func (m *MockProductRepo) ListLowStock(ctx context.Context, page, limit int32) ([]*model.Product, error) {
+	args := m.Called(ctx, page, limit)
 	return args.Get(0).([]*model.Product), args.Error(1)
 }
 
-func (m *MockProductRepo) DecreaseStock(ctx context.Context, productID string, quantity int32) error {
-	args := m.Called(ctx, productID, quantity)
+func (m *MockProductRepo) ListStockMovements(ctx context.Context, productID string, page, limit int32) ([]*model.StockMovement, error) {
+	args := m.Called(ctx, productID, page, limit)
+	return args.Get(0).([]*model.StockMovement), args.Error(1)
+}
+
+type MockCategoryRepo struct {
+	mock.Mock
+}
+
+func (m *MockCategoryRepo) Create(ctx context.Context, c *model.Category) (string, error) {
+	args := m.Called(ctx, c)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockCategoryRepo) GetByID(ctx context.Context, id string) (*model.Category, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Category), args.Error(1)
+}
+
+func (m *MockCategoryRepo) GetBySlug(ctx context.Context, slug string) (*model.Category, error) {
+	args := m.Called(ctx, slug)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Category), args.Error(1)
+}
+
+func (m *MockCategoryRepo) List(ctx context.Context, parentID string) ([]*model.Category, error) {
+	args := m.Called(ctx, parentID)
+	return args.Get(0).([]*model.Category), args.Error(1)
+}
+
+func (m *MockCategoryRepo) Update(ctx context.Context, c *model.Category) (*model.Category, error) {
+	args := m.Called(ctx, c)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Category), args.Error(1)
+}
+
+func (m *MockCategoryRepo) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
 	return args.Error(0)
 }
 
+func (m *MockCategoryRepo) DescendantIDs(ctx context.Context, id string) ([]string, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+// knownCategory returns a category repository holding category c1.
+func knownCategory() *MockCategoryRepo {
+	categories := new(MockCategoryRepo)
+	categories.On("GetByID", mock.Anything, "c1").Return(&model.Category{ID: "c1", Name: "Cat", Slug: "cat"}, nil)
+	return categories
+}
+
+func newBlobStore(t *testing.T) *blob.FileStore {
+	s, err := blob.NewFileStore(t.TempDir(), "http://media.test/media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// testPNG encodes a w×h opaque PNG.
+func testPNG(w, h int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// recordingPublisher collects published stock alerts.
+type recordingPublisher struct {
+	alerts []*model.StockAlert
+}
+
+func (p *recordingPublisher) PublishStockAlert(ctx context.Context, alert *model.StockAlert) error {
+	p.alerts = append(p.alerts, alert)
+	return nil
+}
+
 // Тесттер
 
 func TestCreateProduct_Success(t *testing.T) {
 	mockRepo := new(MockProductRepo)
-	uc := usecase.NewProductUsecase(mockRepo)
+	uc := usecase.NewProductUsecase(mockRepo, knownCategory(), cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
 
 	p := &model.Product{
 		Name:        "Product1",
 		Description: "Desc",
-		Category:    "Cat",
+		CategoryID:  "c1",
 		Stock:       10,
-		Price:       100,
+		Price:       money.New(10000, "USD"),
 	}
 
 	mockRepo.On("Create", mock.Anything, p).Return("productID123", nil)
@@ -72,15 +261,677 @@ func TestCreateProduct_Success(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestCreateProduct_PriceCurrency(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, knownCategory(), cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+	ctx := context.Background()
+
+	p := &model.Product{Name: "Product1", Description: "Desc", CategoryID: "c1", Price: money.Money{Amount: 1999}}
+	mockRepo.On("Create", mock.Anything, p).Return("p1", nil)
+
+	_, err := uc.CreateProduct(ctx, p)
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(1999, "USD"), p.Price)
+
+	_, err = uc.CreateProduct(ctx, &model.Product{Name: "Product2", Description: "Desc", CategoryID: "c1", Price: money.New(1999, "EUR")})
+	assert.EqualError(t, err, "price must be in USD, got EUR")
+	mockRepo.AssertNumberOfCalls(t, "Create", 1)
+}
+
 func TestCreateProduct_InvalidInput(t *testing.T) {
-	uc := usecase.NewProductUsecase(nil)
+	uc := usecase.NewProductUsecase(nil, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
 
 	_, err := uc.CreateProduct(context.Background(), &model.Product{Name: ""})
 	assert.Error(t, err)
 
-	_, err = uc.CreateProduct(context.Background(), &model.Product{Name: "Valid", Description: "", Category: "Cat"})
+	_, err = uc.CreateProduct(context.Background(), &model.Product{Name: "Valid", Description: "", CategoryID: "c1"})
 	assert.Error(t, err)
 
-	_, err = uc.CreateProduct(context.Background(), &model.Product{Name: "Valid", Description: "Desc", Category: "Cat", Stock: -1})
+	_, err = uc.CreateProduct(context.Background(), &model.Product{Name: "Valid", Description: "Desc", CategoryID: "c1", Stock: -1})
 	assert.Error(t, err)
 }
+
+func TestGetProduct_CachesResult(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+
+	p := &model.Product{ID: "p1", Name: "Product1"}
+	mockRepo.On("GetByID", mock.Anything, "p1").Return(p, nil).Once()
+
+	first, err := uc.GetProduct(context.Background(), "p1")
+	assert.NoError(t, err)
+	second, err := uc.GetProduct(context.Background(), "p1")
+	assert.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	mockRepo.AssertNumberOfCalls(t, "GetByID", 1)
+}
+
+func TestListProducts_CachedUntilProductChanges(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	categories := new(MockCategoryRepo)
+	uc := usecase.NewProductUsecase(mockRepo, categories, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+	ctx := context.Background()
+
+	// Listing by slug includes the subcategories.
+	categories.On("GetBySlug", mock.Anything, "cat").Return(&model.Category{ID: "c1", Slug: "cat"}, nil)
+	categories.On("DescendantIDs", mock.Anything, "c1").Return([]string{"c2"}, nil)
+	list := []*model.Product{{ID: "p1", Name: "Product1"}}
+	mockRepo.On("List", mock.Anything, []string{"c1", "c2"}, int32(1), int32(10), "-price", false).Return(list, nil)
+	mockRepo.On("AdjustOrderStock", mock.Anything, mock.Anything).Return(&model.Product{ID: "p1", Version: 2}, nil)
+
+	_, err := uc.ListProducts(ctx, "cat", 1, 10, "-price", false)
+	assert.NoError(t, err)
+	_, err = uc.ListProducts(ctx, "cat", 1, 10, "-price", false)
+	assert.NoError(t, err)
+	mockRepo.AssertNumberOfCalls(t, "List", 1)
+
+	_, err = uc.DecreaseStock(ctx, "p1", "", 1, "o1")
+	assert.NoError(t, err)
+
+	_, err = uc.ListProducts(ctx, "cat", 1, 10, "-price", false)
+	assert.NoError(t, err)
+	mockRepo.AssertNumberOfCalls(t, "List", 2)
+}
+
+func TestListProducts_InvalidSort(t *testing.T) {
+	uc := usecase.NewProductUsecase(nil, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+
+	_, err := uc.ListProducts(context.Background(), "", 1, 10, "password", false)
+	assert.Error(t, err)
+}
+
+func TestUpdateProduct_WritesCommittedVersionThrough(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, knownCategory(), cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+	ctx := context.Background()
+
+	p := &model.Product{ID: "p1", Name: "Product1", Description: "Desc", CategoryID: "c1", Stock: 5, Price: money.New(1000, "USD"), Version: 3}
+	committed := *p
+	committed.Version = 4
+	// A full update leaves the stock alone.
+	mockRepo.On("Update", mock.Anything, p, []string{"name", "description", "category_id", "price", "reorder_level"}).Return(&committed, nil)
+
+	updated, err := uc.UpdateProduct(ctx, p, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), updated.Version)
+
+	// Served from the cache without touching the repository.
+	cached, err := uc.GetProduct(ctx, "p1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), cached.Version)
+	mockRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+}
+
+func TestUpdateProduct_DoesNotCacheOverNewerVersion(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, knownCategory(), cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+	ctx := context.Background()
+
+	// Another writer's version 5 is already cached when this update, which
+	// committed version 4, gets to write through.
+	newest := &model.Product{ID: "p1", Name: "Newest", Version: 5}
+	mockRepo.On("GetByID", mock.Anything, "p1").Return(newest, nil)
+	_, err := uc.GetProduct(ctx, "p1")
+	assert.NoError(t, err)
+
+	p := &model.Product{ID: "p1", Name: "Older", Description: "Desc", CategoryID: "c1", Price: money.New(1000, "USD"), Version: 3}
+	committed := *p
+	committed.Version = 4
+	mockRepo.On("Update", mock.Anything, p, mock.Anything).Return(&committed, nil)
+	_, err = uc.UpdateProduct(ctx, p, nil)
+	assert.NoError(t, err)
+
+	// The key was dropped rather than overwritten, so the next read reloads.
+	cached, err := uc.GetProduct(ctx, "p1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), cached.Version)
+	mockRepo.AssertNumberOfCalls(t, "GetByID", 2)
+}
+
+func TestUpdateProduct_VersionConflict(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, knownCategory(), cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+
+	p := &model.Product{ID: "p1", Name: "Product1", Description: "Desc", CategoryID: "c1", Version: 1}
+	mockRepo.On("Update", mock.Anything, p, mock.Anything).Return(nil, repository.ErrVersionConflict)
+
+	_, err := uc.UpdateProduct(context.Background(), p, nil)
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+}
+
+func TestUpdateProduct_RequiresVersion(t *testing.T) {
+	uc := usecase.NewProductUsecase(nil, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+
+	_, err := uc.UpdateProduct(context.Background(), &model.Product{ID: "p1", Name: "Product1", Description: "Desc", CategoryID: "c1"}, nil)
+	assert.EqualError(t, err, "version is required")
+}
+
+func TestUpdateProduct_MaskedUpdateWritesOnlyMaskedFields(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+
+	// Only the price is sent; the empty name must not fail validation.
+	p := &model.Product{ID: "p1", Price: money.New(1250, "USD")}
+	mockRepo.On("Update", mock.Anything, p, []string{"price"}).
+		Return(&model.Product{ID: "p1", Name: "Product1", Price: money.New(1250, "USD"), Version: 2}, nil)
+
+	updated, err := uc.UpdateProduct(context.Background(), p, []string{"price"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Product1", updated.Name)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateProduct_MaskValidation(t *testing.T) {
+	uc := usecase.NewProductUsecase(nil, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+
+	_, err := uc.UpdateProduct(context.Background(), &model.Product{ID: "p1"}, []string{"name"})
+	assert.EqualError(t, err, "name is required")
+
+	_, err = uc.UpdateProduct(context.Background(), &model.Product{ID: "p1"}, []string{"version"})
+	assert.Error(t, err)
+
+	// Stock goes through AdjustStock, so the ledger records it.
+	_, err = uc.UpdateProduct(context.Background(), &model.Product{ID: "p1", Stock: 5}, []string{"stock"})
+	assert.ErrorIs(t, err, usecase.ErrStockNotUpdatable)
+}
+
+func TestDecreaseStock_RecordsOrderMovement(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+
+	want := &model.StockMovement{ProductID: "p1", Delta: -3, Reason: model.ReasonOrder, Reference: "o1"}
+	mockRepo.On("AdjustOrderStock", mock.Anything, want).Return(&model.Product{ID: "p1", Stock: 7, Version: 2}, nil)
+
+	p, err := uc.DecreaseStock(context.Background(), "p1", "", 3, "o1")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(7), p.Stock)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReleaseStock_GivesBackWhatTheOrderHolds(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+	ctx := context.Background()
+
+	// The repository works out the delta from the order's movements.
+	want := &model.StockMovement{ProductID: "p1", SKU: "S-M", Reason: model.ReasonCancellation, Reference: "o1"}
+	mockRepo.On("AdjustOrderStock", mock.Anything, want).Return(&model.Product{ID: "p1", Stock: 10, Version: 3}, nil).Once()
+	mockRepo.On("AdjustOrderStock", mock.Anything, want).Return(nil, repository.ErrAlreadyApplied).Once()
+
+	p, err := uc.ReleaseStock(ctx, "p1", "S-M", "o1")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(10), p.Stock)
+
+	// A second cancellation finds nothing to give back.
+	_, err = uc.ReleaseStock(ctx, "p1", "S-M", "o1")
+	assert.ErrorIs(t, err, repository.ErrAlreadyApplied)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRestockReturn_OncePerReturn(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+	ctx := context.Background()
+
+	want := &model.StockMovement{ProductID: "p1", SKU: "S-M", Delta: 2, Reason: model.ReasonReturn, Reference: "o1", Note: "return r1"}
+	mockRepo.On("AdjustOrderStock", mock.Anything, want).Return(&model.Product{ID: "p1", Stock: 12, Version: 3}, nil).Once()
+	mockRepo.On("AdjustOrderStock", mock.Anything, want).Return(nil, repository.ErrAlreadyApplied).Once()
+
+	p, err := uc.RestockReturn(ctx, "p1", "S-M", 2, "o1", "r1")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(12), p.Stock)
+
+	// The same return announced again restocks nothing.
+	_, err = uc.RestockReturn(ctx, "p1", "S-M", 2, "o1", "r1")
+	assert.ErrorIs(t, err, repository.ErrAlreadyApplied)
+	_, err = uc.RestockReturn(ctx, "p1", "S-M", 2, "o1", "")
+	assert.Error(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAdjustStock_ReasonAndSignValidation(t *testing.T) {
+	uc := usecase.NewProductUsecase(nil, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+	ctx := context.Background()
+
+	_, err := uc.AdjustStock(ctx, &model.StockMovement{ProductID: "p1", Delta: 5, Reason: "gift"})
+	assert.Error(t, err)
+	_, err = uc.AdjustStock(ctx, &model.StockMovement{ProductID: "p1", Delta: -5, Reason: model.ReasonRestock})
+	assert.EqualError(t, err, "delta must be positive for restock")
+	_, err = uc.AdjustStock(ctx, &model.StockMovement{ProductID: "p1", Delta: 2, Reason: model.ReasonDamage})
+	assert.EqualError(t, err, "delta must be negative for damage")
+	_, err = uc.AdjustStock(ctx, &model.StockMovement{ProductID: "p1", Reason: model.ReasonCorrection})
+	assert.Error(t, err)
+}
+
+func TestAdjustStock_InsufficientStockDoesNotTouchCache(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+	ctx := context.Background()
+
+	mockRepo.On("GetByID", mock.Anything, "p1").Return(&model.Product{ID: "p1", Stock: 1, Version: 1}, nil).Once()
+	mockRepo.On("AdjustStock", mock.Anything, mock.Anything).Return(nil, repository.ErrInsufficientStock)
+
+	_, err := uc.GetProduct(ctx, "p1")
+	assert.NoError(t, err)
+	_, err = uc.AdjustStock(ctx, &model.StockMovement{ProductID: "p1", Delta: -2, Reason: model.ReasonCorrection})
+	assert.ErrorIs(t, err, repository.ErrInsufficientStock)
+
+	cached, err := uc.GetProduct(ctx, "p1")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), cached.Stock)
+	mockRepo.AssertNumberOfCalls(t, "GetByID", 1)
+}
+
+func TestDecreaseStock_AlertsWhenCrossingReorderLevel(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	alerts := &recordingPublisher{}
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), alerts, nil, "USD")
+	ctx := context.Background()
+
+	// 7 -> 5 crosses a reorder level of 5.
+	mockRepo.On("AdjustOrderStock", mock.Anything, mock.MatchedBy(func(m *model.StockMovement) bool { return m.Delta == -2 })).
+		Return(&model.Product{ID: "p1", Stock: 5, ReorderLevel: 5, Version: 2}, nil).Once()
+	// 5 -> 4 is already below it.
+	mockRepo.On("AdjustOrderStock", mock.Anything, mock.MatchedBy(func(m *model.StockMovement) bool { return m.Delta == -1 })).
+		Return(&model.Product{ID: "p1", Stock: 4, ReorderLevel: 5, Version: 3}, nil).Once()
+	// 4 -> 0 sells out.
+	mockRepo.On("AdjustOrderStock", mock.Anything, mock.MatchedBy(func(m *model.StockMovement) bool { return m.Delta == -4 })).
+		Return(&model.Product{ID: "p1", Stock: 0, ReorderLevel: 5, Version: 4}, nil).Once()
+
+	for _, qty := range []int32{2, 1, 4} {
+		_, err := uc.DecreaseStock(ctx, "p1", "", qty, "o1")
+		assert.NoError(t, err)
+	}
+
+	if assert.Len(t, alerts.alerts, 2) {
+		assert.Equal(t, model.AlertLowStock, alerts.alerts[0].Kind)
+		assert.Equal(t, int32(5), alerts.alerts[0].Stock)
+		assert.Equal(t, "o1", alerts.alerts[0].Reference)
+		assert.Equal(t, model.AlertOutOfStock, alerts.alerts[1].Kind)
+	}
+}
+
+func TestAdjustStock_RestockDoesNotAlert(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	alerts := &recordingPublisher{}
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), alerts, nil, "USD")
+
+	mockRepo.On("AdjustStock", mock.Anything, mock.Anything).Return(&model.Product{ID: "p1", Stock: 3, ReorderLevel: 5, Version: 2}, nil)
+
+	_, err := uc.AdjustStock(context.Background(), &model.StockMovement{ProductID: "p1", Delta: 3, Reason: model.ReasonRestock})
+	assert.NoError(t, err)
+	assert.Empty(t, alerts.alerts)
+}
+
+func TestDeleteAndRestoreProduct_RefreshesCache(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+	ctx := context.Background()
+
+	deletedAt := time.Now()
+	mockRepo.On("GetByID", mock.Anything, "p1").Return(&model.Product{ID: "p1", Version: 1}, nil).Once()
+	mockRepo.On("GetByID", mock.Anything, "p1").Return(&model.Product{ID: "p1", Version: 2, DeletedAt: &deletedAt}, nil).Once()
+	mockRepo.On("Delete", mock.Anything, "p1").Return(nil)
+	mockRepo.On("Restore", mock.Anything, "p1").Return(&model.Product{ID: "p1", Version: 3}, nil)
+
+	_, err := uc.GetProduct(ctx, "p1")
+	assert.NoError(t, err)
+	assert.NoError(t, uc.DeleteProduct(ctx, "p1"))
+
+	// Deleted products stay readable for order history.
+	deleted, err := uc.GetProduct(ctx, "p1")
+	assert.NoError(t, err)
+	assert.NotNil(t, deleted.DeletedAt)
+
+	restored, err := uc.RestoreProduct(ctx, "p1")
+	assert.NoError(t, err)
+	assert.Nil(t, restored.DeletedAt)
+
+	cached, err := uc.GetProduct(ctx, "p1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), cached.Version)
+	mockRepo.AssertNumberOfCalls(t, "GetByID", 2)
+}
+
+func TestPurgeDeleted_UsesRetention(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	blobs := newBlobStore(t)
+	lists := cache.NewLocalVersion()
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), lists, &recordingPublisher{}, blobs, "USD")
+	ctx := context.Background()
+
+	deletedAt := time.Now().Add(-72 * time.Hour)
+	mockRepo.On("GetByID", mock.Anything, "p1").Return(&model.Product{ID: "p1", DeletedAt: &deletedAt}, nil).Once()
+	_, err := uc.GetProduct(ctx, "p1")
+	assert.NoError(t, err)
+	mockRepo.On("GetByID", mock.Anything, "p1").Return(nil, errors.New("product not found"))
+
+	img := model.Image{ID: "i1", Key: "products/p1/i1.png", ThumbnailKey: "products/p1/i1_thumb.png"}
+	_ = blobs.Put(ctx, img.Key, []byte("png"), "image/png")
+	retention := 48 * time.Hour
+	mockRepo.On("PurgeDeleted", mock.Anything, mock.MatchedBy(func(before time.Time) bool {
+		return time.Since(before) >= retention && time.Since(before) < retention+time.Minute
+	})).Return([]*model.Product{{ID: "p1", Images: []model.Image{img}}, {ID: "p2"}}, nil)
+
+	n, err := uc.PurgeDeleted(ctx, retention)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	// Images of purged products are deleted with them.
+	_, err = blobs.Get(ctx, img.Key)
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+	// Nor are they served from the cache any longer.
+	_, err = uc.GetProduct(ctx, "p1")
+	assert.EqualError(t, err, "product not found")
+	assert.Equal(t, int64(1), lists.Current(ctx))
+
+	_, err = uc.PurgeDeleted(context.Background(), 0)
+	assert.Error(t, err)
+}
+
+func TestCreateProduct_VariantValidation(t *testing.T) {
+	uc := usecase.NewProductUsecase(nil, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+	ctx := context.Background()
+	base := model.Product{Name: "Shirt", Description: "Desc", CategoryID: "c1"}
+
+	p := base
+	p.Variants = []model.Variant{{SKU: "S-1", Stock: 1}, {SKU: "S-1", Stock: 2}}
+	_, err := uc.CreateProduct(ctx, &p)
+	assert.EqualError(t, err, "sku S-1 must be unique")
+
+	p = base
+	p.Variants = []model.Variant{{Stock: 1}}
+	_, err = uc.CreateProduct(ctx, &p)
+	assert.EqualError(t, err, "variant sku is required")
+
+	p = base
+	p.Variants = []model.Variant{{SKU: "S-1", Stock: -1}}
+	_, err = uc.CreateProduct(ctx, &p)
+	assert.Error(t, err)
+}
+
+func TestDecreaseStock_TargetsVariant(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+
+	mockRepo.On("AdjustOrderStock", mock.Anything, mock.MatchedBy(func(m *model.StockMovement) bool {
+		return m.SKU == "S-M" && m.Delta == -1
+	})).Return(&model.Product{ID: "p1", Stock: 9, Version: 2}, nil)
+
+	_, err := uc.DecreaseStock(context.Background(), "p1", "S-M", 1, "o1")
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestUpsertVariant_WritesThrough(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+	ctx := context.Background()
+
+	v := model.Variant{SKU: "S-L", Attributes: map[string]string{"size": "L"}}
+	mockRepo.On("UpsertVariant", mock.Anything, "p1", v).
+		Return(&model.Product{ID: "p1", Version: 5, Variants: []model.Variant{{SKU: "p1"}, v}}, nil)
+
+	_, err := uc.UpsertVariant(ctx, "p1", v)
+	assert.NoError(t, err)
+
+	cached, err := uc.GetProduct(ctx, "p1")
+	assert.NoError(t, err)
+	assert.Len(t, cached.Variants, 2)
+	mockRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+
+	_, err = uc.UpsertVariant(ctx, "p1", model.Variant{SKU: "S-L", PriceOverride: money.New(-1, "USD")})
+	assert.Error(t, err)
+	_, err = uc.UpsertVariant(ctx, "p1", model.Variant{SKU: "S-L", WeightGrams: -1})
+	assert.EqualError(t, err, "variant weight_grams cannot be negative")
+}
+
+func TestCreateProduct_UnknownCategory(t *testing.T) {
+	categories := new(MockCategoryRepo)
+	categories.On("GetByID", mock.Anything, "c9").Return(nil, errors.New("category not found"))
+	uc := usecase.NewProductUsecase(new(MockProductRepo), categories, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+
+	_, err := uc.CreateProduct(context.Background(), &model.Product{Name: "Shirt", Description: "Desc", CategoryID: "c9"})
+	assert.EqualError(t, err, "category_id must be an existing category")
+
+	_, err = uc.CreateProduct(context.Background(), &model.Product{Name: "Shirt", Description: "Desc"})
+	assert.EqualError(t, err, "category_id is required")
+}
+
+func TestSlugify(t *testing.T) {
+	assert.Equal(t, "smart-phones", model.Slugify("  Smart Phones!"))
+	assert.Equal(t, "t-shirts-kids", model.Slugify("T-Shirts (Kids)"))
+	assert.Equal(t, "ұялы-телефондар", model.Slugify("Ұялы телефондар"))
+	assert.Equal(t, "", model.Slugify("--"))
+}
+
+func TestCreateCategory_DerivesSlugAndAncestors(t *testing.T) {
+	repo := new(MockCategoryRepo)
+	uc := usecase.NewCategoryUsecase(repo, nil, cache.NewLocalVersion())
+
+	repo.On("GetByID", mock.Anything, "phones").
+		Return(&model.Category{ID: "phones", Ancestors: []string{"electronics"}}, nil)
+	repo.On("Create", mock.Anything, mock.Anything).Return("c1", nil)
+
+	c, err := uc.CreateCategory(context.Background(), &model.Category{Name: "Smart Phones", ParentID: "phones"})
+	assert.NoError(t, err)
+	assert.Equal(t, "smart-phones", c.Slug)
+	assert.Equal(t, []string{"electronics", "phones"}, c.Ancestors)
+
+	_, err = uc.CreateCategory(context.Background(), &model.Category{Name: "Phones", Slug: "Phones"})
+	assert.Error(t, err)
+}
+
+func TestUpdateCategory_RejectsMoveIntoOwnSubtree(t *testing.T) {
+	repo := new(MockCategoryRepo)
+	uc := usecase.NewCategoryUsecase(repo, nil, cache.NewLocalVersion())
+
+	repo.On("GetByID", mock.Anything, "c3").
+		Return(&model.Category{ID: "c3", Ancestors: []string{"c1", "c2"}}, nil)
+
+	_, err := uc.UpdateCategory(context.Background(), &model.Category{ID: "c1", Name: "Electronics", ParentID: "c3"})
+	assert.Error(t, err)
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
+
+func TestDeleteCategory_InUse(t *testing.T) {
+	repo := new(MockCategoryRepo)
+	products := new(MockProductRepo)
+	uc := usecase.NewCategoryUsecase(repo, products, cache.NewLocalVersion())
+	ctx := context.Background()
+
+	repo.On("DescendantIDs", mock.Anything, "parent").Return([]string{"child"}, nil)
+	assert.ErrorIs(t, uc.DeleteCategory(ctx, "parent"), usecase.ErrCategoryInUse)
+
+	repo.On("DescendantIDs", mock.Anything, "leaf").Return([]string(nil), nil)
+	products.On("CountByCategory", mock.Anything, "leaf").Return(int64(2), nil)
+	assert.ErrorIs(t, uc.DeleteCategory(ctx, "leaf"), usecase.ErrCategoryInUse)
+	repo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestAddImage_StoresOriginalAndThumbnail(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	blobs := newBlobStore(t)
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, blobs, "USD")
+	ctx := context.Background()
+
+	var stored model.Image
+	mockRepo.On("AddImage", mock.Anything, "p1", mock.MatchedBy(func(img model.Image) bool {
+		stored = img
+		return true
+	})).Return(&model.Product{ID: "p1", Version: 2}, nil)
+
+	_, err := uc.AddImage(ctx, "p1", testPNG(800, 400), "front")
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", stored.ContentType)
+	assert.Equal(t, int32(800), stored.Width)
+	assert.Equal(t, "front", stored.Alt)
+
+	thumb, err := blobs.Get(ctx, stored.ThumbnailKey)
+	assert.NoError(t, err)
+	cfg, err := png.DecodeConfig(bytes.NewReader(thumb))
+	assert.NoError(t, err)
+	assert.Equal(t, 320, cfg.Width)
+	assert.Equal(t, 160, cfg.Height)
+	assert.Equal(t, "http://media.test/media/"+stored.Key, uc.ImageURL(stored.Key))
+}
+
+func TestAddImage_Validation(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, newBlobStore(t), "USD")
+	ctx := context.Background()
+
+	_, err := uc.AddImage(ctx, "p1", []byte("<svg xmlns='http://www.w3.org/2000/svg'/>"), "")
+	assert.ErrorContains(t, err, "must be a JPEG, PNG or GIF")
+
+	_, err = uc.AddImage(ctx, "p1", nil, "")
+	assert.EqualError(t, err, "image is required")
+
+	_, err = uc.AddImage(ctx, "p1", make([]byte, 5<<20+1), "")
+	assert.ErrorContains(t, err, "at most 5 MiB")
+	mockRepo.AssertNotCalled(t, "AddImage", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAddImage_RemovesBlobsWhenProductUpdateFails(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	blobs := newBlobStore(t)
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, blobs, "USD")
+	ctx := context.Background()
+
+	var stored model.Image
+	mockRepo.On("AddImage", mock.Anything, "p1", mock.MatchedBy(func(img model.Image) bool {
+		stored = img
+		return true
+	})).Return(nil, repository.ErrTooManyImages)
+
+	_, err := uc.AddImage(ctx, "p1", testPNG(10, 10), "")
+	assert.ErrorIs(t, err, repository.ErrTooManyImages)
+	_, err = blobs.Get(ctx, stored.Key)
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+	_, err = blobs.Get(ctx, stored.ThumbnailKey)
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+}
+
+func TestDeleteImage_DeletesBlobs(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	blobs := newBlobStore(t)
+	uc := usecase.NewProductUsecase(mockRepo, nil, cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, blobs, "USD")
+	ctx := context.Background()
+
+	img := &model.Image{ID: "i1", Key: "products/p1/i1.png", ThumbnailKey: "products/p1/i1_thumb.png"}
+	_ = blobs.Put(ctx, img.Key, []byte("png"), "image/png")
+	_ = blobs.Put(ctx, img.ThumbnailKey, []byte("png"), "image/png")
+	mockRepo.On("RemoveImage", mock.Anything, "p1", "i1").Return(&model.Product{ID: "p1", Version: 3}, img, nil)
+
+	_, err := uc.DeleteImage(ctx, "p1", "i1")
+	assert.NoError(t, err)
+	_, err = blobs.Get(ctx, img.ThumbnailKey)
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+}
+
+// importCategories resolves the slug "cat" to category c1.
+func importCategories() *MockCategoryRepo {
+	categories := new(MockCategoryRepo)
+	categories.On("GetBySlug", mock.Anything, "cat").Return(&model.Category{ID: "c1", Name: "Cat", Slug: "cat"}, nil)
+	categories.On("GetBySlug", mock.Anything, mock.Anything).Return(nil, errors.New("category not found"))
+	return categories
+}
+
+func int32p(v int32) *int32 { return &v }
+
+func TestImport_DryRunValidatesWithoutWriting(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, importCategories(), cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+	ctx := context.Background()
+	notFound := errors.New("product not found")
+
+	mockRepo.On("GetBySKU", mock.Anything, "A-1").Return(&model.Product{ID: "p1", Name: "Shirt"}, nil)
+	mockRepo.On("GetBySKU", mock.Anything, mock.Anything).Return(nil, notFound)
+	mockRepo.On("GetByName", mock.Anything, "Shirt").Return(&model.Product{ID: "p1", Name: "Shirt"}, nil)
+	mockRepo.On("GetByName", mock.Anything, mock.Anything).Return(nil, notFound)
+
+	imp := uc.NewImport(true, "u1")
+	imp.Add(ctx, model.ImportRow{Line: 2, SKU: "A-1", Name: "Shirt", Description: "D", Category: "cat", Price: "10", Stock: int32p(5)})
+	imp.Add(ctx, model.ImportRow{Line: 3, SKU: "B-1", Name: "Hat", Description: "D", Category: "cat", Price: "5"})
+	// A second variant of the product created on line 3.
+	imp.Add(ctx, model.ImportRow{Line: 4, SKU: "B-2", Name: "Hat", Description: "D", Category: "cat", Price: "5"})
+	imp.Add(ctx, model.ImportRow{Line: 5, SKU: "A-1", Name: "Shirt", Description: "D", Category: "cat", Price: "10"})
+	imp.Add(ctx, model.ImportRow{Line: 6, SKU: "C-1", Name: "Cap", Description: "D", Category: "nope", Price: "5"})
+	imp.Add(ctx, model.ImportRow{Line: 7, SKU: "D-1", Name: "Sock", Description: "D", Category: "cat", Price: "5", Stock: int32p(-1)})
+	report := imp.Finish(ctx)
+
+	assert.True(t, report.DryRun)
+	assert.Equal(t, int32(1), report.Created)
+	assert.Equal(t, int32(2), report.Updated)
+	assert.Equal(t, int32(3), report.Failed)
+	if assert.Len(t, report.Errors, 3) {
+		assert.Equal(t, model.ImportError{Line: 5, SKU: "A-1", Error: "sku A-1 is already imported on line 2"}, report.Errors[0])
+		assert.Equal(t, "category nope not found", report.Errors[1].Error)
+		assert.Equal(t, int32(7), report.Errors[2].Line)
+	}
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestImport_CreatesNewProducts(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, importCategories(), cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+	ctx := context.Background()
+
+	mockRepo.On("GetBySKU", mock.Anything, "B-1").Return(nil, errors.New("product not found"))
+	mockRepo.On("GetByName", mock.Anything, "Hat").Return(nil, errors.New("product not found"))
+	mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(p *model.Product) bool {
+		return p.Name == "Hat" && p.CategoryID == "c1" && len(p.Variants) == 1 &&
+			p.Variants[0].SKU == "B-1" && p.Variants[0].Stock == 4 && p.ReorderLevel == 2
+	})).Return("p2", nil).Once()
+
+	imp := uc.NewImport(false, "u1")
+	imp.Add(ctx, model.ImportRow{Line: 2, SKU: "B-1", Name: "Hat", Description: "D", Category: "cat", Price: "5", Stock: int32p(4), ReorderLevel: int32p(2)})
+	report := imp.Finish(ctx)
+
+	assert.Equal(t, int32(1), report.Created)
+	assert.Zero(t, report.Failed)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestImport_UpdateRecordsStockCorrection(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	alerts := &recordingPublisher{}
+	uc := usecase.NewProductUsecase(mockRepo, importCategories(), cache.NewLRU(100), cache.NewLocalVersion(), alerts, nil, "USD")
+	ctx := context.Background()
+
+	existing := &model.Product{ID: "p1", Name: "Shirt", ReorderLevel: 3, Variants: []model.Variant{{SKU: "A-1", Stock: 10}}}
+	mockRepo.On("GetBySKU", mock.Anything, "A-1").Return(existing, nil)
+	mockRepo.On("GetByName", mock.Anything, "Shirt").Return(existing, nil)
+	// Without a reorder_level column the product's level is kept.
+	mockRepo.On("Update", mock.Anything, mock.Anything, []string{"name", "description", "category_id", "price"}).Return(existing, nil).Once()
+	mockRepo.On("UpsertVariant", mock.Anything, "p1", mock.MatchedBy(func(v model.Variant) bool { return v.SKU == "A-1" })).Return(existing, nil).Once()
+	mockRepo.On("AdjustStock", mock.Anything, mock.MatchedBy(func(m *model.StockMovement) bool {
+		return m.Delta == -8 && m.SKU == "A-1" && m.Reason == model.ReasonCorrection && m.Reference == "import" && m.ActorID == "u1"
+	})).Return(&model.Product{ID: "p1", ReorderLevel: 3, Stock: 2, Variants: []model.Variant{{SKU: "A-1", Stock: 2}}}, nil).Once()
+
+	imp := uc.NewImport(false, "u1")
+	imp.Add(ctx, model.ImportRow{Line: 2, SKU: "A-1", Name: "Shirt", Description: "D", Category: "cat", Price: "12", Stock: int32p(2)})
+	report := imp.Finish(ctx)
+
+	assert.Equal(t, int32(1), report.Updated)
+	assert.Zero(t, report.Failed)
+	assert.Len(t, alerts.alerts, 1)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestImport_RejectsNameOfAnotherProduct(t *testing.T) {
+	mockRepo := new(MockProductRepo)
+	uc := usecase.NewProductUsecase(mockRepo, importCategories(), cache.NewLRU(100), cache.NewLocalVersion(), &recordingPublisher{}, nil, "USD")
+	ctx := context.Background()
+
+	mockRepo.On("GetBySKU", mock.Anything, "A-1").Return(&model.Product{ID: "p1", Name: "Shirt"}, nil)
+	mockRepo.On("GetByName", mock.Anything, "Hat").Return(&model.Product{ID: "p2", Name: "Hat"}, nil)
+
+	imp := uc.NewImport(false, "u1")
+	imp.Add(ctx, model.ImportRow{Line: 2, SKU: "A-1", Name: "Hat", Description: "D", Category: "cat", Price: "5"})
+	report := imp.Finish(ctx)
+
+	assert.Equal(t, int32(1), report.Failed)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
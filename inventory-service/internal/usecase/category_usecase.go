@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"inventory-service/internal/model"
+	"inventory-service/internal/repository"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"golang/pkg/cache"
+)
+
+// ErrCategoryInUse is returned by DeleteCategory for a category that still
+// has subcategories or products.
+var ErrCategoryInUse = errors.New("category still has subcategories or products")
+
+// objectIDPattern matches category IDs, as opposed to slugs.
+var objectIDPattern = regexp.MustCompile(`^[0-9a-f]{24}$`)
+
+// resolveCategory finds a category by ID or, failing that, by slug.
+func resolveCategory(ctx context.Context, repo repository.CategoryRepository, ref string) (*model.Category, error) {
+	if objectIDPattern.MatchString(ref) {
+		c, err := repo.GetByID(ctx, ref)
+		if err == nil || !isNotFound(err) {
+			return c, err
+		}
+	}
+	return repo.GetBySlug(ctx, ref)
+}
+
+type CategoryUsecase struct {
+	repo        repository.CategoryRepository
+	products    repository.ProductRepository
+	listVersion cache.Version
+}
+
+// NewCategoryUsecase creates the category usecase. listVersion is the one
+// product listings are cached under: moving, renaming or deleting a category
+// changes which products a listing by category returns.
+func NewCategoryUsecase(repo repository.CategoryRepository, products repository.ProductRepository, listVersion cache.Version) *CategoryUsecase {
+	return &CategoryUsecase{repo: repo, products: products, listVersion: listVersion}
+}
+
+// prepare validates c's name and slug, deriving the slug from the name when
+// it is empty, and fills in its ancestors from its parent.
+func (u *CategoryUsecase) prepare(ctx context.Context, c *model.Category) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	if c.Slug == "" {
+		c.Slug = model.Slugify(c.Name)
+	}
+	if c.Slug == "" || c.Slug != model.Slugify(c.Slug) {
+		return errors.New("slug must be lowercase letters and digits separated by single dashes")
+	}
+
+	c.Ancestors = nil
+	if c.ParentID == "" {
+		return nil
+	}
+	parent, err := u.repo.GetByID(ctx, c.ParentID)
+	if err != nil {
+		if isNotFound(err) || strings.Contains(err.Error(), "invalid") {
+			return errors.New("parent_id must be an existing category")
+		}
+		return err
+	}
+	c.Ancestors = append(append(c.Ancestors, parent.Ancestors...), parent.ID)
+	return nil
+}
+
+// CreateCategory adds a category under c.ParentID, or at the root.
+func (u *CategoryUsecase) CreateCategory(ctx context.Context, c *model.Category) (*model.Category, error) {
+	if err := u.prepare(ctx, c); err != nil {
+		return nil, err
+	}
+	if _, err := u.repo.Create(ctx, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCategory returns the category with the given ID or slug.
+func (u *CategoryUsecase) GetCategory(ctx context.Context, ref string) (*model.Category, error) {
+	if ref == "" {
+		return nil, errors.New("id is required")
+	}
+	return resolveCategory(ctx, u.repo, ref)
+}
+
+// UpdateCategory overwrites the category's name, slug and parent. Changing
+// the parent moves the whole subtree.
+func (u *CategoryUsecase) UpdateCategory(ctx context.Context, c *model.Category) (*model.Category, error) {
+	if c.ID == "" {
+		return nil, errors.New("id is required")
+	}
+	if err := u.prepare(ctx, c); err != nil {
+		return nil, err
+	}
+	for _, id := range append([]string{c.ParentID}, c.Ancestors...) {
+		if id == c.ID {
+			return nil, errors.New("parent_id must not be the category itself or one of its subcategories")
+		}
+	}
+
+	updated, err := u.repo.Update(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	u.invalidateLists(ctx)
+	return updated, nil
+}
+
+// DeleteCategory removes a category that has no subcategories and is not
+// referenced by any product, deleted ones included.
+func (u *CategoryUsecase) DeleteCategory(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("id is required")
+	}
+	children, err := u.repo.DescendantIDs(ctx, id)
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return ErrCategoryInUse
+	}
+	n, err := u.products.CountByCategory(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrCategoryInUse
+	}
+
+	if err := u.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	u.invalidateLists(ctx)
+	return nil
+}
+
+// ListCategories returns the children of parentID, or the whole tree when
+// parentID is empty, parents before children.
+func (u *CategoryUsecase) ListCategories(ctx context.Context, parentID string) ([]*model.Category, error) {
+	return u.repo.List(ctx, parentID)
+}
+
+func (u *CategoryUsecase) invalidateLists(ctx context.Context) {
+	if err := u.listVersion.Bump(ctx); err != nil {
+		slog.WarnContext(ctx, "failed to bump product list version", "error", err)
+	}
+}
@@ -0,0 +1,104 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"inventory-service/internal/media"
+	"inventory-service/internal/model"
+	"log/slog"
+)
+
+// AddImage validates an uploaded image, stores it with a generated thumbnail
+// and appends it to the product's images. It returns the product as
+// committed.
+func (u *ProductUsecase) AddImage(ctx context.Context, productID string, data []byte, alt string) (*model.Product, error) {
+	if productID == "" {
+		return nil, errors.New("product_id is required")
+	}
+	decoded, err := media.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	thumb, thumbType, err := decoded.Thumbnail()
+	if err != nil {
+		return nil, fmt.Errorf("generating thumbnail: %w", err)
+	}
+
+	id, err := newImageID()
+	if err != nil {
+		return nil, err
+	}
+	img := model.Image{
+		ID:           id,
+		Key:          fmt.Sprintf("products/%s/%s.%s", productID, id, decoded.Ext),
+		ThumbnailKey: fmt.Sprintf("products/%s/%s_thumb.%s", productID, id, media.Ext(thumbType)),
+		ContentType:  decoded.ContentType,
+		Width:        int32(decoded.Width),
+		Height:       int32(decoded.Height),
+		Size:         int64(len(data)),
+		Alt:          alt,
+	}
+
+	// Blobs first: a product never references an image that is not stored.
+	// If the product update fails the blobs are removed again.
+	if err := u.blobs.Put(ctx, img.Key, data, img.ContentType); err != nil {
+		return nil, fmt.Errorf("storing image: %w", err)
+	}
+	if err := u.blobs.Put(ctx, img.ThumbnailKey, thumb, thumbType); err != nil {
+		u.deleteImageBlobs(ctx, img)
+		return nil, fmt.Errorf("storing thumbnail: %w", err)
+	}
+	updated, err := u.repo.AddImage(ctx, productID, img)
+	if err != nil {
+		u.deleteImageBlobs(ctx, img)
+		return nil, err
+	}
+
+	u.writeThrough(ctx, updated)
+	return updated, nil
+}
+
+// DeleteImage removes an image from the product and deletes it and its
+// thumbnail from the blob store. It returns the product as committed.
+func (u *ProductUsecase) DeleteImage(ctx context.Context, productID, imageID string) (*model.Product, error) {
+	if productID == "" {
+		return nil, errors.New("product_id is required")
+	}
+	if imageID == "" {
+		return nil, errors.New("image_id is required")
+	}
+	updated, removed, err := u.repo.RemoveImage(ctx, productID, imageID)
+	if err != nil {
+		return nil, err
+	}
+	u.deleteImageBlobs(ctx, *removed)
+
+	u.writeThrough(ctx, updated)
+	return updated, nil
+}
+
+// ImageURL returns the address clients fetch a stored image from.
+func (u *ProductUsecase) ImageURL(key string) string {
+	return u.blobs.URL(key)
+}
+
+// deleteImageBlobs removes an image's blobs. Failures only leave orphaned
+// files behind, so they are logged rather than returned.
+func (u *ProductUsecase) deleteImageBlobs(ctx context.Context, img model.Image) {
+	for _, key := range []string{img.Key, img.ThumbnailKey} {
+		if err := u.blobs.Delete(ctx, key); err != nil {
+			slog.WarnContext(ctx, "failed to delete image blob", "key", key, "error", err)
+		}
+	}
+}
+
+func newImageID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,298 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"inventory-service/internal/model"
+
+	"golang/pkg/money"
+)
+
+// maxImportErrors bounds the errors kept in an import report; Failed still
+// counts every failed row.
+const maxImportErrors = 1000
+
+// importReference marks stock movements made by imports in the ledger.
+const importReference = "import"
+
+// ProductImport applies the rows of a bulk import one at a time. Each row
+// upserts one variant by SKU: an existing variant is updated along with its
+// product, a new SKU is added to the product with the row's name, or creates
+// that product. A failing row is reported and skipped.
+type ProductImport struct {
+	u       *ProductUsecase
+	dryRun  bool
+	actorID string
+	report  model.ImportReport
+	// skus maps the SKUs seen so far to their line.
+	skus map[string]int32
+	// categories caches resolved category references.
+	categories map[string]string
+	// planned holds the names of products a dry run would have created.
+	planned map[string]bool
+}
+
+// NewImport starts a bulk import. With dryRun every row is validated and
+// counted but nothing is written. actorID is recorded on stock movements.
+func (u *ProductUsecase) NewImport(dryRun bool, actorID string) *ProductImport {
+	return &ProductImport{
+		u:          u,
+		dryRun:     dryRun,
+		actorID:    actorID,
+		report:     model.ImportReport{DryRun: dryRun},
+		skus:       map[string]int32{},
+		categories: map[string]string{},
+		planned:    map[string]bool{},
+	}
+}
+
+// Add applies row and records the outcome in the report.
+func (imp *ProductImport) Add(ctx context.Context, row model.ImportRow) {
+	created, err := imp.apply(ctx, row)
+	switch {
+	case err != nil:
+		imp.report.Failed++
+		if len(imp.report.Errors) < maxImportErrors {
+			imp.report.Errors = append(imp.report.Errors, model.ImportError{Line: row.Line, SKU: row.SKU, Error: err.Error()})
+		}
+	case created:
+		imp.report.Created++
+	default:
+		imp.report.Updated++
+	}
+}
+
+// Finish invalidates cached listings once for the whole import and returns
+// the report.
+func (imp *ProductImport) Finish(ctx context.Context) *model.ImportReport {
+	if !imp.dryRun && imp.report.Created+imp.report.Updated > 0 {
+		imp.u.invalidateLists(ctx)
+	}
+	return &imp.report
+}
+
+func (imp *ProductImport) apply(ctx context.Context, row model.ImportRow) (created bool, err error) {
+	if err := validateImportRow(row); err != nil {
+		return false, err
+	}
+	price, override, err := imp.prices(row)
+	if err != nil {
+		return false, err
+	}
+	if line, ok := imp.skus[row.SKU]; ok {
+		return false, fmt.Errorf("sku %s is already imported on line %d", row.SKU, line)
+	}
+	imp.skus[row.SKU] = row.Line
+
+	categoryID, err := imp.category(ctx, row.Category)
+	if err != nil {
+		return false, err
+	}
+	target, err := imp.target(ctx, row)
+	if err != nil {
+		return false, err
+	}
+
+	if imp.dryRun {
+		if target == nil && !imp.planned[row.Name] {
+			imp.planned[row.Name] = true
+			return true, nil
+		}
+		return false, nil
+	}
+	if target == nil {
+		return true, imp.create(ctx, row, categoryID, price, override)
+	}
+	return false, imp.update(ctx, target, row, categoryID, price, override)
+}
+
+func validateImportRow(row model.ImportRow) error {
+	if err := validateVariant(model.Variant{SKU: row.SKU, WeightGrams: valueOr(row.WeightGrams, 0)}); err != nil {
+		return err
+	}
+	switch {
+	case row.Name == "":
+		return errors.New("name is required")
+	case row.Description == "":
+		return errors.New("description is required")
+	case row.Category == "":
+		return errors.New("category is required")
+	case row.Price == "":
+		return errors.New("price is required")
+	case row.Stock != nil && *row.Stock < 0:
+		return errors.New("stock cannot be negative")
+	case row.ReorderLevel != nil && *row.ReorderLevel < 0:
+		return errors.New("reorder_level cannot be negative")
+	}
+	return nil
+}
+
+// prices parses the row's price and price override, which must be in the
+// store currency.
+func (imp *ProductImport) prices(row model.ImportRow) (price, override money.Money, err error) {
+	currency := row.Currency
+	if currency == "" {
+		currency = imp.u.currency
+	}
+	parse := func(s, field string) (money.Money, error) {
+		m, err := money.Parse(s, currency)
+		if err != nil {
+			return money.Money{}, fmt.Errorf("%s must be a decimal amount with at most %d decimals, e.g. 19.99", field, money.Exponent(currency))
+		}
+		return m, nil
+	}
+	if price, err = parse(row.Price, "price"); err != nil {
+		return price, override, err
+	}
+	if err = imp.u.checkPrice(&price, "price"); err != nil {
+		return price, override, err
+	}
+	if row.PriceOverride != "" {
+		if override, err = parse(row.PriceOverride, "price_override"); err != nil {
+			return price, override, err
+		}
+	}
+	return price, override, imp.u.checkOverride(&override)
+}
+
+// category resolves a category ID or slug to an ID.
+func (imp *ProductImport) category(ctx context.Context, ref string) (string, error) {
+	if id, ok := imp.categories[ref]; ok {
+		return id, nil
+	}
+	c, err := resolveCategory(ctx, imp.u.categories, ref)
+	if err != nil {
+		if isNotFound(err) {
+			return "", fmt.Errorf("category %s not found", ref)
+		}
+		return "", err
+	}
+	imp.categories[ref] = c.ID
+	return c.ID, nil
+}
+
+// target returns the product the row applies to: the one holding its SKU,
+// else the one with its name, else nil for a new product.
+func (imp *ProductImport) target(ctx context.Context, row model.ImportRow) (*model.Product, error) {
+	bySKU, err := imp.u.repo.GetBySKU(ctx, row.SKU)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	byName, err := imp.u.repo.GetByName(ctx, row.Name)
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+
+	target := bySKU
+	if target == nil {
+		target = byName
+	} else if byName != nil && byName.ID != target.ID {
+		return nil, fmt.Errorf("name %q is already used by product %s", row.Name, byName.ID)
+	}
+	if target != nil && target.DeletedAt != nil {
+		return nil, fmt.Errorf("product %s is deleted, restore it first", target.ID)
+	}
+	return target, nil
+}
+
+func (imp *ProductImport) create(ctx context.Context, row model.ImportRow, categoryID string, price, override money.Money) error {
+	p := &model.Product{
+		Name:         row.Name,
+		Description:  row.Description,
+		CategoryID:   categoryID,
+		Price:        price,
+		ReorderLevel: valueOr(row.ReorderLevel, 0),
+		Variants: []model.Variant{{
+			SKU:           row.SKU,
+			Attributes:    row.Attributes,
+			PriceOverride: override,
+			Stock:         valueOr(row.Stock, 0),
+			WeightGrams:   valueOr(row.WeightGrams, 0),
+		}},
+	}
+	_, err := imp.u.repo.Create(ctx, p)
+	return err
+}
+
+// update overwrites the product fields, upserts the variant and records any
+// stock difference as a correction in the stock ledger.
+func (imp *ProductImport) update(ctx context.Context, target *model.Product, row model.ImportRow, categoryID string, price, override money.Money) error {
+	defer func() { _ = imp.u.cache.Delete(ctx, productCacheKey(target.ID)) }()
+
+	fields := []string{"name", "description", "category_id", "price"}
+	if row.ReorderLevel != nil {
+		fields = append(fields, "reorder_level")
+	}
+	_, err := imp.u.repo.Update(ctx, &model.Product{
+		ID:           target.ID,
+		Name:         row.Name,
+		Description:  row.Description,
+		CategoryID:   categoryID,
+		Price:        price,
+		ReorderLevel: valueOr(row.ReorderLevel, 0),
+	}, fields)
+	if err != nil {
+		return err
+	}
+	weight := row.WeightGrams
+	if weight == nil {
+		for _, v := range target.Variants {
+			if v.SKU == row.SKU {
+				weight = &v.WeightGrams
+			}
+		}
+	}
+	updated, err := imp.u.repo.UpsertVariant(ctx, target.ID, model.Variant{
+		SKU:           row.SKU,
+		Attributes:    row.Attributes,
+		PriceOverride: override,
+		WeightGrams:   valueOr(weight, 0),
+	})
+	if err != nil {
+		return err
+	}
+
+	if row.Stock == nil {
+		return nil
+	}
+	var stock int32
+	for _, v := range updated.Variants {
+		if v.SKU == row.SKU {
+			stock = v.Stock
+		}
+	}
+	if delta := *row.Stock - stock; delta != 0 {
+		m := &model.StockMovement{
+			ProductID: target.ID,
+			SKU:       row.SKU,
+			Delta:     delta,
+			Reason:    model.ReasonCorrection,
+			Reference: importReference,
+			ActorID:   imp.actorID,
+		}
+		if updated, err = imp.u.repo.AdjustStock(ctx, m); err != nil {
+			return err
+		}
+		imp.u.publishStockAlert(ctx, m, updated)
+	}
+	return nil
+}
+
+// ExportProducts calls fn for every live product in the category, given by ID
+// or slug, and its subcategories, or for every live product when category is
+// empty.
+func (u *ProductUsecase) ExportProducts(ctx context.Context, category string, fn func(*model.Product) error) error {
+	ids, err := u.categorySubtree(ctx, category)
+	if err != nil {
+		return err
+	}
+	return u.repo.Iterate(ctx, ids, fn)
+}
+
+func valueOr(p *int32, def int32) int32 {
+	if p == nil {
+		return def
+	}
+	return *p
+}
@@ -0,0 +1,16 @@
+package usecase
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Instruments are created from the global meter, which delegates to the
+// provider installed by telemetry.InitMetrics once it is configured.
+var meter = otel.Meter("inventory-service")
+
+// stockOuts counts stock decrements rejected because the product ran out of stock.
+var stockOuts, _ = meter.Int64Counter(
+	"inventory.stockouts",
+	metric.WithDescription("Number of stock decrements rejected for insufficient stock"),
+)
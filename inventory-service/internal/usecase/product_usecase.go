@@ -4,18 +4,140 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net/url"
 	"inventory-service/internal/model"
-	"inventory-service/internal/redis"
 	"inventory-service/internal/repository"
+	"strings"
 	"time"
+
+	"golang/pkg/blob"
+	"golang/pkg/cache"
+	"golang/pkg/money"
+)
+
+const (
+    productCacheTTL  = time.Hour
+    notFoundCacheTTL = 30 * time.Second
+    // Listings are invalidated through listVersion; the TTL only bounds how
+    // long a missed bump can leave a stale page around.
+    listCacheTTL = 5 * time.Minute
 )
 
+// updatableFields are the fields UpdateProduct may write. Stock is not one:
+// it is changed through AdjustStock, which records every change in the
+// stock ledger.
+var updatableFields = []string{"name", "description", "category_id", "price", "reorder_level"}
+
+// ErrStockNotUpdatable is returned by UpdateProduct for masks naming stock.
+var ErrStockNotUpdatable = errors.New("stock must be changed through stock adjustments")
+
+// sortFields are the fields ListProducts may sort by.
+var sortFields = map[string]bool{"name": true, "price": true, "stock": true}
+
+func productCacheKey(id string) string {
+    return fmt.Sprintf("product:%s", id)
+}
+
+func productListCacheKey(version int64, category string, page, limit int32, sort string, inStock bool) string {
+    return fmt.Sprintf("products:list:v%d:%s:%d:%d:%s:%t", version, url.QueryEscape(category), page, limit, sort, inStock)
+}
+
+func isNotFound(err error) bool {
+    return strings.Contains(err.Error(), "not found")
+}
+
+// AlertPublisher delivers stock alerts to whoever restocks products.
+type AlertPublisher interface {
+    PublishStockAlert(ctx context.Context, alert *model.StockAlert) error
+}
+
 type ProductUsecase struct {
-    repo repository.ProductRepository
+    repo        repository.ProductRepository
+    categories  repository.CategoryRepository
+    cache       cache.Swapper
+    listVersion cache.Version
+    alerts      AlertPublisher
+    blobs       blob.BlobStore
+    currency    string
+}
+
+// NewProductUsecase creates the product usecase. categories is used to check
+// product categories and expand listings to subcategories. listVersion is
+// bumped on every product write to invalidate all cached listings at once;
+// alerts receives low-stock and out-of-stock alerts; blobs stores product
+// images. currency is the store currency every price is in.
+func NewProductUsecase(repo repository.ProductRepository, categories repository.CategoryRepository, c cache.Swapper, listVersion cache.Version, alerts AlertPublisher, blobs blob.BlobStore, currency string) *ProductUsecase {
+    return &ProductUsecase{repo: repo, categories: categories, cache: c, listVersion: listVersion, alerts: alerts, blobs: blobs, currency: currency}
+}
+
+// checkPrice requires m to be a non-negative amount of the store currency,
+// which it defaults to. All prices share one currency so that order lines can
+// be added up.
+func (u *ProductUsecase) checkPrice(m *money.Money, field string) error {
+    if m.Currency == "" {
+        m.Currency = u.currency
+    }
+    if m.Currency != u.currency {
+        return fmt.Errorf("%s must be in %s, got %s", field, u.currency, m.Currency)
+    }
+    if m.IsNegative() {
+        return fmt.Errorf("%s cannot be negative", field)
+    }
+    return nil
+}
+
+// checkOverride is checkPrice for a variant price override, where a zero
+// amount means the product price and is stored as no override at all.
+func (u *ProductUsecase) checkOverride(m *money.Money) error {
+    if m.IsZero() {
+        *m = money.Money{}
+        return nil
+    }
+    return u.checkPrice(m, "variant price_override")
+}
+
+// checkCategory verifies that id names an existing category.
+func (u *ProductUsecase) checkCategory(ctx context.Context, id string) error {
+    if id == "" {
+        return errors.New("category_id is required")
+    }
+    _, err := u.categories.GetByID(ctx, id)
+    if err != nil && (isNotFound(err) || strings.Contains(err.Error(), "invalid")) {
+        return errors.New("category_id must be an existing category")
+    }
+    return err
+}
+
+// invalidate drops the cached product, if any, and every cached listing.
+func (u *ProductUsecase) invalidate(ctx context.Context, id string) {
+    if id != "" {
+        _ = u.cache.Delete(ctx, productCacheKey(id))
+    }
+    u.invalidateLists(ctx)
+}
+
+// writeThrough caches a product exactly as the repository committed it, so
+// readers never see a version older than the write, and invalidates every
+// cached listing. Concurrent writes may finish out of order, so the product
+// only replaces a cached copy with a lower version; otherwise the key is
+// dropped and the next read loads whatever was committed last.
+func (u *ProductUsecase) writeThrough(ctx context.Context, p *model.Product) {
+    stored, err := cache.SetIfNewer(ctx, u.cache, productCacheKey(p.ID), p, productCacheTTL,
+        func(cached *model.Product) bool { return p.Version > cached.Version })
+    if err != nil {
+        slog.WarnContext(ctx, "failed to write product through to cache", "product_id", p.ID, "error", err)
+    }
+    if !stored {
+        _ = u.cache.Delete(ctx, productCacheKey(p.ID))
+    }
+    u.invalidateLists(ctx)
 }
 
-func NewProductUsecase(repo repository.ProductRepository) *ProductUsecase {
-    return &ProductUsecase{repo: repo}
+func (u *ProductUsecase) invalidateLists(ctx context.Context) {
+    if err := u.listVersion.Bump(ctx); err != nil {
+        slog.WarnContext(ctx, "failed to bump product list version", "error", err)
+    }
 }
 
 func (u *ProductUsecase) CreateProduct(ctx context.Context, p *model.Product) (string, error) {
@@ -25,16 +147,58 @@ func (u *ProductUsecase) CreateProduct(ctx context.Context, p *model.Product) (s
     if p.Description == "" {
         return "", errors.New("description is required")
     }
-    if p.Category == "" {
-        return "", errors.New("category is required")
-    }
     if p.Stock < 0 {
         return "", errors.New("stock cannot be negative")
     }
-    if p.Price < 0 {
-        return "", errors.New("price cannot be negative")
+    if err := u.checkPrice(&p.Price, "price"); err != nil {
+        return "", err
+    }
+    if p.ReorderLevel < 0 {
+        return "", errors.New("reorder_level cannot be negative")
+    }
+    seen := make(map[string]bool, len(p.Variants))
+    for i, v := range p.Variants {
+        if err := validateVariant(v); err != nil {
+            return "", err
+        }
+        if err := u.checkOverride(&p.Variants[i].PriceOverride); err != nil {
+            return "", err
+        }
+        if v.Stock < 0 {
+            return "", errors.New("variant stock cannot be negative")
+        }
+        if seen[v.SKU] {
+            return "", fmt.Errorf("sku %s must be unique", v.SKU)
+        }
+        seen[v.SKU] = true
+    }
+    if err := u.checkCategory(ctx, p.CategoryID); err != nil {
+        return "", err
+    }
+
+    id, err := u.repo.Create(ctx, p)
+    if err != nil {
+        return "", err
+    }
+
+    u.invalidate(ctx, "")
+    return id, nil
+}
+
+// maxSKULength bounds SKUs, which end up on labels and in order lines.
+const maxSKULength = 64
+
+func validateVariant(v model.Variant) error {
+    if v.SKU == "" {
+        return errors.New("variant sku is required")
+    }
+    if len(v.SKU) > maxSKULength {
+        return fmt.Errorf("variant sku must be at most %d characters", maxSKULength)
     }
-    return u.repo.Create(ctx, p)
+    if v.WeightGrams < 0 {
+        return errors.New("variant weight_grams cannot be negative")
+    }
+    return nil
 }
 
 func (u *ProductUsecase) GetProduct(ctx context.Context, id string) (*model.Product, error) {
@@ -42,63 +206,150 @@ func (u *ProductUsecase) GetProduct(ctx context.Context, id string) (*model.Prod
         return nil, errors.New("id is required")
     }
 
-    key := fmt.Sprintf("product:%s", id)
+    // Кэштен іздеу, болмаса базадан алып кэшке сақтау
+    return cache.GetOrLoad(ctx, u.cache, productCacheKey(id), productCacheTTL,
+        func(ctx context.Context) (*model.Product, error) {
+            return u.repo.GetByID(ctx, id)
+        },
+        cache.WithNegativeCaching(isNotFound, notFoundCacheTTL),
+    )
+}
 
-    // 1. Redis кэштен іздеу
-    cached, err := redis.GetFromCache[model.Product](key)
-    if err != nil {
-        return nil, err
+// UpdateProduct writes the fields of p named in mask, or all updatableFields if
+// mask is empty, and returns the product as committed. A full update must
+// carry the version it was read at; a masked update may omit it since it
+// cannot clobber fields it does not name. Either way a stale version fails
+// with repository.ErrVersionConflict.
+func (u *ProductUsecase) UpdateProduct(ctx context.Context, p *model.Product, mask []string) (*model.Product, error) {
+    if p.ID == "" {
+        return nil, errors.New("id is required")
     }
-    if cached != nil {
-        return cached, nil
+    if len(mask) == 0 {
+        if p.Version < 1 {
+            return nil, errors.New("version is required")
+        }
+        mask = updatableFields
     }
 
-    // 2. Базадан алу
-    product, err := u.repo.GetByID(ctx, id)
+    seen := make(map[string]bool, len(mask))
+    for _, f := range mask {
+        if seen[f] {
+            continue
+        }
+        seen[f] = true
+        switch f {
+        case "name":
+            if p.Name == "" {
+                return nil, errors.New("name is required")
+            }
+        case "description":
+            if p.Description == "" {
+                return nil, errors.New("description is required")
+            }
+        case "category_id":
+            if err := u.checkCategory(ctx, p.CategoryID); err != nil {
+                return nil, err
+            }
+        case "stock":
+            return nil, ErrStockNotUpdatable
+        case "price":
+            if err := u.checkPrice(&p.Price, "price"); err != nil {
+                return nil, err
+            }
+        case "reorder_level":
+            if p.ReorderLevel < 0 {
+                return nil, errors.New("reorder_level cannot be negative")
+            }
+        default:
+            return nil, fmt.Errorf("update_mask path %q must be one of %s", f, strings.Join(updatableFields, ", "))
+        }
+    }
+
+    updated, err := u.repo.Update(ctx, p, mask)
     if err != nil {
         return nil, err
     }
 
-    // 3. Redis-ке сақтау
-    _ = redis.SetToCache(key, product, time.Hour)
+    // Кэшке жаңа нұсқаны жазу
+    u.writeThrough(ctx, updated)
 
-    return product, nil
+    return updated, nil
 }
 
-func (u *ProductUsecase) UpdateProduct(ctx context.Context, p *model.Product) error {
-    if p.ID == "" {
-        return errors.New("id is required")
+// UpsertVariant adds a variant to the product or updates the attributes,
+// price override and weight of the variant with the same SKU, and returns the product as
+// committed. New variants start without stock; use AdjustStock to add some.
+func (u *ProductUsecase) UpsertVariant(ctx context.Context, productID string, v model.Variant) (*model.Product, error) {
+    if productID == "" {
+        return nil, errors.New("product_id is required")
     }
-    if p.Name == "" || p.Description == "" || p.Category == "" {
-        return errors.New("name, description and category are required")
+    if err := validateVariant(v); err != nil {
+        return nil, err
     }
-    if p.Stock < 0 {
-        return errors.New("stock cannot be negative")
+    if err := u.checkOverride(&v.PriceOverride); err != nil {
+        return nil, err
     }
-    if p.Price < 0 {
-        return errors.New("price cannot be negative")
+    updated, err := u.repo.UpsertVariant(ctx, productID, v)
+    if err != nil {
+        return nil, err
     }
 
-    err := u.repo.Update(ctx, p)
-    if err != nil {
+    u.writeThrough(ctx, updated)
+    return updated, nil
+}
+
+func (u *ProductUsecase) DeleteProduct(ctx context.Context, id string) error {
+    if id == "" {
+        return errors.New("id is required")
+    }
+    if err := u.repo.Delete(ctx, id); err != nil {
         return err
     }
 
-    // Кэшті өшіру
-    key := fmt.Sprintf("product:%s", p.ID)
-    _ = redis.DeleteCache(key)
-
+    u.invalidate(ctx, id)
     return nil
 }
 
-func (u *ProductUsecase) DeleteProduct(ctx context.Context, id string) error {
+// RestoreProduct undoes DeleteProduct and returns the product as committed.
+func (u *ProductUsecase) RestoreProduct(ctx context.Context, id string) (*model.Product, error) {
     if id == "" {
-        return errors.New("id is required")
+        return nil, errors.New("id is required")
+    }
+    restored, err := u.repo.Restore(ctx, id)
+    if err != nil {
+        return nil, err
     }
-    return u.repo.Delete(ctx, id)
+
+    u.writeThrough(ctx, restored)
+    return restored, nil
 }
 
-func (u *ProductUsecase) ListProducts(ctx context.Context, category string, page, limit int32) ([]*model.Product, error) {
+// PurgeDeleted permanently removes products that have been deleted for longer
+// than retention, along with their images, and returns how many were removed.
+func (u *ProductUsecase) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+    if retention <= 0 {
+        return 0, errors.New("retention must be positive")
+    }
+    purged, err := u.repo.PurgeDeleted(ctx, time.Now().Add(-retention))
+    keys := make([]string, 0, len(purged))
+    for _, p := range purged {
+        keys = append(keys, productCacheKey(p.ID))
+        for _, img := range p.Images {
+            u.deleteImageBlobs(ctx, img)
+        }
+    }
+    if len(purged) > 0 {
+        // Purged products must not be served from the cache either.
+        _ = u.cache.Delete(ctx, keys...)
+        u.invalidateLists(ctx)
+    }
+    return int64(len(purged)), err
+}
+
+// ListProducts returns a page of products in the category, given by ID or
+// slug, and its subcategories, only those with stock left in some variant if
+// inStock.
+func (u *ProductUsecase) ListProducts(ctx context.Context, category string, page, limit int32, sort string, inStock bool) ([]*model.Product, error) {
     // Әдепкі мәндер
     if page < 1 {
         page = 1
@@ -106,11 +357,214 @@ func (u *ProductUsecase) ListProducts(ctx context.Context, category string, page
     if limit < 1 {
         limit = 10 // Мұны өзіңе ыңғайлы мәнге өзгертуге болады
     }
+    if sort != "" && !sortFields[strings.TrimPrefix(sort, "-")] {
+        return nil, errors.New("sort must be one of name, price, stock, optionally prefixed with -")
+    }
+
+    key := productListCacheKey(u.listVersion.Current(ctx), category, page, limit, sort, inStock)
+    return cache.GetOrLoad(ctx, u.cache, key, listCacheTTL,
+        func(ctx context.Context) ([]*model.Product, error) {
+            ids, err := u.categorySubtree(ctx, category)
+            if err != nil {
+                return nil, err
+            }
+            return u.repo.List(ctx, ids, page, limit, sort, inStock)
+        },
+    )
+}
+
+// categorySubtree returns the IDs of the category given by ID or slug and of
+// all its descendants, or nil for no category.
+func (u *ProductUsecase) categorySubtree(ctx context.Context, ref string) ([]string, error) {
+    if ref == "" {
+        return nil, nil
+    }
+    c, err := resolveCategory(ctx, u.categories, ref)
+    if err != nil {
+        return nil, err
+    }
+    descendants, err := u.categories.DescendantIDs(ctx, c.ID)
+    if err != nil {
+        return nil, err
+    }
+    return append([]string{c.ID}, descendants...), nil
+}
 
-    return u.repo.List(ctx, category, page, limit)
+// reasonSigns is the sign a stock movement's delta must have for each reason;
+// 0 allows either.
+var reasonSigns = map[string]int32{
+    model.ReasonRestock:      1,
+    model.ReasonCancellation: 1,
+    model.ReasonReturn:       1,
+    model.ReasonDamage:       -1,
+    model.ReasonOrder:        -1,
+    model.ReasonCorrection:   0,
 }
 
-func (u *ProductUsecase) DecreaseStock(ctx context.Context, productID string, quantity int32) error {
-    return u.repo.DecreaseStock(ctx, productID, quantity)
+// AdjustStock applies m.Delta to the stock of variant m.SKU and records m in
+// the stock ledger, both or neither. m.SKU may be left empty for a product
+// with a single variant. It returns the product as committed; m is filled in
+// with the stored movement.
+func (u *ProductUsecase) AdjustStock(ctx context.Context, m *model.StockMovement) (*model.Product, error) {
+    if m.ProductID == "" {
+        return nil, errors.New("product_id is required")
+    }
+    if m.Delta == 0 {
+        return nil, errors.New("delta must be non-zero")
+    }
+    sign, ok := reasonSigns[m.Reason]
+    if !ok {
+        return nil, errors.New("reason must be one of restock, damage, correction, order, cancellation, return")
+    }
+    if sign > 0 && m.Delta < 0 {
+        return nil, fmt.Errorf("delta must be positive for %s", m.Reason)
+    }
+    if sign < 0 && m.Delta > 0 {
+        return nil, fmt.Errorf("delta must be negative for %s", m.Reason)
+    }
+    return u.applyMovement(ctx, m, u.repo.AdjustStock)
 }
 
+// applyMovement records m through adjust and, once committed, caches the
+// product and raises any stock alert.
+func (u *ProductUsecase) applyMovement(ctx context.Context, m *model.StockMovement, adjust func(context.Context, *model.StockMovement) (*model.Product, error)) (*model.Product, error) {
+    updated, err := adjust(ctx, m)
+    if err != nil {
+        if errors.Is(err, repository.ErrInsufficientStock) {
+            stockOuts.Add(ctx, 1)
+        }
+        return nil, err
+    }
+
+    u.writeThrough(ctx, updated)
+    u.publishStockAlert(ctx, m, updated)
+    return updated, nil
+}
+
+// publishStockAlert sends the alert raised by movement m, if any. The stock
+// change is committed either way; a lost alert only delays restocking until
+// someone checks ListLowStock.
+func (u *ProductUsecase) publishStockAlert(ctx context.Context, m *model.StockMovement, p *model.Product) {
+    alert := stockAlert(m, p)
+    if alert == nil {
+        return
+    }
+    if err := u.alerts.PublishStockAlert(ctx, alert); err != nil {
+        slog.WarnContext(ctx, "failed to publish stock alert", "kind", alert.Kind, "product_id", alert.ProductID, "error", err)
+    }
+}
+
+// stockAlert returns the alert raised by movement m, which left the product
+// as p, or nil if m did not take the stock across a threshold. Since the
+// stock is changed with $inc, exactly one of several concurrent decreases
+// crosses each threshold.
+func stockAlert(m *model.StockMovement, p *model.Product) *model.StockAlert {
+    if m.Delta >= 0 {
+        return nil
+    }
+    before := p.Stock - m.Delta
+
+    var kind string
+    switch {
+    case p.Stock == 0 && before > 0:
+        kind = model.AlertOutOfStock
+    case p.Stock <= p.ReorderLevel && before > p.ReorderLevel:
+        kind = model.AlertLowStock
+    default:
+        return nil
+    }
+    return &model.StockAlert{
+        Kind:         kind,
+        ProductID:    p.ID,
+        SKU:          m.SKU,
+        Name:         p.Name,
+        Stock:        p.Stock,
+        ReorderLevel: p.ReorderLevel,
+        Reason:       m.Reason,
+        Reference:    m.Reference,
+        OccurredAt:   m.CreatedAt,
+    }
+}
+
+// DecreaseStock atomically takes quantity from the variant's stock for the
+// given order and returns the product as committed. sku may be empty for a
+// product with a single variant. It fails with repository.ErrAlreadyApplied
+// while the order holds stock of the variant, so an order is only taken
+// stock for once however often it is announced.
+func (u *ProductUsecase) DecreaseStock(ctx context.Context, productID, sku string, quantity int32, orderID string) (*model.Product, error) {
+    if quantity < 1 {
+        return nil, errors.New("quantity must be positive")
+    }
+    return u.applyMovement(ctx, &model.StockMovement{
+        ProductID: productID,
+        SKU:       sku,
+        Delta:     -quantity,
+        Reason:    model.ReasonOrder,
+        Reference: orderID,
+    }, u.repo.AdjustOrderStock)
+}
+
+// ReleaseStock gives back all the stock of the variant the cancelled order
+// holds and returns the product as committed. It fails with
+// repository.ErrAlreadyApplied if the order holds none, e.g. because it was
+// released already or never taken.
+func (u *ProductUsecase) ReleaseStock(ctx context.Context, productID, sku, orderID string) (*model.Product, error) {
+    if orderID == "" {
+        return nil, errors.New("order_id is required")
+    }
+    return u.applyMovement(ctx, &model.StockMovement{
+        ProductID: productID,
+        SKU:       sku,
+        Reason:    model.ReasonCancellation,
+        Reference: orderID,
+    }, u.repo.AdjustOrderStock)
+}
+
+// RestockReturn puts quantity units of the variant that a customer returned
+// from the given order back in stock and returns the product as committed.
+// It fails with repository.ErrAlreadyApplied if the return was restocked
+// already, so each return is restocked once however often it is announced.
+func (u *ProductUsecase) RestockReturn(ctx context.Context, productID, sku string, quantity int32, orderID, returnID string) (*model.Product, error) {
+    if quantity < 1 {
+        return nil, errors.New("quantity must be positive")
+    }
+    if returnID == "" {
+        return nil, errors.New("return_id is required")
+    }
+    return u.applyMovement(ctx, &model.StockMovement{
+        ProductID: productID,
+        SKU:       sku,
+        Delta:     quantity,
+        Reason:    model.ReasonReturn,
+        Reference: orderID,
+        Note:      "return " + returnID,
+    }, u.repo.AdjustOrderStock)
+}
+
+// GetStockMovements returns a page of the product's stock ledger, newest
+// first. The ledger is not cached.
+func (u *ProductUsecase) GetStockMovements(ctx context.Context, productID string, page, limit int32) ([]*model.StockMovement, error) {
+    if productID == "" {
+        return nil, errors.New("product_id is required")
+    }
+    if page < 1 {
+        page = 1
+    }
+    if limit < 1 || limit > 100 {
+        limit = 20
+    }
+    return u.repo.ListStockMovements(ctx, productID, page, limit)
+}
+
+
+// ListLowStock returns the products that need replenishing, lowest stock
+// first. Like the ledger it is read straight from the database.
+func (u *ProductUsecase) ListLowStock(ctx context.Context, page, limit int32) ([]*model.Product, error) {
+    if page < 1 {
+        page = 1
+    }
+    if limit < 1 || limit > 100 {
+        limit = 20
+    }
+    return u.repo.ListLowStock(ctx, page, limit)
+}
\ No newline at end of file
@@ -1,62 +1,287 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"log/slog"
 	"net"
+	"time"
 
 	"order-service/config"
+	"order-service/internal/db/migration"
 	queue "order-service/internal/events"
 	"order-service/internal/handler"
+	"order-service/internal/inventory"
+	"order-service/internal/payments"
 	"order-service/internal/pb"
-	"order-service/internal/redis"
+	"order-service/internal/pricing"
 	"order-service/internal/repository"
 	"order-service/internal/usecase"
+	"order-service/internal/users"
+
+	"golang/pkg/blob"
+	"golang/pkg/cache"
+	"golang/pkg/logger"
+	"golang/pkg/telemetry"
 
 	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
 	cfg := config.Load()
+	logger.Init("order-service", cfg.LogLevel, cfg.LogFormat)
+
+	metricsHandler, shutdownMetrics, err := telemetry.InitMetrics("order-service")
+	if err != nil {
+		logger.Fatal("metrics init failed", "error", err)
+	}
+	defer shutdownMetrics(cfg.Ctx)
+	telemetry.ServeMetrics(cfg.MetricsPort, metricsHandler)
+
+	shutdownTracing, err := telemetry.InitTracing(cfg.Ctx, "order-service", cfg.TracesExporter, cfg.OTLPEndpoint)
+	if err != nil {
+		logger.Fatal("tracing init failed", "error", err)
+	}
+	defer shutdownTracing(cfg.Ctx)
 
 	// MongoDB
-	client, err := mongo.Connect(cfg.Ctx, options.Client().ApplyURI(cfg.MongoURI))
+	client, err := mongo.Connect(cfg.Ctx, options.Client().ApplyURI(cfg.MongoURI).SetMonitor(otelmongo.NewMonitor()))
 	if err != nil {
-		log.Fatalf("❌ MongoDB connection failed: %v", err)
+		logger.Fatal("mongodb connection failed", "error", err)
 	}
 	defer client.Disconnect(cfg.Ctx)
 
 	// NATS
 	nc, err := nats.Connect(cfg.NATSURL)
 	if err != nil {
-		log.Fatalf("❌ NATS connection failed: %v", err)
+		logger.Fatal("nats connection failed", "error", err)
 	}
 	defer nc.Close()
 
-	redis.Init(cfg.RedisURL)
+	// Redis
+	rdb := redis.NewClient(&redis.Options{Addr: cfg.RedisURL})
+	defer rdb.Close()
+	if err := redisotel.InstrumentTracing(rdb); err != nil {
+		logger.Fatal("redis tracing instrumentation failed", "error", err)
+	}
 
 	publisher, err := queue.NewNATSPublisher(nc)
 	if err != nil {
-		log.Fatalf("❌ Failed to create NATS publisher: %v", err)
+		logger.Fatal("failed to create nats publisher", "error", err)
+	}
+
+	// inventory-service, to check ordered products
+	inventoryConn, err := grpc.NewClient(cfg.InventoryService,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(logger.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		logger.Fatal("inventory client init failed", "error", err)
+	}
+	defer inventoryConn.Close()
+
+	// user-service, to look up saved shipping addresses
+	userConn, err := grpc.NewClient(cfg.UserService,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(logger.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		logger.Fatal("user client init failed", "error", err)
+	}
+	defer userConn.Close()
+
+	// payment-service, to refund returns
+	paymentConn, err := grpc.NewClient(cfg.PaymentService,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(logger.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		logger.Fatal("payment client init failed", "error", err)
+	}
+	defer paymentConn.Close()
+
+	db := client.Database(cfg.MongoDBName)
+	if err := migration.MigrateUp(db, cfg.Currency); err != nil {
+		logger.Fatal("migration up failed", "error", err)
+	}
+	slog.Info("mongo migrations applied")
+
+	orderRepo := repository.NewMongoOrderRepository(db.Collection("orders"))
+	idempotencyRepo := repository.NewMongoIdempotencyRepository(db.Collection("idempotency_keys"))
+	catalog := inventory.NewGRPCCatalog(inventoryConn)
+	promotionUsecase := usecase.NewPromotionUsecase(
+		repository.NewMongoPromotionRepository(db.Collection("promotions"), db.Collection("promotion_usage")),
+		catalog,
+		cfg.Currency,
+	)
+
+	// Tax and shipping rules
+	var prices *pricing.Calculator
+	if cfg.PricingRules != "" {
+		rules, err := pricing.LoadRules(cfg.PricingRules, cfg.Currency)
+		if err != nil {
+			logger.Fatal("failed to load pricing rules", "error", err)
+		}
+		prices = pricing.NewCalculator(rules, catalog)
+	} else {
+		slog.Info("no PRICING_RULES set, orders carry no tax or shipping")
+	}
+
+	orderUsecase := usecase.NewOrderUsecase(orderRepo, publisher, idempotencyRepo, cache.NewRedis(rdb), catalog, promotionUsecase, prices, users.NewGRPCAddressBook(userConn))
+
+	// Retry the events taking or giving back the stock of orders that did
+	// not go out when the orders changed.
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := orderUsecase.PublishPendingStock(cfg.Ctx)
+			if err != nil {
+				slog.Error("publishing pending stock events failed", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.Info("published pending stock events", "count", n)
+			}
+		}
+	}()
+
+	// Guests' carts expire in Redis; users' carts are kept in MongoDB
+	cartUsecase := usecase.NewCartUsecase(
+		repository.NewGuestCartRepository(cache.NewRedis(rdb), cfg.CartGuestTTL),
+		repository.NewMongoCartRepository(db.Collection("carts")),
+		catalog,
+		orderUsecase,
+	)
+
+	returnUsecase := usecase.NewReturnUsecase(orderRepo, publisher, payments.NewGRPCPayments(paymentConn), cache.NewRedis(rdb))
+
+	// Retry refunds payment-service could not be reached for, and the
+	// events restocking returns that did not go out.
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := returnUsecase.RetryRefunds(cfg.Ctx)
+			if err != nil {
+				slog.Error("retrying refunds failed", "error", err)
+			} else if n > 0 {
+				slog.Info("settled pending refunds", "count", n)
+			}
+			n, err = returnUsecase.PublishPending(cfg.Ctx)
+			if err != nil {
+				slog.Error("publishing pending return events failed", "error", err)
+			} else if n > 0 {
+				slog.Info("published pending return events", "count", n)
+			}
+		}
+	}()
+
+	invoiceUsecase := usecase.NewInvoiceUsecase(
+		orderRepo,
+		repository.NewMongoInvoiceRepository(db.Collection("invoices"), db.Collection("counters"), db.Collection("orders")),
+		newInvoiceStore(cfg),
+		catalog,
+		users.NewGRPCDirectory(userConn),
+		publisher,
+		cache.NewRedis(rdb),
+		cfg.InvoiceIssuer,
+	)
+
+	// Finish invoices that could not be issued or sent when their orders
+	// were paid.
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := invoiceUsecase.IssuePending(cfg.Ctx)
+			if err != nil {
+				slog.Error("issuing pending invoices failed", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.Info("issued pending invoices", "count", n)
+			}
+		}
+	}()
+
+	// Orders are paid or cancelled as payment-service reports on payments,
+	// and invoiced once paid
+	paymentConsumer, err := queue.NewPaymentConsumer(nc, orderUsecase, invoiceUsecase)
+	if err != nil {
+		logger.Fatal("failed to create payment consumer", "error", err)
 	}
+	if err := paymentConsumer.Subscribe(cfg.Ctx); err != nil {
+		logger.Fatal("failed to subscribe to payment events", "error", err)
+	}
+	slog.Info("nats subscription active", "subjects", []string{queue.PaymentSucceeded, queue.PaymentFailed})
 
-	orderRepo := repository.NewMongoOrderRepository(client.Database(cfg.MongoDBName).Collection("orders"))
-	orderUsecase := usecase.NewOrderUsecase(orderRepo, publisher)
+	// Invoices are sent once email-service confirms it emailed them
+	invoiceConsumer, err := queue.NewInvoiceConsumer(nc, invoiceUsecase)
+	if err != nil {
+		logger.Fatal("failed to create invoice consumer", "error", err)
+	}
+	if err := invoiceConsumer.Subscribe(cfg.Ctx); err != nil {
+		logger.Fatal("failed to subscribe to invoice events", "error", err)
+	}
+	slog.Info("nats subscription active", "subjects", []string{queue.InvoiceSent})
 
 	orderHandler := handler.NewOrderHandler(orderUsecase, publisher)
+	cartHandler := handler.NewCartHandler(cartUsecase)
+	promotionHandler := handler.NewPromotionHandler(promotionUsecase)
+	returnHandler := handler.NewReturnHandler(returnUsecase)
+	invoiceHandler := handler.NewInvoiceHandler(invoiceUsecase)
 
 	lis, err := net.Listen("tcp", ":"+cfg.Port)
 	if err != nil {
-		log.Fatalf("❌ Listen error: %v", err)
+		logger.Fatal("listen failed", "error", err)
 	}
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			logger.UnaryServerInterceptor(),
+			telemetry.UnaryServerInterceptor("order-service"),
+		),
+	)
 	pb.RegisterOrderServiceServer(grpcServer, orderHandler)
+	pb.RegisterCartServiceServer(grpcServer, cartHandler)
+	pb.RegisterPromotionServiceServer(grpcServer, promotionHandler)
+	pb.RegisterReturnServiceServer(grpcServer, returnHandler)
+	pb.RegisterInvoiceServiceServer(grpcServer, invoiceHandler)
 
-	fmt.Println("🚀 OrderService running on port", cfg.Port)
+	slog.Info("order service listening", "port", cfg.Port)
 	if err := grpcServer.Serve(lis); err != nil {
-		log.Fatalf("❌ gRPC serve error: %v", err)
+		logger.Fatal("grpc serve failed", "error", err)
+	}
+}
+
+// newInvoiceStore returns where invoice PDFs are kept. email-service reads
+// them from the same store.
+func newInvoiceStore(cfg *config.Config) blob.BlobStore {
+	if cfg.InvoiceStore == "s3" {
+		return blob.NewS3Store(blob.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+		})
+	}
+
+	// Invoices are private: they are downloaded through the API, never
+	// from the store, so the files are not served.
+	store, err := blob.NewFileStore(cfg.InvoiceDir, "")
+	if err != nil {
+		logger.Fatal("invoice store init failed", "dir", cfg.InvoiceDir, "error", err)
 	}
+	return store
 }
@@ -2,8 +2,11 @@ package config
 
 import (
     "context"
-    "log"
+    "log/slog"
     "os"
+    "time"
+
+    "golang/pkg/money"
 
     "github.com/joho/godotenv"
 )
@@ -15,12 +18,59 @@ type Config struct {
     Port        string
     NATSURL     string
     RedisURL    string // Redis URL қосылды
+    InventoryService string // inventory-service gRPC адресі
+    UserService      string // user-service gRPC address, for address books
+    PaymentService   string // payment-service gRPC address, for refunds
+    MetricsPort string
+    TracesExporter string
+    OTLPEndpoint   string
+    LogLevel       string
+    LogFormat      string
+    Currency       string // ISO 4217 code legacy order totals are in
+    CartGuestTTL   time.Duration // how long an untouched guest cart is kept
+    PricingRules   string        // JSON file of tax and shipping rules; empty for none
+    InvoiceStore   string        // local | s3
+    InvoiceDir     string        // local: where invoice PDFs are stored, shared with email-service
+    InvoiceIssuer  string        // seller printed at the top of invoices
+    S3             S3Config
+}
+
+// S3Config addresses the bucket invoices are stored in when InvoiceStore is
+// s3.
+type S3Config struct {
+    Endpoint  string
+    Region    string
+    Bucket    string
+    AccessKey string
+    SecretKey string
 }
 
 func Load() *Config {
     // Попытка загрузить .env файл
     if err := godotenv.Load("../.env"); err != nil {
-        log.Println("No .env file found, using environment variables directly")
+        slog.Info("no .env file found, using environment variables directly")
+    }
+
+    currency := getEnvWithDefault("CURRENCY", "USD")
+    if !money.ValidCurrency(currency) {
+        slog.Error("invalid CURRENCY, want an ISO 4217 code such as USD", "value", currency)
+        os.Exit(1)
+    }
+
+    cartGuestTTL := 7 * 24 * time.Hour
+    if v := os.Getenv("CART_GUEST_TTL"); v != "" {
+        d, err := time.ParseDuration(v)
+        if err != nil || d <= 0 {
+            slog.Error("invalid CART_GUEST_TTL", "value", v, "error", err)
+            os.Exit(1)
+        }
+        cartGuestTTL = d
+    }
+
+    invoiceStore := getEnvWithDefault("INVOICE_STORE", "local")
+    if invoiceStore != "local" && invoiceStore != "s3" {
+        slog.Error("invalid INVOICE_STORE, want local or s3", "value", invoiceStore)
+        os.Exit(1)
     }
 
     // Получение переменных окружения
@@ -31,13 +81,42 @@ func Load() *Config {
         Port:        getEnv("PORT"),
         NATSURL:    getEnv("NATS_URL"),
         RedisURL:    getEnv("REDIS_URL"), // Redis URL-ді қосу
+        InventoryService: getEnvWithDefault("INVENTORY_SERVICE", "localhost:50053"),
+        UserService:      getEnvWithDefault("USER_SERVICE", "localhost:50051"),
+        PaymentService:   getEnvWithDefault("PAYMENT_SERVICE", "localhost:50054"),
+        MetricsPort: getEnvWithDefault("METRICS_PORT", "9092"),
+        TracesExporter: getEnvWithDefault("TRACES_EXPORTER", "none"),
+        OTLPEndpoint:   getEnvWithDefault("OTLP_ENDPOINT", "localhost:4317"),
+        LogLevel:       getEnvWithDefault("LOG_LEVEL", "info"),
+        LogFormat:      getEnvWithDefault("LOG_FORMAT", "json"),
+        Currency:       currency,
+        CartGuestTTL:   cartGuestTTL,
+        PricingRules:   os.Getenv("PRICING_RULES"),
+        InvoiceStore:   invoiceStore,
+        InvoiceDir:     getEnvWithDefault("INVOICE_DIR", "./invoices"),
+        InvoiceIssuer:  os.Getenv("INVOICE_ISSUER"),
+        S3: S3Config{
+            Endpoint:  os.Getenv("S3_ENDPOINT"),
+            Region:    getEnvWithDefault("S3_REGION", "us-east-1"),
+            Bucket:    os.Getenv("S3_BUCKET"),
+            AccessKey: os.Getenv("S3_ACCESS_KEY"),
+            SecretKey: os.Getenv("S3_SECRET_KEY"),
+        },
     }
 }
 
 func getEnv(key string) string {
     value := os.Getenv(key)
     if value == "" {
-        log.Fatalf("Environment variable %s is not set", key)
+        slog.Error("environment variable is not set", "key", key)
+        os.Exit(1)
     }
     return value
+}
+
+func getEnvWithDefault(key, defaultValue string) string {
+    if value := os.Getenv(key); value != "" {
+        return value
+    }
+    return defaultValue
 }
\ No newline at end of file
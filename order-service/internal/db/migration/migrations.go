@@ -0,0 +1,96 @@
+package migration
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"golang/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MigrateUp brings stored orders up to date. currency is the store currency
+// legacy float totals are taken to be in.
+func MigrateUp(db *mongo.Database, currency string) error {
+	return convertTotals(db, currency)
+}
+
+func MigrateDown(db *mongo.Database) error {
+	return revertTotals(db)
+}
+
+// convertTotals turns the float totals of orders stored before money amounts
+// into integer minor units of currency, rounding half away from zero to the
+// nearest minor unit, and records the currency.
+func convertTotals(db *mongo.Database, currency string) error {
+	ctx := context.Background()
+	orders := db.Collection("orders")
+
+	cursor, err := orders.Find(ctx, bson.M{"total": bson.M{"$type": "double"}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID    primitive.ObjectID `bson:"_id"`
+			Total float64            `bson:"total"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		total, err := money.FromFloat(doc.Total, currency)
+		if err != nil {
+			return fmt.Errorf("order %s: total %v: %w", doc.ID.Hex(), doc.Total, err)
+		}
+		// Matching the old total keeps a rerun from converting twice.
+		_, err = orders.UpdateOne(ctx,
+			bson.M{"_id": doc.ID, "total": doc.Total},
+			bson.M{"$set": bson.M{"total": total.Amount, "currency": currency}},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// revertTotals turns integer totals back into floats in major units and drops
+// the currency and unit prices.
+func revertTotals(db *mongo.Database) error {
+	ctx := context.Background()
+	orders := db.Collection("orders")
+
+	cursor, err := orders.Find(ctx, bson.M{"total": bson.M{"$type": "long"}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Total    int64              `bson:"total"`
+			Currency string             `bson:"currency"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		scale := math.Pow10(money.Exponent(doc.Currency))
+		_, err = orders.UpdateOne(ctx,
+			bson.M{"_id": doc.ID, "total": doc.Total},
+			bson.M{
+				"$set":   bson.M{"total": float64(doc.Total) / scale},
+				"$unset": bson.M{"currency": "", "products.$[].unit_price": ""},
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,110 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"order-service/internal/repository"
+
+	"golang/pkg/logger"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InvoiceSent is the subject email-service confirms on that it emailed an
+// invoice.
+const InvoiceSent = "invoice.sent"
+
+// InvoicesStream is the JetStream stream email-service publishes invoice
+// events to. It is created by whichever service starts first.
+const InvoicesStream = "INVOICES"
+
+// InvoiceSentMessage is the body of invoice.sent events.
+type InvoiceSentMessage struct {
+	OrderID       string `json:"order_id"`
+	InvoiceNumber string `json:"invoice_number"`
+}
+
+// SentInvoices records the invoices emailed to customers.
+type SentInvoices interface {
+	ConfirmSent(ctx context.Context, orderID string) error
+}
+
+// InvoiceConsumer records invoices as sent once email-service confirms it
+// emailed them.
+type InvoiceConsumer struct {
+	js       nats.JetStreamContext
+	invoices SentInvoices
+}
+
+// NewInvoiceConsumer creates the consumer and the invoices stream, unless it
+// exists.
+func NewInvoiceConsumer(nc *nats.Conn, invoices SentInvoices) (*InvoiceConsumer, error) {
+	js, err := nc.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     InvoicesStream,
+		Subjects: []string{"invoice.>"},
+		Storage:  nats.FileStorage,
+	})
+	if err != nil && !errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		return nil, err
+	}
+	return &InvoiceConsumer{js: js, invoices: invoices}, nil
+}
+
+// Subscribe listens for confirmations through a durable consumer shared by
+// the replicas. Confirmations are acknowledged only once recorded.
+func (c *InvoiceConsumer) Subscribe(ctx context.Context) error {
+	durable := "order-service-invoice-sent"
+	_, err := c.js.QueueSubscribe(InvoiceSent, durable, func(msg *nats.Msg) {
+		if c.handle(ctx, msg) {
+			_ = msg.Ack()
+		} else {
+			_ = msg.NakWithDelay(settleRetryDelay)
+		}
+	}, nats.BindStream(InvoicesStream), nats.Durable(durable), nats.ManualAck())
+	return err
+}
+
+// handle records a confirmation and reports whether it is done with it.
+func (c *InvoiceConsumer) handle(ctx context.Context, msg *nats.Msg) bool {
+	// Continue the trace started by the publisher
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(msg.Header))
+	ctx, span := tracer.Start(ctx, InvoiceSent+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination.name", InvoiceSent),
+		),
+	)
+	defer span.End()
+
+	requestID := msg.Header.Get(logger.RequestIDHeader)
+	if !logger.ValidRequestID(requestID) {
+		requestID = logger.NewRequestID()
+	}
+	ctx = logger.WithRequestID(ctx, requestID)
+
+	var sent InvoiceSentMessage
+	if err := json.Unmarshal(msg.Data, &sent); err != nil {
+		slog.ErrorContext(ctx, "failed to parse message", "subject", InvoiceSent, "error", err)
+		return true
+	}
+
+	slog.InfoContext(ctx, "message received", "subject", InvoiceSent, "order_id", sent.OrderID, "invoice_number", sent.InvoiceNumber)
+	if err := c.invoices.ConfirmSent(ctx, sent.OrderID); err != nil {
+		slog.ErrorContext(ctx, "failed to record invoice sent", "order_id", sent.OrderID, "invoice_number", sent.InvoiceNumber, "error", err)
+		// Invoices that do not exist never will.
+		return errors.Is(err, repository.ErrInvoiceNotFound)
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"order-service/internal/model"
+	"order-service/internal/repository"
+
+	"golang/pkg/logger"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Subjects payment-service reports the outcome of payments on.
+const (
+	PaymentSucceeded = "payment.succeeded"
+	PaymentFailed    = "payment.failed"
+)
+
+// PaymentsStream is the JetStream stream payment-service publishes payment
+// events to. It is created by whichever service starts first.
+const PaymentsStream = "PAYMENTS"
+
+// settleRetryDelay is how long a payment event that could not be applied
+// waits before it is delivered again.
+const settleRetryDelay = 10 * time.Second
+
+// PaymentMessage is the body of payment events.
+type PaymentMessage struct {
+	PaymentID string `json:"payment_id"`
+	OrderID   string `json:"order_id"`
+	Reason    string `json:"reason,omitempty"` // why a payment failed
+}
+
+// PaymentSettler applies the outcome of a payment to its order.
+type PaymentSettler interface {
+	SettlePayment(ctx context.Context, orderID, paymentID string, paid bool) error
+}
+
+// Invoicer issues the invoices of paid orders.
+type Invoicer interface {
+	Issue(ctx context.Context, orderID string) (*model.Invoice, error)
+}
+
+// PaymentConsumer moves orders along as payment-service reports payments
+// succeeding or failing, and invoices the orders paid.
+type PaymentConsumer struct {
+	js       nats.JetStreamContext
+	settler  PaymentSettler
+	invoicer Invoicer
+}
+
+// NewPaymentConsumer creates the consumer. invoicer may be nil, in which case
+// paid orders are not invoiced here.
+func NewPaymentConsumer(nc *nats.Conn, settler PaymentSettler, invoicer Invoicer) (*PaymentConsumer, error) {
+	js, err := nc.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     PaymentsStream,
+		Subjects: []string{"payment.>"},
+		Storage:  nats.FileStorage,
+	})
+	if err != nil && !errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		return nil, err
+	}
+	return &PaymentConsumer{js: js, settler: settler, invoicer: invoicer}, nil
+}
+
+// Subscribe listens for payment events through durable consumers, so events
+// published while order-service is down are delivered when it is back.
+// Replicas share a queue group, so each event is handled once, and events
+// are acknowledged only once applied.
+func (c *PaymentConsumer) Subscribe(ctx context.Context) error {
+	for _, subject := range []string{PaymentSucceeded, PaymentFailed} {
+		paid := subject == PaymentSucceeded
+		durable := "order-service-" + strings.ReplaceAll(subject, ".", "-")
+		if _, err := c.js.QueueSubscribe(subject, durable, func(msg *nats.Msg) {
+			if c.handle(ctx, msg, paid) {
+				_ = msg.Ack()
+			} else {
+				_ = msg.NakWithDelay(settleRetryDelay)
+			}
+		}, nats.BindStream(PaymentsStream), nats.Durable(durable), nats.ManualAck()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle applies a payment event and reports whether it is done with it;
+// events that failed for a reason that may pass are not.
+func (c *PaymentConsumer) handle(ctx context.Context, msg *nats.Msg, paid bool) bool {
+	// Continue the trace started by the publisher
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(msg.Header))
+	ctx, span := tracer.Start(ctx, msg.Subject+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination.name", msg.Subject),
+		),
+	)
+	defer span.End()
+
+	requestID := msg.Header.Get(logger.RequestIDHeader)
+	if !logger.ValidRequestID(requestID) {
+		requestID = logger.NewRequestID()
+	}
+	ctx = logger.WithRequestID(ctx, requestID)
+
+	var payment PaymentMessage
+	if err := json.Unmarshal(msg.Data, &payment); err != nil {
+		slog.ErrorContext(ctx, "failed to parse message", "subject", msg.Subject, "error", err)
+		return true
+	}
+
+	slog.InfoContext(ctx, "message received", "subject", msg.Subject, "order_id", payment.OrderID, "payment_id", payment.PaymentID, "reason", payment.Reason)
+	if err := c.settler.SettlePayment(ctx, payment.OrderID, payment.PaymentID, paid); err != nil {
+		slog.ErrorContext(ctx, "failed to settle order payment", "order_id", payment.OrderID, "payment_id", payment.PaymentID, "error", err)
+		// Orders that do not exist never will.
+		return errors.Is(err, repository.ErrOrderNotFound) || errors.Is(err, repository.ErrInvalidID)
+	}
+	if paid && c.invoicer != nil {
+		// Invoices not issued now are issued by the retry loop.
+		if _, err := c.invoicer.Issue(ctx, payment.OrderID); err != nil {
+			slog.WarnContext(ctx, "failed to issue invoice", "order_id", payment.OrderID, "error", err)
+		}
+	}
+	return true
+}
@@ -3,36 +3,241 @@ package queue
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"time"
 
 	"order-service/internal/model"
 
+	"golang/pkg/logger"
+	"golang/pkg/money"
+
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("order-service/events")
+
+// OrdersStream is the JetStream stream keeping order events until their
+// consumers have handled them. It is created by whichever service starts
+// first.
+const OrdersStream = "ORDERS"
+
 type Publisher interface {
+	// PublishOrderCreated announces a new order, or a cancelled one set
+	// PENDING again, so that its stock is taken.
 	PublishOrderCreated(ctx context.Context, order *model.Order) error
+	// PublishOrderCancelled announces a cancelled order, so that the stock
+	// it took is given back.
+	PublishOrderCancelled(ctx context.Context, order *model.Order) error
+	PublishOrderReturned(ctx context.Context, order *model.Order, ret *model.Return) error
+	PublishOrderInvoiced(ctx context.Context, inv *model.Invoice, key, email string) error
+}
+
+// OrderCancelledMessage is the body of order.cancelled events. The stock
+// given back is what the order took, whatever the quantities.
+type OrderCancelledMessage struct {
+	OrderID  string           `json:"order_id"`
+	Products []OrderedProduct `json:"products"`
+}
+
+type OrderedProduct struct {
+	ProductID string `json:"product_id"`
+	SKU       string `json:"sku,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
+
+// OrderReturnedMessage is the body of order.returned events, which tell
+// inventory-service to restock returned units.
+type OrderReturnedMessage struct {
+	OrderID  string            `json:"order_id"`
+	ReturnID string            `json:"return_id"`
+	Products []ReturnedProduct `json:"products"`
+}
+
+type ReturnedProduct struct {
+	ProductID string `json:"product_id"`
+	SKU       string `json:"sku,omitempty"`
+	Quantity  int    `json:"quantity"`
+}
+
+// OrderInvoicedMessage is the body of order.invoiced events, on which
+// email-service sends the order confirmation with the invoice attached.
+type OrderInvoicedMessage struct {
+	OrderID       string      `json:"order_id"`
+	UserID        string      `json:"user_id"`
+	Email         string      `json:"email,omitempty"` // empty if the user has none
+	InvoiceNumber string      `json:"invoice_number"`
+	InvoiceKey    string      `json:"invoice_key"` // where the PDF is in the blob store
+	Total         money.Money `json:"total"`
 }
 
 type NATSPublisher struct {
 	js nats.JetStreamContext
 }
 
+// NewNATSPublisher creates the publisher and the orders stream, unless it
+// exists.
 func NewNATSPublisher(nc *nats.Conn) (*NATSPublisher, error) {
 	js, err := nc.JetStream()
 	if err != nil {
 		return nil, err
 	}
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     OrdersStream,
+		Subjects: []string{"order.>"},
+		Storage:  nats.FileStorage,
+	})
+	if err != nil && !errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		return nil, err
+	}
 	return &NATSPublisher{js: js}, nil
 }
 
 func (p *NATSPublisher)PublishOrderCreated(ctx context.Context, order *model.Order) error {
+	ctx, span := tracer.Start(ctx, "order.created publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination.name", "order.created"),
+		),
+	)
+	defer span.End()
+
 	data, err := json.Marshal(order)
 	if err != nil {
 		return err
 	}
-	log.Printf("Publishing order.created event: order_id=%s, products=%+v at %v", order.ID, order.Products, time.Now())
-	_, err = p.js.Publish("order.created", data, nats.AckWait(20*time.Second))
+	msg := nats.NewMsg("order.created")
+	msg.Data = data
+	// Carry the trace context and request ID to the consumers in the message headers
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(msg.Header))
+	if id := logger.RequestID(ctx); id != "" {
+		msg.Header.Set(logger.RequestIDHeader, id)
+	}
+
+	slog.InfoContext(ctx, "publishing event", "subject", "order.created", "order_id", order.ID, "items", len(order.Products))
+	_, err = p.js.PublishMsg(msg, nats.AckWait(20*time.Second))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+func (p *NATSPublisher) PublishOrderCancelled(ctx context.Context, order *model.Order) error {
+	ctx, span := tracer.Start(ctx, "order.cancelled publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination.name", "order.cancelled"),
+		),
+	)
+	defer span.End()
+
+	body := OrderCancelledMessage{OrderID: order.ID}
+	for _, item := range order.Products {
+		body.Products = append(body.Products, OrderedProduct{
+			ProductID: item.ProductID,
+			SKU:       item.SKU,
+			Quantity:  item.Quantity,
+		})
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg("order.cancelled")
+	msg.Data = data
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(msg.Header))
+	if id := logger.RequestID(ctx); id != "" {
+		msg.Header.Set(logger.RequestIDHeader, id)
+	}
+
+	slog.InfoContext(ctx, "publishing event", "subject", "order.cancelled", "order_id", order.ID, "items", len(order.Products))
+	_, err = p.js.PublishMsg(msg, nats.AckWait(20*time.Second))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+func (p *NATSPublisher) PublishOrderReturned(ctx context.Context, order *model.Order, ret *model.Return) error {
+	ctx, span := tracer.Start(ctx, "order.returned publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination.name", "order.returned"),
+		),
+	)
+	defer span.End()
+
+	body := OrderReturnedMessage{OrderID: order.ID, ReturnID: ret.ID}
+	for _, item := range ret.Items {
+		body.Products = append(body.Products, ReturnedProduct{
+			ProductID: item.ProductID,
+			SKU:       item.SKU,
+			Quantity:  item.Quantity,
+		})
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg("order.returned")
+	msg.Data = data
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(msg.Header))
+	if id := logger.RequestID(ctx); id != "" {
+		msg.Header.Set(logger.RequestIDHeader, id)
+	}
+
+	slog.InfoContext(ctx, "publishing event", "subject", "order.returned", "order_id", order.ID, "return_id", ret.ID, "items", len(ret.Items))
+	_, err = p.js.PublishMsg(msg, nats.AckWait(20*time.Second))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
+
+func (p *NATSPublisher) PublishOrderInvoiced(ctx context.Context, inv *model.Invoice, key, email string) error {
+	ctx, span := tracer.Start(ctx, "order.invoiced publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination.name", "order.invoiced"),
+		),
+	)
+	defer span.End()
+
+	data, err := json.Marshal(OrderInvoicedMessage{
+		OrderID:       inv.OrderID,
+		UserID:        inv.UserID,
+		Email:         email,
+		InvoiceNumber: inv.Number,
+		InvoiceKey:    key,
+		Total:         inv.Total,
+	})
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg("order.invoiced")
+	msg.Data = data
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(msg.Header))
+	if id := logger.RequestID(ctx); id != "" {
+		msg.Header.Set(logger.RequestIDHeader, id)
+	}
+
+	slog.InfoContext(ctx, "publishing event", "subject", "order.invoiced", "order_id", inv.OrderID, "invoice_number", inv.Number)
+	_, err = p.js.PublishMsg(msg, nats.AckWait(20*time.Second))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
 	return err
 }
\ No newline at end of file
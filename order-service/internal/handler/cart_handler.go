@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"order-service/internal/model"
+	"order-service/internal/pb"
+	"order-service/internal/usecase"
+
+	"golang/pkg/money"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type CartHandler struct {
+	pb.UnimplementedCartServiceServer
+	usecase *usecase.CartUsecase
+}
+
+func NewCartHandler(u *usecase.CartUsecase) *CartHandler {
+	return &CartHandler{usecase: u}
+}
+
+func (h *CartHandler) GetCart(ctx context.Context, req *pb.GetCartRequest) (*pb.Cart, error) {
+	cart, err := h.usecase.GetCart(ctx, ownerFromProto(req.Owner))
+	if err != nil {
+		return nil, cartError(err)
+	}
+	return cartToProto(cart), nil
+}
+
+func (h *CartHandler) AddCartItem(ctx context.Context, req *pb.AddCartItemRequest) (*pb.Cart, error) {
+	cart, err := h.usecase.AddItem(ctx, ownerFromProto(req.Owner), model.CartItem{
+		ProductID: req.ProductId,
+		SKU:       req.Sku,
+		Quantity:  int(req.Quantity),
+	})
+	if err != nil {
+		return nil, cartError(err)
+	}
+	return cartToProto(cart), nil
+}
+
+func (h *CartHandler) UpdateCartItem(ctx context.Context, req *pb.UpdateCartItemRequest) (*pb.Cart, error) {
+	cart, err := h.usecase.UpdateItem(ctx, ownerFromProto(req.Owner), model.CartItem{
+		ProductID: req.ProductId,
+		SKU:       req.Sku,
+		Quantity:  int(req.Quantity),
+	})
+	if err != nil {
+		return nil, cartError(err)
+	}
+	return cartToProto(cart), nil
+}
+
+func (h *CartHandler) RemoveCartItem(ctx context.Context, req *pb.RemoveCartItemRequest) (*pb.Cart, error) {
+	cart, err := h.usecase.RemoveItem(ctx, ownerFromProto(req.Owner), req.ProductId, req.Sku)
+	if err != nil {
+		return nil, cartError(err)
+	}
+	return cartToProto(cart), nil
+}
+
+func (h *CartHandler) MergeCart(ctx context.Context, req *pb.MergeCartRequest) (*pb.Cart, error) {
+	cart, err := h.usecase.MergeCart(ctx, req.GuestId, req.UserId)
+	if err != nil {
+		return nil, cartError(err)
+	}
+	return cartToProto(cart), nil
+}
+
+func (h *CartHandler) Checkout(ctx context.Context, req *pb.CheckoutRequest) (*pb.CheckoutResponse, error) {
+	order, replayed, err := h.usecase.Checkout(ctx, idempotencyKey(ctx), &model.Order{
+		UserID:          req.UserId,
+		Total:           money.New(req.GetTotal().GetAmount(), req.GetTotal().GetCurrency()),
+		PromoCode:       req.PromoCode,
+		Region:          req.Region,
+		AddressID:       req.AddressId,
+		ShippingAddress: addressFromProto(req.ShippingAddress),
+	})
+	if err != nil {
+		return nil, cartError(err)
+	}
+	if replayed {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(idempotentReplayedKey, "true"))
+	}
+	return &pb.CheckoutResponse{OrderId: order.ID, Total: moneyToProto(order.Total)}, nil
+}
+
+func cartError(err error) error {
+	switch {
+	case errors.Is(err, usecase.ErrCartEmpty),
+		errors.Is(err, usecase.ErrCartNotReady),
+		errors.Is(err, usecase.ErrInsufficientStock),
+		errors.Is(err, usecase.ErrTotalMismatch),
+		errors.Is(err, usecase.ErrIdempotencyKeyReused):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, usecase.ErrRequestInProgress), errors.Is(err, usecase.ErrCartBusy):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, usecase.ErrCatalogUnavailable), errors.Is(err, usecase.ErrAddressBookUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+func ownerFromProto(o *pb.CartOwner) model.CartOwner {
+	return model.CartOwner{UserID: o.GetUserId(), GuestID: o.GetGuestId()}
+}
+
+func cartToProto(cart *model.PricedCart) *pb.Cart {
+	resp := &pb.Cart{
+		Owner: &pb.CartOwner{UserId: cart.Owner.UserID, GuestId: cart.Owner.GuestID},
+		Total: moneyToProto(cart.Total),
+	}
+	for _, line := range cart.Lines {
+		resp.Items = append(resp.Items, &pb.CartItem{
+			ProductId: line.ProductID,
+			Sku:       line.SKU,
+			Quantity:  int32(line.Quantity),
+			Name:      line.Name,
+			UnitPrice: moneyToProto(line.UnitPrice),
+			LineTotal: moneyToProto(line.LineTotal),
+			Available: line.Available,
+			Problem:   line.Problem,
+		})
+	}
+	return resp
+}
@@ -2,14 +2,24 @@ package handler
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
 
 	"order-service/internal/events"
 	"order-service/internal/model"
 	"order-service/internal/pb"
+	pbaddress "order-service/internal/pb/address"
+	pbmoney "order-service/internal/pb/money"
+	"order-service/internal/pricing"
+	"order-service/internal/repository"
 	"order-service/internal/usecase"
 
+	"golang/pkg/address"
+	"golang/pkg/money"
+
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -28,22 +38,42 @@ func NewOrderHandler(u *usecase.OrderUsecase, p queue.Publisher) *OrderHandler {
 
 func (h *OrderHandler) CreateOrder(ctx context.Context, req *pb.CreateOrderRequest) (*pb.CreateOrderResponse, error) {
 	// Log the incoming request for debugging
-	log.Printf("Received CreateOrder request: user_id=%s, total=%f, items=%+v", req.UserId, req.Total, req.Items)
+	slog.DebugContext(ctx, "create order request", "user_id", req.UserId, "total", req.GetTotal().GetAmount(), "items", len(req.Items))
 
 	order := &model.Order{
-		UserID: req.UserId,
-		Total:  req.Total,
+		UserID:          req.UserId,
+		Total:           money.New(req.GetTotal().GetAmount(), req.GetTotal().GetCurrency()),
+		PromoCode:       req.PromoCode,
+		Region:          req.Region,
+		AddressID:       req.AddressId,
+		ShippingAddress: addressFromProto(req.ShippingAddress),
 	}
 	for _, item := range req.Items {
 		order.Products = append(order.Products, model.Product{
 			ProductID: item.ProductId, // (e.g., 68016c6489e4500884e8382f)
+			SKU:       item.Sku,
 			Quantity:  int(item.Quantity),
 		})
 	}
 
-	id, err := h.usecase.CreateOrder(ctx, order)
-	if err != nil {
+	id, replayed, err := h.usecase.CreateOrderIdempotent(ctx, idempotencyKey(ctx), order)
+	switch {
+	case errors.Is(err, usecase.ErrIdempotencyKeyReused):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, usecase.ErrRequestInProgress):
+		return nil, status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, usecase.ErrTotalMismatch):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, usecase.ErrCatalogUnavailable), errors.Is(err, usecase.ErrAddressBookUnavailable):
+		return nil, status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, usecase.ErrInvalidOrder), errors.Is(err, usecase.ErrPromotionNotApplicable),
+		errors.Is(err, address.ErrInvalid), errors.Is(err, pricing.ErrInvalidRegion), errors.Is(err, money.ErrOverflow):
 		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if replayed {
+		_ = grpc.SetHeader(ctx, metadata.Pairs(idempotentReplayedKey, "true"))
 	}
 
 	return &pb.CreateOrderResponse{
@@ -52,29 +82,128 @@ func (h *OrderHandler) CreateOrder(ctx context.Context, req *pb.CreateOrderReque
 	}, nil
 }
 
+const (
+	// idempotencyKeyMetadata carries the client's Idempotency-Key header.
+	idempotencyKeyMetadata = "idempotency-key"
+	// idempotentReplayedKey is set on responses answered from a previous request.
+	idempotentReplayedKey = "idempotent-replayed"
+)
+
+func idempotencyKey(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if v := md.Get(idempotencyKeyMetadata); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// moneyToProto leaves amounts that were never set, such as unit prices of
+// orders placed before prices were recorded, unset.
+func moneyToProto(m money.Money) *pbmoney.Money {
+	if m == (money.Money{}) {
+		return nil
+	}
+	return &pbmoney.Money{Currency: m.Currency, Amount: m.Amount}
+}
+
+// addressFromProto returns nil for an address that was not sent.
+func addressFromProto(a *pbaddress.Address) *address.Address {
+	if a == nil {
+		return nil
+	}
+	return &address.Address{
+		Name:       a.Name,
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		Region:     a.Region,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+		Phone:      a.Phone,
+	}
+}
+
+func addressToProto(a *address.Address) *pbaddress.Address {
+	if a == nil {
+		return nil
+	}
+	return &pbaddress.Address{
+		Name:       a.Name,
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		Region:     a.Region,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+		Phone:      a.Phone,
+	}
+}
+
 func (h *OrderHandler) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.GetOrderResponse, error) {
 	order, err := h.usecase.GetOrder(ctx, req.Id)
 	if err != nil {
 		return nil, status.Error(codes.NotFound, err.Error())
 	}
+	return orderToProto(order), nil
+}
+
+func orderToProto(order *model.Order) *pb.GetOrderResponse {
 	resp := &pb.GetOrderResponse{
-		Id:     order.ID,
-		UserId: order.UserID,
-		Total:  order.Total,
-		Status: order.Status,
+		Id:              order.ID,
+		UserId:          order.UserID,
+		Subtotal:        moneyToProto(order.Subtotal),
+		Tax:             moneyToProto(order.Tax),
+		Shipping:        moneyToProto(order.Shipping),
+		Total:           moneyToProto(order.Total),
+		PromoCode:       order.PromoCode,
+		Region:          order.Region,
+		ShippingAddress: addressToProto(order.ShippingAddress),
+		AddressId:       order.AddressID,
+		PaymentId:       order.PaymentID,
+		InvoiceNumber:   order.InvoiceNumber,
+		Status:          order.Status,
 	}
 	for _, p := range order.Products {
 		resp.Items = append(resp.Items, &pb.OrderItem{
-			ProductId: p.ProductID,
-			Quantity:  int32(p.Quantity),
+			ProductId:        p.ProductID,
+			Sku:              p.SKU,
+			Quantity:         int32(p.Quantity),
+			UnitPrice:        moneyToProto(p.UnitPrice),
+			ReturnedQuantity: int32(p.Returned),
 		})
 	}
-	return resp, nil
+	for i := range order.Returns {
+		resp.Returns = append(resp.Returns, returnToProto(&order.Returns[i]))
+	}
+	for _, a := range order.Adjustments {
+		resp.Adjustments = append(resp.Adjustments, &pb.Adjustment{
+			PromotionId: a.PromotionID,
+			Code:        a.Code,
+			Description: a.Description,
+			ProductId:   a.ProductID,
+			Sku:         a.SKU,
+			Amount:      moneyToProto(a.Amount),
+		})
+	}
+	return resp
 }
 
 func (h *OrderHandler) UpdateOrderStatus(ctx context.Context, req *pb.UpdateOrderStatusRequest) (*pb.UpdateOrderStatusResponse, error) {
-	err := h.usecase.UpdateOrderStatus(ctx, req.Id, req.Status)
-	if err != nil {
+	err := h.usecase.UpdateOrderStatus(ctx, req.Id, req.UserId, req.Status)
+	switch {
+	case err == nil:
+	case errors.Is(err, repository.ErrOrderNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, usecase.ErrCancelOnly):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, usecase.ErrOrderPaid), errors.Is(err, usecase.ErrPromotionNotApplicable):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, usecase.ErrStatusConflict):
+		return nil, status.Error(codes.Aborted, err.Error())
+	default:
 		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
 	return &pb.UpdateOrderStatusResponse{
@@ -90,19 +219,7 @@ func (h *OrderHandler) ListUserOrders(ctx context.Context, req *pb.ListUserOrder
 	}
 	resp := &pb.ListUserOrdersResponse{}
 	for _, order := range orders {
-		orderResp := &pb.GetOrderResponse{
-			Id:     order.ID,
-			UserId: order.UserID,
-			Total:  order.Total,
-			Status: order.Status,
-		}
-		for _, p := range order.Products {
-			orderResp.Items = append(orderResp.Items, &pb.OrderItem{
-				ProductId: p.ProductID,
-				Quantity:  int32(p.Quantity),
-			})
-		}
-		resp.Orders = append(resp.Orders, orderResp)
+		resp.Orders = append(resp.Orders, orderToProto(order))
 	}
 	return resp, nil
 }
\ No newline at end of file
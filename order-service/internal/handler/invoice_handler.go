@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"order-service/internal/pb"
+	"order-service/internal/repository"
+	"order-service/internal/usecase"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type InvoiceHandler struct {
+	pb.UnimplementedInvoiceServiceServer
+	usecase *usecase.InvoiceUsecase
+}
+
+func NewInvoiceHandler(u *usecase.InvoiceUsecase) *InvoiceHandler {
+	return &InvoiceHandler{usecase: u}
+}
+
+func (h *InvoiceHandler) GetInvoice(ctx context.Context, req *pb.GetInvoiceRequest) (*pb.Invoice, error) {
+	inv, pdf, err := h.usecase.GetInvoice(ctx, req.OrderId, req.UserId)
+	if err != nil {
+		return nil, invoiceError(err)
+	}
+	return &pb.Invoice{
+		Number:   inv.Number,
+		OrderId:  inv.OrderID,
+		Total:    moneyToProto(inv.Total),
+		IssuedAt: timestamppb.New(inv.IssuedAt),
+		Pdf:      pdf,
+	}, nil
+}
+
+func invoiceError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrOrderNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, repository.ErrInvalidID):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, usecase.ErrOrderNotInvoiceable):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, usecase.ErrCatalogUnavailable), errors.Is(err, usecase.ErrInvoiceStoreUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
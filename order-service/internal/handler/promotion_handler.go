@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"order-service/internal/model"
+	"order-service/internal/pb"
+	"order-service/internal/usecase"
+
+	"golang/pkg/money"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type PromotionHandler struct {
+	pb.UnimplementedPromotionServiceServer
+	usecase *usecase.PromotionUsecase
+}
+
+func NewPromotionHandler(u *usecase.PromotionUsecase) *PromotionHandler {
+	return &PromotionHandler{usecase: u}
+}
+
+func (h *PromotionHandler) CreatePromotion(ctx context.Context, req *pb.CreatePromotionRequest) (*pb.Promotion, error) {
+	p, err := h.usecase.CreatePromotion(ctx, promotionFromProto(req.Promotion))
+	if err != nil {
+		return nil, promotionError(err)
+	}
+	return promotionToProto(p), nil
+}
+
+func (h *PromotionHandler) GetPromotion(ctx context.Context, req *pb.GetPromotionRequest) (*pb.Promotion, error) {
+	p, err := h.usecase.GetPromotion(ctx, req.Id)
+	if err != nil {
+		return nil, promotionError(err)
+	}
+	return promotionToProto(p), nil
+}
+
+func (h *PromotionHandler) UpdatePromotion(ctx context.Context, req *pb.UpdatePromotionRequest) (*pb.Promotion, error) {
+	p, err := h.usecase.UpdatePromotion(ctx, promotionFromProto(req.Promotion))
+	if err != nil {
+		return nil, promotionError(err)
+	}
+	return promotionToProto(p), nil
+}
+
+func (h *PromotionHandler) ListPromotions(ctx context.Context, req *pb.ListPromotionsRequest) (*pb.ListPromotionsResponse, error) {
+	promotions, err := h.usecase.ListPromotions(ctx, req.CurrentOnly)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	resp := &pb.ListPromotionsResponse{}
+	for _, p := range promotions {
+		resp.Promotions = append(resp.Promotions, promotionToProto(p))
+	}
+	return resp, nil
+}
+
+func promotionError(err error) error {
+	switch err.Error() {
+	case "promotion not found":
+		return status.Error(codes.NotFound, err.Error())
+	case "promotion code already exists":
+		return status.Error(codes.AlreadyExists, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+func promotionFromProto(p *pb.Promotion) *model.Promotion {
+	return &model.Promotion{
+		ID:             p.GetId(),
+		Code:           p.GetCode(),
+		Description:    p.GetDescription(),
+		Type:           p.GetType(),
+		PercentOff:     p.GetPercentOff(),
+		AmountOff:      money.New(p.GetAmountOff().GetAmount(), p.GetAmountOff().GetCurrency()),
+		ProductIDs:     p.GetProductIds(),
+		CategoryIDs:    p.GetCategoryIds(),
+		MinSubtotal:    money.New(p.GetMinSubtotal().GetAmount(), p.GetMinSubtotal().GetCurrency()),
+		StartsAt:       timeFromProto(p.GetStartsAt()),
+		EndsAt:         timeFromProto(p.GetEndsAt()),
+		MaxUses:        p.GetMaxUses(),
+		MaxUsesPerUser: p.GetMaxUsesPerUser(),
+		Active:         p.GetActive(),
+	}
+}
+
+func promotionToProto(p *model.Promotion) *pb.Promotion {
+	return &pb.Promotion{
+		Id:             p.ID,
+		Code:           p.Code,
+		Description:    p.Description,
+		Type:           p.Type,
+		PercentOff:     p.PercentOff,
+		AmountOff:      moneyToProto(p.AmountOff),
+		ProductIds:     p.ProductIDs,
+		CategoryIds:    p.CategoryIDs,
+		MinSubtotal:    moneyToProto(p.MinSubtotal),
+		StartsAt:       timeToProto(p.StartsAt),
+		EndsAt:         timeToProto(p.EndsAt),
+		MaxUses:        p.MaxUses,
+		MaxUsesPerUser: p.MaxUsesPerUser,
+		Uses:           p.Uses,
+		Active:         p.Active,
+	}
+}
+
+// timeFromProto and timeToProto map an unset timestamp to the zero time and
+// back.
+func timeFromProto(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+func timeToProto(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
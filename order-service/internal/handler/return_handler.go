@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"order-service/internal/model"
+	"order-service/internal/pb"
+	"order-service/internal/repository"
+	"order-service/internal/usecase"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type ReturnHandler struct {
+	pb.UnimplementedReturnServiceServer
+	usecase *usecase.ReturnUsecase
+}
+
+func NewReturnHandler(u *usecase.ReturnUsecase) *ReturnHandler {
+	return &ReturnHandler{usecase: u}
+}
+
+func (h *ReturnHandler) CreateReturn(ctx context.Context, req *pb.CreateReturnRequest) (*pb.Return, error) {
+	var items []model.ReturnItem
+	for _, item := range req.Items {
+		items = append(items, model.ReturnItem{
+			ProductID: item.ProductId,
+			SKU:       item.Sku,
+			Quantity:  int(item.Quantity),
+		})
+	}
+	ret, err := h.usecase.CreateReturn(ctx, req.OrderId, req.UserId, items, req.Reason)
+	if err != nil {
+		return nil, returnError(err)
+	}
+	return returnToProto(ret), nil
+}
+
+func returnError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrOrderNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, repository.ErrInvalidID), errors.Is(err, usecase.ErrInvalidReturn):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, usecase.ErrOrderNotReturnable), errors.Is(err, usecase.ErrOrderNotPaid),
+		errors.Is(err, usecase.ErrTooManyReturned):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, usecase.ErrReturnConflict):
+		return status.Error(codes.Aborted, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func returnToProto(ret *model.Return) *pb.Return {
+	resp := &pb.Return{
+		Id:           ret.ID,
+		Refund:       moneyToProto(ret.Refund),
+		Reason:       ret.Reason,
+		RefundStatus: ret.RefundStatus,
+		CreatedAt:    timestamppb.New(ret.CreatedAt),
+	}
+	for _, item := range ret.Items {
+		resp.Items = append(resp.Items, &pb.ReturnItem{
+			ProductId: item.ProductID,
+			Sku:       item.SKU,
+			Quantity:  int32(item.Quantity),
+			Refund:    moneyToProto(item.Refund),
+		})
+	}
+	return resp
+}
@@ -0,0 +1,85 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+
+	"order-service/internal/model"
+	pb "order-service/internal/pb/inventory"
+	pbmoney "order-service/internal/pb/money"
+
+	"golang/pkg/money"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrProductNotFound is returned by Catalog.GetProduct for unknown product IDs.
+var ErrProductNotFound = errors.New("product not found")
+
+// Catalog looks up products in inventory-service.
+type Catalog interface {
+	GetProduct(ctx context.Context, id string) (*model.CatalogProduct, error)
+	// CategoryPath returns the IDs of a category and its ancestors.
+	CategoryPath(ctx context.Context, categoryID string) ([]string, error)
+}
+
+type GRPCCatalog struct {
+	client pb.InventoryServiceClient
+}
+
+func NewGRPCCatalog(conn grpc.ClientConnInterface) *GRPCCatalog {
+	return &GRPCCatalog{client: pb.NewInventoryServiceClient(conn)}
+}
+
+func (c *GRPCCatalog) GetProduct(ctx context.Context, id string) (*model.CatalogProduct, error) {
+	resp, err := c.client.GetProduct(ctx, &pb.GetProductRequest{Id: id})
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.NotFound, codes.InvalidArgument:
+		return nil, ErrProductNotFound
+	default:
+		return nil, err
+	}
+
+	p := resp.GetProduct()
+	out := &model.CatalogProduct{
+		ID:         p.GetId(),
+		Name:       p.GetName(),
+		Price:      moneyFromProto(p.GetPrice()),
+		Stock:      p.GetStock(),
+		CategoryID: p.GetCategoryId(),
+		Deleted:    p.GetDeletedAt() != nil,
+	}
+	for _, v := range p.GetVariants() {
+		price := moneyFromProto(v.GetPriceOverride())
+		if price.IsZero() {
+			price = out.Price
+		}
+		out.Variants = append(out.Variants, model.CatalogVariant{
+			SKU:         v.GetSku(),
+			Price:       price,
+			Stock:       v.GetStock(),
+			WeightGrams: v.GetWeightGrams(),
+		})
+	}
+	return out, nil
+}
+
+func (c *GRPCCatalog) CategoryPath(ctx context.Context, categoryID string) ([]string, error) {
+	resp, err := c.client.GetCategory(ctx, &pb.GetCategoryRequest{Id: categoryID})
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.NotFound, codes.InvalidArgument:
+		// Products of a deleted category belong to no category.
+		return nil, nil
+	default:
+		return nil, err
+	}
+	return append(resp.GetCategory().GetAncestorIds(), categoryID), nil
+}
+
+func moneyFromProto(m *pbmoney.Money) money.Money {
+	return money.New(m.GetAmount(), m.GetCurrency())
+}
@@ -0,0 +1,163 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// pageWidth and pageHeight are the size of an A4 page in points.
+const (
+	pageWidth  = 595
+	pageHeight = 842
+)
+
+// document builds a PDF of text and rules, which is all invoices need. Text
+// is set in the standard Helvetica fonts, which every reader has, so nothing
+// is embedded; characters those fonts lack print as "?".
+type document struct {
+	title   string
+	created time.Time
+	pages   []*bytes.Buffer // content streams
+}
+
+func newDocument(title string, created time.Time) *document {
+	return &document{title: title, created: created}
+}
+
+// page starts a new page; later drawing goes to it.
+func (d *document) page() {
+	d.pages = append(d.pages, new(bytes.Buffer))
+}
+
+func (d *document) current() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.page()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// text sets s with its baseline starting at x, y, measured from the
+// bottom-left corner of the page.
+func (d *document) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(d.current(), "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, escape(s))
+}
+
+// textRight sets s so that it ends at x.
+func (d *document) textRight(x, y, size float64, bold bool, s string) {
+	d.text(x-textWidth(s, size), y, size, bold, s)
+}
+
+// rule draws a horizontal line from x1 to x2.
+func (d *document) rule(x1, x2, y float64) {
+	fmt.Fprintf(d.current(), "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y, x2, y)
+}
+
+// bytes lays out the objects of the document: the catalog, the page tree,
+// the two fonts, then a page and its content stream for every page, and the
+// info dictionary last.
+func (d *document) bytes() []byte {
+	if len(d.pages) == 0 {
+		d.page()
+	}
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()))
+	}
+	info := len(offsets) + 1
+	object(fmt.Sprintf("<< /Title (%s) /Producer (order-service) /CreationDate (D:%s) >>",
+		escape(d.title), d.created.UTC().Format("20060102150405Z")))
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, info, xref)
+	return out.Bytes()
+}
+
+// escape encodes s as the body of a PDF string in WinAnsiEncoding, which
+// matches Latin-1 from 0xA0 up.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// helveticaWidths are the advance widths of the printable ASCII characters
+// in Helvetica, in thousandths of the font size. Helvetica-Bold is close
+// enough for aligning numbers, whose digits are as wide in both.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0 to 9
+	278, 278, 584, 584, 584, 556, 1015, // : to @
+	667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, // A to M
+	722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N to Z
+	278, 278, 278, 469, 556, 333, // [ to `
+	556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, // a to m
+	556, 556, 556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, // n to z
+	334, 260, 334, 584, // { to ~
+}
+
+// textWidth returns how wide s is set in Helvetica at size, in points.
+// Characters outside ASCII are taken to be as wide as an "n".
+func textWidth(s string, size float64) float64 {
+	var w int
+	for _, r := range s {
+		if r >= 0x20 && r < 0x7f {
+			w += helveticaWidths[r-0x20]
+		} else {
+			w += 556
+		}
+	}
+	return float64(w) * size / 1000
+}
+
+// truncate shortens s with an ellipsis until it is at most width points wide
+// at size.
+func truncate(s string, size, width float64) string {
+	if textWidth(s, size) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && textWidth(string(runes)+"...", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
@@ -0,0 +1,154 @@
+// Package invoice renders the invoices of paid orders as PDF.
+package invoice
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"order-service/internal/model"
+
+	"golang/pkg/address"
+	"golang/pkg/money"
+)
+
+// Layout of a page, in points from its bottom-left corner.
+const (
+	left      = 50.0
+	right     = pageWidth - 50.0
+	top       = pageHeight - 60.0
+	bottom    = 70.0
+	lineGap   = 16.0
+	qtyRight  = 340.0 // right edge of the quantity column
+	unitRight = 450.0 // right edge of the unit price column
+	descWidth = 250.0 // widest item description
+)
+
+// Render returns inv as a PDF. issuer, printed at the top, names the seller;
+// it may be empty. The same invoice always renders to the same bytes.
+func Render(inv *model.Invoice, issuer string) []byte {
+	r := &renderer{doc: newDocument("Invoice "+inv.Number, inv.IssuedAt)}
+	r.doc.page()
+	r.y = top
+
+	if issuer != "" {
+		r.doc.text(left, r.y, 12, true, issuer)
+		r.y -= 28
+	}
+	r.doc.text(left, r.y, 20, true, "Invoice "+inv.Number)
+	r.y -= 26
+	r.field("Issued", inv.IssuedAt.UTC().Format("2 January 2006"))
+	r.field("Order", inv.OrderID)
+	r.field("Payment", inv.PaymentID)
+	if a := inv.ShippingAddress; a != nil {
+		r.y -= 8
+		r.doc.text(left, r.y, 10, true, "Ship to")
+		r.y -= lineGap
+		for _, line := range addressLines(a) {
+			r.doc.text(left, r.y, 10, false, line)
+			r.y -= 14
+		}
+	}
+
+	r.y -= 14
+	r.header()
+	for _, l := range inv.Lines {
+		r.room(lineGap)
+		r.doc.text(left, r.y, 10, false, truncate(l.Description, 10, descWidth))
+		r.doc.textRight(qtyRight, r.y, 10, false, strconv.Itoa(l.Quantity))
+		r.doc.textRight(unitRight, r.y, 10, false, l.UnitPrice.String())
+		r.doc.textRight(right, r.y, 10, false, l.Amount.String())
+		r.y -= lineGap
+	}
+	for _, a := range inv.Adjustments {
+		r.room(lineGap)
+		desc := a.Description
+		if a.Code != "" {
+			desc += " (" + a.Code + ")"
+		}
+		r.doc.text(left, r.y, 10, false, truncate("Discount: "+desc, 10, unitRight-left))
+		r.doc.textRight(right, r.y, 10, false, a.Amount.String())
+		r.y -= lineGap
+	}
+
+	r.room(6 * lineGap)
+	r.doc.rule(left, right, r.y+10)
+	r.y -= 6
+	r.total("Subtotal", inv.Subtotal, false)
+	if discount := discounts(inv); discount.Amount != 0 {
+		r.total("Discounts", discount, false)
+	}
+	r.total("Tax", inv.Tax, false)
+	r.total("Shipping", inv.Shipping, false)
+	r.total("Total", inv.Total, true)
+
+	r.y -= 20
+	r.room(lineGap)
+	r.doc.text(left, r.y, 10, false, "Paid in full. Thank you for your order.")
+	return r.doc.bytes()
+}
+
+type renderer struct {
+	doc *document
+	y   float64 // baseline of the next line
+}
+
+func (r *renderer) field(name, value string) {
+	if value == "" {
+		return
+	}
+	r.doc.text(left, r.y, 10, true, name+":")
+	r.doc.text(left+60, r.y, 10, false, value)
+	r.y -= 14
+}
+
+func (r *renderer) header() {
+	r.doc.text(left, r.y, 10, true, "Item")
+	r.doc.textRight(qtyRight, r.y, 10, true, "Qty")
+	r.doc.textRight(unitRight, r.y, 10, true, "Unit price")
+	r.doc.textRight(right, r.y, 10, true, "Amount")
+	r.doc.rule(left, right, r.y-5)
+	r.y -= lineGap + 4
+}
+
+// room moves to a new page, repeating the column headers, unless height
+// points are left on this one.
+func (r *renderer) room(height float64) {
+	if r.y-height >= bottom {
+		return
+	}
+	r.doc.page()
+	r.y = top
+	r.header()
+}
+
+func (r *renderer) total(name string, amount money.Money, bold bool) {
+	r.doc.textRight(unitRight, r.y, 10, bold, name)
+	r.doc.textRight(right, r.y, 10, bold, amount.String())
+	r.y -= lineGap
+}
+
+// discounts returns the sum of the invoice's adjustments.
+func discounts(inv *model.Invoice) money.Money {
+	sum := money.New(0, inv.Total.Currency)
+	for _, a := range inv.Adjustments {
+		sum.Amount += a.Amount.Amount
+	}
+	return sum
+}
+
+func addressLines(a *address.Address) []string {
+	var lines []string
+	for _, s := range []string{
+		a.Name,
+		a.Line1,
+		a.Line2,
+		strings.TrimSpace(fmt.Sprintf("%s %s", a.PostalCode, a.City)),
+		strings.TrimSpace(fmt.Sprintf("%s %s", a.Region, a.Country)),
+	} {
+		if s != "" {
+			lines = append(lines, s)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,63 @@
+package model
+
+import (
+	"time"
+
+	"golang/pkg/money"
+)
+
+// CartOwner names a cart: a logged-in user's or a guest's.
+type CartOwner struct {
+	UserID  string
+	GuestID string
+}
+
+// Cart is the items a shopper means to order.
+type Cart struct {
+	Items []CartItem
+	// LastOrderID is the order created by the last checkout, and
+	// LastCheckoutKey the idempotency key it was made with, so that a retried
+	// checkout can be answered after the cart was emptied.
+	LastOrderID     string
+	LastCheckoutKey string
+	UpdatedAt       time.Time
+	// Version counts the saves of the cart. A cart is only saved if the
+	// stored one is still at the version it was read at; zero means it was
+	// not stored.
+	Version int64
+}
+
+type CartItem struct {
+	ProductID string
+	SKU       string
+	Quantity  int
+}
+
+// Find returns the index of the item for the given variant, or -1.
+func (c *Cart) Find(productID, sku string) int {
+	for i, item := range c.Items {
+		if item.ProductID == productID && item.SKU == sku {
+			return i
+		}
+	}
+	return -1
+}
+
+// CartLine is a cart item with its current catalog details.
+type CartLine struct {
+	CartItem
+	Name      string
+	UnitPrice money.Money
+	LineTotal money.Money
+	Available int32
+	// Problem says why the item cannot be ordered right now; empty if it can.
+	Problem string
+}
+
+// PricedCart is a cart at current prices and stock.
+type PricedCart struct {
+	Owner CartOwner
+	Lines []CartLine
+	// Total is the sum of the lines without a problem.
+	Total money.Money
+}
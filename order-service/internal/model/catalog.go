@@ -0,0 +1,40 @@
+package model
+
+import "golang/pkg/money"
+
+// CatalogProduct is the view of an inventory-service product that orders
+// are checked against.
+type CatalogProduct struct {
+	ID         string
+	Name       string
+	Price      money.Money
+	Stock      int32
+	CategoryID string
+	Deleted    bool
+	Variants   []CatalogVariant
+}
+
+// CatalogVariant is a sellable variant of a CatalogProduct.
+type CatalogVariant struct {
+	SKU         string
+	Price       money.Money // effective price, with any override applied
+	Stock       int32
+	WeightGrams int32
+}
+
+// Variant returns the variant with the given SKU. An empty SKU selects the
+// only variant of a single-variant product.
+func (p *CatalogProduct) Variant(sku string) (CatalogVariant, bool) {
+	if sku == "" {
+		if len(p.Variants) == 1 {
+			return p.Variants[0], true
+		}
+		return CatalogVariant{}, false
+	}
+	for _, v := range p.Variants {
+		if v.SKU == sku {
+			return v, true
+		}
+	}
+	return CatalogVariant{}, false
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+const (
+	IdempotencyInProgress = "IN_PROGRESS"
+	IdempotencyCompleted  = "COMPLETED"
+)
+
+// IdempotencyRecord remembers the outcome of a request made with an
+// Idempotency-Key so that retries can be answered with the original response.
+type IdempotencyRecord struct {
+	Key         string
+	RequestHash string
+	Status      string
+	OrderID     string
+	LockedAt    time.Time
+	CreatedAt   time.Time
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"fmt"
+	"time"
+
+	"golang/pkg/address"
+	"golang/pkg/money"
+)
+
+// Invoice is the bill of a paid order. Its lines and amounts are copied from
+// the order when it is issued and never change afterwards.
+type Invoice struct {
+	Seq             int64  // 1, 2, 3, ... in the order invoices were issued, with no gaps
+	Number          string // Seq as printed, e.g. INV-000042
+	OrderID         string
+	UserID          string
+	PaymentID       string
+	Lines           []InvoiceLine
+	Adjustments     []Adjustment // discounts
+	Subtotal        money.Money
+	Tax             money.Money
+	Shipping        money.Money
+	Total           money.Money
+	ShippingAddress *address.Address
+	IssuedAt        time.Time
+	Published       bool // the order.invoiced event went out
+	Sent            bool // email-service confirmed it emailed the invoice
+}
+
+type InvoiceLine struct {
+	Description string // product name, with the SKU of variants
+	ProductID   string
+	SKU         string
+	Quantity    int
+	UnitPrice   money.Money
+	Amount      money.Money // UnitPrice times Quantity
+}
+
+// InvoiceNumber formats the sequence number of an invoice as printed on it.
+func InvoiceNumber(seq int64) string {
+	return fmt.Sprintf("INV-%06d", seq)
+}
@@ -1,14 +1,50 @@
 package model
 
+import (
+	"golang/pkg/address"
+	"golang/pkg/money"
+)
+
 type Product struct {
-	ProductID string
-	Quantity  int
+	ProductID   string
+	SKU         string // variant ordered
+	Quantity    int
+	UnitPrice   money.Money // catalog price when the order was placed
+	CategoryID  string      // catalog category when the order was placed
+	WeightGrams int32       // shipping weight of one unit
+	Returned    int         // units returned so far
 }
 
 type Order struct {
-	ID        string
-	UserID    string
-	Products  []Product
-	Total     float64
-	Status    string
-}
\ No newline at end of file
+	ID          string
+	UserID      string
+	Products    []Product
+	Subtotal    money.Money  // sum of the lines at their unit prices
+	Adjustments []Adjustment // discounts
+	Shipping    money.Money
+	Tax         money.Money
+	Total       money.Money // Subtotal plus Adjustments, Shipping and Tax
+	PromoCode   string      // coupon code given with the order
+	Region      string      // ISO 3166 region the order ships to, e.g. US-CA
+	// AddressID names an address book entry to ship to; when the order is
+	// placed it is copied into ShippingAddress, which is never updated
+	// afterwards.
+	AddressID       string
+	ShippingAddress *address.Address
+	// PaymentID is the payment-service payment that settled the order:
+	// COMPLETED orders were paid by it, CANCELLED ones failed to be.
+	PaymentID string
+	// InvoiceNumber is set once the paid order's invoice is issued.
+	InvoiceNumber string
+	// IdempotencyKey is the user-scoped Idempotency-Key the order was
+	// created with, if any; no two orders share one.
+	IdempotencyKey string `json:"-"`
+	Returns       []Return // oldest first
+	Status        string
+
+	// StockPublished is false while the event telling inventory-service to
+	// take or give back the order's stock is still to go out: order.created
+	// while the order is PENDING or COMPLETED, order.cancelled once it is
+	// CANCELLED.
+	StockPublished bool `json:"-"`
+}
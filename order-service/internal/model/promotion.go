@@ -0,0 +1,60 @@
+package model
+
+import (
+	"time"
+
+	"golang/pkg/money"
+)
+
+const (
+	PromotionPercentage = "PERCENTAGE"
+	PromotionFixed      = "FIXED"
+)
+
+// Promotion is a discount. Promotions with a Code are coupons applied when the
+// code is given with an order; those without apply to every order that
+// qualifies.
+type Promotion struct {
+	ID          string
+	Code        string
+	Description string
+	Type        string
+	PercentOff  int32       // 1 to 100, for PERCENTAGE promotions
+	AmountOff   money.Money // for FIXED promotions, taken off the order once
+	// ProductIDs and CategoryIDs, with their subcategories, limit the items
+	// discounted; when both are empty the whole order is.
+	ProductIDs     []string
+	CategoryIDs    []string
+	MinSubtotal    money.Money
+	StartsAt       time.Time // zero for no start
+	EndsAt         time.Time // zero for no end
+	MaxUses        int64     // 0 for unlimited
+	MaxUsesPerUser int64     // 0 for unlimited
+	Uses           int64
+	Active         bool
+	CreatedAt      time.Time
+}
+
+// Current reports whether the promotion is active and within its validity
+// window at t.
+func (p *Promotion) Current(t time.Time) bool {
+	return p.Active &&
+		(p.StartsAt.IsZero() || !t.Before(p.StartsAt)) &&
+		(p.EndsAt.IsZero() || t.Before(p.EndsAt))
+}
+
+// Scoped reports whether the promotion is limited to some items.
+func (p *Promotion) Scoped() bool {
+	return len(p.ProductIDs) > 0 || len(p.CategoryIDs) > 0
+}
+
+// Adjustment is a discount applied to an order by one promotion, either to
+// one line or, with an empty ProductID, to the whole order.
+type Adjustment struct {
+	PromotionID string
+	Code        string
+	Description string
+	ProductID   string
+	SKU         string
+	Amount      money.Money // negative for discounts
+}
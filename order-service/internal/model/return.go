@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"golang/pkg/money"
+)
+
+// Refund statuses of returns.
+const (
+	// RefundPending returns are still to be refunded through the order's
+	// payment.
+	RefundPending = "PENDING"
+	RefundDone    = "REFUNDED"
+	// RefundFailed returns were refused by the payment, e.g. because it
+	// has too little left to refund.
+	RefundFailed = "FAILED"
+)
+
+// Return gives back some units of a completed order's lines.
+type Return struct {
+	ID           string
+	Items        []ReturnItem
+	Refund       money.Money // sum of the items' refunds
+	Reason       string
+	RefundStatus string
+	CreatedAt    time.Time
+	// Published is set once the order.returned event restocking the items
+	// went out.
+	Published bool
+}
+
+type ReturnItem struct {
+	Line      int // index of the order line returned
+	ProductID string
+	SKU       string
+	Quantity  int
+	Refund    money.Money
+}
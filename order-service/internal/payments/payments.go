@@ -0,0 +1,52 @@
+package payments
+
+import (
+	"context"
+	"errors"
+
+	pbmoney "order-service/internal/pb/money"
+	pb "order-service/internal/pb/payment"
+
+	"golang/pkg/money"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrRefundRejected is returned by Payments.Refund for refunds payment-service
+// will not make however often they are retried, e.g. because they exceed
+// what is left of the payment.
+var ErrRefundRejected = errors.New("refund rejected")
+
+// Payments refunds the payments of orders in payment-service.
+type Payments interface {
+	// Refund gives amount of a payment back. Refunds with the same reference
+	// are made once.
+	Refund(ctx context.Context, paymentID string, amount money.Money, reference, reason string) error
+}
+
+type GRPCPayments struct {
+	client pb.PaymentServiceClient
+}
+
+func NewGRPCPayments(conn grpc.ClientConnInterface) *GRPCPayments {
+	return &GRPCPayments{client: pb.NewPaymentServiceClient(conn)}
+}
+
+func (p *GRPCPayments) Refund(ctx context.Context, paymentID string, amount money.Money, reference, reason string) error {
+	_, err := p.client.RefundPayment(ctx, &pb.RefundPaymentRequest{
+		Id:        paymentID,
+		Amount:    &pbmoney.Money{Amount: amount.Amount, Currency: amount.Currency},
+		Reference: reference,
+		Reason:    reason,
+	})
+	switch status.Code(err) {
+	case codes.OK:
+		return nil
+	case codes.NotFound, codes.InvalidArgument, codes.FailedPrecondition:
+		return ErrRefundRejected
+	default:
+		return err
+	}
+}
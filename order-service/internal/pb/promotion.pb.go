@@ -0,0 +1,524 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v6.30.2
+// source: proto/promotion.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	money "order-service/internal/pb/money"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Promotion struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Upper-case letters, digits, '-' and '_'; empty for automatic promotions.
+	Code        string `protobuf:"bytes,2,opt,name=code,proto3" json:"code,omitempty"`
+	Description string `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	// PERCENTAGE or FIXED.
+	Type string `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	// 1 to 100, for PERCENTAGE promotions.
+	PercentOff int32 `protobuf:"varint,5,opt,name=percent_off,json=percentOff,proto3" json:"percent_off,omitempty"`
+	// For FIXED promotions; taken off the order once.
+	AmountOff *money.Money `protobuf:"bytes,6,opt,name=amount_off,json=amountOff,proto3" json:"amount_off,omitempty"`
+	// Products and categories, with their subcategories, the discount applies
+	// to; when both are empty it applies to the whole order.
+	ProductIds  []string `protobuf:"bytes,7,rep,name=product_ids,json=productIds,proto3" json:"product_ids,omitempty"`
+	CategoryIds []string `protobuf:"bytes,8,rep,name=category_ids,json=categoryIds,proto3" json:"category_ids,omitempty"`
+	// Order subtotal needed for the promotion to apply.
+	MinSubtotal *money.Money `protobuf:"bytes,9,opt,name=min_subtotal,json=minSubtotal,proto3" json:"min_subtotal,omitempty"`
+	// Validity window; either end may be unset.
+	StartsAt *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=starts_at,json=startsAt,proto3" json:"starts_at,omitempty"`
+	EndsAt   *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=ends_at,json=endsAt,proto3" json:"ends_at,omitempty"`
+	// Usage limits; 0 means unlimited.
+	MaxUses        int64 `protobuf:"varint,12,opt,name=max_uses,json=maxUses,proto3" json:"max_uses,omitempty"`
+	MaxUsesPerUser int64 `protobuf:"varint,13,opt,name=max_uses_per_user,json=maxUsesPerUser,proto3" json:"max_uses_per_user,omitempty"`
+	// Orders placed with the promotion so far.
+	Uses          int64 `protobuf:"varint,14,opt,name=uses,proto3" json:"uses,omitempty"`
+	Active        bool  `protobuf:"varint,15,opt,name=active,proto3" json:"active,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Promotion) Reset() {
+	*x = Promotion{}
+	mi := &file_proto_promotion_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Promotion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Promotion) ProtoMessage() {}
+
+func (x *Promotion) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_promotion_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Promotion.ProtoReflect.Descriptor instead.
+func (*Promotion) Descriptor() ([]byte, []int) {
+	return file_proto_promotion_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Promotion) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Promotion) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Promotion) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Promotion) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Promotion) GetPercentOff() int32 {
+	if x != nil {
+		return x.PercentOff
+	}
+	return 0
+}
+
+func (x *Promotion) GetAmountOff() *money.Money {
+	if x != nil {
+		return x.AmountOff
+	}
+	return nil
+}
+
+func (x *Promotion) GetProductIds() []string {
+	if x != nil {
+		return x.ProductIds
+	}
+	return nil
+}
+
+func (x *Promotion) GetCategoryIds() []string {
+	if x != nil {
+		return x.CategoryIds
+	}
+	return nil
+}
+
+func (x *Promotion) GetMinSubtotal() *money.Money {
+	if x != nil {
+		return x.MinSubtotal
+	}
+	return nil
+}
+
+func (x *Promotion) GetStartsAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartsAt
+	}
+	return nil
+}
+
+func (x *Promotion) GetEndsAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndsAt
+	}
+	return nil
+}
+
+func (x *Promotion) GetMaxUses() int64 {
+	if x != nil {
+		return x.MaxUses
+	}
+	return 0
+}
+
+func (x *Promotion) GetMaxUsesPerUser() int64 {
+	if x != nil {
+		return x.MaxUsesPerUser
+	}
+	return 0
+}
+
+func (x *Promotion) GetUses() int64 {
+	if x != nil {
+		return x.Uses
+	}
+	return 0
+}
+
+func (x *Promotion) GetActive() bool {
+	if x != nil {
+		return x.Active
+	}
+	return false
+}
+
+type CreatePromotionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Promotion     *Promotion             `protobuf:"bytes,1,opt,name=promotion,proto3" json:"promotion,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreatePromotionRequest) Reset() {
+	*x = CreatePromotionRequest{}
+	mi := &file_proto_promotion_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreatePromotionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreatePromotionRequest) ProtoMessage() {}
+
+func (x *CreatePromotionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_promotion_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreatePromotionRequest.ProtoReflect.Descriptor instead.
+func (*CreatePromotionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_promotion_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreatePromotionRequest) GetPromotion() *Promotion {
+	if x != nil {
+		return x.Promotion
+	}
+	return nil
+}
+
+type GetPromotionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPromotionRequest) Reset() {
+	*x = GetPromotionRequest{}
+	mi := &file_proto_promotion_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPromotionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPromotionRequest) ProtoMessage() {}
+
+func (x *GetPromotionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_promotion_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPromotionRequest.ProtoReflect.Descriptor instead.
+func (*GetPromotionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_promotion_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetPromotionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type UpdatePromotionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Promotion     *Promotion             `protobuf:"bytes,1,opt,name=promotion,proto3" json:"promotion,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdatePromotionRequest) Reset() {
+	*x = UpdatePromotionRequest{}
+	mi := &file_proto_promotion_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdatePromotionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdatePromotionRequest) ProtoMessage() {}
+
+func (x *UpdatePromotionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_promotion_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdatePromotionRequest.ProtoReflect.Descriptor instead.
+func (*UpdatePromotionRequest) Descriptor() ([]byte, []int) {
+	return file_proto_promotion_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *UpdatePromotionRequest) GetPromotion() *Promotion {
+	if x != nil {
+		return x.Promotion
+	}
+	return nil
+}
+
+type ListPromotionsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only promotions that are active and within their validity window.
+	CurrentOnly   bool `protobuf:"varint,1,opt,name=current_only,json=currentOnly,proto3" json:"current_only,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPromotionsRequest) Reset() {
+	*x = ListPromotionsRequest{}
+	mi := &file_proto_promotion_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPromotionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPromotionsRequest) ProtoMessage() {}
+
+func (x *ListPromotionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_promotion_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPromotionsRequest.ProtoReflect.Descriptor instead.
+func (*ListPromotionsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_promotion_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListPromotionsRequest) GetCurrentOnly() bool {
+	if x != nil {
+		return x.CurrentOnly
+	}
+	return false
+}
+
+type ListPromotionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Promotions    []*Promotion           `protobuf:"bytes,1,rep,name=promotions,proto3" json:"promotions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListPromotionsResponse) Reset() {
+	*x = ListPromotionsResponse{}
+	mi := &file_proto_promotion_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListPromotionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListPromotionsResponse) ProtoMessage() {}
+
+func (x *ListPromotionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_promotion_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListPromotionsResponse.ProtoReflect.Descriptor instead.
+func (*ListPromotionsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_promotion_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListPromotionsResponse) GetPromotions() []*Promotion {
+	if x != nil {
+		return x.Promotions
+	}
+	return nil
+}
+
+var File_proto_promotion_proto protoreflect.FileDescriptor
+
+const file_proto_promotion_proto_rawDesc = "" +
+	"\n" +
+	"\x15proto/promotion.proto\x12\x02pb\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x11proto/money.proto\"\x82\x04\n" +
+	"\tPromotion\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\tR\x04code\x12 \n" +
+	"\vdescription\x18\x03 \x01(\tR\vdescription\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\x12\x1f\n" +
+	"\vpercent_off\x18\x05 \x01(\x05R\n" +
+	"percentOff\x12(\n" +
+	"\n" +
+	"amount_off\x18\x06 \x01(\v2\t.pb.MoneyR\tamountOff\x12\x1f\n" +
+	"\vproduct_ids\x18\a \x03(\tR\n" +
+	"productIds\x12!\n" +
+	"\fcategory_ids\x18\b \x03(\tR\vcategoryIds\x12,\n" +
+	"\fmin_subtotal\x18\t \x01(\v2\t.pb.MoneyR\vminSubtotal\x127\n" +
+	"\tstarts_at\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\bstartsAt\x123\n" +
+	"\aends_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\x06endsAt\x12\x19\n" +
+	"\bmax_uses\x18\f \x01(\x03R\amaxUses\x12)\n" +
+	"\x11max_uses_per_user\x18\r \x01(\x03R\x0emaxUsesPerUser\x12\x12\n" +
+	"\x04uses\x18\x0e \x01(\x03R\x04uses\x12\x16\n" +
+	"\x06active\x18\x0f \x01(\bR\x06active\"E\n" +
+	"\x16CreatePromotionRequest\x12+\n" +
+	"\tpromotion\x18\x01 \x01(\v2\r.pb.PromotionR\tpromotion\"%\n" +
+	"\x13GetPromotionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"E\n" +
+	"\x16UpdatePromotionRequest\x12+\n" +
+	"\tpromotion\x18\x01 \x01(\v2\r.pb.PromotionR\tpromotion\":\n" +
+	"\x15ListPromotionsRequest\x12!\n" +
+	"\fcurrent_only\x18\x01 \x01(\bR\vcurrentOnly\"G\n" +
+	"\x16ListPromotionsResponse\x12-\n" +
+	"\n" +
+	"promotions\x18\x01 \x03(\v2\r.pb.PromotionR\n" +
+	"promotions2\x8f\x02\n" +
+	"\x10PromotionService\x12<\n" +
+	"\x0fCreatePromotion\x12\x1a.pb.CreatePromotionRequest\x1a\r.pb.Promotion\x126\n" +
+	"\fGetPromotion\x12\x17.pb.GetPromotionRequest\x1a\r.pb.Promotion\x12<\n" +
+	"\x0fUpdatePromotion\x12\x1a.pb.UpdatePromotionRequest\x1a\r.pb.Promotion\x12G\n" +
+	"\x0eListPromotions\x12\x19.pb.ListPromotionsRequest\x1a\x1a.pb.ListPromotionsResponseB\x1bZ\x19order-service/internal/pbb\x06proto3"
+
+var (
+	file_proto_promotion_proto_rawDescOnce sync.Once
+	file_proto_promotion_proto_rawDescData []byte
+)
+
+func file_proto_promotion_proto_rawDescGZIP() []byte {
+	file_proto_promotion_proto_rawDescOnce.Do(func() {
+		file_proto_promotion_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_promotion_proto_rawDesc), len(file_proto_promotion_proto_rawDesc)))
+	})
+	return file_proto_promotion_proto_rawDescData
+}
+
+var file_proto_promotion_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_proto_promotion_proto_goTypes = []any{
+	(*Promotion)(nil),              // 0: pb.Promotion
+	(*CreatePromotionRequest)(nil), // 1: pb.CreatePromotionRequest
+	(*GetPromotionRequest)(nil),    // 2: pb.GetPromotionRequest
+	(*UpdatePromotionRequest)(nil), // 3: pb.UpdatePromotionRequest
+	(*ListPromotionsRequest)(nil),  // 4: pb.ListPromotionsRequest
+	(*ListPromotionsResponse)(nil), // 5: pb.ListPromotionsResponse
+	(*money.Money)(nil),            // 6: pb.Money
+	(*timestamppb.Timestamp)(nil),  // 7: google.protobuf.Timestamp
+}
+var file_proto_promotion_proto_depIdxs = []int32{
+	6,  // 0: pb.Promotion.amount_off:type_name -> pb.Money
+	6,  // 1: pb.Promotion.min_subtotal:type_name -> pb.Money
+	7,  // 2: pb.Promotion.starts_at:type_name -> google.protobuf.Timestamp
+	7,  // 3: pb.Promotion.ends_at:type_name -> google.protobuf.Timestamp
+	0,  // 4: pb.CreatePromotionRequest.promotion:type_name -> pb.Promotion
+	0,  // 5: pb.UpdatePromotionRequest.promotion:type_name -> pb.Promotion
+	0,  // 6: pb.ListPromotionsResponse.promotions:type_name -> pb.Promotion
+	1,  // 7: pb.PromotionService.CreatePromotion:input_type -> pb.CreatePromotionRequest
+	2,  // 8: pb.PromotionService.GetPromotion:input_type -> pb.GetPromotionRequest
+	3,  // 9: pb.PromotionService.UpdatePromotion:input_type -> pb.UpdatePromotionRequest
+	4,  // 10: pb.PromotionService.ListPromotions:input_type -> pb.ListPromotionsRequest
+	0,  // 11: pb.PromotionService.CreatePromotion:output_type -> pb.Promotion
+	0,  // 12: pb.PromotionService.GetPromotion:output_type -> pb.Promotion
+	0,  // 13: pb.PromotionService.UpdatePromotion:output_type -> pb.Promotion
+	5,  // 14: pb.PromotionService.ListPromotions:output_type -> pb.ListPromotionsResponse
+	11, // [11:15] is the sub-list for method output_type
+	7,  // [7:11] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_proto_promotion_proto_init() }
+func file_proto_promotion_proto_init() {
+	if File_proto_promotion_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_promotion_proto_rawDesc), len(file_proto_promotion_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_promotion_proto_goTypes,
+		DependencyIndexes: file_proto_promotion_proto_depIdxs,
+		MessageInfos:      file_proto_promotion_proto_msgTypes,
+	}.Build()
+	File_proto_promotion_proto = out.File
+	file_proto_promotion_proto_goTypes = nil
+	file_proto_promotion_proto_depIdxs = nil
+}
@@ -0,0 +1,161 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v6.30.2
+// source: proto/return.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type CreateReturnRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	OrderId string                 `protobuf:"bytes,1,opt,name=order_id,json=orderId,proto3" json:"order_id,omitempty"`
+	// The user who placed the order.
+	UserId string `protobuf:"bytes,2,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Lines of the order to return and how many of their units; each at most
+	// the units not returned yet.
+	Items         []*ReturnItem `protobuf:"bytes,3,rep,name=items,proto3" json:"items,omitempty"`
+	Reason        string        `protobuf:"bytes,4,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateReturnRequest) Reset() {
+	*x = CreateReturnRequest{}
+	mi := &file_proto_return_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateReturnRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateReturnRequest) ProtoMessage() {}
+
+func (x *CreateReturnRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_return_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateReturnRequest.ProtoReflect.Descriptor instead.
+func (*CreateReturnRequest) Descriptor() ([]byte, []int) {
+	return file_proto_return_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CreateReturnRequest) GetOrderId() string {
+	if x != nil {
+		return x.OrderId
+	}
+	return ""
+}
+
+func (x *CreateReturnRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *CreateReturnRequest) GetItems() []*ReturnItem {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *CreateReturnRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+var File_proto_return_proto protoreflect.FileDescriptor
+
+const file_proto_return_proto_rawDesc = "" +
+	"\n" +
+	"\x12proto/return.proto\x12\x02pb\x1a\x11proto/order.proto\"\x87\x01\n" +
+	"\x13CreateReturnRequest\x12\x19\n" +
+	"\border_id\x18\x01 \x01(\tR\aorderId\x12\x17\n" +
+	"\auser_id\x18\x02 \x01(\tR\x06userId\x12$\n" +
+	"\x05items\x18\x03 \x03(\v2\x0e.pb.ReturnItemR\x05items\x12\x16\n" +
+	"\x06reason\x18\x04 \x01(\tR\x06reason2D\n" +
+	"\rReturnService\x123\n" +
+	"\fCreateReturn\x12\x17.pb.CreateReturnRequest\x1a\n" +
+	".pb.ReturnB\x1bZ\x19order-service/internal/pbb\x06proto3"
+
+var (
+	file_proto_return_proto_rawDescOnce sync.Once
+	file_proto_return_proto_rawDescData []byte
+)
+
+func file_proto_return_proto_rawDescGZIP() []byte {
+	file_proto_return_proto_rawDescOnce.Do(func() {
+		file_proto_return_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_proto_return_proto_rawDesc), len(file_proto_return_proto_rawDesc)))
+	})
+	return file_proto_return_proto_rawDescData
+}
+
+var file_proto_return_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_proto_return_proto_goTypes = []any{
+	(*CreateReturnRequest)(nil), // 0: pb.CreateReturnRequest
+	(*ReturnItem)(nil),          // 1: pb.ReturnItem
+	(*Return)(nil),              // 2: pb.Return
+}
+var file_proto_return_proto_depIdxs = []int32{
+	1, // 0: pb.CreateReturnRequest.items:type_name -> pb.ReturnItem
+	0, // 1: pb.ReturnService.CreateReturn:input_type -> pb.CreateReturnRequest
+	2, // 2: pb.ReturnService.CreateReturn:output_type -> pb.Return
+	2, // [2:3] is the sub-list for method output_type
+	1, // [1:2] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_proto_return_proto_init() }
+func file_proto_return_proto_init() {
+	if File_proto_return_proto != nil {
+		return
+	}
+	file_proto_order_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_return_proto_rawDesc), len(file_proto_return_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_return_proto_goTypes,
+		DependencyIndexes: file_proto_return_proto_depIdxs,
+		MessageInfos:      file_proto_return_proto_msgTypes,
+	}.Build()
+	File_proto_return_proto = out.File
+	file_proto_return_proto_goTypes = nil
+	file_proto_return_proto_depIdxs = nil
+}
@@ -0,0 +1,389 @@
+// Package pricing works out the tax and shipping of orders from rules kept in
+// a JSON file, for example:
+//
+//	{
+//	  "default_region": "US",
+//	  "tax": {
+//	    "US-CA": {"rate": "7.25", "categories": {"<grocery category ID>": "0"}},
+//	    "DE":    {"rate": "19", "categories": {"<books category ID>": "7"}}
+//	  },
+//	  "shipping": {
+//	    "default": {"method": "weight", "amount": "4.99", "per_kg": "1.50", "free_over": "50.00"},
+//	    "regions": {"DE": {"method": "flat", "amount": "9.90"}}
+//	  }
+//	}
+//
+// Regions are ISO 3166 codes: a country such as "US" or a subdivision such as
+// "US-CA", which falls back to the rules of its country. Rates are percentages
+// with at most two decimals; a category rate applies to the category and its
+// subcategories. Amounts are decimals in the store currency.
+package pricing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"order-service/internal/inventory"
+	"order-service/internal/model"
+
+	"golang/pkg/money"
+)
+
+const (
+	ShippingFlat   = "flat"
+	ShippingWeight = "weight"
+)
+
+// ErrInvalidRegion means an order names a region that is not an ISO 3166
+// code.
+var ErrInvalidRegion = errors.New("region must be an ISO 3166 code such as US or US-CA")
+
+var regionPattern = regexp.MustCompile(`^[A-Z]{2}(-[A-Z0-9]{1,3})?$`)
+
+// Rules are the tax and shipping rules of the store.
+type Rules struct {
+	DefaultRegion string
+	Tax           map[string]TaxRule // by region
+	Shipping      map[string]ShippingRule
+	// DefaultShipping applies to regions without a rule of their own.
+	DefaultShipping *ShippingRule
+}
+
+// TaxRule is the tax of one region. Rates are in hundredths of a percent.
+type TaxRule struct {
+	Rate       int64
+	Categories map[string]int64 // by category ID
+}
+
+type ShippingRule struct {
+	Method   string      // ShippingFlat or ShippingWeight
+	Amount   money.Money // the flat fee, or the base fee of weight-based shipping
+	PerKg    money.Money // for weight-based shipping, per started kilogram
+	FreeOver money.Money // orders of at least this much ship free; zero for never
+}
+
+type rulesFile struct {
+	DefaultRegion string                 `json:"default_region"`
+	Tax           map[string]taxRuleFile `json:"tax"`
+	Shipping      shippingFile           `json:"shipping"`
+}
+
+type taxRuleFile struct {
+	Rate       string            `json:"rate"`
+	Categories map[string]string `json:"categories"`
+}
+
+type shippingFile struct {
+	Default *shippingRuleFile           `json:"default"`
+	Regions map[string]shippingRuleFile `json:"regions"`
+}
+
+type shippingRuleFile struct {
+	Method   string `json:"method"`
+	Amount   string `json:"amount"`
+	PerKg    string `json:"per_kg"`
+	FreeOver string `json:"free_over"`
+}
+
+// LoadRules reads the rules from the JSON file at path. Amounts are in
+// currency.
+func LoadRules(path, currency string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ParseRules(data, currency)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// ParseRules reads rules in the format of the package documentation.
+func ParseRules(data []byte, currency string) (*Rules, error) {
+	var f rulesFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+
+	rules := &Rules{
+		DefaultRegion: f.DefaultRegion,
+		Tax:           make(map[string]TaxRule, len(f.Tax)),
+		Shipping:      make(map[string]ShippingRule, len(f.Shipping.Regions)),
+	}
+	if rules.DefaultRegion != "" && !regionPattern.MatchString(rules.DefaultRegion) {
+		return nil, fmt.Errorf("default_region: %w", ErrInvalidRegion)
+	}
+	for region, t := range f.Tax {
+		if !regionPattern.MatchString(region) {
+			return nil, fmt.Errorf("tax %q: %w", region, ErrInvalidRegion)
+		}
+		rate, err := parseRate(t.Rate)
+		if err != nil {
+			return nil, fmt.Errorf("tax %s: %w", region, err)
+		}
+		rule := TaxRule{Rate: rate, Categories: make(map[string]int64, len(t.Categories))}
+		for category, r := range t.Categories {
+			if rule.Categories[category], err = parseRate(r); err != nil {
+				return nil, fmt.Errorf("tax %s category %s: %w", region, category, err)
+			}
+		}
+		rules.Tax[region] = rule
+	}
+	if f.Shipping.Default != nil {
+		rule, err := f.Shipping.Default.parse(currency)
+		if err != nil {
+			return nil, fmt.Errorf("shipping default: %w", err)
+		}
+		rules.DefaultShipping = &rule
+	}
+	for region, s := range f.Shipping.Regions {
+		if !regionPattern.MatchString(region) {
+			return nil, fmt.Errorf("shipping %q: %w", region, ErrInvalidRegion)
+		}
+		rule, err := s.parse(currency)
+		if err != nil {
+			return nil, fmt.Errorf("shipping %s: %w", region, err)
+		}
+		rules.Shipping[region] = rule
+	}
+	return rules, nil
+}
+
+// parseRate reads a percentage with at most two decimals, e.g. "7.25", into
+// hundredths of a percent.
+func parseRate(s string) (int64, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(frac) > 2 {
+		return 0, fmt.Errorf("rate %q has more than two decimals", s)
+	}
+	rate, err := strconv.ParseInt(whole+frac+strings.Repeat("0", 2-len(frac)), 10, 64)
+	if err != nil || strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		return 0, fmt.Errorf("rate %q is not a percentage", s)
+	}
+	if rate > 100_00 {
+		return 0, fmt.Errorf("rate %q is over 100", s)
+	}
+	return rate, nil
+}
+
+func (f shippingRuleFile) parse(currency string) (ShippingRule, error) {
+	rule := ShippingRule{Method: f.Method}
+	if rule.Method != ShippingFlat && rule.Method != ShippingWeight {
+		return rule, fmt.Errorf("method must be %s or %s", ShippingFlat, ShippingWeight)
+	}
+	for _, amount := range []struct {
+		s     string
+		m     *money.Money
+		field string
+	}{{f.Amount, &rule.Amount, "amount"}, {f.PerKg, &rule.PerKg, "per_kg"}, {f.FreeOver, &rule.FreeOver, "free_over"}} {
+		if amount.s == "" {
+			*amount.m = money.New(0, currency)
+			continue
+		}
+		m, err := money.Parse(amount.s, currency)
+		if err != nil {
+			return rule, fmt.Errorf("%s: %w", amount.field, err)
+		}
+		if m.IsNegative() {
+			return rule, fmt.Errorf("%s cannot be negative", amount.field)
+		}
+		*amount.m = m
+	}
+	return rule, nil
+}
+
+// lookup returns the entry for region, or for its country.
+func lookup[T any](m map[string]T, region string) (T, bool) {
+	if v, ok := m[region]; ok {
+		return v, true
+	}
+	country, _, _ := strings.Cut(region, "-")
+	v, ok := m[country]
+	return v, ok
+}
+
+// Calculator applies Rules to orders.
+type Calculator struct {
+	rules   *Rules
+	catalog inventory.Catalog
+}
+
+// NewCalculator creates a calculator; catalog resolves the categories of
+// ordered products for category tax rates.
+func NewCalculator(rules *Rules, catalog inventory.Catalog) *Calculator {
+	return &Calculator{rules: rules, catalog: catalog}
+}
+
+// Price sets the tax and shipping of an order whose lines are priced and
+// whose discounts are applied. The order's region defaults to the rules'
+// default region. Tax is charged on each line less its discounts, with
+// discounts on the whole order spread over the lines in proportion to their
+// amounts; shipping is not taxed.
+func (c *Calculator) Price(ctx context.Context, order *model.Order) error {
+	order.Region = strings.ToUpper(strings.TrimSpace(order.Region))
+	if order.Region == "" {
+		order.Region = c.rules.DefaultRegion
+	}
+	if order.Region != "" && !regionPattern.MatchString(order.Region) {
+		return ErrInvalidRegion
+	}
+	currency := order.Subtotal.Currency
+
+	amounts, grams, err := lineAmounts(order)
+	if err != nil {
+		return err
+	}
+	var merchandise int64
+	for _, a := range amounts {
+		merchandise += a
+	}
+
+	tax, err := c.tax(ctx, order, amounts)
+	if err != nil {
+		return err
+	}
+	order.Tax = money.New(tax, currency)
+	order.Shipping = money.New(c.shipping(order.Region, merchandise, grams), currency)
+	return nil
+}
+
+// LineTotals returns what each line of a priced order was charged: its
+// amount after discounts, as Price works it out, plus a share of the order's
+// tax in proportion to that amount. Shipping is left out.
+func LineTotals(order *model.Order) ([]int64, error) {
+	amounts, _, err := lineAmounts(order)
+	if err != nil {
+		return nil, err
+	}
+	for i, share := range prorate(order.Tax.Amount, amounts) {
+		amounts[i] += share
+	}
+	return amounts, nil
+}
+
+// lineAmounts returns what each line of an order costs after its own
+// discounts and its share of the discounts on the whole order, and the
+// order's weight in grams.
+func lineAmounts(order *model.Order) ([]int64, int64, error) {
+	amounts := make([]int64, len(order.Products))
+	var grams int64
+	for i, line := range order.Products {
+		total, err := line.UnitPrice.Mul(int64(line.Quantity))
+		if err != nil {
+			return nil, 0, err
+		}
+		amounts[i] = total.Amount
+		grams += int64(line.WeightGrams) * int64(line.Quantity)
+	}
+	var orderDiscount int64
+	for _, a := range order.Adjustments {
+		if a.ProductID == "" {
+			orderDiscount -= a.Amount.Amount
+			continue
+		}
+		for i, line := range order.Products {
+			if line.ProductID == a.ProductID && line.SKU == a.SKU {
+				amounts[i] = max(amounts[i]+a.Amount.Amount, 0)
+				break
+			}
+		}
+	}
+	for i, share := range prorate(orderDiscount, amounts) {
+		amounts[i] -= share
+	}
+	return amounts, grams, nil
+}
+
+func (c *Calculator) tax(ctx context.Context, order *model.Order, amounts []int64) (int64, error) {
+	rule, ok := lookup(c.rules.Tax, order.Region)
+	if !ok {
+		return 0, nil
+	}
+	paths := make(map[string][]string)
+	var tax int64
+	for i, line := range order.Products {
+		rate := rule.Rate
+		if len(rule.Categories) > 0 && line.CategoryID != "" {
+			path, ok := paths[line.CategoryID]
+			if !ok {
+				var err error
+				if path, err = c.catalog.CategoryPath(ctx, line.CategoryID); err != nil {
+					return 0, err
+				}
+				paths[line.CategoryID] = path
+			}
+			// The most specific category with a rate of its own wins.
+			for j := len(path) - 1; j >= 0; j-- {
+				if r, ok := rule.Categories[path[j]]; ok {
+					rate = r
+					break
+				}
+			}
+		}
+		tax += percent(amounts[i], rate)
+	}
+	return tax, nil
+}
+
+func (c *Calculator) shipping(region string, merchandise, grams int64) int64 {
+	rule, ok := lookup(c.rules.Shipping, region)
+	if !ok {
+		if c.rules.DefaultShipping == nil {
+			return 0
+		}
+		rule = *c.rules.DefaultShipping
+	}
+	if !rule.FreeOver.IsZero() && merchandise >= rule.FreeOver.Amount {
+		return 0
+	}
+	if rule.Method == ShippingFlat {
+		return rule.Amount.Amount
+	}
+	kilograms := (grams + 999) / 1000
+	return rule.Amount.Amount + rule.PerKg.Amount*kilograms
+}
+
+// percent returns rate hundredths of a percent of amount, rounded half up.
+func percent(amount, rate int64) int64 {
+	return amount/100_00*rate + (amount%100_00*rate+50_00)/100_00
+}
+
+// prorate splits total over parts in proportion to their sizes, rounding
+// down and giving what is left to the last non-zero part.
+func prorate(total int64, parts []int64) []int64 {
+	shares := make([]int64, len(parts))
+	var sum int64
+	last := -1
+	for i, p := range parts {
+		if p > 0 {
+			sum += p
+			last = i
+		}
+	}
+	if total <= 0 || sum <= 0 {
+		return shares
+	}
+	total = min(total, sum)
+	left := total
+	for i, p := range parts {
+		if p <= 0 || i == last {
+			continue
+		}
+		share := new(big.Int).Mul(big.NewInt(total), big.NewInt(p))
+		shares[i] = share.Quo(share, big.NewInt(sum)).Int64()
+		left -= shares[i]
+	}
+	shares[last] = left
+	return shares
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"order-service/internal/model"
+
+	"golang/pkg/cache"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrCartConflict means the cart was changed since it was read.
+var ErrCartConflict = errors.New("cart was changed concurrently")
+
+type CartRepository interface {
+	// Get returns the cart stored under id, or an empty cart if there is none.
+	Get(ctx context.Context, id string) (*model.Cart, error)
+	// Save stores the cart and advances its version, or returns
+	// ErrCartConflict if the stored cart is no longer at cart.Version.
+	Save(ctx context.Context, id string, cart *model.Cart) error
+	Delete(ctx context.Context, id string) error
+}
+
+// GuestCartRepository keeps guests' carts in a key/value store, in production
+// Redis. A cart expires once it has not been saved for ttl.
+type GuestCartRepository struct {
+	store cache.Swapper
+	ttl   time.Duration
+}
+
+func NewGuestCartRepository(store cache.Swapper, ttl time.Duration) *GuestCartRepository {
+	return &GuestCartRepository{store: store, ttl: ttl}
+}
+
+func guestCartKey(id string) string {
+	return "cart:guest:" + id
+}
+
+func (r *GuestCartRepository) Get(ctx context.Context, id string) (*model.Cart, error) {
+	data, err := r.store.Get(ctx, guestCartKey(id))
+	if errors.Is(err, cache.ErrMiss) {
+		return &model.Cart{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cart model.Cart
+	if err := json.Unmarshal(data, &cart); err != nil {
+		return nil, err
+	}
+	return &cart, nil
+}
+
+func (r *GuestCartRepository) Save(ctx context.Context, id string, cart *model.Cart) error {
+	key := guestCartKey(id)
+	old, err := r.store.Get(ctx, key)
+	if errors.Is(err, cache.ErrMiss) {
+		old = nil
+	} else if err != nil {
+		return err
+	}
+	var stored model.Cart
+	if old != nil {
+		if err := json.Unmarshal(old, &stored); err != nil {
+			return err
+		}
+	}
+	if stored.Version != cart.Version {
+		return ErrCartConflict
+	}
+
+	next := *cart
+	next.UpdatedAt = time.Now().UTC()
+	next.Version++
+	data, err := json.Marshal(&next)
+	if err != nil {
+		return err
+	}
+	// The swap fails if the cart was saved since it was read above.
+	ok, err := r.store.CompareAndSwap(ctx, key, old, data, r.ttl)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCartConflict
+	}
+	*cart = next
+	return nil
+}
+
+func (r *GuestCartRepository) Delete(ctx context.Context, id string) error {
+	return r.store.Delete(ctx, guestCartKey(id))
+}
+
+// MongoCartRepository keeps users' carts, keyed by user ID.
+type MongoCartRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoCartRepository(collection *mongo.Collection) *MongoCartRepository {
+	return &MongoCartRepository{collection: collection}
+}
+
+type cartDocument struct {
+	UserID          string             `bson:"_id"`
+	Items           []cartItemDocument `bson:"items"`
+	LastOrderID     string             `bson:"last_order_id,omitempty"`
+	LastCheckoutKey string             `bson:"last_checkout_key,omitempty"`
+	UpdatedAt       time.Time          `bson:"updated_at"`
+	Version         int64              `bson:"version"`
+}
+
+type cartItemDocument struct {
+	ProductID string `bson:"product_id"`
+	SKU       string `bson:"sku"`
+	Quantity  int    `bson:"quantity"`
+}
+
+func (r *MongoCartRepository) Get(ctx context.Context, id string) (*model.Cart, error) {
+	var doc cartDocument
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return &model.Cart{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cart := &model.Cart{
+		LastOrderID:     doc.LastOrderID,
+		LastCheckoutKey: doc.LastCheckoutKey,
+		UpdatedAt:       doc.UpdatedAt,
+		Version:         doc.Version,
+	}
+	for _, item := range doc.Items {
+		cart.Items = append(cart.Items, model.CartItem{
+			ProductID: item.ProductID,
+			SKU:       item.SKU,
+			Quantity:  item.Quantity,
+		})
+	}
+	return cart, nil
+}
+
+func (r *MongoCartRepository) Save(ctx context.Context, id string, cart *model.Cart) error {
+	doc := cartDocument{
+		UserID:          id,
+		Items:           []cartItemDocument{},
+		LastOrderID:     cart.LastOrderID,
+		LastCheckoutKey: cart.LastCheckoutKey,
+		UpdatedAt:       time.Now().UTC(),
+		Version:         cart.Version + 1,
+	}
+	for _, item := range cart.Items {
+		doc.Items = append(doc.Items, cartItemDocument{
+			ProductID: item.ProductID,
+			SKU:       item.SKU,
+			Quantity:  item.Quantity,
+		})
+	}
+	// A cart not stored yet is inserted by the upsert; if another save
+	// inserted it first, the insert fails on the duplicate _id.
+	filter := bson.M{"_id": id, "version": cart.Version}
+	if cart.Version == 0 {
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	}
+	res, err := r.collection.ReplaceOne(ctx, filter, doc, options.Replace().SetUpsert(cart.Version == 0))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrCartConflict
+	}
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 && res.UpsertedCount == 0 {
+		return ErrCartConflict
+	}
+	cart.UpdatedAt, cart.Version = doc.UpdatedAt, doc.Version
+	return nil
+}
+
+func (r *MongoCartRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
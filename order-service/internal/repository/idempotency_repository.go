@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"order-service/internal/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type IdempotencyRepository interface {
+	// Reserve claims key for a new request. If the key is already known the
+	// existing record is returned with reserved set to false.
+	Reserve(ctx context.Context, key, requestHash string) (record *model.IdempotencyRecord, reserved bool, err error)
+	// Complete stores the order created for key.
+	Complete(ctx context.Context, key, orderID string) error
+	// Release forgets a reservation whose request failed so that it can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+const (
+	// idempotencyTTL is how long keys are remembered.
+	idempotencyTTL = 24 * time.Hour
+	// idempotencyLockTimeout is how long an in-progress reservation is honored
+	// before a retry may take it over, e.g. after the original request crashed.
+	idempotencyLockTimeout = 30 * time.Second
+)
+
+type idempotencyDocument struct {
+	Key         string    `bson:"_id"`
+	RequestHash string    `bson:"request_hash"`
+	Status      string    `bson:"status"`
+	OrderID     string    `bson:"order_id,omitempty"`
+	LockedAt    time.Time `bson:"locked_at"`
+	CreatedAt   time.Time `bson:"created_at"`
+}
+
+func (d idempotencyDocument) toModel() *model.IdempotencyRecord {
+	return &model.IdempotencyRecord{
+		Key:         d.Key,
+		RequestHash: d.RequestHash,
+		Status:      d.Status,
+		OrderID:     d.OrderID,
+		LockedAt:    d.LockedAt,
+		CreatedAt:   d.CreatedAt,
+	}
+}
+
+type MongoIdempotencyRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoIdempotencyRepository(collection *mongo.Collection) *MongoIdempotencyRepository {
+	// expire keys once they are no longer useful for retries
+	collection.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(idempotencyTTL.Seconds())),
+		},
+	)
+	return &MongoIdempotencyRepository{collection: collection}
+}
+
+func (r *MongoIdempotencyRepository) Reserve(ctx context.Context, key, requestHash string) (*model.IdempotencyRecord, bool, error) {
+	now := time.Now().UTC()
+	doc := idempotencyDocument{
+		Key:         key,
+		RequestHash: requestHash,
+		Status:      model.IdempotencyInProgress,
+		LockedAt:    now,
+		CreatedAt:   now,
+	}
+	_, err := r.collection.InsertOne(ctx, doc)
+	if err == nil {
+		return doc.toModel(), true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, false, err
+	}
+
+	// Take over a reservation abandoned by a request that never finished.
+	var existing idempotencyDocument
+	err = r.collection.FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":          key,
+			"request_hash": requestHash,
+			"status":       model.IdempotencyInProgress,
+			"locked_at":    bson.M{"$lt": now.Add(-idempotencyLockTimeout)},
+		},
+		bson.M{"$set": bson.M{"locked_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&existing)
+	if err == nil {
+		return existing.toModel(), true, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, false, err
+	}
+
+	err = r.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&existing)
+	if err == mongo.ErrNoDocuments {
+		// The key expired or was released in the meantime; let the caller retry.
+		return nil, false, errors.New("idempotency key released concurrently")
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return existing.toModel(), false, nil
+}
+
+func (r *MongoIdempotencyRepository) Complete(ctx context.Context, key, orderID string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": key},
+		bson.M{"$set": bson.M{"status": model.IdempotencyCompleted, "order_id": orderID}},
+	)
+	return err
+}
+
+func (r *MongoIdempotencyRepository) Release(ctx context.Context, key string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key, "status": model.IdempotencyInProgress})
+	return err
+}
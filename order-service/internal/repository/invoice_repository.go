@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"order-service/internal/model"
+
+	"golang/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvoiceNotFound = errors.New("invoice not found")
+	// ErrInvoiceExists is returned by Create for orders that already have
+	// an invoice.
+	ErrInvoiceExists = errors.New("order already has an invoice")
+)
+
+type InvoiceRepository interface {
+	// Create gives inv the next number of the sequence and stores it,
+	// recording the number on its order. The number is taken in the same
+	// transaction, so an invoice that fails to be stored leaves no gap.
+	Create(ctx context.Context, inv *model.Invoice) error
+	FindByOrder(ctx context.Context, orderID string) (*model.Invoice, error)
+	// FindUnpublished returns the invoices whose order.invoiced event has
+	// not gone out.
+	FindUnpublished(ctx context.Context) ([]*model.Invoice, error)
+	MarkPublished(ctx context.Context, seq int64) error
+	// MarkSent records that the invoice of an order was emailed.
+	MarkSent(ctx context.Context, orderID string) error
+}
+
+type MongoInvoiceRepository struct {
+	invoices *mongo.Collection
+	counters *mongo.Collection
+	orders   *mongo.Collection
+}
+
+// invoiceCounter is the counters document holding the last invoice number
+// taken.
+const invoiceCounter = "invoices"
+
+func NewMongoInvoiceRepository(invoices, counters, orders *mongo.Collection) *MongoInvoiceRepository {
+	// One invoice per order.
+	invoices.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "order_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+	)
+	return &MongoInvoiceRepository{invoices: invoices, counters: counters, orders: orders}
+}
+
+// invoiceDocument is the stored shape of an invoice, keyed by its sequence
+// number. Amounts are in minor units of currency.
+type invoiceDocument struct {
+	Seq         int64                 `bson:"_id"`
+	Number      string                `bson:"number"`
+	OrderID     string                `bson:"order_id"`
+	UserID      string                `bson:"user_id"`
+	PaymentID   string                `bson:"payment_id"`
+	Lines       []invoiceLineDocument `bson:"lines"`
+	Adjustments []adjustmentDocument  `bson:"adjustments,omitempty"`
+	Subtotal    int64                 `bson:"subtotal"`
+	Tax         int64                 `bson:"tax"`
+	Shipping    int64                 `bson:"shipping"`
+	Total       int64                 `bson:"total"`
+	Currency    string                `bson:"currency"`
+	ShipTo      *addressDocument      `bson:"shipping_address,omitempty"`
+	IssuedAt    time.Time             `bson:"issued_at"`
+	Sent        bool                  `bson:"sent"`
+	// Invoices sent before order.invoiced was confirmed count as published.
+	Published bool `bson:"published,omitempty"`
+}
+
+type invoiceLineDocument struct {
+	Description string `bson:"description"`
+	ProductID   string `bson:"product_id"`
+	SKU         string `bson:"sku,omitempty"`
+	Quantity    int    `bson:"quantity"`
+	UnitPrice   int64  `bson:"unit_price"`
+	Amount      int64  `bson:"amount"`
+}
+
+func toInvoiceDocument(inv *model.Invoice) invoiceDocument {
+	doc := invoiceDocument{
+		Seq:       inv.Seq,
+		Number:    inv.Number,
+		OrderID:   inv.OrderID,
+		UserID:    inv.UserID,
+		PaymentID: inv.PaymentID,
+		Lines:     []invoiceLineDocument{},
+		Subtotal:  inv.Subtotal.Amount,
+		Tax:       inv.Tax.Amount,
+		Shipping:  inv.Shipping.Amount,
+		Total:     inv.Total.Amount,
+		Currency:  inv.Total.Currency,
+		ShipTo:    toAddressDocument(inv.ShippingAddress),
+		IssuedAt:  inv.IssuedAt,
+		Sent:      inv.Sent,
+		Published: inv.Published,
+	}
+	for _, l := range inv.Lines {
+		doc.Lines = append(doc.Lines, invoiceLineDocument{
+			Description: l.Description,
+			ProductID:   l.ProductID,
+			SKU:         l.SKU,
+			Quantity:    l.Quantity,
+			UnitPrice:   l.UnitPrice.Amount,
+			Amount:      l.Amount.Amount,
+		})
+	}
+	for _, a := range inv.Adjustments {
+		doc.Adjustments = append(doc.Adjustments, adjustmentDocument{
+			PromotionID: a.PromotionID,
+			Code:        a.Code,
+			Description: a.Description,
+			ProductID:   a.ProductID,
+			SKU:         a.SKU,
+			Amount:      a.Amount.Amount,
+		})
+	}
+	return doc
+}
+
+func (d invoiceDocument) toModel() *model.Invoice {
+	inv := &model.Invoice{
+		Seq:             d.Seq,
+		Number:          d.Number,
+		OrderID:         d.OrderID,
+		UserID:          d.UserID,
+		PaymentID:       d.PaymentID,
+		Subtotal:        money.New(d.Subtotal, d.Currency),
+		Tax:             money.New(d.Tax, d.Currency),
+		Shipping:        money.New(d.Shipping, d.Currency),
+		Total:           money.New(d.Total, d.Currency),
+		ShippingAddress: d.ShipTo.toModel(),
+		IssuedAt:        d.IssuedAt,
+		Published:       d.Published || d.Sent,
+		Sent:            d.Sent,
+	}
+	for _, l := range d.Lines {
+		inv.Lines = append(inv.Lines, model.InvoiceLine{
+			Description: l.Description,
+			ProductID:   l.ProductID,
+			SKU:         l.SKU,
+			Quantity:    l.Quantity,
+			UnitPrice:   money.New(l.UnitPrice, d.Currency),
+			Amount:      money.New(l.Amount, d.Currency),
+		})
+	}
+	for _, a := range d.Adjustments {
+		inv.Adjustments = append(inv.Adjustments, model.Adjustment{
+			PromotionID: a.PromotionID,
+			Code:        a.Code,
+			Description: a.Description,
+			ProductID:   a.ProductID,
+			SKU:         a.SKU,
+			Amount:      money.New(a.Amount, d.Currency),
+		})
+	}
+	return inv
+}
+
+func (r *MongoInvoiceRepository) Create(ctx context.Context, inv *model.Invoice) error {
+	orderID, err := primitive.ObjectIDFromHex(inv.OrderID)
+	if err != nil {
+		return ErrInvalidID
+	}
+
+	session, err := r.invoices.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		var counter struct {
+			Seq int64 `bson:"seq"`
+		}
+		err := r.counters.FindOneAndUpdate(sc,
+			bson.M{"_id": invoiceCounter},
+			bson.M{"$inc": bson.M{"seq": 1}},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&counter)
+		if err != nil {
+			return nil, err
+		}
+		inv.Seq = counter.Seq
+		inv.Number = model.InvoiceNumber(counter.Seq)
+
+		if _, err := r.invoices.InsertOne(sc, toInvoiceDocument(inv)); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				return nil, ErrInvoiceExists
+			}
+			return nil, err
+		}
+		res, err := r.orders.UpdateOne(sc,
+			bson.M{"_id": orderID},
+			bson.M{"$set": bson.M{"invoice_number": inv.Number}},
+		)
+		if err != nil {
+			return nil, err
+		}
+		if res.MatchedCount == 0 {
+			return nil, ErrOrderNotFound
+		}
+		return nil, nil
+	})
+	return err
+}
+
+func (r *MongoInvoiceRepository) FindByOrder(ctx context.Context, orderID string) (*model.Invoice, error) {
+	var doc invoiceDocument
+	err := r.invoices.FindOne(ctx, bson.M{"order_id": orderID}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInvoiceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toModel(), nil
+}
+
+func (r *MongoInvoiceRepository) FindUnpublished(ctx context.Context) ([]*model.Invoice, error) {
+	filter := bson.M{"sent": false, "published": bson.M{"$ne": true}}
+	cursor, err := r.invoices.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var invoices []*model.Invoice
+	for cursor.Next(ctx) {
+		var doc invoiceDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, doc.toModel())
+	}
+	return invoices, cursor.Err()
+}
+
+func (r *MongoInvoiceRepository) MarkPublished(ctx context.Context, seq int64) error {
+	res, err := r.invoices.UpdateOne(ctx, bson.M{"_id": seq}, bson.M{"$set": bson.M{"published": true}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrInvoiceNotFound
+	}
+	return nil
+}
+
+func (r *MongoInvoiceRepository) MarkSent(ctx context.Context, orderID string) error {
+	res, err := r.invoices.UpdateOne(ctx, bson.M{"order_id": orderID}, bson.M{"$set": bson.M{"sent": true}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrInvoiceNotFound
+	}
+	return nil
+}
@@ -3,8 +3,14 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
+
 	"order-service/internal/model"
 
+	"golang/pkg/address"
+	"golang/pkg/money"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -16,23 +22,274 @@ type MongoOrderRepository struct {
 }
 
 func NewMongoOrderRepository(collection *mongo.Collection) *MongoOrderRepository {
+	// An idempotency key creates one order, even if its reservation was
+	// taken over by a retry.
+	collection.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "idempotency_key", Value: 1}},
+			Options: options.Index().SetUnique(true).SetSparse(true),
+		},
+	)
 	return &MongoOrderRepository{collection: collection}
 }
 
-func (r *MongoOrderRepository) Create(ctx context.Context, order *model.Order) (string, error) {
-	doc := bson.M{
-		"user_id": order.UserID,
-		"products": bson.A{},
-		"total":   order.Total,
-		"status":  order.Status,
+// orderDocument is the stored shape of an order. Amounts are in minor units
+// of currency.
+type orderDocument struct {
+	ID          primitive.ObjectID   `bson:"_id,omitempty"`
+	UserID      string               `bson:"user_id"`
+	Products    []orderItemDocument  `bson:"products"`
+	Subtotal    int64                `bson:"subtotal,omitempty"`
+	Adjustments []adjustmentDocument `bson:"adjustments,omitempty"`
+	Shipping    int64                `bson:"shipping,omitempty"`
+	Tax         int64                `bson:"tax,omitempty"`
+	Total       int64                `bson:"total"`
+	Currency    string               `bson:"currency"`
+	PromoCode   string               `bson:"promo_code,omitempty"`
+	Region      string               `bson:"region,omitempty"`
+	AddressID   string               `bson:"address_id,omitempty"`
+	ShipTo      *addressDocument     `bson:"shipping_address,omitempty"`
+	PaymentID   string               `bson:"payment_id,omitempty"`
+	Returns     []returnDocument     `bson:"returns,omitempty"`
+	Invoice     string               `bson:"invoice_number,omitempty"`
+	Idempotency string               `bson:"idempotency_key,omitempty"`
+	Status      string               `bson:"status"`
+	// StockUnpublished is set until the stock event of the order's status
+	// goes out; orders stored before it was tracked owe none.
+	StockUnpublished bool `bson:"stock_unpublished,omitempty"`
+}
+
+type orderItemDocument struct {
+	ProductID   string `bson:"product_id"`
+	SKU         string `bson:"sku,omitempty"`
+	Quantity    int    `bson:"quantity"`
+	UnitPrice   int64  `bson:"unit_price,omitempty"`
+	CategoryID  string `bson:"category_id,omitempty"`
+	WeightGrams int32  `bson:"weight_grams,omitempty"`
+	Returned    int    `bson:"returned,omitempty"`
+}
+
+type returnDocument struct {
+	ID           string               `bson:"id"`
+	Items        []returnItemDocument `bson:"items"`
+	Refund       int64                `bson:"refund"`
+	Reason       string               `bson:"reason,omitempty"`
+	RefundStatus string               `bson:"refund_status"`
+	CreatedAt    time.Time            `bson:"created_at"`
+	// Unpublished is set until the return's order.returned event goes out;
+	// returns stored before it was tracked owe none.
+	Unpublished bool `bson:"unpublished,omitempty"`
+}
+
+type returnItemDocument struct {
+	Line      int    `bson:"line"`
+	ProductID string `bson:"product_id"`
+	SKU       string `bson:"sku,omitempty"`
+	Quantity  int    `bson:"quantity"`
+	Refund    int64  `bson:"refund"`
+}
+
+func toReturnDocument(ret *model.Return) returnDocument {
+	doc := returnDocument{
+		ID:           ret.ID,
+		Items:        []returnItemDocument{},
+		Refund:       ret.Refund.Amount,
+		Reason:       ret.Reason,
+		RefundStatus: ret.RefundStatus,
+		CreatedAt:    ret.CreatedAt,
+		Unpublished:  !ret.Published,
+	}
+	for _, item := range ret.Items {
+		doc.Items = append(doc.Items, returnItemDocument{
+			Line:      item.Line,
+			ProductID: item.ProductID,
+			SKU:       item.SKU,
+			Quantity:  item.Quantity,
+			Refund:    item.Refund.Amount,
+		})
+	}
+	return doc
+}
+
+// addressDocument is the shipping address of an order, copied when the order
+// was placed.
+type addressDocument struct {
+	Name       string `bson:"name"`
+	Line1      string `bson:"line1"`
+	Line2      string `bson:"line2,omitempty"`
+	City       string `bson:"city"`
+	Region     string `bson:"region,omitempty"`
+	PostalCode string `bson:"postal_code,omitempty"`
+	Country    string `bson:"country"`
+	Phone      string `bson:"phone,omitempty"`
+}
+
+func toAddressDocument(a *address.Address) *addressDocument {
+	if a == nil {
+		return nil
+	}
+	return &addressDocument{
+		Name:       a.Name,
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		Region:     a.Region,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+		Phone:      a.Phone,
+	}
+}
+
+func (a *addressDocument) toModel() *address.Address {
+	if a == nil {
+		return nil
+	}
+	return &address.Address{
+		Name:       a.Name,
+		Line1:      a.Line1,
+		Line2:      a.Line2,
+		City:       a.City,
+		Region:     a.Region,
+		PostalCode: a.PostalCode,
+		Country:    a.Country,
+		Phone:      a.Phone,
+	}
+}
+
+type adjustmentDocument struct {
+	PromotionID string `bson:"promotion_id"`
+	Code        string `bson:"code,omitempty"`
+	Description string `bson:"description"`
+	ProductID   string `bson:"product_id,omitempty"`
+	SKU         string `bson:"sku,omitempty"`
+	Amount      int64  `bson:"amount"`
+}
+
+func toOrderDocument(order *model.Order) orderDocument {
+	doc := orderDocument{
+		UserID:      order.UserID,
+		Products:    []orderItemDocument{},
+		Subtotal:    order.Subtotal.Amount,
+		Shipping:    order.Shipping.Amount,
+		Tax:         order.Tax.Amount,
+		Total:       order.Total.Amount,
+		Currency:    order.Total.Currency,
+		PromoCode:   order.PromoCode,
+		Region:      order.Region,
+		AddressID:   order.AddressID,
+		PaymentID:   order.PaymentID,
+		Invoice:     order.InvoiceNumber,
+		Idempotency: order.IdempotencyKey,
+		Status:      order.Status,
+		ShipTo:      toAddressDocument(order.ShippingAddress),
+
+		StockUnpublished: !order.StockPublished,
 	}
 	for _, p := range order.Products {
-		doc["products"] = append(doc["products"].(bson.A), bson.M{
-			"product_id": p.ProductID,
-			"quantity":   p.Quantity,
+		doc.Products = append(doc.Products, orderItemDocument{
+			ProductID:   p.ProductID,
+			SKU:         p.SKU,
+			Quantity:    p.Quantity,
+			UnitPrice:   p.UnitPrice.Amount,
+			CategoryID:  p.CategoryID,
+			WeightGrams: p.WeightGrams,
+			Returned:    p.Returned,
+		})
+	}
+	for i := range order.Returns {
+		doc.Returns = append(doc.Returns, toReturnDocument(&order.Returns[i]))
+	}
+	for _, a := range order.Adjustments {
+		doc.Adjustments = append(doc.Adjustments, adjustmentDocument{
+			PromotionID: a.PromotionID,
+			Code:        a.Code,
+			Description: a.Description,
+			ProductID:   a.ProductID,
+			SKU:         a.SKU,
+			Amount:      a.Amount.Amount,
+		})
+	}
+	return doc
+}
+
+func (d orderDocument) toModel() *model.Order {
+	order := &model.Order{
+		ID:              d.ID.Hex(),
+		UserID:          d.UserID,
+		Subtotal:        unitPrice(d.Subtotal, d.Currency),
+		Shipping:        unitPrice(d.Shipping, d.Currency),
+		Tax:             unitPrice(d.Tax, d.Currency),
+		Total:           money.New(d.Total, d.Currency),
+		PromoCode:       d.PromoCode,
+		Region:          d.Region,
+		AddressID:       d.AddressID,
+		PaymentID:       d.PaymentID,
+		InvoiceNumber:   d.Invoice,
+		IdempotencyKey:  d.Idempotency,
+		Status:          d.Status,
+		ShippingAddress: d.ShipTo.toModel(),
+		StockPublished:  !d.StockUnpublished,
+	}
+	for _, p := range d.Products {
+		order.Products = append(order.Products, model.Product{
+			ProductID:   p.ProductID,
+			SKU:         p.SKU,
+			Quantity:    p.Quantity,
+			UnitPrice:   unitPrice(p.UnitPrice, d.Currency),
+			CategoryID:  p.CategoryID,
+			WeightGrams: p.WeightGrams,
+			Returned:    p.Returned,
+		})
+	}
+	for _, r := range d.Returns {
+		ret := model.Return{
+			ID:           r.ID,
+			Refund:       money.New(r.Refund, d.Currency),
+			Reason:       r.Reason,
+			RefundStatus: r.RefundStatus,
+			CreatedAt:    r.CreatedAt,
+			Published:    !r.Unpublished,
+		}
+		for _, item := range r.Items {
+			ret.Items = append(ret.Items, model.ReturnItem{
+				Line:      item.Line,
+				ProductID: item.ProductID,
+				SKU:       item.SKU,
+				Quantity:  item.Quantity,
+				Refund:    money.New(item.Refund, d.Currency),
+			})
+		}
+		order.Returns = append(order.Returns, ret)
+	}
+	for _, a := range d.Adjustments {
+		order.Adjustments = append(order.Adjustments, model.Adjustment{
+			PromotionID: a.PromotionID,
+			Code:        a.Code,
+			Description: a.Description,
+			ProductID:   a.ProductID,
+			SKU:         a.SKU,
+			Amount:      money.New(a.Amount, d.Currency),
 		})
 	}
-	res, err := r.collection.InsertOne(ctx, doc)
+	return order
+}
+
+// unitPrice is the price of an order line, or an order's subtotal, tax or
+// shipping; orders placed before these were recorded have none, and free
+// shipping or no tax is stored as none.
+func unitPrice(amount int64, currency string) money.Money {
+	if amount == 0 {
+		return money.Money{}
+	}
+	return money.New(amount, currency)
+}
+
+func (r *MongoOrderRepository) Create(ctx context.Context, order *model.Order) (string, error) {
+	res, err := r.collection.InsertOne(ctx, toOrderDocument(order))
+	if mongo.IsDuplicateKeyError(err) {
+		return "", ErrDuplicateOrder
+	}
 	if err != nil {
 		return "", err
 	}
@@ -40,83 +297,229 @@ func (r *MongoOrderRepository) Create(ctx context.Context, order *model.Order) (
 	return id.Hex(), nil
 }
 
+func (r *MongoOrderRepository) FindByIdempotencyKey(ctx context.Context, key string) (*model.Order, error) {
+	var doc orderDocument
+	err := r.collection.FindOne(ctx, bson.M{"idempotency_key": key}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toModel(), nil
+}
+
 func (r *MongoOrderRepository) FindByID(ctx context.Context, id string) (*model.Order, error) {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, errors.New("invalid ID")
+		return nil, ErrInvalidID
 	}
-	var result struct {
-		ID        primitive.ObjectID `bson:"_id"`
-		UserID    string             `bson:"user_id"`
-		Products  []struct {
-			ProductID string `bson:"product_id"`
-			Quantity  int    `bson:"quantity"`
-		} `bson:"products"`
-		Total  float64 `bson:"total"`
-		Status string  `bson:"status"`
+	var doc orderDocument
+	err = r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrOrderNotFound
 	}
-	err = r.collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&result)
 	if err != nil {
 		return nil, err
 	}
-	order := &model.Order{
-		ID:     result.ID.Hex(),
-		UserID: result.UserID,
-		Total:  result.Total,
-		Status: result.Status,
+	return doc.toModel(), nil
+}
+
+// UpdateStatus also marks the stock event of the new status as owed.
+func (r *MongoOrderRepository) UpdateStatus(ctx context.Context, id, from, status string) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, ErrInvalidID
 	}
-	for _, p := range result.Products {
-		order.Products = append(order.Products, model.Product{
-			ProductID: p.ProductID,
-			Quantity:  p.Quantity,
-		})
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objID, "status": from},
+		bson.M{"$set": bson.M{"status": status, "stock_unpublished": true}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount == 1, nil
+}
+
+// SettlePayment moves a PENDING order to status and records the payment that
+// settled it. It reports false, changing nothing, if the order is not
+// PENDING. A CANCELLED order owes its order.cancelled event.
+func (r *MongoOrderRepository) SettlePayment(ctx context.Context, id, paymentID, status string) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, ErrInvalidID
+	}
+	set := bson.M{"status": status, "payment_id": paymentID}
+	if status == "CANCELLED" {
+		set["stock_unpublished"] = true
+	}
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objID, "status": "PENDING"},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *MongoOrderRepository) AddReturn(ctx context.Context, order *model.Order, ret *model.Return) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(order.ID)
+	if err != nil {
+		return false, ErrInvalidID
+	}
+	// Each returned line must still have the units returned it was read
+	// with; lines stored before returns existed have none recorded.
+	filter := bson.M{"_id": objID, "status": "COMPLETED"}
+	inc := bson.M{}
+	for _, item := range ret.Items {
+		field := fmt.Sprintf("products.%d.returned", item.Line)
+		before := order.Products[item.Line].Returned
+		if before == 0 {
+			filter[field] = bson.M{"$in": bson.A{0, nil}}
+		} else {
+			filter[field] = before
+		}
+		inc[field] = item.Quantity
+	}
+	ret.ID = primitive.NewObjectID().Hex()
+	res, err := r.collection.UpdateOne(ctx, filter, bson.M{
+		"$inc":  inc,
+		"$push": bson.M{"returns": toReturnDocument(ret)},
+	})
+	if err != nil {
+		return false, err
 	}
-	return order, nil
+	return res.ModifiedCount == 1, nil
 }
 
-func (r *MongoOrderRepository) UpdateStatus(ctx context.Context, id string, status string) error {
+func (r *MongoOrderRepository) SetRefundStatus(ctx context.Context, orderID, returnID, status string) error {
+	objID, err := primitive.ObjectIDFromHex(orderID)
+	if err != nil {
+		return ErrInvalidID
+	}
+	res, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": objID, "returns.id": returnID},
+		bson.M{"$set": bson.M{"returns.$.refund_status": status}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("return not found")
+	}
+	return nil
+}
+
+func (r *MongoOrderRepository) MarkStockPublished(ctx context.Context, id, status string) error {
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return errors.New("invalid ID")
+		return ErrInvalidID
 	}
-	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$set": bson.M{"status": status}})
+	_, err = r.collection.UpdateOne(ctx,
+		bson.M{"_id": objID, "status": status},
+		bson.M{"$unset": bson.M{"stock_unpublished": ""}},
+	)
 	return err
 }
 
-func (r *MongoOrderRepository) FindByUserID(ctx context.Context, userID string) ([]*model.Order, error) {
-	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, options.Find())
+func (r *MongoOrderRepository) FindStockUnpublished(ctx context.Context) ([]*model.Order, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"stock_unpublished": true})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var orders []*model.Order
+	for cursor.Next(ctx) {
+		var doc orderDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		orders = append(orders, doc.toModel())
+	}
+	return orders, cursor.Err()
+}
+
+func (r *MongoOrderRepository) MarkReturnPublished(ctx context.Context, orderID, returnID string) error {
+	objID, err := primitive.ObjectIDFromHex(orderID)
+	if err != nil {
+		return ErrInvalidID
+	}
+	_, err = r.collection.UpdateOne(ctx,
+		bson.M{"_id": objID, "returns.id": returnID},
+		bson.M{"$unset": bson.M{"returns.$.unpublished": ""}},
+	)
+	return err
+}
+
+func (r *MongoOrderRepository) FindUnpublishedReturns(ctx context.Context) ([]*model.Order, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"returns.unpublished": true})
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 	var orders []*model.Order
 	for cursor.Next(ctx) {
-		var result struct {
-			ID        primitive.ObjectID `bson:"_id"`
-			UserID    string             `bson:"user_id"`
-			Products  []struct {
-				ProductID string `bson:"product_id"`
-				Quantity  int    `bson:"quantity"`
-			} `bson:"products"`
-			Total  float64 `bson:"total"`
-			Status string  `bson:"status"`
+		var doc orderDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
 		}
-		if err := cursor.Decode(&result); err != nil {
+		orders = append(orders, doc.toModel())
+	}
+	return orders, cursor.Err()
+}
+
+func (r *MongoOrderRepository) FindUninvoiced(ctx context.Context) ([]*model.Order, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"status":         "COMPLETED",
+		"payment_id":     bson.M{"$gt": ""},
+		"invoice_number": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var orders []*model.Order
+	for cursor.Next(ctx) {
+		var doc orderDocument
+		if err := cursor.Decode(&doc); err != nil {
 			return nil, err
 		}
-		order := &model.Order{
-			ID:     result.ID.Hex(),
-			UserID: result.UserID,
-			Total:  result.Total,
-			Status: result.Status,
+		orders = append(orders, doc.toModel())
+	}
+	return orders, cursor.Err()
+}
+
+func (r *MongoOrderRepository) FindPendingRefunds(ctx context.Context) ([]*model.Order, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"returns.refund_status": model.RefundPending})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var orders []*model.Order
+	for cursor.Next(ctx) {
+		var doc orderDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
 		}
-		for _, p := range result.Products {
-			order.Products = append(order.Products, model.Product{
-				ProductID: p.ProductID,
-				Quantity:  p.Quantity,
-			})
+		orders = append(orders, doc.toModel())
+	}
+	return orders, cursor.Err()
+}
+
+func (r *MongoOrderRepository) FindByUserID(ctx context.Context, userID string) ([]*model.Order, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, options.Find())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var orders []*model.Order
+	for cursor.Next(ctx) {
+		var doc orderDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
 		}
-		orders = append(orders, order)
+		orders = append(orders, doc.toModel())
 	}
 	return orders, nil
 }
\ No newline at end of file
@@ -2,12 +2,49 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"order-service/internal/model"
 )
 
+var (
+	// ErrOrderNotFound is returned for orders that do not exist.
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrInvalidID is returned for IDs that cannot be an order's.
+	ErrInvalidID = errors.New("invalid ID")
+	// ErrDuplicateOrder is returned by Create for orders whose idempotency
+	// key another order was created with.
+	ErrDuplicateOrder = errors.New("an order was already created with this idempotency key")
+)
+
 type OrderRepository interface {
 	Create(ctx context.Context, order *model.Order) (string, error)
 	FindByID(ctx context.Context, id string) (*model.Order, error)
-	UpdateStatus(ctx context.Context, id string, status string) error
+	FindByIdempotencyKey(ctx context.Context, key string) (*model.Order, error)
+	// UpdateStatus moves an order from status from to status. It reports
+	// false, changing nothing, if the order is no longer in from.
+	UpdateStatus(ctx context.Context, id, from, status string) (bool, error)
+	SettlePayment(ctx context.Context, id, paymentID, status string) (bool, error)
+	// MarkStockPublished records that the stock event of status went out,
+	// unless the order has moved on from status since.
+	MarkStockPublished(ctx context.Context, id, status string) error
+	// FindStockUnpublished returns the orders whose stock event has not
+	// gone out.
+	FindStockUnpublished(ctx context.Context) ([]*model.Order, error)
+	// AddReturn records a return of a COMPLETED order, as order was read,
+	// and adds its quantities to the lines' returned units. It assigns
+	// ret.ID, and reports false, changing nothing, if the order is no longer
+	// COMPLETED or another return of the same lines got in first.
+	AddReturn(ctx context.Context, order *model.Order, ret *model.Return) (bool, error)
+	SetRefundStatus(ctx context.Context, orderID, returnID, status string) error
+	// FindPendingRefunds returns orders with returns still to be refunded.
+	FindPendingRefunds(ctx context.Context) ([]*model.Order, error)
+	// MarkReturnPublished records that the return's order.returned event
+	// went out.
+	MarkReturnPublished(ctx context.Context, orderID, returnID string) error
+	// FindUnpublishedReturns returns orders with returns whose
+	// order.returned event has not gone out.
+	FindUnpublishedReturns(ctx context.Context) ([]*model.Order, error)
+	// FindUninvoiced returns the paid orders no invoice was issued for.
+	FindUninvoiced(ctx context.Context) ([]*model.Order, error)
 	FindByUserID(ctx context.Context, userID string) ([]*model.Order, error)
 }
\ No newline at end of file
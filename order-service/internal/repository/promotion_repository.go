@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"order-service/internal/model"
+
+	"golang/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrPromotionUsedUp is returned by Redeem when a usage limit is reached.
+var ErrPromotionUsedUp = errors.New("promotion usage limit reached")
+
+type PromotionRepository interface {
+	Create(ctx context.Context, p *model.Promotion) (string, error)
+	FindByID(ctx context.Context, id string) (*model.Promotion, error)
+	FindByCode(ctx context.Context, code string) (*model.Promotion, error)
+	// Update replaces every field but the ID, usage count and creation time.
+	Update(ctx context.Context, p *model.Promotion) error
+	List(ctx context.Context) ([]*model.Promotion, error)
+	// ListAutomatic returns the active promotions without a code.
+	ListAutomatic(ctx context.Context) ([]*model.Promotion, error)
+	// UsesBy returns how many orders userID placed with the promotion.
+	UsesBy(ctx context.Context, p *model.Promotion, userID string) (int64, error)
+	// Redeem counts an order placed by userID with the promotion, or fails
+	// with ErrPromotionUsedUp if that would exceed a usage limit.
+	Redeem(ctx context.Context, p *model.Promotion, userID string) error
+	// Unredeem takes back a use counted for an order that was not placed.
+	Unredeem(ctx context.Context, p *model.Promotion, userID string) error
+}
+
+type MongoPromotionRepository struct {
+	promotions *mongo.Collection
+	usage      *mongo.Collection
+}
+
+// NewMongoPromotionRepository keeps promotions in promotions and their use
+// per user in usage.
+func NewMongoPromotionRepository(promotions, usage *mongo.Collection) *MongoPromotionRepository {
+	// Codes are unique among coupons; automatic promotions have none.
+	promotions.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{
+			Keys: bson.D{{Key: "code", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"code": bson.M{"$gt": ""}}),
+		},
+	)
+	return &MongoPromotionRepository{promotions: promotions, usage: usage}
+}
+
+// promotionDocument is the stored shape of a promotion. Amounts are in minor
+// units of currency.
+type promotionDocument struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	Code           string             `bson:"code,omitempty"`
+	Description    string             `bson:"description"`
+	Type           string             `bson:"type"`
+	PercentOff     int32              `bson:"percent_off,omitempty"`
+	AmountOff      int64              `bson:"amount_off,omitempty"`
+	MinSubtotal    int64              `bson:"min_subtotal,omitempty"`
+	Currency       string             `bson:"currency,omitempty"`
+	ProductIDs     []string           `bson:"product_ids,omitempty"`
+	CategoryIDs    []string           `bson:"category_ids,omitempty"`
+	StartsAt       time.Time          `bson:"starts_at,omitempty"`
+	EndsAt         time.Time          `bson:"ends_at,omitempty"`
+	MaxUses        int64              `bson:"max_uses"`
+	MaxUsesPerUser int64              `bson:"max_uses_per_user"`
+	Uses           int64              `bson:"uses"`
+	Active         bool               `bson:"active"`
+	CreatedAt      time.Time          `bson:"created_at"`
+}
+
+// promotionOptionalFields are the fields of promotionDocument left out when
+// empty.
+var promotionOptionalFields = []string{
+	"code", "percent_off", "amount_off", "min_subtotal", "currency",
+	"product_ids", "category_ids", "starts_at", "ends_at",
+}
+
+type promotionUsageDocument struct {
+	ID    string `bson:"_id"` // promotion ID and user ID
+	Count int64  `bson:"count"`
+}
+
+func toPromotionDocument(p *model.Promotion) promotionDocument {
+	currency := p.AmountOff.Currency
+	if currency == "" {
+		currency = p.MinSubtotal.Currency
+	}
+	return promotionDocument{
+		Code:           p.Code,
+		Description:    p.Description,
+		Type:           p.Type,
+		PercentOff:     p.PercentOff,
+		AmountOff:      p.AmountOff.Amount,
+		MinSubtotal:    p.MinSubtotal.Amount,
+		Currency:       currency,
+		ProductIDs:     p.ProductIDs,
+		CategoryIDs:    p.CategoryIDs,
+		StartsAt:       p.StartsAt,
+		EndsAt:         p.EndsAt,
+		MaxUses:        p.MaxUses,
+		MaxUsesPerUser: p.MaxUsesPerUser,
+		Uses:           p.Uses,
+		Active:         p.Active,
+		CreatedAt:      p.CreatedAt,
+	}
+}
+
+func (d promotionDocument) toModel() *model.Promotion {
+	amount := func(v int64) money.Money {
+		if v == 0 {
+			return money.Money{}
+		}
+		return money.New(v, d.Currency)
+	}
+	return &model.Promotion{
+		ID:             d.ID.Hex(),
+		Code:           d.Code,
+		Description:    d.Description,
+		Type:           d.Type,
+		PercentOff:     d.PercentOff,
+		AmountOff:      amount(d.AmountOff),
+		MinSubtotal:    amount(d.MinSubtotal),
+		ProductIDs:     d.ProductIDs,
+		CategoryIDs:    d.CategoryIDs,
+		StartsAt:       d.StartsAt,
+		EndsAt:         d.EndsAt,
+		MaxUses:        d.MaxUses,
+		MaxUsesPerUser: d.MaxUsesPerUser,
+		Uses:           d.Uses,
+		Active:         d.Active,
+		CreatedAt:      d.CreatedAt,
+	}
+}
+
+func (r *MongoPromotionRepository) Create(ctx context.Context, p *model.Promotion) (string, error) {
+	res, err := r.promotions.InsertOne(ctx, toPromotionDocument(p))
+	if mongo.IsDuplicateKeyError(err) {
+		return "", errors.New("promotion code already exists")
+	}
+	if err != nil {
+		return "", err
+	}
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (r *MongoPromotionRepository) FindByID(ctx context.Context, id string) (*model.Promotion, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("promotion not found")
+	}
+	return r.findOne(ctx, bson.M{"_id": objID})
+}
+
+func (r *MongoPromotionRepository) FindByCode(ctx context.Context, code string) (*model.Promotion, error) {
+	return r.findOne(ctx, bson.M{"code": code})
+}
+
+func (r *MongoPromotionRepository) findOne(ctx context.Context, filter bson.M) (*model.Promotion, error) {
+	var doc promotionDocument
+	err := r.promotions.FindOne(ctx, filter).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return nil, errors.New("promotion not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return doc.toModel(), nil
+}
+
+func (r *MongoPromotionRepository) Update(ctx context.Context, p *model.Promotion) error {
+	objID, err := primitive.ObjectIDFromHex(p.ID)
+	if err != nil {
+		return errors.New("promotion not found")
+	}
+	// Marshalling drops empty optional fields, which are unset instead.
+	data, err := bson.Marshal(toPromotionDocument(p))
+	if err != nil {
+		return err
+	}
+	var set bson.M
+	if err := bson.Unmarshal(data, &set); err != nil {
+		return err
+	}
+	for _, field := range []string{"_id", "uses", "created_at"} {
+		delete(set, field)
+	}
+	unset := bson.M{}
+	for _, field := range promotionOptionalFields {
+		if _, ok := set[field]; !ok {
+			unset[field] = ""
+		}
+	}
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	res, err := r.promotions.UpdateOne(ctx, bson.M{"_id": objID}, update)
+	if mongo.IsDuplicateKeyError(err) {
+		return errors.New("promotion code already exists")
+	}
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return errors.New("promotion not found")
+	}
+	return nil
+}
+
+func (r *MongoPromotionRepository) List(ctx context.Context) ([]*model.Promotion, error) {
+	return r.find(ctx, bson.M{})
+}
+
+func (r *MongoPromotionRepository) ListAutomatic(ctx context.Context) ([]*model.Promotion, error) {
+	return r.find(ctx, bson.M{"active": true, "code": bson.M{"$exists": false}})
+}
+
+func (r *MongoPromotionRepository) find(ctx context.Context, filter bson.M) ([]*model.Promotion, error) {
+	cursor, err := r.promotions.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var promotions []*model.Promotion
+	for cursor.Next(ctx) {
+		var doc promotionDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, err
+		}
+		promotions = append(promotions, doc.toModel())
+	}
+	return promotions, cursor.Err()
+}
+
+func usageID(p *model.Promotion, userID string) string {
+	return p.ID + ":" + userID
+}
+
+func (r *MongoPromotionRepository) UsesBy(ctx context.Context, p *model.Promotion, userID string) (int64, error) {
+	var doc promotionUsageDocument
+	err := r.usage.FindOne(ctx, bson.M{"_id": usageID(p, userID)}).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return doc.Count, err
+}
+
+func (r *MongoPromotionRepository) Redeem(ctx context.Context, p *model.Promotion, userID string) error {
+	objID, err := primitive.ObjectIDFromHex(p.ID)
+	if err != nil {
+		return errors.New("promotion not found")
+	}
+
+	// Both counters only move while under their limit. The per-user upsert
+	// fails on the existing document when the user is at the limit.
+	userFilter := bson.M{"_id": usageID(p, userID)}
+	if p.MaxUsesPerUser > 0 {
+		userFilter["count"] = bson.M{"$lt": p.MaxUsesPerUser}
+	}
+	_, err = r.usage.UpdateOne(ctx, userFilter, bson.M{"$inc": bson.M{"count": 1}}, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrPromotionUsedUp
+	}
+	if err != nil {
+		return err
+	}
+
+	res, err := r.promotions.UpdateOne(ctx,
+		bson.M{"_id": objID, "$or": bson.A{
+			bson.M{"max_uses": 0},
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$uses", "$max_uses"}}},
+		}},
+		bson.M{"$inc": bson.M{"uses": 1}},
+	)
+	if err == nil && res.MatchedCount == 0 {
+		err = ErrPromotionUsedUp
+	}
+	if err != nil {
+		_, _ = r.usage.UpdateOne(ctx, bson.M{"_id": usageID(p, userID)}, bson.M{"$inc": bson.M{"count": -1}})
+		return err
+	}
+	return nil
+}
+
+func (r *MongoPromotionRepository) Unredeem(ctx context.Context, p *model.Promotion, userID string) error {
+	objID, err := primitive.ObjectIDFromHex(p.ID)
+	if err != nil {
+		return errors.New("promotion not found")
+	}
+	if _, err := r.promotions.UpdateOne(ctx, bson.M{"_id": objID}, bson.M{"$inc": bson.M{"uses": -1}}); err != nil {
+		return err
+	}
+	_, err = r.usage.UpdateOne(ctx, bson.M{"_id": usageID(p, userID)}, bson.M{"$inc": bson.M{"count": -1}})
+	return err
+}
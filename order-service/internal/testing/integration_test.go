@@ -11,6 +11,8 @@ import (
 	"order-service/internal/repository"
 	"order-service/internal/usecase"
 
+	"golang/pkg/cache"
+
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -39,7 +41,7 @@ func TestMain(m *testing.M) {
 	testRepo = repository.NewMongoOrderRepository(coll)
 
 	// Паблишердің орнына nil береміз (publish тексермейміз)
-	orderUc = usecase.NewOrderUsecase(testRepo, nil)
+	orderUc = usecase.NewOrderUsecase(testRepo, nil, nil, cache.NewLRU(100), nil, nil, nil, nil)
 
 	// Тесттерді іске қосу
 	code := m.Run()
@@ -1,13 +1,28 @@
 package testing
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"order-service/internal/inventory"
 	"order-service/internal/model"
+	"order-service/internal/payments"
+	"order-service/internal/pricing"
+	"order-service/internal/repository"
 	"order-service/internal/usecase"
+	"order-service/internal/users"
+
+	"golang/pkg/address"
+	"golang/pkg/blob"
+	"golang/pkg/cache"
+	"golang/pkg/money"
 )
 
 // 🔧 Mock репо
@@ -25,11 +40,62 @@ func (m *MockOrderRepo) FindByID(ctx context.Context, id string) (*model.Order,
 	return args.Get(0).(*model.Order), args.Error(1)
 }
 
-func (m *MockOrderRepo) UpdateStatus(ctx context.Context, id, status string) error {
+func (m *MockOrderRepo) FindByIdempotencyKey(ctx context.Context, key string) (*model.Order, error) {
+	args := m.Called(ctx, key)
+	o, _ := args.Get(0).(*model.Order)
+	return o, args.Error(1)
+}
+
+func (m *MockOrderRepo) UpdateStatus(ctx context.Context, id, from, status string) (bool, error) {
+	args := m.Called(ctx, id, from, status)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOrderRepo) SettlePayment(ctx context.Context, id, paymentID, status string) (bool, error) {
+	args := m.Called(ctx, id, paymentID, status)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOrderRepo) MarkStockPublished(ctx context.Context, id, status string) error {
 	args := m.Called(ctx, id, status)
 	return args.Error(0)
 }
 
+func (m *MockOrderRepo) FindStockUnpublished(ctx context.Context) ([]*model.Order, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*model.Order), args.Error(1)
+}
+
+func (m *MockOrderRepo) AddReturn(ctx context.Context, order *model.Order, ret *model.Return) (bool, error) {
+	args := m.Called(ctx, order, ret)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockOrderRepo) SetRefundStatus(ctx context.Context, orderID, returnID, status string) error {
+	args := m.Called(ctx, orderID, returnID, status)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepo) FindPendingRefunds(ctx context.Context) ([]*model.Order, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*model.Order), args.Error(1)
+}
+
+func (m *MockOrderRepo) MarkReturnPublished(ctx context.Context, orderID, returnID string) error {
+	args := m.Called(ctx, orderID, returnID)
+	return args.Error(0)
+}
+
+func (m *MockOrderRepo) FindUnpublishedReturns(ctx context.Context) ([]*model.Order, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*model.Order), args.Error(1)
+}
+
+func (m *MockOrderRepo) FindUninvoiced(ctx context.Context) ([]*model.Order, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*model.Order), args.Error(1)
+}
+
 func (m *MockOrderRepo) FindByUserID(ctx context.Context, userID string) ([]*model.Order, error) {
 	args := m.Called(ctx, userID)
 	return args.Get(0).([]*model.Order), args.Error(1)
@@ -45,6 +111,174 @@ func (m *MockPublisher) PublishOrderCreated(ctx context.Context, order *model.Or
 	return args.Error(0)
 }
 
+func (m *MockPublisher) PublishOrderCancelled(ctx context.Context, order *model.Order) error {
+	args := m.Called(ctx, order)
+	return args.Error(0)
+}
+
+func (m *MockPublisher) PublishOrderReturned(ctx context.Context, order *model.Order, ret *model.Return) error {
+	args := m.Called(ctx, order, ret)
+	return args.Error(0)
+}
+
+func (m *MockPublisher) PublishOrderInvoiced(ctx context.Context, inv *model.Invoice, key, email string) error {
+	args := m.Called(ctx, inv, key, email)
+	return args.Error(0)
+}
+
+// 🔧 Mock payment-service
+type MockPayments struct {
+	mock.Mock
+}
+
+func (m *MockPayments) Refund(ctx context.Context, paymentID string, amount money.Money, reference, reason string) error {
+	args := m.Called(ctx, paymentID, amount, reference, reason)
+	return args.Error(0)
+}
+
+// 🔧 Mock invoice репо
+type MockInvoiceRepo struct {
+	mock.Mock
+}
+
+func (m *MockInvoiceRepo) Create(ctx context.Context, inv *model.Invoice) error {
+	args := m.Called(ctx, inv)
+	return args.Error(0)
+}
+
+func (m *MockInvoiceRepo) FindByOrder(ctx context.Context, orderID string) (*model.Invoice, error) {
+	args := m.Called(ctx, orderID)
+	inv, _ := args.Get(0).(*model.Invoice)
+	return inv, args.Error(1)
+}
+
+func (m *MockInvoiceRepo) FindUnpublished(ctx context.Context) ([]*model.Invoice, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*model.Invoice), args.Error(1)
+}
+
+func (m *MockInvoiceRepo) MarkPublished(ctx context.Context, seq int64) error {
+	args := m.Called(ctx, seq)
+	return args.Error(0)
+}
+
+func (m *MockInvoiceRepo) MarkSent(ctx context.Context, orderID string) error {
+	args := m.Called(ctx, orderID)
+	return args.Error(0)
+}
+
+// 🔧 Mock user directory
+type MockDirectory struct {
+	mock.Mock
+}
+
+func (m *MockDirectory) Email(ctx context.Context, userID string) (string, error) {
+	args := m.Called(ctx, userID)
+	return args.String(0), args.Error(1)
+}
+
+// 🔧 Mock idempotency репо
+type MockIdempotencyRepo struct {
+	mock.Mock
+}
+
+func (m *MockIdempotencyRepo) Reserve(ctx context.Context, key, requestHash string) (*model.IdempotencyRecord, bool, error) {
+	args := m.Called(ctx, key, requestHash)
+	record, _ := args.Get(0).(*model.IdempotencyRecord)
+	return record, args.Bool(1), args.Error(2)
+}
+
+func (m *MockIdempotencyRepo) Complete(ctx context.Context, key, orderID string) error {
+	args := m.Called(ctx, key, orderID)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepo) Release(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+// 🔧 Mock каталог
+type MockCatalog struct {
+	mock.Mock
+}
+
+func (m *MockCatalog) GetProduct(ctx context.Context, id string) (*model.CatalogProduct, error) {
+	args := m.Called(ctx, id)
+	p, _ := args.Get(0).(*model.CatalogProduct)
+	return p, args.Error(1)
+}
+
+func (m *MockCatalog) CategoryPath(ctx context.Context, categoryID string) ([]string, error) {
+	args := m.Called(ctx, categoryID)
+	path, _ := args.Get(0).([]string)
+	return path, args.Error(1)
+}
+
+// 🔧 Mock address book
+type MockAddressBook struct {
+	mock.Mock
+}
+
+func (m *MockAddressBook) GetAddress(ctx context.Context, userID, id string) (address.Address, error) {
+	args := m.Called(ctx, userID, id)
+	return args.Get(0).(address.Address), args.Error(1)
+}
+
+// 🔧 Mock промо репо
+type MockPromotionRepo struct {
+	mock.Mock
+}
+
+func (m *MockPromotionRepo) Create(ctx context.Context, p *model.Promotion) (string, error) {
+	args := m.Called(ctx, p)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPromotionRepo) FindByID(ctx context.Context, id string) (*model.Promotion, error) {
+	args := m.Called(ctx, id)
+	p, _ := args.Get(0).(*model.Promotion)
+	return p, args.Error(1)
+}
+
+func (m *MockPromotionRepo) FindByCode(ctx context.Context, code string) (*model.Promotion, error) {
+	args := m.Called(ctx, code)
+	p, _ := args.Get(0).(*model.Promotion)
+	return p, args.Error(1)
+}
+
+func (m *MockPromotionRepo) Update(ctx context.Context, p *model.Promotion) error {
+	args := m.Called(ctx, p)
+	return args.Error(0)
+}
+
+func (m *MockPromotionRepo) List(ctx context.Context) ([]*model.Promotion, error) {
+	args := m.Called(ctx)
+	promotions, _ := args.Get(0).([]*model.Promotion)
+	return promotions, args.Error(1)
+}
+
+func (m *MockPromotionRepo) ListAutomatic(ctx context.Context) ([]*model.Promotion, error) {
+	args := m.Called(ctx)
+	promotions, _ := args.Get(0).([]*model.Promotion)
+	return promotions, args.Error(1)
+}
+
+func (m *MockPromotionRepo) UsesBy(ctx context.Context, p *model.Promotion, userID string) (int64, error) {
+	args := m.Called(ctx, p, userID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockPromotionRepo) Redeem(ctx context.Context, p *model.Promotion, userID string) error {
+	args := m.Called(ctx, p, userID)
+	return args.Error(0)
+}
+
+func (m *MockPromotionRepo) Unredeem(ctx context.Context, p *model.Promotion, userID string) error {
+	args := m.Called(ctx, p, userID)
+	return args.Error(0)
+}
+
 func getSampleOrder() *model.Order {
 	return &model.Order{
 		UserID: "user123",
@@ -57,11 +291,12 @@ func getSampleOrder() *model.Order {
 func TestCreateOrder_Success(t *testing.T) {
 	mockRepo := new(MockOrderRepo)
 	mockPub := new(MockPublisher)
-	uc := usecase.NewOrderUsecase(mockRepo, mockPub)
+	uc := usecase.NewOrderUsecase(mockRepo, mockPub, nil, cache.NewLRU(100), nil, nil, nil, nil)
 
 	order := getSampleOrder()
 	mockRepo.On("Create", mock.Anything, order).Return("order123", nil)
 	mockPub.On("PublishOrderCreated", mock.Anything, order).Return(nil)
+	mockRepo.On("MarkStockPublished", mock.Anything, "order123", "PENDING").Return(nil)
 
 	id, err := uc.CreateOrder(context.Background(), order)
 
@@ -74,20 +309,20 @@ func TestCreateOrder_Success(t *testing.T) {
 func TestCreateOrder_InvalidInput(t *testing.T) {
 	mockRepo := new(MockOrderRepo)
 	mockPub := new(MockPublisher)
-	uc := usecase.NewOrderUsecase(mockRepo, mockPub)
+	uc := usecase.NewOrderUsecase(mockRepo, mockPub, nil, cache.NewLRU(100), nil, nil, nil, nil)
 
 	order := &model.Order{} // invalid: no UserID or Products
 
 	id, err := uc.CreateOrder(context.Background(), order)
 
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, usecase.ErrInvalidOrder)
 	assert.Empty(t, id)
 }
 
 func TestGetOrder(t *testing.T) {
 	mockRepo := new(MockOrderRepo)
 	mockPub := new(MockPublisher)
-	uc := usecase.NewOrderUsecase(mockRepo, mockPub)
+	uc := usecase.NewOrderUsecase(mockRepo, mockPub, nil, cache.NewLRU(100), nil, nil, nil, nil)
 
 	expectedOrder := getSampleOrder()
 	expectedOrder.ID = "order123"
@@ -100,24 +335,1293 @@ func TestGetOrder(t *testing.T) {
 	assert.Equal(t, expectedOrder, order)
 }
 
+func TestGetOrder_NotFoundIsCached(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	uc := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), nil, nil, nil, nil)
+	mockRepo.On("FindByID", mock.Anything, "missing").Return((*model.Order)(nil), repository.ErrOrderNotFound).Once()
+
+	for range 2 {
+		_, err := uc.GetOrder(context.Background(), "missing")
+		assert.ErrorIs(t, err, repository.ErrOrderNotFound)
+	}
+	mockRepo.AssertExpectations(t)
+}
+
 func TestUpdateOrderStatus(t *testing.T) {
 	mockRepo := new(MockOrderRepo)
 	mockPub := new(MockPublisher)
-	uc := usecase.NewOrderUsecase(mockRepo, mockPub)
+	uc := usecase.NewOrderUsecase(mockRepo, mockPub, nil, cache.NewLRU(100), nil, nil, nil, nil)
 
-	mockRepo.On("UpdateStatus", mock.Anything, "order123", "COMPLETED").Return(nil)
+	existing := getSampleOrder()
+	existing.ID = "order123"
+	existing.Status = "PENDING"
+	mockRepo.On("FindByID", mock.Anything, "order123").Return(existing, nil)
+	mockRepo.On("UpdateStatus", mock.Anything, "order123", "PENDING", "CANCELLED").Return(true, nil)
+	// The cancelled order's stock is given back.
+	mockPub.On("PublishOrderCancelled", mock.Anything, mock.MatchedBy(func(o *model.Order) bool {
+		return o.ID == "order123" && o.Status == "CANCELLED"
+	})).Return(nil)
+	mockRepo.On("MarkStockPublished", mock.Anything, "order123", "CANCELLED").Return(nil)
 
-	err := uc.UpdateOrderStatus(context.Background(), "order123", "COMPLETED")
+	err := uc.UpdateOrderStatus(context.Background(), "order123", "", "CANCELLED")
 
 	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockPub.AssertExpectations(t)
+}
+
+func TestUpdateOrderStatus_NotFound(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	mockPub := new(MockPublisher)
+	uc := usecase.NewOrderUsecase(mockRepo, mockPub, nil, cache.NewLRU(100), nil, nil, nil, nil)
+
+	mockRepo.On("FindByID", mock.Anything, "missing").Return((*model.Order)(nil), repository.ErrOrderNotFound)
+
+	err := uc.UpdateOrderStatus(context.Background(), "missing", "", "CANCELLED")
+
+	assert.ErrorIs(t, err, repository.ErrOrderNotFound)
+	mockRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateOrderStatus_Rejects(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	uc := usecase.NewOrderUsecase(mockRepo, new(MockPublisher), nil, cache.NewLRU(100), nil, nil, nil, nil)
+
+	pending := getSampleOrder()
+	pending.ID = "order123"
+	pending.Status = "PENDING"
+	paid := getSampleOrder()
+	paid.ID = "order456"
+	paid.Status = "COMPLETED"
+	paid.PaymentID = "pay1"
+	mockRepo.On("FindByID", mock.Anything, "order123").Return(pending, nil)
+	mockRepo.On("FindByID", mock.Anything, "order456").Return(paid, nil)
+
+	// Only payments complete orders.
+	err := uc.UpdateOrderStatus(context.Background(), "order123", "", "COMPLETED")
+	assert.ErrorIs(t, err, usecase.ErrStatusNotSettable)
+
+	// Customers only cancel, and only their own orders.
+	err = uc.UpdateOrderStatus(context.Background(), "order123", "user123", "PENDING")
+	assert.ErrorIs(t, err, usecase.ErrCancelOnly)
+	err = uc.UpdateOrderStatus(context.Background(), "order123", "someone-else", "CANCELLED")
+	assert.ErrorIs(t, err, repository.ErrOrderNotFound)
+
+	// Paid orders are cancelled by returning them.
+	err = uc.UpdateOrderStatus(context.Background(), "order456", "", "CANCELLED")
+	assert.ErrorIs(t, err, usecase.ErrOrderPaid)
+
+	// The order was settled between reading and updating it.
+	mockRepo.On("UpdateStatus", mock.Anything, "order123", "PENDING", "CANCELLED").Return(false, nil).Once()
+	err = uc.UpdateOrderStatus(context.Background(), "order123", "user123", "CANCELLED")
+	assert.ErrorIs(t, err, usecase.ErrStatusConflict)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSettlePayment(t *testing.T) {
+	mockRepo, mockPub := new(MockOrderRepo), new(MockPublisher)
+	lru := cache.NewLRU(100)
+	uc := usecase.NewOrderUsecase(mockRepo, mockPub, nil, lru, nil, nil, nil, nil)
+
+	existing := getSampleOrder()
+	existing.ID = "order123"
+	mockRepo.On("FindByID", mock.Anything, "order123").Return(existing, nil)
+	mockRepo.On("SettlePayment", mock.Anything, "order123", "pay1", "COMPLETED").Return(true, nil).Once()
+	_ = lru.Set(context.Background(), "order:order123", []byte("{}"), time.Minute)
+
+	assert.NoError(t, uc.SettlePayment(context.Background(), "order123", "pay1", true))
+	_, err := lru.Get(context.Background(), "order:order123")
+	assert.Error(t, err, "the cached order is invalidated")
+
+	// A failed payment cancels the order, giving back its stock. An event
+	// that does not go out stays owed.
+	mockRepo.On("SettlePayment", mock.Anything, "order123", "pay2", "CANCELLED").Return(true, nil).Once()
+	mockPub.On("PublishOrderCancelled", mock.Anything, mock.Anything).Return(errors.New("nats: timeout")).Once()
+	assert.NoError(t, uc.SettlePayment(context.Background(), "order123", "pay2", false))
+	mockRepo.AssertNotCalled(t, "MarkStockPublished", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+	mockPub.AssertExpectations(t)
+}
+
+func TestPublishPendingStock(t *testing.T) {
+	mockRepo, mockPub := new(MockOrderRepo), new(MockPublisher)
+	uc := usecase.NewOrderUsecase(mockRepo, mockPub, nil, cache.NewLRU(100), nil, nil, nil, nil)
+
+	placed := getSampleOrder()
+	placed.ID = "order1"
+	placed.Status = "PENDING"
+	cancelled := getSampleOrder()
+	cancelled.ID = "order2"
+	cancelled.Status = "CANCELLED"
+	stuck := getSampleOrder()
+	stuck.ID = "order3"
+	stuck.Status = "CANCELLED"
+	mockRepo.On("FindStockUnpublished", mock.Anything).Return([]*model.Order{placed, cancelled, stuck}, nil)
+	mockPub.On("PublishOrderCreated", mock.Anything, placed).Return(nil).Once()
+	mockPub.On("PublishOrderCancelled", mock.Anything, cancelled).Return(nil).Once()
+	mockPub.On("PublishOrderCancelled", mock.Anything, stuck).Return(errors.New("nats: timeout")).Once()
+	mockRepo.On("MarkStockPublished", mock.Anything, "order1", "PENDING").Return(nil).Once()
+	mockRepo.On("MarkStockPublished", mock.Anything, "order2", "CANCELLED").Return(nil).Once()
+
+	n, err := uc.PublishPendingStock(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	mockRepo.AssertExpectations(t)
+	mockPub.AssertExpectations(t)
+}
+
+func TestSettlePayment_OrderNotPending(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	uc := usecase.NewOrderUsecase(mockRepo, new(MockPublisher), nil, cache.NewLRU(100), nil, nil, nil, nil)
+
+	existing := getSampleOrder()
+	existing.ID = "order123"
+	existing.Status = "CANCELLED"
+	mockRepo.On("FindByID", mock.Anything, "order123").Return(existing, nil)
+	mockRepo.On("SettlePayment", mock.Anything, "order123", "pay1", "COMPLETED").Return(false, nil)
+
+	// A duplicate or late event is not an error; the order stays as it is.
+	assert.NoError(t, uc.SettlePayment(context.Background(), "order123", "pay1", true))
+	assert.Error(t, uc.SettlePayment(context.Background(), "order123", "", true))
+}
+
+func TestCancelledOrdersGiveBackPromotionUses(t *testing.T) {
+	mockRepo, mockPub, promos := new(MockOrderRepo), new(MockPublisher), new(MockPromotionRepo)
+	uc := usecase.NewOrderUsecase(mockRepo, mockPub, nil, cache.NewLRU(100), nil, usecase.NewPromotionUsecase(promos, nil, "USD"), nil, nil)
+	ctx := context.Background()
+
+	order := getSampleOrder()
+	order.ID = "order123"
+	order.Status = "PENDING"
+	order.Adjustments = []model.Adjustment{
+		{PromotionID: "promo1", ProductID: "p1", Amount: money.New(-100, "USD")},
+		{PromotionID: "promo1", ProductID: "p2", Amount: money.New(-50, "USD")},
+	}
+	promo := &model.Promotion{ID: "promo1"}
+	mockRepo.On("FindByID", mock.Anything, "order123").Return(order, nil)
+	// Each cancellation gives back the stock, and reopening takes it again.
+	mockPub.On("PublishOrderCancelled", mock.Anything, mock.Anything).Return(nil).Twice()
+	mockPub.On("PublishOrderCreated", mock.Anything, mock.MatchedBy(func(o *model.Order) bool { return o.Status == "PENDING" })).Return(nil).Once()
+	mockRepo.On("MarkStockPublished", mock.Anything, "order123", "CANCELLED").Return(nil).Twice()
+	mockRepo.On("MarkStockPublished", mock.Anything, "order123", "PENDING").Return(nil).Once()
+
+	// A failed payment and a cancellation each give the use back once.
+	promos.On("Unredeem", mock.Anything, promo, order.UserID).Return(nil).Twice()
+	mockRepo.On("SettlePayment", mock.Anything, "order123", "pay1", "CANCELLED").Return(true, nil).Once()
+	assert.NoError(t, uc.SettlePayment(ctx, "order123", "pay1", false))
+	mockRepo.On("UpdateStatus", mock.Anything, "order123", "PENDING", "CANCELLED").Return(true, nil).Once()
+	assert.NoError(t, uc.UpdateOrderStatus(ctx, "order123", "", "CANCELLED"))
+
+	// Reopening the order takes the use again, if there is one left.
+	order.Status = "CANCELLED"
+	assert.NoError(t, uc.UpdateOrderStatus(ctx, "order123", "", "CANCELLED"), "already cancelled")
+	promos.On("Redeem", mock.Anything, promo, order.UserID).Return(nil).Once()
+	mockRepo.On("UpdateStatus", mock.Anything, "order123", "CANCELLED", "PENDING").Return(true, nil).Once()
+	assert.NoError(t, uc.UpdateOrderStatus(ctx, "order123", "", "PENDING"))
+	promos.On("Redeem", mock.Anything, promo, order.UserID).Return(repository.ErrPromotionUsedUp).Once()
+	assert.ErrorIs(t, uc.UpdateOrderStatus(ctx, "order123", "", "PENDING"), usecase.ErrPromotionNotApplicable)
+
+	mockRepo.AssertExpectations(t)
+	mockPub.AssertExpectations(t)
+	promos.AssertExpectations(t)
+}
+
+// 🔧 Redis outage
+type failingCache struct{}
+
+func (failingCache) Get(context.Context, string) ([]byte, error) {
+	return nil, errors.New("redis: connection refused")
+}
+
+func (failingCache) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("redis: connection refused")
+}
+
+func (failingCache) Delete(context.Context, ...string) error {
+	return errors.New("redis: connection refused")
+}
+
+func TestListUserOrders_FallsBackWhenCacheFails(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	mockPub := new(MockPublisher)
+	uc := usecase.NewOrderUsecase(mockRepo, mockPub, nil, failingCache{}, nil, nil, nil, nil)
+
+	orders := []*model.Order{getSampleOrder()}
+	mockRepo.On("FindByUserID", mock.Anything, "user123").Return(orders, nil)
+
+	result, err := uc.ListUserOrders(context.Background(), "user123")
+
+	assert.NoError(t, err)
+	assert.Equal(t, orders, result)
 }
 
 func TestUpdateOrderStatus_InvalidStatus(t *testing.T) {
 	mockRepo := new(MockOrderRepo)
 	mockPub := new(MockPublisher)
-	uc := usecase.NewOrderUsecase(mockRepo, mockPub)
+	uc := usecase.NewOrderUsecase(mockRepo, mockPub, nil, cache.NewLRU(100), nil, nil, nil, nil)
 
-	err := uc.UpdateOrderStatus(context.Background(), "order123", "SHIPPED") // invalid
+	err := uc.UpdateOrderStatus(context.Background(), "order123", "", "SHIPPED") // invalid
 
 	assert.Error(t, err)
-}
\ No newline at end of file
+}
+func TestCreateOrderIdempotent_FirstRequest(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	mockPub := new(MockPublisher)
+	mockIdem := new(MockIdempotencyRepo)
+	uc := usecase.NewOrderUsecase(mockRepo, mockPub, mockIdem, cache.NewLRU(100), nil, nil, nil, nil)
+
+	order := getSampleOrder()
+	mockIdem.On("Reserve", mock.Anything, "user123:key-1", mock.Anything).Return(&model.IdempotencyRecord{}, true, nil)
+	mockRepo.On("FindByIdempotencyKey", mock.Anything, "user123:key-1").Return(nil, repository.ErrOrderNotFound)
+	mockRepo.On("Create", mock.Anything, order).Return("order123", nil)
+	mockPub.On("PublishOrderCreated", mock.Anything, order).Return(nil)
+	mockRepo.On("MarkStockPublished", mock.Anything, "order123", "PENDING").Return(nil)
+	mockIdem.On("Complete", mock.Anything, "user123:key-1", "order123").Return(nil)
+
+	id, replayed, err := uc.CreateOrderIdempotent(context.Background(), "key-1", order)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "order123", id)
+	assert.False(t, replayed)
+	assert.Equal(t, "user123:key-1", order.IdempotencyKey)
+	mockRepo.AssertExpectations(t)
+	mockIdem.AssertExpectations(t)
+}
+
+func TestCreateOrderIdempotent_Replay(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	mockPub := new(MockPublisher)
+	mockIdem := new(MockIdempotencyRepo)
+	uc := usecase.NewOrderUsecase(mockRepo, mockPub, mockIdem, cache.NewLRU(100), nil, nil, nil, nil)
+
+	// capture the hash of the first request
+	var hash string
+	mockIdem.On("Reserve", mock.Anything, "user123:key-1", mock.Anything).Run(func(args mock.Arguments) {
+		hash = args.String(2)
+	}).Return(&model.IdempotencyRecord{}, true, nil).Once()
+	mockRepo.On("FindByIdempotencyKey", mock.Anything, "user123:key-1").Return(nil, repository.ErrOrderNotFound).Once()
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return("order123", nil).Once()
+	mockPub.On("PublishOrderCreated", mock.Anything, mock.Anything).Return(nil).Once()
+	mockRepo.On("MarkStockPublished", mock.Anything, "order123", "PENDING").Return(nil)
+	mockIdem.On("Complete", mock.Anything, "user123:key-1", "order123").Return(nil)
+
+	_, _, err := uc.CreateOrderIdempotent(context.Background(), "key-1", getSampleOrder())
+	assert.NoError(t, err)
+
+	mockIdem.On("Reserve", mock.Anything, "user123:key-1", hash).Return(&model.IdempotencyRecord{
+		RequestHash: hash,
+		Status:      model.IdempotencyCompleted,
+		OrderID:     "order123",
+	}, false, nil).Once()
+
+	id, replayed, err := uc.CreateOrderIdempotent(context.Background(), "key-1", getSampleOrder())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "order123", id)
+	assert.True(t, replayed)
+	mockRepo.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestCreateOrderIdempotent_DifferentPayload(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	mockIdem := new(MockIdempotencyRepo)
+	uc := usecase.NewOrderUsecase(mockRepo, nil, mockIdem, cache.NewLRU(100), nil, nil, nil, nil)
+
+	mockIdem.On("Reserve", mock.Anything, "user123:key-1", mock.Anything).Return(&model.IdempotencyRecord{
+		RequestHash: "other",
+		Status:      model.IdempotencyCompleted,
+		OrderID:     "order123",
+	}, false, nil)
+
+	_, _, err := uc.CreateOrderIdempotent(context.Background(), "key-1", getSampleOrder())
+
+	assert.ErrorIs(t, err, usecase.ErrIdempotencyKeyReused)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestCreateOrderIdempotent_ReleasesKeyOnFailure(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	mockIdem := new(MockIdempotencyRepo)
+	uc := usecase.NewOrderUsecase(mockRepo, nil, mockIdem, cache.NewLRU(100), nil, nil, nil, nil)
+
+	order := getSampleOrder()
+	mockIdem.On("Reserve", mock.Anything, "user123:key-1", mock.Anything).Return(&model.IdempotencyRecord{}, true, nil)
+	mockRepo.On("FindByIdempotencyKey", mock.Anything, "user123:key-1").Return(nil, repository.ErrOrderNotFound)
+	mockRepo.On("Create", mock.Anything, order).Return("", errors.New("db down"))
+	mockIdem.On("Release", mock.Anything, "user123:key-1").Return(nil)
+
+	_, _, err := uc.CreateOrderIdempotent(context.Background(), "key-1", order)
+
+	assert.Error(t, err)
+	mockIdem.AssertExpectations(t)
+}
+
+func TestCreateOrderIdempotent_TakeoverFindsOrder(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	mockIdem := new(MockIdempotencyRepo)
+	uc := usecase.NewOrderUsecase(mockRepo, nil, mockIdem, cache.NewLRU(100), nil, nil, nil, nil)
+
+	// The first request created the order but failed to complete the key,
+	// so its reservation was taken over once the lock timed out.
+	mockIdem.On("Reserve", mock.Anything, "user123:key-1", mock.Anything).Return(&model.IdempotencyRecord{}, true, nil)
+	mockRepo.On("FindByIdempotencyKey", mock.Anything, "user123:key-1").Return(&model.Order{ID: "order123"}, nil)
+	mockIdem.On("Complete", mock.Anything, "user123:key-1", "order123").Return(nil)
+
+	id, replayed, err := uc.CreateOrderIdempotent(context.Background(), "key-1", getSampleOrder())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "order123", id)
+	assert.True(t, replayed)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	mockIdem.AssertExpectations(t)
+}
+
+func TestCreateOrderIdempotent_DuplicateOrder(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	mockIdem := new(MockIdempotencyRepo)
+	uc := usecase.NewOrderUsecase(mockRepo, nil, mockIdem, cache.NewLRU(100), nil, nil, nil, nil)
+
+	// A request racing this one stored the order first.
+	mockIdem.On("Reserve", mock.Anything, "user123:key-1", mock.Anything).Return(&model.IdempotencyRecord{}, true, nil)
+	mockRepo.On("FindByIdempotencyKey", mock.Anything, "user123:key-1").Return(nil, repository.ErrOrderNotFound).Once()
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return("", repository.ErrDuplicateOrder)
+	mockRepo.On("FindByIdempotencyKey", mock.Anything, "user123:key-1").Return(&model.Order{ID: "order123"}, nil).Once()
+	mockIdem.On("Complete", mock.Anything, "user123:key-1", "order123").Return(nil)
+
+	id, replayed, err := uc.CreateOrderIdempotent(context.Background(), "key-1", getSampleOrder())
+
+	assert.NoError(t, err)
+	assert.Equal(t, "order123", id)
+	assert.True(t, replayed)
+	mockIdem.AssertNotCalled(t, "Release", mock.Anything, mock.Anything)
+}
+
+func TestCreateOrder_RejectsDeletedProduct(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	mockCatalog := new(MockCatalog)
+	uc := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), mockCatalog, nil, nil, nil)
+
+	order := getSampleOrder()
+	mockCatalog.On("GetProduct", mock.Anything, "507f1f77bcf86cd799439011").
+		Return(&model.CatalogProduct{ID: "507f1f77bcf86cd799439011", Deleted: true}, nil)
+
+	_, err := uc.CreateOrder(context.Background(), order)
+	assert.EqualError(t, err, "invalid order: product 507f1f77bcf86cd799439011 is no longer available")
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestCreateOrder_RejectsUnknownProduct(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	mockCatalog := new(MockCatalog)
+	uc := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), mockCatalog, nil, nil, nil)
+
+	mockCatalog.On("GetProduct", mock.Anything, mock.Anything).Return(nil, inventory.ErrProductNotFound)
+
+	_, err := uc.CreateOrder(context.Background(), getSampleOrder())
+	assert.EqualError(t, err, "invalid order: product 507f1f77bcf86cd799439011 not found")
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestCreateOrder_CatalogUnavailable(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	mockCatalog := new(MockCatalog)
+	uc := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), mockCatalog, nil, nil, nil)
+
+	mockCatalog.On("GetProduct", mock.Anything, mock.Anything).Return(nil, errors.New("connection refused"))
+
+	_, err := uc.CreateOrder(context.Background(), getSampleOrder())
+	assert.ErrorIs(t, err, usecase.ErrCatalogUnavailable)
+}
+
+func TestCreateOrder_ResolvesVariant(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	mockCatalog := new(MockCatalog)
+	uc := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), mockCatalog, nil, nil, nil)
+	ctx := context.Background()
+
+	single := &model.CatalogProduct{ID: "p1", Variants: []model.CatalogVariant{{SKU: "p1"}}}
+	several := &model.CatalogProduct{ID: "p2", Variants: []model.CatalogVariant{{SKU: "TEE-S"}, {SKU: "TEE-M"}}}
+	mockCatalog.On("GetProduct", mock.Anything, "p1").Return(single, nil)
+	mockCatalog.On("GetProduct", mock.Anything, "p2").Return(several, nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return("order1", nil)
+
+	// A single-variant product is ordered without a SKU and recorded with it.
+	order := &model.Order{UserID: "user123", Products: []model.Product{{ProductID: "p1", Quantity: 1}}}
+	_, err := uc.CreateOrder(ctx, order)
+	assert.NoError(t, err)
+	assert.Equal(t, "p1", order.Products[0].SKU)
+
+	_, err = uc.CreateOrder(ctx, &model.Order{UserID: "user123", Products: []model.Product{{ProductID: "p2", Quantity: 1}}})
+	assert.EqualError(t, err, "invalid order: sku is required for product p2, which has several variants")
+
+	_, err = uc.CreateOrder(ctx, &model.Order{UserID: "user123", Products: []model.Product{{ProductID: "p2", SKU: "TEE-XL", Quantity: 1}}})
+	assert.EqualError(t, err, "invalid order: variant TEE-XL of product p2 not found")
+
+	_, err = uc.CreateOrder(ctx, &model.Order{UserID: "user123", Products: []model.Product{{ProductID: "p2", SKU: "TEE-M", Quantity: 1}}})
+	assert.NoError(t, err)
+}
+
+func TestCreateOrder_ComputesTotal(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	mockCatalog := new(MockCatalog)
+	uc := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), mockCatalog, nil, nil, nil)
+	ctx := context.Background()
+
+	tee := &model.CatalogProduct{ID: "p1", Variants: []model.CatalogVariant{
+		{SKU: "TEE-S", Price: money.New(1999, "USD")},
+		{SKU: "TEE-XL", Price: money.New(2199, "USD")},
+	}}
+	mug := &model.CatalogProduct{ID: "p2", Variants: []model.CatalogVariant{{SKU: "p2", Price: money.New(10, "USD")}}}
+	mockCatalog.On("GetProduct", mock.Anything, "p1").Return(tee, nil)
+	mockCatalog.On("GetProduct", mock.Anything, "p2").Return(mug, nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return("order1", nil)
+
+	items := func() []model.Product {
+		return []model.Product{
+			{ProductID: "p1", SKU: "TEE-S", Quantity: 2},
+			{ProductID: "p1", SKU: "TEE-XL", Quantity: 1},
+			{ProductID: "p2", Quantity: 3},
+		}
+	}
+
+	// Ten-cent lines add up exactly, unlike 0.1 + 0.2 in floats.
+	order := &model.Order{UserID: "user123", Products: items()}
+	_, err := uc.CreateOrder(ctx, order)
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(6227, "USD"), order.Total)
+	assert.Equal(t, money.New(2199, "USD"), order.Products[1].UnitPrice)
+
+	// The total the client expects is checked, not trusted.
+	_, err = uc.CreateOrder(ctx, &model.Order{UserID: "user123", Products: items(), Total: money.New(6227, "USD")})
+	assert.NoError(t, err)
+	_, err = uc.CreateOrder(ctx, &model.Order{UserID: "user123", Products: items(), Total: money.New(100, "USD")})
+	assert.ErrorIs(t, err, usecase.ErrTotalMismatch)
+	_, err = uc.CreateOrder(ctx, &model.Order{UserID: "user123", Products: items(), Total: money.New(6227, "EUR")})
+	assert.ErrorIs(t, err, usecase.ErrTotalMismatch)
+	mockRepo.AssertNumberOfCalls(t, "Create", 2)
+}
+
+// newCartUsecase keeps both guests' and users' carts in memory.
+func newCartUsecase(catalog *MockCatalog, orders *usecase.OrderUsecase) *usecase.CartUsecase {
+	return usecase.NewCartUsecase(
+		repository.NewGuestCartRepository(cache.NewLRU(100), time.Hour),
+		repository.NewGuestCartRepository(cache.NewLRU(100), time.Hour),
+		catalog,
+		orders,
+	)
+}
+
+func cartCatalog() (*MockCatalog, *model.CatalogProduct, *model.CatalogProduct) {
+	tee := &model.CatalogProduct{ID: "p1", Name: "Tee", Variants: []model.CatalogVariant{
+		{SKU: "TEE-S", Price: money.New(1999, "USD"), Stock: 5},
+		{SKU: "TEE-M", Price: money.New(1999, "USD"), Stock: 0},
+	}}
+	mug := &model.CatalogProduct{ID: "p2", Name: "Mug", Variants: []model.CatalogVariant{{SKU: "p2", Price: money.New(850, "USD"), Stock: 10}}}
+	catalog := new(MockCatalog)
+	catalog.On("GetProduct", mock.Anything, "p1").Return(tee, nil)
+	catalog.On("GetProduct", mock.Anything, "p2").Return(mug, nil)
+	catalog.On("GetProduct", mock.Anything, mock.Anything).Return(nil, inventory.ErrProductNotFound)
+	return catalog, tee, mug
+}
+
+func TestCart_AddItemChecksStock(t *testing.T) {
+	catalog, _, _ := cartCatalog()
+	uc := newCartUsecase(catalog, nil)
+	ctx := context.Background()
+	guest := model.CartOwner{GuestID: "g1"}
+
+	_, err := uc.AddItem(ctx, guest, model.CartItem{ProductID: "p1", SKU: "TEE-S", Quantity: 3})
+	assert.NoError(t, err)
+	// Quantities add up and the sum is checked against stock.
+	_, err = uc.AddItem(ctx, guest, model.CartItem{ProductID: "p1", SKU: "TEE-S", Quantity: 3})
+	assert.ErrorIs(t, err, usecase.ErrInsufficientStock)
+	_, err = uc.AddItem(ctx, guest, model.CartItem{ProductID: "p1", SKU: "TEE-M", Quantity: 1})
+	assert.ErrorIs(t, err, usecase.ErrInsufficientStock)
+	_, err = uc.AddItem(ctx, guest, model.CartItem{ProductID: "p1", Quantity: 1})
+	assert.EqualError(t, err, "sku is required for product p1, which has several variants")
+	_, err = uc.AddItem(ctx, guest, model.CartItem{ProductID: "p9", Quantity: 1})
+	assert.EqualError(t, err, "product p9 not found")
+
+	// A single-variant product is added without a SKU.
+	cart, err := uc.AddItem(ctx, guest, model.CartItem{ProductID: "p2", Quantity: 2})
+	assert.NoError(t, err)
+	assert.Len(t, cart.Lines, 2)
+	assert.Equal(t, 3, cart.Lines[0].Quantity)
+	assert.Equal(t, "p2", cart.Lines[1].SKU)
+	assert.Equal(t, money.New(7697, "USD"), cart.Total)
+
+	cart, err = uc.UpdateItem(ctx, guest, model.CartItem{ProductID: "p1", Quantity: 0})
+	assert.NoError(t, err)
+	assert.Len(t, cart.Lines, 1)
+	assert.Equal(t, money.New(1700, "USD"), cart.Total)
+
+	_, err = uc.GetCart(ctx, model.CartOwner{})
+	assert.Error(t, err)
+}
+
+func TestCart_PricesItemsLive(t *testing.T) {
+	catalog, tee, mug := cartCatalog()
+	uc := newCartUsecase(catalog, nil)
+	ctx := context.Background()
+	owner := model.CartOwner{UserID: "user123"}
+
+	_, err := uc.AddItem(ctx, owner, model.CartItem{ProductID: "p1", SKU: "TEE-S", Quantity: 2})
+	assert.NoError(t, err)
+	_, err = uc.AddItem(ctx, owner, model.CartItem{ProductID: "p2", Quantity: 1})
+	assert.NoError(t, err)
+
+	tee.Variants[0].Price = money.New(1499, "USD")
+	tee.Variants[0].Stock = 1
+	mug.Deleted = true
+
+	cart, err := uc.GetCart(ctx, owner)
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(1499, "USD"), cart.Lines[0].UnitPrice)
+	assert.Equal(t, "only 1 left", cart.Lines[0].Problem)
+	assert.Equal(t, "no longer available", cart.Lines[1].Problem)
+	assert.True(t, cart.Total.IsZero())
+}
+
+func TestCart_MergeOnLogin(t *testing.T) {
+	catalog, _, _ := cartCatalog()
+	uc := newCartUsecase(catalog, nil)
+	ctx := context.Background()
+	guest := model.CartOwner{GuestID: "g1"}
+	user := model.CartOwner{UserID: "user123"}
+
+	_, err := uc.AddItem(ctx, guest, model.CartItem{ProductID: "p1", SKU: "TEE-S", Quantity: 1})
+	assert.NoError(t, err)
+	_, err = uc.AddItem(ctx, guest, model.CartItem{ProductID: "p2", Quantity: 1})
+	assert.NoError(t, err)
+	_, err = uc.AddItem(ctx, user, model.CartItem{ProductID: "p1", SKU: "TEE-S", Quantity: 2})
+	assert.NoError(t, err)
+
+	cart, err := uc.MergeCart(ctx, "g1", "user123")
+	assert.NoError(t, err)
+	assert.Len(t, cart.Lines, 2)
+	assert.Equal(t, 3, cart.Lines[0].Quantity)
+
+	left, err := uc.GetCart(ctx, guest)
+	assert.NoError(t, err)
+	assert.Empty(t, left.Lines)
+}
+
+func TestCart_Checkout(t *testing.T) {
+	catalog, tee, _ := cartCatalog()
+	mockRepo := new(MockOrderRepo)
+	orders := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), catalog, nil, nil, nil)
+	uc := newCartUsecase(catalog, orders)
+	ctx := context.Background()
+	owner := model.CartOwner{UserID: "user123"}
+
+	_, _, err := uc.Checkout(ctx, "", &model.Order{UserID: "user123"})
+	assert.ErrorIs(t, err, usecase.ErrCartEmpty)
+
+	_, err = uc.AddItem(ctx, owner, model.CartItem{ProductID: "p1", SKU: "TEE-S", Quantity: 2})
+	assert.NoError(t, err)
+
+	tee.Variants[0].Stock = 1
+	_, _, err = uc.Checkout(ctx, "", &model.Order{UserID: "user123"})
+	assert.ErrorIs(t, err, usecase.ErrCartNotReady)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+
+	tee.Variants[0].Stock = 5
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return("order1", nil)
+	_, _, err = uc.Checkout(ctx, "", &model.Order{UserID: "user123", Total: money.New(100, "USD")})
+	assert.ErrorIs(t, err, usecase.ErrTotalMismatch)
+
+	order, replayed, err := uc.Checkout(ctx, "", &model.Order{UserID: "user123", Total: money.New(3998, "USD")})
+	assert.NoError(t, err)
+	assert.False(t, replayed)
+	assert.Equal(t, "order1", order.ID)
+	assert.Equal(t, []model.Product{{ProductID: "p1", SKU: "TEE-S", Quantity: 2, UnitPrice: money.New(1999, "USD")}}, order.Products)
+
+	cart, err := uc.GetCart(ctx, owner)
+	assert.NoError(t, err)
+	assert.Empty(t, cart.Lines)
+}
+
+func TestCart_ConcurrentChangesAreNotLost(t *testing.T) {
+	catalog, _, _ := cartCatalog()
+	uc := newCartUsecase(catalog, nil)
+	ctx := context.Background()
+	owner := model.CartOwner{UserID: "user123"}
+
+	var wg sync.WaitGroup
+	var added atomic.Int32
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := uc.AddItem(ctx, owner, model.CartItem{ProductID: "p2", Quantity: 1})
+			if err == nil {
+				added.Add(1)
+			} else {
+				assert.ErrorIs(t, err, usecase.ErrCartBusy)
+			}
+		}()
+	}
+	wg.Wait()
+
+	cart, err := uc.GetCart(ctx, owner)
+	assert.NoError(t, err)
+	assert.NotZero(t, added.Load())
+	assert.Equal(t, int(added.Load()), cart.Lines[0].Quantity)
+}
+
+func TestCart_CheckoutKeepsItemsAddedMeanwhile(t *testing.T) {
+	catalog, _, _ := cartCatalog()
+	mockRepo := new(MockOrderRepo)
+	orders := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), catalog, nil, nil, nil)
+	uc := newCartUsecase(catalog, orders)
+	ctx := context.Background()
+	owner := model.CartOwner{UserID: "user123"}
+
+	_, err := uc.AddItem(ctx, owner, model.CartItem{ProductID: "p1", SKU: "TEE-S", Quantity: 2})
+	assert.NoError(t, err)
+	// While the order is being created, the shopper adds a mug and another
+	// tee in a second tab.
+	mockRepo.On("Create", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		_, err := uc.AddItem(ctx, owner, model.CartItem{ProductID: "p2", Quantity: 1})
+		assert.NoError(t, err)
+		_, err = uc.AddItem(ctx, owner, model.CartItem{ProductID: "p1", SKU: "TEE-S", Quantity: 1})
+		assert.NoError(t, err)
+	}).Return("order1", nil)
+
+	order, _, err := uc.Checkout(ctx, "", &model.Order{UserID: "user123"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, order.Products[0].Quantity)
+
+	cart, err := uc.GetCart(ctx, owner)
+	assert.NoError(t, err)
+	assert.Len(t, cart.Lines, 2)
+	assert.Equal(t, model.CartItem{ProductID: "p1", SKU: "TEE-S", Quantity: 1}, cart.Lines[0].CartItem)
+	assert.Equal(t, model.CartItem{ProductID: "p2", SKU: "p2", Quantity: 1}, cart.Lines[1].CartItem)
+}
+
+func TestCreatePromotion_Validates(t *testing.T) {
+	mockRepo := new(MockPromotionRepo)
+	uc := usecase.NewPromotionUsecase(mockRepo, nil, "USD")
+	ctx := context.Background()
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return("promo1", nil)
+
+	p, err := uc.CreatePromotion(ctx, &model.Promotion{
+		Code: " summer-10 ", Description: "Summer sale", Type: model.PromotionPercentage, PercentOff: 10, Active: true,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "promo1", p.ID)
+	assert.Equal(t, "SUMMER-10", p.Code)
+
+	p, err = uc.CreatePromotion(ctx, &model.Promotion{
+		Description: "$5 off", Type: model.PromotionFixed, AmountOff: money.New(500, ""),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(500, "USD"), p.AmountOff)
+
+	now := time.Now()
+	for _, tc := range []struct {
+		promotion model.Promotion
+		err       string
+	}{
+		{model.Promotion{Code: "S!", Description: "x", Type: model.PromotionPercentage, PercentOff: 10}, "code must be 3 to 32 letters, digits, '-' or '_'"},
+		{model.Promotion{Description: "x", Type: model.PromotionPercentage, PercentOff: 101}, "percent_off must be between 1 and 100"},
+		{model.Promotion{Description: "x", Type: model.PromotionFixed}, "amount_off must be positive for FIXED promotions"},
+		{model.Promotion{Description: "x", Type: model.PromotionFixed, AmountOff: money.New(500, "EUR")}, "amount_off must be in USD, got EUR"},
+		{model.Promotion{Description: "x", Type: "BOGO"}, "type must be PERCENTAGE or FIXED"},
+		{model.Promotion{Description: "x", Type: model.PromotionPercentage, PercentOff: 10, StartsAt: now, EndsAt: now.Add(-time.Hour)}, "ends_at must be after starts_at"},
+		{model.Promotion{Description: "x", Type: model.PromotionPercentage, PercentOff: 10, MaxUses: -1}, "usage limits cannot be negative"},
+	} {
+		_, err := uc.CreatePromotion(ctx, &tc.promotion)
+		assert.EqualError(t, err, tc.err)
+	}
+	mockRepo.AssertNumberOfCalls(t, "Create", 2)
+}
+
+// promotionCatalog sells a 250 g tee in shirts, under apparel, and a 400 g
+// mug in kitchen.
+func promotionCatalog() *MockCatalog {
+	catalog := new(MockCatalog)
+	catalog.On("GetProduct", mock.Anything, "p1").Return(&model.CatalogProduct{ID: "p1", CategoryID: "shirts",
+		Variants: []model.CatalogVariant{{SKU: "TEE-S", Price: money.New(1999, "USD"), WeightGrams: 250}}}, nil)
+	catalog.On("GetProduct", mock.Anything, "p2").Return(&model.CatalogProduct{ID: "p2", CategoryID: "kitchen",
+		Variants: []model.CatalogVariant{{SKU: "p2", Price: money.New(850, "USD"), WeightGrams: 400}}}, nil)
+	catalog.On("CategoryPath", mock.Anything, "shirts").Return([]string{"apparel", "shirts"}, nil)
+	catalog.On("CategoryPath", mock.Anything, "kitchen").Return([]string{"home", "kitchen"}, nil)
+	return catalog
+}
+
+func promotionOrder(code string) *model.Order {
+	return &model.Order{UserID: "user123", PromoCode: code, Products: []model.Product{
+		{ProductID: "p1", SKU: "TEE-S", Quantity: 2},
+		{ProductID: "p2", Quantity: 1},
+	}}
+}
+
+func TestCreateOrder_AppliesPromotions(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	promoRepo := new(MockPromotionRepo)
+	catalog := promotionCatalog()
+	promotions := usecase.NewPromotionUsecase(promoRepo, catalog, "USD")
+	uc := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), catalog, promotions, nil, nil)
+	ctx := context.Background()
+
+	apparel := &model.Promotion{ID: "auto1", Description: "10% off apparel", Type: model.PromotionPercentage,
+		PercentOff: 10, CategoryIDs: []string{"apparel"}, Active: true}
+	save5 := &model.Promotion{ID: "promo1", Code: "SAVE5", Description: "$5 off $40", Type: model.PromotionFixed,
+		AmountOff: money.New(500, "USD"), MinSubtotal: money.New(4000, "USD"), MaxUsesPerUser: 1, Active: true}
+	promoRepo.On("ListAutomatic", mock.Anything).Return([]*model.Promotion{apparel}, nil)
+	promoRepo.On("FindByCode", mock.Anything, "SAVE5").Return(save5, nil)
+	promoRepo.On("UsesBy", mock.Anything, save5, "user123").Return(int64(0), nil)
+	promoRepo.On("Redeem", mock.Anything, mock.Anything, "user123").Return(nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return("order1", nil)
+
+	// Percentages come off the lines they cover, then fixed amounts off the
+	// order.
+	order := promotionOrder("save5")
+	_, err := uc.CreateOrder(ctx, order)
+	assert.NoError(t, err)
+	assert.Equal(t, "SAVE5", order.PromoCode)
+	assert.Equal(t, money.New(4848, "USD"), order.Subtotal)
+	assert.Equal(t, []model.Adjustment{
+		{PromotionID: "auto1", Description: "10% off apparel", ProductID: "p1", SKU: "TEE-S", Amount: money.New(-399, "USD")},
+		{PromotionID: "promo1", Code: "SAVE5", Description: "$5 off $40", Amount: money.New(-500, "USD")},
+	}, order.Adjustments)
+	assert.Equal(t, money.New(3949, "USD"), order.Total)
+	promoRepo.AssertNumberOfCalls(t, "Redeem", 2)
+
+	// The expected total is checked against the discounted one.
+	order = promotionOrder("SAVE5")
+	order.Total = money.New(4848, "USD")
+	_, err = uc.CreateOrder(ctx, order)
+	assert.ErrorIs(t, err, usecase.ErrTotalMismatch)
+}
+
+func TestCreateOrder_RejectsInapplicableCode(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	promoRepo := new(MockPromotionRepo)
+	catalog := promotionCatalog()
+	promotions := usecase.NewPromotionUsecase(promoRepo, catalog, "USD")
+	uc := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), catalog, promotions, nil, nil)
+	ctx := context.Background()
+
+	expired := &model.Promotion{ID: "promo1", Code: "OLD", Description: "Old", Type: model.PromotionPercentage,
+		PercentOff: 10, EndsAt: time.Now().Add(-time.Hour), Active: true}
+	big := &model.Promotion{ID: "promo2", Code: "BIG", Description: "Big spender", Type: model.PromotionPercentage,
+		PercentOff: 10, MinSubtotal: money.New(10000, "USD"), Active: true}
+	shirts := &model.Promotion{ID: "promo3", Code: "SHIRTS", Description: "Shirts", Type: model.PromotionPercentage,
+		PercentOff: 10, CategoryIDs: []string{"shirts"}, Active: true}
+	once := &model.Promotion{ID: "promo4", Code: "ONCE", Description: "Once", Type: model.PromotionFixed,
+		AmountOff: money.New(100, "USD"), MaxUsesPerUser: 1, Active: true}
+	promoRepo.On("ListAutomatic", mock.Anything).Return(nil, nil)
+	promoRepo.On("FindByCode", mock.Anything, "NOPE").Return(nil, errors.New("promotion not found"))
+	promoRepo.On("FindByCode", mock.Anything, "OLD").Return(expired, nil)
+	promoRepo.On("FindByCode", mock.Anything, "BIG").Return(big, nil)
+	promoRepo.On("FindByCode", mock.Anything, "SHIRTS").Return(shirts, nil)
+	promoRepo.On("FindByCode", mock.Anything, "ONCE").Return(once, nil)
+	promoRepo.On("UsesBy", mock.Anything, once, "user123").Return(int64(1), nil)
+
+	for code, msg := range map[string]string{
+		"nope": "promotion cannot be applied: NOPE is not a valid code",
+		"OLD":  "promotion cannot be applied: code OLD is not valid at this time",
+		"BIG":  "promotion cannot be applied: code BIG needs an order of at least 100.00 USD",
+		"ONCE": "promotion cannot be applied: code ONCE was already used",
+	} {
+		_, err := uc.CreateOrder(ctx, promotionOrder(code))
+		assert.EqualError(t, err, msg)
+	}
+
+	// A code for items not in the order is rejected too.
+	order := promotionOrder("SHIRTS")
+	order.Products = order.Products[1:]
+	_, err := uc.CreateOrder(ctx, order)
+	assert.EqualError(t, err, "promotion cannot be applied: code SHIRTS does not apply to any item in the order")
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestCreateOrder_PromotionUsedUp(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	promoRepo := new(MockPromotionRepo)
+	catalog := promotionCatalog()
+	promotions := usecase.NewPromotionUsecase(promoRepo, catalog, "USD")
+	uc := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), catalog, promotions, nil, nil)
+	ctx := context.Background()
+
+	auto := &model.Promotion{ID: "auto1", Description: "5% off", Type: model.PromotionPercentage, PercentOff: 5, Active: true}
+	mug := &model.Promotion{ID: "promo1", Code: "MUG", Description: "Free mug", Type: model.PromotionFixed,
+		AmountOff: money.New(10000, "USD"), ProductIDs: []string{"p2"}, MaxUses: 100, Active: true}
+	promoRepo.On("ListAutomatic", mock.Anything).Return([]*model.Promotion{auto}, nil)
+	promoRepo.On("FindByCode", mock.Anything, "MUG").Return(mug, nil)
+	promoRepo.On("Redeem", mock.Anything, auto, "user123").Return(nil)
+	promoRepo.On("Redeem", mock.Anything, mug, "user123").Return(repository.ErrPromotionUsedUp)
+	promoRepo.On("Unredeem", mock.Anything, auto, "user123").Return(nil)
+
+	// A fixed discount never takes the items it covers below zero: 5% then
+	// the rest of the mug's 850.
+	order := promotionOrder("MUG")
+	_, err := uc.CreateOrder(ctx, order)
+	assert.EqualError(t, err, "promotion cannot be applied: Free mug has been used up")
+	assert.Equal(t, money.New(-808, "USD"), order.Adjustments[2].Amount)
+
+	// The use counted for the automatic promotion is taken back.
+	promoRepo.AssertCalled(t, "Unredeem", mock.Anything, auto, "user123")
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+const pricingRules = `{
+  "default_region": "US",
+  "tax": {
+    "US-CA": {"rate": "7.25", "categories": {"kitchen": "0"}},
+    "US":    {"rate": "5"}
+  },
+  "shipping": {
+    "default": {"method": "weight", "amount": "4.99", "per_kg": "1.50", "free_over": "100.00"},
+    "regions": {"DE": {"method": "flat", "amount": "9.90"}}
+  }
+}`
+
+func TestParsePricingRules(t *testing.T) {
+	rules, err := pricing.ParseRules([]byte(pricingRules), "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(725), rules.Tax["US-CA"].Rate)
+	assert.Equal(t, money.New(990, "USD"), rules.Shipping["DE"].Amount)
+
+	for rules, msg := range map[string]string{
+		`{"tax": {"US": {"rate": "7.255"}}}`:                               `tax US: rate "7.255" has more than two decimals`,
+		`{"tax": {"US": {"rate": "-1"}}}`:                                  `tax US: rate "-1" is not a percentage`,
+		`{"tax": {"usa": {"rate": "5"}}}`:                                  `tax "usa": region must be an ISO 3166 code such as US or US-CA`,
+		`{"shipping": {"default": {"method": "air"}}}`:                     "shipping default: method must be flat or weight",
+		`{"shipping": {"default": {"method": "flat", "amount": "4.999"}}}`: "shipping default: amount: money: 4.999 has more than 2 decimals for USD",
+	} {
+		_, err := pricing.ParseRules([]byte(rules), "USD")
+		assert.EqualError(t, err, msg)
+	}
+	_, err = pricing.ParseRules([]byte(`{"taxes": {}}`), "USD")
+	assert.Error(t, err)
+}
+
+func TestCreateOrder_TaxAndShipping(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	catalog := promotionCatalog()
+	rules, err := pricing.ParseRules([]byte(pricingRules), "USD")
+	assert.NoError(t, err)
+	uc := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), catalog, nil, pricing.NewCalculator(rules, catalog), nil)
+	ctx := context.Background()
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return("order1", nil)
+
+	// Two tees and a mug: 48.48 and 900 g, which ship as 1 kg for 6.49.
+	for _, tc := range []struct {
+		region    string
+		tax, ship int64
+	}{
+		// Kitchen items are not taxed in California.
+		{"us-ca", 290, 649},
+		// Regions without rules of their own fall back to their country's.
+		{"US-NY", 200 + 43, 649},
+		{"", 243, 649},
+		{"DE", 0, 990},
+	} {
+		order := promotionOrder("")
+		order.Region = tc.region
+		_, err := uc.CreateOrder(ctx, order)
+		assert.NoError(t, err, tc.region)
+		assert.Equal(t, money.New(tc.tax, "USD"), order.Tax, tc.region)
+		assert.Equal(t, money.New(tc.ship, "USD"), order.Shipping, tc.region)
+		assert.Equal(t, money.New(4848+tc.tax+tc.ship, "USD"), order.Total, tc.region)
+	}
+
+	// Orders of 100.00 or more ship free.
+	order := promotionOrder("")
+	order.Products[0].Quantity = 5
+	_, err = uc.CreateOrder(ctx, order)
+	assert.NoError(t, err)
+	assert.Equal(t, "US", order.Region)
+	assert.True(t, order.Shipping.IsZero())
+
+	order = promotionOrder("")
+	order.Region = "USA"
+	_, err = uc.CreateOrder(ctx, order)
+	assert.ErrorIs(t, err, pricing.ErrInvalidRegion)
+}
+
+func TestCreateOrder_TaxAfterDiscounts(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	promoRepo := new(MockPromotionRepo)
+	catalog := promotionCatalog()
+	rules, err := pricing.ParseRules([]byte(pricingRules), "USD")
+	assert.NoError(t, err)
+	promotions := usecase.NewPromotionUsecase(promoRepo, catalog, "USD")
+	uc := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), catalog, promotions, pricing.NewCalculator(rules, catalog), nil)
+	ctx := context.Background()
+
+	save5 := &model.Promotion{ID: "promo1", Code: "SAVE5", Description: "$5 off", Type: model.PromotionFixed,
+		AmountOff: money.New(500, "USD"), Active: true}
+	promoRepo.On("ListAutomatic", mock.Anything).Return(nil, nil)
+	promoRepo.On("FindByCode", mock.Anything, "SAVE5").Return(save5, nil)
+	promoRepo.On("Redeem", mock.Anything, save5, "user123").Return(nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return("order1", nil)
+
+	// The 5.00 off the order is spread over the tees (4.12) and the mug
+	// (0.88); only the tees are taxed: 7.25% of 35.86.
+	order := promotionOrder("SAVE5")
+	order.Region = "US-CA"
+	_, err = uc.CreateOrder(ctx, order)
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(260, "USD"), order.Tax)
+	assert.Equal(t, money.New(4848-500+260+649, "USD"), order.Total)
+}
+
+func savedAddress() address.Address {
+	return address.Address{Name: "Alice", Line1: "1 Main St", City: "Los Angeles", Region: "CA", PostalCode: "90001", Country: "US"}
+}
+
+func TestCreateOrder_SnapshotsSavedAddress(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	book := new(MockAddressBook)
+	catalog := promotionCatalog()
+	rules, err := pricing.ParseRules([]byte(pricingRules), "USD")
+	assert.NoError(t, err)
+	uc := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), catalog, nil, pricing.NewCalculator(rules, catalog), book)
+	ctx := context.Background()
+
+	home := savedAddress()
+	book.On("GetAddress", mock.Anything, "user123", "addr1").Return(home, nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return("order1", nil)
+
+	order := promotionOrder("")
+	order.AddressID = "addr1"
+	_, err = uc.CreateOrder(ctx, order)
+	assert.NoError(t, err)
+	assert.Equal(t, &home, order.ShippingAddress)
+	// Taxed as shipped to California.
+	assert.Equal(t, "US-CA", order.Region)
+	assert.Equal(t, money.New(290, "USD"), order.Tax)
+
+	// The order keeps its own copy of the address.
+	home.Line1 = "2 Other St"
+	assert.Equal(t, "1 Main St", order.ShippingAddress.Line1)
+}
+
+func TestCreateOrder_ShippingAddressErrors(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	book := new(MockAddressBook)
+	uc := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), nil, nil, nil, book)
+	ctx := context.Background()
+
+	book.On("GetAddress", mock.Anything, "user123", "other").Return(address.Address{}, users.ErrAddressNotFound)
+	book.On("GetAddress", mock.Anything, "user123", "down").Return(address.Address{}, errors.New("connection refused"))
+
+	order := promotionOrder("")
+	order.AddressID = "other"
+	_, err := uc.CreateOrder(ctx, order)
+	assert.EqualError(t, err, "invalid order: address other not found")
+
+	order = promotionOrder("")
+	order.AddressID = "down"
+	_, err = uc.CreateOrder(ctx, order)
+	assert.ErrorIs(t, err, usecase.ErrAddressBookUnavailable)
+
+	inline := savedAddress()
+	order = promotionOrder("")
+	order.AddressID = "addr1"
+	order.ShippingAddress = &inline
+	_, err = uc.CreateOrder(ctx, order)
+	assert.Error(t, err)
+
+	inline.PostalCode = ""
+	order = promotionOrder("")
+	order.ShippingAddress = &inline
+	_, err = uc.CreateOrder(ctx, order)
+	assert.ErrorIs(t, err, address.ErrInvalid)
+	mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestCreateOrder_InlineAddressIsNormalized(t *testing.T) {
+	mockRepo := new(MockOrderRepo)
+	uc := usecase.NewOrderUsecase(mockRepo, nil, nil, cache.NewLRU(100), nil, nil, nil, nil)
+	mockRepo.On("Create", mock.Anything, mock.Anything).Return("order1", nil)
+
+	order := promotionOrder("")
+	order.ShippingAddress = &address.Address{Name: "Bob", Line1: "10 Downing St", City: "London", PostalCode: "sw1a 2aa", Country: "gb"}
+	_, err := uc.CreateOrder(context.Background(), order)
+	assert.NoError(t, err)
+	assert.Equal(t, "SW1A 2AA", order.ShippingAddress.PostalCode)
+	assert.Equal(t, "GB", order.Region)
+}
+
+// returnableOrder is a paid order of 3 units at 9.99 and 1 at 5.00, with
+// 10% tax: the first line cost 32.96 with its tax, the second 5.50.
+func returnableOrder() *model.Order {
+	return &model.Order{
+		ID:     "order1",
+		UserID: "user123",
+		Products: []model.Product{
+			{ProductID: "p1", Quantity: 3, UnitPrice: money.New(999, "USD")},
+			{ProductID: "p2", SKU: "p2-red", Quantity: 1, UnitPrice: money.New(500, "USD")},
+		},
+		Subtotal:  money.New(3497, "USD"),
+		Tax:       money.New(349, "USD"),
+		Total:     money.New(3846, "USD"),
+		PaymentID: "pay1",
+		Status:    "COMPLETED",
+	}
+}
+
+func TestLineTotals(t *testing.T) {
+	totals, err := pricing.LineTotals(returnableOrder())
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3296, 550}, totals)
+}
+
+func TestCreateReturn_RefundsUnits(t *testing.T) {
+	ctx := context.Background()
+	mockRepo, mockPub, pay := new(MockOrderRepo), new(MockPublisher), new(MockPayments)
+	uc := usecase.NewReturnUsecase(mockRepo, mockPub, pay, cache.NewLRU(100))
+
+	order := returnableOrder()
+	mockRepo.On("FindByID", mock.Anything, "order1").Return(order, nil)
+	mockRepo.On("AddReturn", mock.Anything, order, mock.Anything).Return(true, nil).Run(func(args mock.Arguments) {
+		args.Get(2).(*model.Return).ID = "ret1"
+	}).Once()
+	mockPub.On("PublishOrderReturned", mock.Anything, order, mock.Anything).Return(nil)
+	mockRepo.On("MarkReturnPublished", mock.Anything, "order1", "ret1").Return(nil).Once()
+	pay.On("Refund", mock.Anything, "pay1", money.New(1098, "USD"), "ret1", "damaged").Return(nil).Once()
+	mockRepo.On("SetRefundStatus", mock.Anything, "order1", "ret1", model.RefundDone).Return(nil).Once()
+
+	ret, err := uc.CreateReturn(ctx, "order1", "user123", []model.ReturnItem{{ProductID: "p1", Quantity: 1}}, "damaged")
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(1098, "USD"), ret.Refund)
+	assert.Equal(t, model.RefundDone, ret.RefundStatus)
+	assert.Equal(t, 0, ret.Items[0].Line)
+
+	// The last units of the line get what rounding left over.
+	order.Products[0].Returned = 1
+	mockRepo.On("AddReturn", mock.Anything, order, mock.Anything).Return(true, nil).Run(func(args mock.Arguments) {
+		args.Get(2).(*model.Return).ID = "ret2"
+	}).Once()
+	mockRepo.On("MarkReturnPublished", mock.Anything, "order1", "ret2").Return(nil).Once()
+	pay.On("Refund", mock.Anything, "pay1", money.New(2198+550, "USD"), "ret2", "order return").Return(nil).Once()
+	mockRepo.On("SetRefundStatus", mock.Anything, "order1", "ret2", model.RefundDone).Return(nil).Once()
+
+	ret, err = uc.CreateReturn(ctx, "order1", "user123", []model.ReturnItem{
+		{ProductID: "p1", Quantity: 2},
+		{ProductID: "p2", SKU: "p2-red", Quantity: 1},
+	}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(2748, "USD"), ret.Refund)
+	assert.Len(t, ret.Items, 2)
+	mockRepo.AssertExpectations(t)
+	pay.AssertExpectations(t)
+	mockPub.AssertNumberOfCalls(t, "PublishOrderReturned", 2)
+}
+
+func TestCreateReturn_Rejects(t *testing.T) {
+	ctx := context.Background()
+	mockRepo := new(MockOrderRepo)
+	uc := usecase.NewReturnUsecase(mockRepo, nil, new(MockPayments), cache.NewLRU(100))
+	one := []model.ReturnItem{{ProductID: "p1", Quantity: 1}}
+
+	pending := returnableOrder()
+	pending.ID = "pending"
+	pending.Status = "PENDING"
+	mockRepo.On("FindByID", mock.Anything, "pending").Return(pending, nil)
+	_, err := uc.CreateReturn(ctx, "pending", "user123", one, "")
+	assert.ErrorIs(t, err, usecase.ErrOrderNotReturnable)
+
+	unpaid := returnableOrder()
+	unpaid.ID = "unpaid"
+	unpaid.PaymentID = ""
+	mockRepo.On("FindByID", mock.Anything, "unpaid").Return(unpaid, nil)
+	_, err = uc.CreateReturn(ctx, "unpaid", "user123", one, "")
+	assert.ErrorIs(t, err, usecase.ErrOrderNotPaid)
+
+	returned := returnableOrder()
+	returned.Products[0].Returned = 2
+	mockRepo.On("FindByID", mock.Anything, "order1").Return(returned, nil)
+	_, err = uc.CreateReturn(ctx, "order1", "someone-else", one, "")
+	assert.ErrorIs(t, err, repository.ErrOrderNotFound)
+	_, err = uc.CreateReturn(ctx, "order1", "user123", []model.ReturnItem{{ProductID: "p1"}}, "")
+	assert.ErrorIs(t, err, usecase.ErrInvalidReturn)
+	_, err = uc.CreateReturn(ctx, "order1", "user123", []model.ReturnItem{{ProductID: "p1", Quantity: 2}}, "")
+	assert.ErrorIs(t, err, usecase.ErrTooManyReturned)
+	_, err = uc.CreateReturn(ctx, "order1", "user123", []model.ReturnItem{{ProductID: "p2", Quantity: 1}}, "")
+	assert.ErrorIs(t, err, usecase.ErrTooManyReturned, "p2 was ordered as p2-red")
+
+	// Another return of the same units got in first.
+	mockRepo.On("AddReturn", mock.Anything, returned, mock.Anything).Return(false, nil)
+	_, err = uc.CreateReturn(ctx, "order1", "user123", one, "")
+	assert.ErrorIs(t, err, usecase.ErrReturnConflict)
+}
+
+func TestCreateReturn_RetriesRefund(t *testing.T) {
+	ctx := context.Background()
+	mockRepo, pay := new(MockOrderRepo), new(MockPayments)
+	uc := usecase.NewReturnUsecase(mockRepo, nil, pay, cache.NewLRU(100))
+
+	order := returnableOrder()
+	mockRepo.On("FindByID", mock.Anything, "order1").Return(order, nil)
+	mockRepo.On("AddReturn", mock.Anything, order, mock.Anything).Return(true, nil).Run(func(args mock.Arguments) {
+		args.Get(2).(*model.Return).ID = "ret1"
+	})
+	pay.On("Refund", mock.Anything, "pay1", money.New(550, "USD"), "ret1", mock.Anything).Return(errors.New("connection refused")).Once()
+
+	ret, err := uc.CreateReturn(ctx, "order1", "user123", []model.ReturnItem{{ProductID: "p2", SKU: "p2-red", Quantity: 1}}, "")
+	assert.NoError(t, err, "the return is made; its refund is retried")
+	assert.Equal(t, model.RefundPending, ret.RefundStatus)
+	mockRepo.AssertNotCalled(t, "SetRefundStatus", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	order.Returns = []model.Return{*ret}
+	mockRepo.On("FindPendingRefunds", mock.Anything).Return([]*model.Order{order}, nil)
+	pay.On("Refund", mock.Anything, "pay1", money.New(550, "USD"), "ret1", mock.Anything).Return(payments.ErrRefundRejected).Once()
+	mockRepo.On("SetRefundStatus", mock.Anything, "order1", "ret1", model.RefundFailed).Return(nil).Once()
+
+	n, err := uc.RetryRefunds(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateReturn_RepublishesEvent(t *testing.T) {
+	ctx := context.Background()
+	mockRepo, mockPub := new(MockOrderRepo), new(MockPublisher)
+	uc := usecase.NewReturnUsecase(mockRepo, mockPub, new(MockPayments), cache.NewLRU(100))
+
+	order := returnableOrder()
+	order.Returns = []model.Return{
+		{ID: "ret1", Items: []model.ReturnItem{{ProductID: "p1", Quantity: 1}}, Published: true},
+		{ID: "ret2", Items: []model.ReturnItem{{ProductID: "p2", SKU: "p2-red", Quantity: 1}}},
+	}
+	mockRepo.On("FindUnpublishedReturns", mock.Anything).Return([]*model.Order{order}, nil)
+	mockPub.On("PublishOrderReturned", mock.Anything, order, &order.Returns[1]).Return(errors.New("nats: timeout")).Once()
+
+	// An event that does not go out stays owed.
+	n, err := uc.PublishPending(ctx)
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+	mockRepo.AssertNotCalled(t, "MarkReturnPublished", mock.Anything, mock.Anything, mock.Anything)
+
+	// Only the returns not yet announced are, once each.
+	mockPub.On("PublishOrderReturned", mock.Anything, order, &order.Returns[1]).Return(nil).Once()
+	mockRepo.On("MarkReturnPublished", mock.Anything, "order1", "ret2").Return(nil).Once()
+	n, err = uc.PublishPending(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	mockRepo.AssertExpectations(t)
+	mockPub.AssertExpectations(t)
+}
+
+func paidOrder() *model.Order {
+	order := returnableOrder()
+	order.ID = "507f1f77bcf86cd799439099"
+	order.ShippingAddress = &address.Address{Name: "Ann Lee", Line1: "1 Main St", City: "Austin", Region: "TX", PostalCode: "78701", Country: "US"}
+	return order
+}
+
+func TestIssueInvoice(t *testing.T) {
+	ctx := context.Background()
+	orders, invoices, catalog, dir, pub := new(MockOrderRepo), new(MockInvoiceRepo), new(MockCatalog), new(MockDirectory), new(MockPublisher)
+	store, err := blob.NewFileStore(t.TempDir(), "")
+	assert.NoError(t, err)
+	uc := usecase.NewInvoiceUsecase(orders, invoices, store, catalog, dir, pub, cache.NewLRU(100), "Acme Store")
+
+	order := paidOrder()
+	orders.On("FindByID", mock.Anything, order.ID).Return(order, nil)
+	invoices.On("FindByOrder", mock.Anything, order.ID).Return(nil, repository.ErrInvoiceNotFound).Once()
+	catalog.On("GetProduct", mock.Anything, "p1").Return(&model.CatalogProduct{ID: "p1", Name: "Mug"}, nil)
+	catalog.On("GetProduct", mock.Anything, "p2").Return(nil, inventory.ErrProductNotFound)
+	invoices.On("Create", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		inv := args.Get(1).(*model.Invoice)
+		inv.Seq, inv.Number = 7, model.InvoiceNumber(7)
+	})
+	dir.On("Email", mock.Anything, "user123").Return("ann@example.com", nil)
+	pub.On("PublishOrderInvoiced", mock.Anything, mock.Anything, "invoices/INV-000007.pdf", "ann@example.com").Return(nil).Once()
+	invoices.On("MarkPublished", mock.Anything, int64(7)).Return(nil).Once()
+
+	inv, err := uc.Issue(ctx, order.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "INV-000007", inv.Number)
+	assert.True(t, inv.Published)
+	assert.False(t, inv.Sent, "sent once email-service confirms it")
+	assert.Equal(t, "Mug", inv.Lines[0].Description)
+	assert.Equal(t, money.New(2997, "USD"), inv.Lines[0].Amount)
+	assert.Equal(t, "p2 (p2-red)", inv.Lines[1].Description, "products gone from the catalog are named by ID")
+	assert.Equal(t, order.Total, inv.Total)
+
+	pdf, err := store.Get(ctx, "invoices/INV-000007.pdf")
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4")))
+	assert.Contains(t, string(pdf), "(Invoice INV-000007)")
+	assert.Contains(t, string(pdf), "(38.46 USD)")
+
+	// Issuing again, e.g. for a redelivered payment event, sends nothing more.
+	invoices.On("FindByOrder", mock.Anything, order.ID).Return(inv, nil)
+	again, err := uc.Issue(ctx, order.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, inv, again)
+	_, got, err := uc.GetInvoice(ctx, order.ID, "user123")
+	assert.NoError(t, err)
+	assert.Equal(t, pdf, got)
+	invoices.AssertNumberOfCalls(t, "Create", 1)
+	pub.AssertExpectations(t)
+}
+
+func TestGetInvoice_Rejects(t *testing.T) {
+	ctx := context.Background()
+	orders, invoices := new(MockOrderRepo), new(MockInvoiceRepo)
+	store, err := blob.NewFileStore(t.TempDir(), "")
+	assert.NoError(t, err)
+	uc := usecase.NewInvoiceUsecase(orders, invoices, store, nil, new(MockDirectory), new(MockPublisher), cache.NewLRU(100), "")
+
+	order := paidOrder()
+	order.Status = "PENDING"
+	orders.On("FindByID", mock.Anything, order.ID).Return(order, nil)
+	invoices.On("FindByOrder", mock.Anything, order.ID).Return(nil, repository.ErrInvoiceNotFound)
+
+	_, _, err = uc.GetInvoice(ctx, "", "user123")
+	assert.ErrorIs(t, err, repository.ErrInvalidID)
+	_, _, err = uc.GetInvoice(ctx, order.ID, "someone-else")
+	assert.ErrorIs(t, err, repository.ErrOrderNotFound)
+	_, _, err = uc.GetInvoice(ctx, order.ID, "user123")
+	assert.ErrorIs(t, err, usecase.ErrOrderNotInvoiceable)
+	invoices.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestIssuePending_RetriesUnpublished(t *testing.T) {
+	ctx := context.Background()
+	orders, invoices, dir, pub := new(MockOrderRepo), new(MockInvoiceRepo), new(MockDirectory), new(MockPublisher)
+	store, err := blob.NewFileStore(t.TempDir(), "")
+	assert.NoError(t, err)
+	uc := usecase.NewInvoiceUsecase(orders, invoices, store, nil, dir, pub, cache.NewLRU(100), "")
+
+	unpublished := &model.Invoice{Seq: 3, Number: "INV-000003", OrderID: "o3", UserID: "user123", Total: money.New(100, "USD")}
+	orders.On("FindUninvoiced", mock.Anything).Return([]*model.Order{}, nil)
+	invoices.On("FindUnpublished", mock.Anything).Return([]*model.Invoice{unpublished}, nil)
+	dir.On("Email", mock.Anything, "user123").Return("", errors.New("connection refused")).Once()
+
+	n, err := uc.IssuePending(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n, "user-service is down")
+	pub.AssertNotCalled(t, "PublishOrderInvoiced", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	dir.On("Email", mock.Anything, "user123").Return("", users.ErrUserNotFound)
+	pub.On("PublishOrderInvoiced", mock.Anything, unpublished, "invoices/INV-000003.pdf", "").Return(nil)
+	invoices.On("MarkPublished", mock.Anything, int64(3)).Return(nil)
+	n, err = uc.IssuePending(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	invoices.AssertNotCalled(t, "MarkSent", mock.Anything, mock.Anything)
+}
+
+func TestConfirmInvoiceSent(t *testing.T) {
+	ctx := context.Background()
+	invoices := new(MockInvoiceRepo)
+	store, err := blob.NewFileStore(t.TempDir(), "")
+	assert.NoError(t, err)
+	uc := usecase.NewInvoiceUsecase(new(MockOrderRepo), invoices, store, nil, new(MockDirectory), new(MockPublisher), cache.NewLRU(100), "")
+
+	invoices.On("MarkSent", mock.Anything, "o3").Return(nil).Once()
+	invoices.On("MarkSent", mock.Anything, "o4").Return(repository.ErrInvoiceNotFound).Once()
+
+	assert.NoError(t, uc.ConfirmSent(ctx, "o3"))
+	assert.ErrorIs(t, uc.ConfirmSent(ctx, "o4"), repository.ErrInvoiceNotFound)
+	invoices.AssertExpectations(t)
+}
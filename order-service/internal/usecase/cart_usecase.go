@@ -0,0 +1,411 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"order-service/internal/inventory"
+	"order-service/internal/model"
+	"order-service/internal/repository"
+)
+
+var (
+	ErrCartEmpty = errors.New("cart is empty")
+	// ErrCartNotReady means some items cannot be ordered as they are.
+	ErrCartNotReady      = errors.New("cart has items that cannot be ordered")
+	ErrInsufficientStock = errors.New("not enough stock")
+	// ErrCartBusy means the cart kept being changed while a change was
+	// retried.
+	ErrCartBusy = errors.New("cart is being changed concurrently, try again")
+)
+
+const (
+	maxCartItems      = 100
+	maxCartQuantity   = 999
+	maxGuestIDLength  = 64
+	problemGone       = "no longer available"
+	problemOutOfStock = "out of stock"
+)
+
+// maxCartAttempts bounds how often a change is retried on a cart that keeps
+// being changed concurrently.
+const maxCartAttempts = 5
+
+type CartUsecase struct {
+	guests  repository.CartRepository
+	users   repository.CartRepository
+	catalog inventory.Catalog
+	orders  *OrderUsecase
+}
+
+// NewCartUsecase creates the cart usecase. Guests' carts are kept in guests
+// and users' carts in users; items are checked against catalog, and orders
+// creates the order at checkout.
+func NewCartUsecase(guests, users repository.CartRepository, catalog inventory.Catalog, orders *OrderUsecase) *CartUsecase {
+	return &CartUsecase{
+		guests:  guests,
+		users:   users,
+		catalog: catalog,
+		orders:  orders,
+	}
+}
+
+// repo returns where the owner's cart is kept and under which ID.
+func (u *CartUsecase) repo(owner model.CartOwner) (repository.CartRepository, string, error) {
+	switch {
+	case owner.UserID != "" && owner.GuestID != "":
+		return nil, "", errors.New("cart owner must be either a user or a guest")
+	case owner.UserID != "":
+		return u.users, owner.UserID, nil
+	case owner.GuestID == "":
+		return nil, "", errors.New("cart owner is required")
+	case len(owner.GuestID) > maxGuestIDLength:
+		return nil, "", fmt.Errorf("guest_id must be at most %d characters", maxGuestIDLength)
+	}
+	return u.guests, owner.GuestID, nil
+}
+
+func (u *CartUsecase) GetCart(ctx context.Context, owner model.CartOwner) (*model.PricedCart, error) {
+	repo, id, err := u.repo(owner)
+	if err != nil {
+		return nil, err
+	}
+	cart, err := repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return u.price(ctx, owner, cart)
+}
+
+// AddItem adds quantity units of a variant to the cart, on top of any already
+// there. The variant must exist and have enough stock for the new quantity.
+func (u *CartUsecase) AddItem(ctx context.Context, owner model.CartOwner, item model.CartItem) (*model.PricedCart, error) {
+	if item.Quantity <= 0 {
+		return nil, errors.New("quantity must be positive")
+	}
+	return u.modify(ctx, owner, func(cart *model.Cart) error {
+		v, err := u.variant(ctx, item.ProductID, item.SKU)
+		if err != nil {
+			return err
+		}
+		i := cart.Find(item.ProductID, v.SKU)
+		if i < 0 {
+			if len(cart.Items) >= maxCartItems {
+				return fmt.Errorf("cart must hold at most %d items", maxCartItems)
+			}
+			cart.Items = append(cart.Items, model.CartItem{ProductID: item.ProductID, SKU: v.SKU})
+			i = len(cart.Items) - 1
+		}
+		return setQuantity(cart, i, cart.Items[i].Quantity+item.Quantity, v)
+	})
+}
+
+// UpdateItem sets the quantity of an item already in the cart; zero removes
+// it.
+func (u *CartUsecase) UpdateItem(ctx context.Context, owner model.CartOwner, item model.CartItem) (*model.PricedCart, error) {
+	if item.Quantity < 0 {
+		return nil, errors.New("quantity cannot be negative")
+	}
+	return u.modify(ctx, owner, func(cart *model.Cart) error {
+		i, err := findItem(cart, item.ProductID, item.SKU)
+		if err != nil {
+			return err
+		}
+		if item.Quantity == 0 {
+			cart.Items = append(cart.Items[:i], cart.Items[i+1:]...)
+			return nil
+		}
+		v, err := u.variant(ctx, item.ProductID, cart.Items[i].SKU)
+		if err != nil {
+			return err
+		}
+		return setQuantity(cart, i, item.Quantity, v)
+	})
+}
+
+func (u *CartUsecase) RemoveItem(ctx context.Context, owner model.CartOwner, productID, sku string) (*model.PricedCart, error) {
+	return u.modify(ctx, owner, func(cart *model.Cart) error {
+		i, err := findItem(cart, productID, sku)
+		if err != nil {
+			return err
+		}
+		cart.Items = append(cart.Items[:i], cart.Items[i+1:]...)
+		return nil
+	})
+}
+
+func (u *CartUsecase) modify(ctx context.Context, owner model.CartOwner, fn func(*model.Cart) error) (*model.PricedCart, error) {
+	repo, id, err := u.repo(owner)
+	if err != nil {
+		return nil, err
+	}
+	cart, err := update(ctx, repo, id, fn)
+	if err != nil {
+		return nil, err
+	}
+	return u.price(ctx, owner, cart)
+}
+
+// update applies fn to the stored cart and saves it. If the cart was changed
+// in between, fn is applied again to the new cart, so no change is lost.
+func update(ctx context.Context, repo repository.CartRepository, id string, fn func(*model.Cart) error) (*model.Cart, error) {
+	for attempt := 1; ; attempt++ {
+		cart, err := repo.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(cart); err != nil {
+			return nil, err
+		}
+		err = repo.Save(ctx, id, cart)
+		switch {
+		case errors.Is(err, repository.ErrCartConflict) && attempt < maxCartAttempts:
+			continue
+		case errors.Is(err, repository.ErrCartConflict):
+			return nil, ErrCartBusy
+		case err != nil:
+			return nil, err
+		}
+		return cart, nil
+	}
+}
+
+// findItem finds the item for a variant. An empty SKU selects the only item
+// of the product.
+func findItem(cart *model.Cart, productID, sku string) (int, error) {
+	if sku != "" {
+		if i := cart.Find(productID, sku); i >= 0 {
+			return i, nil
+		}
+		return 0, fmt.Errorf("variant %s of product %s is not in the cart", sku, productID)
+	}
+	found := -1
+	for i, item := range cart.Items {
+		if item.ProductID != productID {
+			continue
+		}
+		if found >= 0 {
+			return 0, fmt.Errorf("sku is required for product %s, which is in the cart in several variants", productID)
+		}
+		found = i
+	}
+	if found < 0 {
+		return 0, fmt.Errorf("product %s is not in the cart", productID)
+	}
+	return found, nil
+}
+
+func setQuantity(cart *model.Cart, i, quantity int, v model.CatalogVariant) error {
+	if quantity > maxCartQuantity {
+		return fmt.Errorf("quantity must be at most %d", maxCartQuantity)
+	}
+	if quantity > int(v.Stock) {
+		return fmt.Errorf("%w: only %d of %s left", ErrInsufficientStock, v.Stock, v.SKU)
+	}
+	cart.Items[i].Quantity = quantity
+	return nil
+}
+
+// variant looks up a variant that can be added to a cart. An empty SKU
+// selects the only variant of a single-variant product.
+func (u *CartUsecase) variant(ctx context.Context, productID, sku string) (model.CatalogVariant, error) {
+	if productID == "" {
+		return model.CatalogVariant{}, errors.New("product_id is required")
+	}
+	p, err := u.catalog.GetProduct(ctx, productID)
+	if errors.Is(err, inventory.ErrProductNotFound) {
+		return model.CatalogVariant{}, fmt.Errorf("product %s not found", productID)
+	}
+	if err != nil {
+		return model.CatalogVariant{}, fmt.Errorf("%w: %v", ErrCatalogUnavailable, err)
+	}
+	if p.Deleted {
+		return model.CatalogVariant{}, fmt.Errorf("product %s is no longer available", productID)
+	}
+	v, ok := p.Variant(sku)
+	switch {
+	case !ok && sku == "":
+		return v, fmt.Errorf("sku is required for product %s, which has several variants", productID)
+	case !ok:
+		return v, fmt.Errorf("variant %s of product %s not found", sku, productID)
+	}
+	return v, nil
+}
+
+// price looks up the current price and stock of every item. Items that cannot
+// be ordered as they are get a problem and are left out of the total.
+func (u *CartUsecase) price(ctx context.Context, owner model.CartOwner, cart *model.Cart) (*model.PricedCart, error) {
+	priced := &model.PricedCart{Owner: owner}
+	products := make(map[string]*model.CatalogProduct)
+	for _, item := range cart.Items {
+		p, ok := products[item.ProductID]
+		if !ok {
+			var err error
+			p, err = u.catalog.GetProduct(ctx, item.ProductID)
+			if err != nil && !errors.Is(err, inventory.ErrProductNotFound) {
+				return nil, fmt.Errorf("%w: %v", ErrCatalogUnavailable, err)
+			}
+			products[item.ProductID] = p
+		}
+
+		line := model.CartLine{CartItem: item}
+		if p == nil || p.Deleted {
+			line.Problem = problemGone
+			priced.Lines = append(priced.Lines, line)
+			continue
+		}
+		line.Name = p.Name
+		v, ok := p.Variant(item.SKU)
+		if !ok {
+			line.Problem = problemGone
+			priced.Lines = append(priced.Lines, line)
+			continue
+		}
+		line.UnitPrice = v.Price
+		line.Available = v.Stock
+		total, err := v.Price.Mul(int64(item.Quantity))
+		if err != nil {
+			return nil, err
+		}
+		line.LineTotal = total
+		switch {
+		case v.Stock <= 0:
+			line.Problem = problemOutOfStock
+		case int(v.Stock) < item.Quantity:
+			line.Problem = fmt.Sprintf("only %d left", v.Stock)
+		default:
+			if priced.Total, err = priced.Total.Add(total); err != nil {
+				return nil, err
+			}
+		}
+		priced.Lines = append(priced.Lines, line)
+	}
+	return priced, nil
+}
+
+// MergeCart moves a guest's items into a user's cart, adding up the
+// quantities of items in both, and deletes the guest cart. Items beyond the
+// size limit of a cart are dropped.
+func (u *CartUsecase) MergeCart(ctx context.Context, guestID, userID string) (*model.PricedCart, error) {
+	if _, _, err := u.repo(model.CartOwner{GuestID: guestID}); err != nil {
+		return nil, err
+	}
+	if userID == "" {
+		return nil, errors.New("user_id is required")
+	}
+	guest, err := u.guests.Get(ctx, guestID)
+	if err != nil {
+		return nil, err
+	}
+	owner := model.CartOwner{UserID: userID}
+	if len(guest.Items) == 0 {
+		return u.GetCart(ctx, owner)
+	}
+
+	priced, err := u.modify(ctx, owner, func(cart *model.Cart) error {
+		for _, item := range guest.Items {
+			if i := cart.Find(item.ProductID, item.SKU); i >= 0 {
+				cart.Items[i].Quantity = min(cart.Items[i].Quantity+item.Quantity, maxCartQuantity)
+			} else if len(cart.Items) < maxCartItems {
+				cart.Items = append(cart.Items, item)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// A guest cart left behind would be merged again on the next login.
+	if err := u.guests.Delete(ctx, guestID); err != nil {
+		slog.WarnContext(ctx, "failed to delete merged guest cart", "error", err)
+	}
+	return priced, nil
+}
+
+// Checkout orders everything in a user's cart and empties it. details holds
+// what the client sent with the checkout: the user, and the coupon code,
+// region and shipping address of the order; its products come from the cart.
+// Its total is what the client expects to pay and, when set, must match the
+// current prices less discounts plus tax and shipping. A retry with the same
+// idempotency key returns the original order with replayed set, even after
+// the cart was emptied.
+func (u *CartUsecase) Checkout(ctx context.Context, key string, details *model.Order) (order *model.Order, replayed bool, err error) {
+	userID := details.UserID
+	if userID == "" {
+		return nil, false, errors.New("user_id is required")
+	}
+	cart, err := u.users.Get(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(cart.Items) == 0 {
+		if key != "" && key == cart.LastCheckoutKey {
+			order, err := u.orders.GetOrder(ctx, cart.LastOrderID)
+			return order, true, err
+		}
+		return nil, false, ErrCartEmpty
+	}
+
+	priced, err := u.price(ctx, model.CartOwner{UserID: userID}, cart)
+	if err != nil {
+		return nil, false, err
+	}
+	order = &model.Order{
+		UserID:          userID,
+		Total:           details.Total,
+		PromoCode:       details.PromoCode,
+		Region:          details.Region,
+		AddressID:       details.AddressID,
+		ShippingAddress: details.ShippingAddress,
+	}
+	for _, line := range priced.Lines {
+		if line.Problem != "" {
+			return nil, false, fmt.Errorf("%w: %s of product %s is %s", ErrCartNotReady, line.SKU, line.ProductID, line.Problem)
+		}
+		order.Products = append(order.Products, model.Product{
+			ProductID: line.ProductID,
+			SKU:       line.SKU,
+			Quantity:  line.Quantity,
+		})
+	}
+
+	id, replayed, err := u.orders.CreateOrderIdempotent(ctx, key, order)
+	if err != nil {
+		return nil, false, err
+	}
+	if replayed {
+		// Answered from the idempotency record; report the stored order.
+		if order, err = u.orders.GetOrder(ctx, id); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// Take out only what was ordered: items added while the order was being
+	// created stay in the cart.
+	_, err = update(ctx, u.users, userID, func(cart *model.Cart) error {
+		if key != "" && cart.LastCheckoutKey == key {
+			return nil
+		}
+		for _, p := range order.Products {
+			i := cart.Find(p.ProductID, p.SKU)
+			if i < 0 {
+				continue
+			}
+			if cart.Items[i].Quantity > p.Quantity {
+				cart.Items[i].Quantity -= p.Quantity
+			} else {
+				cart.Items = append(cart.Items[:i], cart.Items[i+1:]...)
+			}
+		}
+		cart.LastOrderID = id
+		cart.LastCheckoutKey = key
+		return nil
+	})
+	if err != nil {
+		// The order stands; the shopper can empty the cart by hand.
+		slog.ErrorContext(ctx, "failed to empty cart after checkout", "order_id", id, "error", err)
+	}
+	return order, replayed, nil
+}
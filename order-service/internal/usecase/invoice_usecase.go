@@ -0,0 +1,274 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	queue "order-service/internal/events"
+	"order-service/internal/inventory"
+	"order-service/internal/invoice"
+	"order-service/internal/model"
+	"order-service/internal/repository"
+	"order-service/internal/users"
+
+	"golang/pkg/blob"
+	"golang/pkg/cache"
+)
+
+var (
+	// ErrOrderNotInvoiceable means the order was not paid through
+	// payment-service, so it gets no invoice.
+	ErrOrderNotInvoiceable = errors.New("only paid orders have an invoice")
+	// ErrInvoiceStoreUnavailable means the invoice's PDF could not be read
+	// or written.
+	ErrInvoiceStoreUnavailable = errors.New("invoice store unavailable")
+)
+
+// invoiceKey is where the PDF of an invoice is kept in the blob store.
+func invoiceKey(number string) string {
+	return "invoices/" + number + ".pdf"
+}
+
+type InvoiceUsecase struct {
+	orders    repository.OrderRepository
+	invoices  repository.InvoiceRepository
+	blobs     blob.BlobStore
+	catalog   inventory.Catalog
+	users     users.Directory
+	publisher queue.Publisher
+	cache     cache.Cache
+	issuer    string
+}
+
+// NewInvoiceUsecase creates the invoice usecase. catalog may be nil, in which
+// case invoice lines are described by product ID. issuer is the seller
+// printed at the top of invoices.
+func NewInvoiceUsecase(orders repository.OrderRepository, invoices repository.InvoiceRepository, blobs blob.BlobStore, catalog inventory.Catalog, directory users.Directory, publisher queue.Publisher, c cache.Cache, issuer string) *InvoiceUsecase {
+	return &InvoiceUsecase{
+		orders:    orders,
+		invoices:  invoices,
+		blobs:     blobs,
+		catalog:   catalog,
+		users:     directory,
+		publisher: publisher,
+		cache:     c,
+		issuer:    issuer,
+	}
+}
+
+// Issue issues the invoice of a paid order, stores its PDF and announces it
+// on order.invoiced, so the customer is sent it. Each step is skipped if it
+// was done before, so Issue can be repeated until it succeeds. The invoice
+// is sent once email-service confirms it, see ConfirmSent.
+func (u *InvoiceUsecase) Issue(ctx context.Context, orderID string) (*model.Invoice, error) {
+	inv, err := u.find(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.send(ctx, inv); err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// GetInvoice returns the invoice of one of the user's paid orders and its
+// PDF, issuing it if that has not happened yet. An empty userID matches any
+// user.
+func (u *InvoiceUsecase) GetInvoice(ctx context.Context, orderID, userID string) (*model.Invoice, []byte, error) {
+	if orderID == "" {
+		return nil, nil, fmt.Errorf("%w: order ID is required", repository.ErrInvalidID)
+	}
+	if userID != "" {
+		order, err := u.orders.FindByID(ctx, orderID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if order.UserID != userID {
+			return nil, nil, repository.ErrOrderNotFound
+		}
+	}
+	inv, err := u.find(ctx, orderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	pdf, err := u.pdf(ctx, inv)
+	if err != nil {
+		return nil, nil, err
+	}
+	return inv, pdf, nil
+}
+
+// IssuePending finishes invoices that were not: those of paid orders not
+// invoiced yet and those not announced. It returns how many it finished.
+func (u *InvoiceUsecase) IssuePending(ctx context.Context) (int, error) {
+	done := 0
+	orders, err := u.orders.FindUninvoiced(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, order := range orders {
+		if _, err := u.Issue(ctx, order.ID); err != nil {
+			slog.WarnContext(ctx, "failed to issue invoice", "order_id", order.ID, "error", err)
+			continue
+		}
+		done++
+	}
+
+	unpublished, err := u.invoices.FindUnpublished(ctx)
+	if err != nil {
+		return done, err
+	}
+	for _, inv := range unpublished {
+		if err := u.send(ctx, inv); err != nil {
+			slog.WarnContext(ctx, "failed to send invoice", "order_id", inv.OrderID, "invoice_number", inv.Number, "error", err)
+			continue
+		}
+		done++
+	}
+	return done, nil
+}
+
+// ConfirmSent records that email-service emailed the invoice of an order.
+func (u *InvoiceUsecase) ConfirmSent(ctx context.Context, orderID string) error {
+	if err := u.invoices.MarkSent(ctx, orderID); err != nil {
+		return err
+	}
+	slog.InfoContext(ctx, "invoice sent", "order_id", orderID)
+	return nil
+}
+
+// find returns the invoice of an order, creating it for a paid order that
+// has none.
+func (u *InvoiceUsecase) find(ctx context.Context, orderID string) (*model.Invoice, error) {
+	inv, err := u.invoices.FindByOrder(ctx, orderID)
+	if !errors.Is(err, repository.ErrInvoiceNotFound) {
+		return inv, err
+	}
+
+	order, err := u.orders.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != "COMPLETED" || order.PaymentID == "" {
+		return nil, ErrOrderNotInvoiceable
+	}
+	inv, err = u.newInvoice(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	err = u.invoices.Create(ctx, inv)
+	if errors.Is(err, repository.ErrInvoiceExists) {
+		// Issued concurrently; that one stands.
+		return u.invoices.FindByOrder(ctx, orderID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "invoice issued", "order_id", order.ID, "invoice_number", inv.Number)
+
+	// The order now carries its invoice number.
+	_ = u.cache.Delete(ctx, orderCacheKey(order.ID), userOrdersCacheKey(order.UserID))
+	return inv, nil
+}
+
+// newInvoice copies the lines and amounts of a paid order onto an invoice,
+// describing the lines with the products' current names.
+func (u *InvoiceUsecase) newInvoice(ctx context.Context, order *model.Order) (*model.Invoice, error) {
+	inv := &model.Invoice{
+		OrderID:         order.ID,
+		UserID:          order.UserID,
+		PaymentID:       order.PaymentID,
+		Adjustments:     order.Adjustments,
+		Subtotal:        order.Subtotal,
+		Tax:             order.Tax,
+		Shipping:        order.Shipping,
+		Total:           order.Total,
+		ShippingAddress: order.ShippingAddress,
+		IssuedAt:        time.Now().UTC().Truncate(time.Millisecond),
+	}
+	for _, p := range order.Products {
+		amount, err := p.UnitPrice.Mul(int64(p.Quantity))
+		if err != nil {
+			return nil, err
+		}
+		desc, err := u.describe(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+		inv.Lines = append(inv.Lines, model.InvoiceLine{
+			Description: desc,
+			ProductID:   p.ProductID,
+			SKU:         p.SKU,
+			Quantity:    p.Quantity,
+			UnitPrice:   p.UnitPrice,
+			Amount:      amount,
+		})
+	}
+	return inv, nil
+}
+
+func (u *InvoiceUsecase) describe(ctx context.Context, p model.Product) (string, error) {
+	name := p.ProductID
+	if u.catalog != nil {
+		product, err := u.catalog.GetProduct(ctx, p.ProductID)
+		switch {
+		case err == nil:
+			name = product.Name
+		case errors.Is(err, inventory.ErrProductNotFound):
+			// Purged from the catalog since; the ID has to do.
+		default:
+			return "", ErrCatalogUnavailable
+		}
+	}
+	if p.SKU != "" {
+		name += " (" + p.SKU + ")"
+	}
+	return name, nil
+}
+
+// pdf returns the PDF of inv, rendering and storing it if it is not stored.
+func (u *InvoiceUsecase) pdf(ctx context.Context, inv *model.Invoice) ([]byte, error) {
+	key := invoiceKey(inv.Number)
+	data, err := u.blobs.Get(ctx, key)
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, blob.ErrNotFound) {
+		slog.ErrorContext(ctx, "failed to read invoice", "key", key, "error", err)
+		return nil, ErrInvoiceStoreUnavailable
+	}
+	data = invoice.Render(inv, u.issuer)
+	if err := u.blobs.Put(ctx, key, data, "application/pdf"); err != nil {
+		slog.ErrorContext(ctx, "failed to store invoice", "key", key, "error", err)
+		return nil, ErrInvoiceStoreUnavailable
+	}
+	return data, nil
+}
+
+// send stores the PDF of inv and publishes order.invoiced, unless that was
+// done before. The event stays in the orders stream until email-service has
+// emailed the invoice, so it is published once.
+func (u *InvoiceUsecase) send(ctx context.Context, inv *model.Invoice) error {
+	if _, err := u.pdf(ctx, inv); err != nil {
+		return err
+	}
+	if inv.Published {
+		return nil
+	}
+
+	email, err := u.users.Email(ctx, inv.UserID)
+	if err != nil && !errors.Is(err, users.ErrUserNotFound) {
+		return err
+	}
+	if err := u.publisher.PublishOrderInvoiced(ctx, inv, invoiceKey(inv.Number), email); err != nil {
+		return err
+	}
+	if err := u.invoices.MarkPublished(ctx, inv.Seq); err != nil {
+		return err
+	}
+	inv.Published = true
+	return nil
+}
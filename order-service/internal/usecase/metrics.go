@@ -0,0 +1,16 @@
+package usecase
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Instruments are created from the global meter, which delegates to the
+// provider installed by telemetry.InitMetrics once it is configured.
+var meter = otel.Meter("order-service")
+
+// ordersCreated counts orders successfully persisted.
+var ordersCreated, _ = meter.Int64Counter(
+	"orders.created",
+	metric.WithDescription("Number of orders created"),
+)
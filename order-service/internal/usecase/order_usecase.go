@@ -2,32 +2,101 @@ package usecase
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 
 	queue "order-service/internal/events"
+	"order-service/internal/inventory"
 	"order-service/internal/model"
-	"order-service/internal/redis"
+	"order-service/internal/pricing"
 	"order-service/internal/repository"
+	"order-service/internal/users"
+
+	"golang/pkg/address"
+	"golang/pkg/cache"
+	"golang/pkg/money"
+)
+
+var (
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+	ErrRequestInProgress    = errors.New("a request with this idempotency key is still in progress")
+	// ErrCatalogUnavailable means ordered products could not be checked.
+	ErrCatalogUnavailable = errors.New("inventory service unavailable")
+	// ErrTotalMismatch means the client expected a different total than the
+	// current prices add up to.
+	ErrTotalMismatch = errors.New("order total does not match current prices")
+	// ErrAddressBookUnavailable means a saved address could not be looked up.
+	ErrAddressBookUnavailable = errors.New("user service unavailable")
+	// ErrStatusNotSettable means the status can only be reached another way:
+	// orders are COMPLETED by their payment alone.
+	ErrStatusNotSettable = errors.New("orders are completed only by their payment")
+	// ErrCancelOnly means a customer asked for a status change other than
+	// cancelling their order.
+	ErrCancelOnly = errors.New("customers can only cancel their orders")
+	// ErrOrderPaid means the order was paid, so it can only be undone by
+	// returning it, which refunds it.
+	ErrOrderPaid = errors.New("paid orders can only be cancelled by returning them for a refund")
+	// ErrStatusConflict means the order's status changed while it was being
+	// updated, e.g. by its payment; the update can be retried.
+	ErrStatusConflict = errors.New("order changed during the update, try again")
+	// ErrInvalidOrder means the order names no user or items, or items,
+	// variants or an address that do not exist.
+	ErrInvalidOrder = errors.New("invalid order")
+)
+
+const (
+	orderCacheTTL      = 10 * time.Minute
+	userOrdersCacheTTL = 10 * time.Minute
+	notFoundCacheTTL   = 30 * time.Second
 )
 
+func orderCacheKey(id string) string {
+	return fmt.Sprintf("order:%s", id)
+}
+
+func userOrdersCacheKey(userID string) string {
+	return fmt.Sprintf("orders:user:%s", userID)
+}
+
 type OrderUsecase struct {
-	repo      repository.OrderRepository
-	publisher queue.Publisher
+	repo        repository.OrderRepository
+	publisher   queue.Publisher
+	idempotency repository.IdempotencyRepository
+	cache       cache.Cache
+	catalog     inventory.Catalog
+	promotions  *PromotionUsecase
+	pricing     *pricing.Calculator
+	addresses   users.AddressBook
 }
 
-func NewOrderUsecase(repo repository.OrderRepository, publisher queue.Publisher) *OrderUsecase {
+// NewOrderUsecase creates the order usecase. idempotency may be nil, in which
+// case idempotency keys are ignored; catalog may be nil, in which case ordered
+// products are not checked; promotions may be nil, in which case orders get
+// no discounts; prices may be nil, in which case orders carry no tax or
+// shipping; addresses may be nil, in which case orders can only be shipped to
+// addresses given inline.
+func NewOrderUsecase(repo repository.OrderRepository, publisher queue.Publisher, idempotency repository.IdempotencyRepository, c cache.Cache, catalog inventory.Catalog, promotions *PromotionUsecase, prices *pricing.Calculator, addresses users.AddressBook) *OrderUsecase {
 	return &OrderUsecase{
-		repo:      repo,
-		publisher: publisher,
+		repo:        repo,
+		publisher:   publisher,
+		idempotency: idempotency,
+		cache:       c,
+		catalog:     catalog,
+		promotions:  promotions,
+		pricing:     prices,
+		addresses:   addresses,
 	}
 }
 
 func (u *OrderUsecase) CreateOrder(ctx context.Context, order *model.Order) (string, error) {
 	if order.UserID == "" || len(order.Products) == 0 {
-		return "", errors.New("invalid order data")
+		return "", fmt.Errorf("%w: user and items are required", ErrInvalidOrder)
 	}
 	if order.Status == "" {
 		order.Status = "PENDING"
@@ -35,79 +104,447 @@ func (u *OrderUsecase) CreateOrder(ctx context.Context, order *model.Order) (str
 
 	for _, product := range order.Products {
 		if product.ProductID == "" {
-			return "", errors.New("invalid product ID in order")
+			return "", fmt.Errorf("%w: items need a product", ErrInvalidOrder)
 		}
 		if len(product.ProductID) != 24 {
-			log.Printf("Warning: ProductID %s does not match expected ObjectID format", product.ProductID)
+			slog.WarnContext(ctx, "product id does not match expected ObjectID format", "product_id", product.ProductID)
+		}
+	}
+
+	if err := u.shipTo(ctx, order); err != nil {
+		return "", err
+	}
+	if err := u.checkProducts(ctx, order.Products); err != nil {
+		return "", err
+	}
+	applied, err := u.priceOrder(ctx, order)
+	if err != nil {
+		return "", err
+	}
+	if len(applied) > 0 {
+		if err := u.promotions.Redeem(ctx, applied, order.UserID); err != nil {
+			return "", err
 		}
 	}
 
 	id, err := u.repo.Create(ctx, order)
 	if err != nil {
+		if len(applied) > 0 {
+			u.promotions.Unredeem(ctx, applied, order.UserID)
+		}
 		return "", err
 	}
 	order.ID = id
+	ordersCreated.Add(ctx, 1)
+
+	// The user's cached order list no longer includes every order.
+	_ = u.cache.Delete(ctx, userOrdersCacheKey(order.UserID))
 
-	// ✅ NATS publisher бар ма, соны тексер
-	if u.publisher != nil {
-		err = u.publisher.PublishOrderCreated(ctx, order)
+	u.publishStock(ctx, order)
+	return id, nil
+}
+
+// publishStock tells inventory-service to take the stock of order, or give
+// it back once the order is CANCELLED, and records that it did. An event that
+// does not go out is left to PublishPendingStock; inventory-service applies
+// each at most once per change of status.
+func (u *OrderUsecase) publishStock(ctx context.Context, order *model.Order) bool {
+	if u.publisher == nil {
+		return false
+	}
+	subject := "order.created"
+	publish := u.publisher.PublishOrderCreated
+	if order.Status == "CANCELLED" {
+		subject = "order.cancelled"
+		publish = u.publisher.PublishOrderCancelled
+	}
+	if err := publish(ctx, order); err != nil {
+		slog.ErrorContext(ctx, "failed to publish event", "subject", subject, "order_id", order.ID, "error", err)
+		return false
+	}
+	if err := u.repo.MarkStockPublished(ctx, order.ID, order.Status); err != nil {
+		slog.WarnContext(ctx, "failed to record published event", "subject", subject, "order_id", order.ID, "error", err)
+	}
+	return true
+}
+
+// PublishPendingStock publishes the stock events of orders that did not go
+// out when their status changed and returns how many it published.
+func (u *OrderUsecase) PublishPendingStock(ctx context.Context) (int, error) {
+	orders, err := u.repo.FindStockUnpublished(ctx)
+	if err != nil {
+		return 0, err
+	}
+	published := 0
+	for _, order := range orders {
+		if u.publishStock(ctx, order) {
+			published++
+		}
+	}
+	return published, nil
+}
+
+// shipTo sets the shipping address of an order given either an address book
+// entry, which is copied so that later edits to it leave the order alone, or
+// an address inline. Orders without a region ship to the address's.
+func (u *OrderUsecase) shipTo(ctx context.Context, order *model.Order) error {
+	switch {
+	case order.AddressID != "" && order.ShippingAddress != nil:
+		return fmt.Errorf("%w: give either address_id or shipping_address, not both", ErrInvalidOrder)
+	case order.AddressID != "":
+		if u.addresses == nil {
+			return fmt.Errorf("%w: saved addresses are not available, give shipping_address instead", ErrInvalidOrder)
+		}
+		a, err := u.addresses.GetAddress(ctx, order.UserID, order.AddressID)
+		if errors.Is(err, users.ErrAddressNotFound) {
+			return fmt.Errorf("%w: address %s not found", ErrInvalidOrder, order.AddressID)
+		}
 		if err != nil {
-			log.Printf("[NATS] failed to publish order.created event: %v", err)
+			return fmt.Errorf("%w: %v", ErrAddressBookUnavailable, err)
 		}
+		order.ShippingAddress = &a
+	case order.ShippingAddress != nil:
+		a := order.ShippingAddress.Normalize()
+		if err := a.Validate(); err != nil {
+			return err
+		}
+		order.ShippingAddress = &a
+	default:
+		return nil
+	}
+	if strings.TrimSpace(order.Region) == "" {
+		order.Region = order.ShippingAddress.Subdivision()
 	}
+	return nil
+}
 
-	return id, nil
+// checkProducts rejects orders for products that do not exist or have been
+// deleted, and for unknown variants. Deleted products stay readable for
+// existing orders only. Lines ordering a single-variant product without a SKU
+// get that variant's SKU and every line gets its current unit price.
+func (u *OrderUsecase) checkProducts(ctx context.Context, items []model.Product) error {
+	if u.catalog == nil {
+		return nil
+	}
+	for i, item := range items {
+		p, err := u.catalog.GetProduct(ctx, item.ProductID)
+		if errors.Is(err, inventory.ErrProductNotFound) {
+			return fmt.Errorf("%w: product %s not found", ErrInvalidOrder, item.ProductID)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrCatalogUnavailable, err)
+		}
+		if p.Deleted {
+			return fmt.Errorf("%w: product %s is no longer available", ErrInvalidOrder, item.ProductID)
+		}
+		v, ok := p.Variant(item.SKU)
+		switch {
+		case !ok && item.SKU == "":
+			return fmt.Errorf("%w: sku is required for product %s, which has several variants", ErrInvalidOrder, item.ProductID)
+		case !ok:
+			return fmt.Errorf("%w: variant %s of product %s not found", ErrInvalidOrder, item.SKU, item.ProductID)
+		}
+		items[i].SKU = v.SKU
+		items[i].UnitPrice = v.Price
+		items[i].CategoryID = p.CategoryID
+		items[i].WeightGrams = v.WeightGrams
+	}
+	return nil
+}
+
+// priceOrder sets the order subtotal to the sum of its lines, applies the
+// promotions the order qualifies for, works out tax and shipping and sets the
+// total to the subtotal less the discounts plus tax and shipping. A total
+// sent by the client is what it expects to pay and must match. Without a
+// catalog there are no prices and the client's total is kept. It returns the
+// promotions applied, which are yet to be redeemed.
+func (u *OrderUsecase) priceOrder(ctx context.Context, order *model.Order) ([]*model.Promotion, error) {
+	if u.catalog == nil {
+		return nil, nil
+	}
+	var subtotal money.Money
+	for _, item := range order.Products {
+		line, err := item.UnitPrice.Mul(int64(item.Quantity))
+		if err != nil {
+			return nil, fmt.Errorf("product %s: %w", item.ProductID, err)
+		}
+		if subtotal, err = subtotal.Add(line); err != nil {
+			return nil, fmt.Errorf("product %s: %w", item.ProductID, err)
+		}
+	}
+	order.Subtotal = subtotal
+
+	var applied []*model.Promotion
+	if u.promotions != nil {
+		var err error
+		if applied, err = u.promotions.Apply(ctx, order); err != nil {
+			return nil, err
+		}
+	}
+	if u.pricing != nil {
+		err := u.pricing.Price(ctx, order)
+		if err != nil && !errors.Is(err, pricing.ErrInvalidRegion) {
+			err = fmt.Errorf("%w: %v", ErrCatalogUnavailable, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	total := subtotal
+	charges := []money.Money{order.Shipping, order.Tax}
+	for _, a := range order.Adjustments {
+		charges = append(charges, a.Amount)
+	}
+	for _, m := range charges {
+		var err error
+		if total, err = total.Add(m); err != nil {
+			return nil, err
+		}
+	}
+
+	expected := order.Total
+	if expected.Currency == "" {
+		expected.Currency = total.Currency
+	}
+	if !expected.IsZero() && expected != total {
+		return nil, fmt.Errorf("%w: expected %s, got %s", ErrTotalMismatch, expected, total)
+	}
+	order.Total = total
+	return applied, nil
+}
+
+// CreateOrderIdempotent creates the order at most once per idempotency key and
+// user. A retry with the same payload returns the original order ID with
+// replayed set; reusing the key for a different payload fails with
+// ErrIdempotencyKeyReused, and a retry racing the original request fails with
+// ErrRequestInProgress.
+func (u *OrderUsecase) CreateOrderIdempotent(ctx context.Context, key string, order *model.Order) (id string, replayed bool, err error) {
+	if key == "" || u.idempotency == nil {
+		id, err = u.CreateOrder(ctx, order)
+		return id, false, err
+	}
+
+	hash, err := requestHash(order)
+	if err != nil {
+		return "", false, err
+	}
+	scopedKey := order.UserID + ":" + key
+
+	record, reserved, err := u.idempotency.Reserve(ctx, scopedKey, hash)
+	if err != nil {
+		return "", false, err
+	}
+	if !reserved {
+		switch {
+		case record.RequestHash != hash:
+			return "", false, ErrIdempotencyKeyReused
+		case record.Status != model.IdempotencyCompleted:
+			return "", false, ErrRequestInProgress
+		}
+		slog.InfoContext(ctx, "replaying idempotent order creation", "order_id", record.OrderID)
+		return record.OrderID, true, nil
+	}
+
+	// The reservation may have been taken over from a request that created
+	// the order but failed to record it.
+	if id, err := u.replayCreated(ctx, scopedKey); id != "" || err != nil {
+		return id, id != "", err
+	}
+
+	// The order carries its key, so a second order for it cannot be stored
+	// even by requests racing past the reservation.
+	order.IdempotencyKey = scopedKey
+	id, err = u.CreateOrder(ctx, order)
+	if errors.Is(err, repository.ErrDuplicateOrder) {
+		if id, err := u.replayCreated(ctx, scopedKey); id != "" || err != nil {
+			return id, id != "", err
+		}
+	}
+	if err != nil {
+		if relErr := u.idempotency.Release(ctx, scopedKey); relErr != nil {
+			slog.WarnContext(ctx, "failed to release idempotency key", "error", relErr)
+		}
+		return "", false, err
+	}
+	if err := u.idempotency.Complete(ctx, scopedKey, id); err != nil {
+		// The order exists but retries will see the key as in progress until
+		// the reservation lock times out, and then find the order.
+		slog.ErrorContext(ctx, "failed to record idempotency key", "order_id", id, "error", err)
+	}
+	return id, false, nil
+}
+
+// replayCreated returns the ID of the order created with key, completing
+// the key's record, or "" if there is none.
+func (u *OrderUsecase) replayCreated(ctx context.Context, key string) (string, error) {
+	order, err := u.repo.FindByIdempotencyKey(ctx, key)
+	if errors.Is(err, repository.ErrOrderNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := u.idempotency.Complete(ctx, key, order.ID); err != nil {
+		slog.ErrorContext(ctx, "failed to record idempotency key", "order_id", order.ID, "error", err)
+	}
+	slog.InfoContext(ctx, "replaying idempotent order creation", "order_id", order.ID)
+	return order.ID, nil
+}
+
+// requestHash fingerprints the parts of an order that a client controls.
+func requestHash(order *model.Order) (string, error) {
+	var shipTo *address.Address
+	if order.ShippingAddress != nil {
+		a := order.ShippingAddress.Normalize()
+		shipTo = &a
+	}
+	data, err := json.Marshal(struct {
+		UserID          string
+		Products        []model.Product
+		Total           money.Money
+		PromoCode       string           `json:",omitempty"`
+		Region          string           `json:",omitempty"`
+		AddressID       string           `json:",omitempty"`
+		ShippingAddress *address.Address `json:",omitempty"`
+	}{order.UserID, order.Products, order.Total, normalizeCode(order.PromoCode), strings.ToUpper(strings.TrimSpace(order.Region)), order.AddressID, shipTo})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
 }
 
 func (u *OrderUsecase) GetOrder(ctx context.Context, id string) (*model.Order, error) {
 	if id == "" {
 		return nil, errors.New("invalid order ID")
 	}
-	return u.repo.FindByID(ctx, id)
+
+	order, err := cache.GetOrLoad(ctx, u.cache, orderCacheKey(id), orderCacheTTL,
+		func(ctx context.Context) (*model.Order, error) {
+			return u.repo.FindByID(ctx, id)
+		},
+		cache.WithNegativeCaching(func(err error) bool {
+			return errors.Is(err, repository.ErrOrderNotFound)
+		}, notFoundCacheTTL),
+	)
+	if errors.Is(err, cache.ErrNotFound) {
+		return nil, repository.ErrOrderNotFound
+	}
+	return order, err
 }
 
-func (u *OrderUsecase) UpdateOrderStatus(ctx context.Context, id string, status string) error {
+// UpdateOrderStatus sets an order PENDING or CANCELLED. userID, when set, is
+// the customer asking, who may only cancel one of their own orders; staff
+// leave it empty. COMPLETED orders are paid, so they are left to returns,
+// which refund them. A cancelled order gives back the promotion uses and the
+// stock it counted, and one set PENDING again takes them anew.
+func (u *OrderUsecase) UpdateOrderStatus(ctx context.Context, id, userID, status string) error {
 	if id == "" || status == "" {
 		return errors.New("invalid input data")
 	}
-	validStatuses := map[string]bool{
-		"PENDING":   true,
-		"COMPLETED": true,
-		"CANCELLED": true,
-	}
-	if !validStatuses[status] {
+	switch status {
+	case "PENDING", "CANCELLED":
+	case "COMPLETED":
+		return ErrStatusNotSettable
+	default:
 		return errors.New("invalid status")
 	}
-	return u.repo.UpdateStatus(ctx, id, status)
-}
-
-func (u *OrderUsecase) ListUserOrders(ctx context.Context, userID string) ([]*model.Order, error) {
-	if userID == "" {
-		return nil, errors.New("invalid user ID")
+	if userID != "" && status != "CANCELLED" {
+		return ErrCancelOnly
 	}
 
-	// Cache key for orders
-	cacheKey := fmt.Sprintf("orders:user:%s", userID)
-
-	// Check the cache first
-	cachedOrders, err := redis.GetFromCache[[]*model.Order](cacheKey)
+	order, err := u.repo.FindByID(ctx, id)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if userID != "" && order.UserID != userID {
+		return repository.ErrOrderNotFound
+	}
+	if order.Status == "COMPLETED" {
+		return ErrOrderPaid
+	}
+	if order.Status == status {
+		return nil
 	}
 
-	if cachedOrders != nil {
-		// If we have cached data, return it
-		return *cachedOrders, nil
+	applied := orderPromotions(order)
+	reopened := order.Status == "CANCELLED" && u.promotions != nil
+	if reopened {
+		if err := u.promotions.Redeem(ctx, applied, order.UserID); err != nil {
+			return err
+		}
+	}
+	// Only from the status read, so a payment settling the order meanwhile
+	// is not overwritten.
+	updated, err := u.repo.UpdateStatus(ctx, id, order.Status, status)
+	if err == nil && !updated {
+		err = ErrStatusConflict
+	}
+	if err != nil {
+		if reopened {
+			u.promotions.Unredeem(ctx, applied, order.UserID)
+		}
+		return err
 	}
+	if status == "CANCELLED" && u.promotions != nil {
+		u.promotions.Unredeem(ctx, applied, order.UserID)
+	}
+
+	_ = u.cache.Delete(ctx, orderCacheKey(id), userOrdersCacheKey(order.UserID))
+	changed := *order
+	changed.Status = status
+	u.publishStock(ctx, &changed)
+	return nil
+}
 
-	// Otherwise, fetch from the repository
-	orders, err := u.repo.FindByUserID(ctx, userID)
+// SettlePayment applies the outcome of a payment to its order: a PENDING
+// order is COMPLETED when paid and CANCELLED when the payment failed, giving
+// back the promotion uses and the stock it counted. Orders that are no longer PENDING are
+// left alone, so events delivered twice or out of order change nothing.
+func (u *OrderUsecase) SettlePayment(ctx context.Context, orderID, paymentID string, paid bool) error {
+	if orderID == "" || paymentID == "" {
+		return errors.New("invalid input data")
+	}
+	order, err := u.repo.FindByID(ctx, orderID)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	status := "CANCELLED"
+	if paid {
+		status = "COMPLETED"
+	}
+	settled, err := u.repo.SettlePayment(ctx, orderID, paymentID, status)
+	if err != nil {
+		return err
+	}
+	if !settled {
+		slog.WarnContext(ctx, "payment for an order that is not pending ignored", "order_id", orderID, "status", order.Status, "payment_id", paymentID, "paid", paid)
+		return nil
 	}
+	if !paid && u.promotions != nil {
+		u.promotions.Unredeem(ctx, orderPromotions(order), order.UserID)
+	}
+
+	_ = u.cache.Delete(ctx, orderCacheKey(orderID), userOrdersCacheKey(order.UserID))
+	if !paid {
+		cancelled := *order
+		cancelled.Status = status
+		u.publishStock(ctx, &cancelled)
+	}
+	return nil
+}
 
-	// Save the result in cache for subsequent requests
-	_ = redis.SetToCache(cacheKey, orders, 10*time.Minute)
+func (u *OrderUsecase) ListUserOrders(ctx context.Context, userID string) ([]*model.Order, error) {
+	if userID == "" {
+		return nil, errors.New("invalid user ID")
+	}
 
-	return orders, nil
+	// Serve from the cache, falling back to the repository on a miss or a
+	// cache outage
+	return cache.GetOrLoad(ctx, u.cache, userOrdersCacheKey(userID), userOrdersCacheTTL,
+		func(ctx context.Context) ([]*model.Order, error) {
+			return u.repo.FindByUserID(ctx, userID)
+		},
+	)
 }
@@ -0,0 +1,357 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"order-service/internal/inventory"
+	"order-service/internal/model"
+	"order-service/internal/repository"
+
+	"golang/pkg/money"
+)
+
+// ErrPromotionNotApplicable means the coupon code given with an order cannot
+// be used for it.
+var ErrPromotionNotApplicable = errors.New("promotion cannot be applied")
+
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+type PromotionUsecase struct {
+	repo     repository.PromotionRepository
+	catalog  inventory.Catalog
+	currency string
+}
+
+// NewPromotionUsecase creates the promotion usecase. Amounts must be in
+// currency, the store currency; catalog resolves the categories of ordered
+// products for promotions limited to categories.
+func NewPromotionUsecase(repo repository.PromotionRepository, catalog inventory.Catalog, currency string) *PromotionUsecase {
+	return &PromotionUsecase{repo: repo, catalog: catalog, currency: currency}
+}
+
+// normalizeCode upper-cases a coupon code, so that codes match however they
+// are typed.
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (u *PromotionUsecase) validate(p *model.Promotion) error {
+	p.Code = normalizeCode(p.Code)
+	if p.Code != "" && !promoCodePattern.MatchString(p.Code) {
+		return errors.New("code must be 3 to 32 letters, digits, '-' or '_'")
+	}
+	if p.Description == "" {
+		return errors.New("description is required")
+	}
+	switch p.Type {
+	case model.PromotionPercentage:
+		if p.PercentOff < 1 || p.PercentOff > 100 {
+			return errors.New("percent_off must be between 1 and 100")
+		}
+		if !p.AmountOff.IsZero() {
+			return errors.New("amount_off must be empty for PERCENTAGE promotions")
+		}
+		p.AmountOff = money.Money{}
+	case model.PromotionFixed:
+		if p.AmountOff.Amount <= 0 {
+			return errors.New("amount_off must be positive for FIXED promotions")
+		}
+		if p.PercentOff != 0 {
+			return errors.New("percent_off must be empty for FIXED promotions")
+		}
+	default:
+		return fmt.Errorf("type must be %s or %s", model.PromotionPercentage, model.PromotionFixed)
+	}
+	for _, amount := range []struct {
+		m     *money.Money
+		field string
+	}{{&p.AmountOff, "amount_off"}, {&p.MinSubtotal, "min_subtotal"}} {
+		if amount.m.IsZero() {
+			*amount.m = money.Money{}
+			continue
+		}
+		if amount.m.Currency == "" {
+			amount.m.Currency = u.currency
+		}
+		if amount.m.Currency != u.currency {
+			return fmt.Errorf("%s must be in %s, got %s", amount.field, u.currency, amount.m.Currency)
+		}
+		if amount.m.IsNegative() {
+			return fmt.Errorf("%s cannot be negative", amount.field)
+		}
+	}
+	if !p.StartsAt.IsZero() && !p.EndsAt.IsZero() && !p.EndsAt.After(p.StartsAt) {
+		return errors.New("ends_at must be after starts_at")
+	}
+	if p.MaxUses < 0 || p.MaxUsesPerUser < 0 {
+		return errors.New("usage limits cannot be negative")
+	}
+	return nil
+}
+
+func (u *PromotionUsecase) CreatePromotion(ctx context.Context, p *model.Promotion) (*model.Promotion, error) {
+	if err := u.validate(p); err != nil {
+		return nil, err
+	}
+	p.Uses = 0
+	p.CreatedAt = time.Now().UTC()
+	id, err := u.repo.Create(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	p.ID = id
+	return p, nil
+}
+
+func (u *PromotionUsecase) GetPromotion(ctx context.Context, id string) (*model.Promotion, error) {
+	if id == "" {
+		return nil, errors.New("promotion id is required")
+	}
+	return u.repo.FindByID(ctx, id)
+}
+
+func (u *PromotionUsecase) UpdatePromotion(ctx context.Context, p *model.Promotion) (*model.Promotion, error) {
+	if p.ID == "" {
+		return nil, errors.New("promotion id is required")
+	}
+	if err := u.validate(p); err != nil {
+		return nil, err
+	}
+	if err := u.repo.Update(ctx, p); err != nil {
+		return nil, err
+	}
+	return u.repo.FindByID(ctx, p.ID)
+}
+
+// ListPromotions returns every promotion, newest first, or with currentOnly
+// those that are active and within their validity window.
+func (u *PromotionUsecase) ListPromotions(ctx context.Context, currentOnly bool) ([]*model.Promotion, error) {
+	promotions, err := u.repo.List(ctx)
+	if err != nil || !currentOnly {
+		return promotions, err
+	}
+	now := time.Now()
+	return slices.DeleteFunc(promotions, func(p *model.Promotion) bool { return !p.Current(now) }), nil
+}
+
+// Apply works out the discounts on an order whose lines are priced and whose
+// subtotal is set, and records them as the order's adjustments. Every
+// automatic promotion the order qualifies for applies, as does the order's
+// coupon code, which must be valid. Percentage discounts come off each line
+// they apply to, fixed ones off the order once; discounts never take a line
+// below zero. It returns the promotions that gave a discount.
+func (u *PromotionUsecase) Apply(ctx context.Context, order *model.Order) ([]*model.Promotion, error) {
+	now := time.Now()
+	automatic, err := u.repo.ListAutomatic(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []*model.Promotion
+	for _, p := range automatic {
+		if p.Current(now) && u.qualifies(ctx, p, order) == nil {
+			candidates = append(candidates, p)
+		}
+	}
+
+	var coupon *model.Promotion
+	if order.PromoCode = normalizeCode(order.PromoCode); order.PromoCode != "" {
+		coupon, err = u.repo.FindByCode(ctx, order.PromoCode)
+		if err != nil && err.Error() == "promotion not found" {
+			return nil, fmt.Errorf("%w: %s is not a valid code", ErrPromotionNotApplicable, order.PromoCode)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !coupon.Current(now) {
+			return nil, fmt.Errorf("%w: code %s is not valid at this time", ErrPromotionNotApplicable, coupon.Code)
+		}
+		if err := u.qualifies(ctx, coupon, order); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, coupon)
+	}
+
+	// Percentages are taken off the full line prices, fixed amounts off what
+	// is left.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Type == model.PromotionPercentage && candidates[j].Type != model.PromotionPercentage
+	})
+
+	// Line totals, and what is left of them after the discounts so far.
+	full := make([]int64, len(order.Products))
+	remaining := make([]int64, len(order.Products))
+	for i, line := range order.Products {
+		total, err := line.UnitPrice.Mul(int64(line.Quantity))
+		if err != nil {
+			return nil, err
+		}
+		full[i], remaining[i] = total.Amount, total.Amount
+	}
+	currency := order.Subtotal.Currency
+	paths := make(map[string][]string)
+
+	order.Adjustments = nil
+	var applied []*model.Promotion
+	for _, p := range candidates {
+		eligible, err := u.eligibleLines(ctx, p, order, paths)
+		if err != nil {
+			return nil, err
+		}
+		adjustment := model.Adjustment{PromotionID: p.ID, Code: p.Code, Description: p.Description}
+		discounted := false
+		switch p.Type {
+		case model.PromotionPercentage:
+			for _, i := range eligible {
+				line := order.Products[i]
+				discount := min(percentOf(full[i], p.PercentOff), remaining[i])
+				if discount <= 0 {
+					continue
+				}
+				remaining[i] -= discount
+				a := adjustment
+				a.ProductID, a.SKU = line.ProductID, line.SKU
+				a.Amount = money.New(-discount, currency)
+				order.Adjustments = append(order.Adjustments, a)
+				discounted = true
+			}
+		case model.PromotionFixed:
+			left := p.AmountOff.Amount
+			for _, i := range eligible {
+				take := min(left, remaining[i])
+				remaining[i] -= take
+				left -= take
+			}
+			if discount := p.AmountOff.Amount - left; discount > 0 {
+				adjustment.Amount = money.New(-discount, currency)
+				order.Adjustments = append(order.Adjustments, adjustment)
+				discounted = true
+			}
+		}
+
+		if !discounted {
+			if p == coupon {
+				return nil, fmt.Errorf("%w: code %s does not apply to any item in the order", ErrPromotionNotApplicable, p.Code)
+			}
+			continue
+		}
+		applied = append(applied, p)
+	}
+	return applied, nil
+}
+
+// percentOf returns percent percent of amount, rounded down, without
+// overflowing.
+func percentOf(amount int64, percent int32) int64 {
+	return amount/100*int64(percent) + amount%100*int64(percent)/100
+}
+
+// qualifies checks the order-wide conditions of a promotion: the minimum
+// subtotal and the usage limits.
+func (u *PromotionUsecase) qualifies(ctx context.Context, p *model.Promotion, order *model.Order) error {
+	if !p.MinSubtotal.IsZero() &&
+		(p.MinSubtotal.Currency != order.Subtotal.Currency || order.Subtotal.Amount < p.MinSubtotal.Amount) {
+		return fmt.Errorf("%w: code %s needs an order of at least %s", ErrPromotionNotApplicable, p.Code, p.MinSubtotal)
+	}
+	if p.MaxUses > 0 && p.Uses >= p.MaxUses {
+		return fmt.Errorf("%w: code %s has been used up", ErrPromotionNotApplicable, p.Code)
+	}
+	if p.MaxUsesPerUser > 0 {
+		uses, err := u.repo.UsesBy(ctx, p, order.UserID)
+		if err != nil {
+			return err
+		}
+		if uses >= p.MaxUsesPerUser {
+			return fmt.Errorf("%w: code %s was already used", ErrPromotionNotApplicable, p.Code)
+		}
+	}
+	return nil
+}
+
+// eligibleLines returns the indexes of the order lines a promotion applies
+// to. paths caches the category paths of products.
+func (u *PromotionUsecase) eligibleLines(ctx context.Context, p *model.Promotion, order *model.Order, paths map[string][]string) ([]int, error) {
+	var eligible []int
+	for i, line := range order.Products {
+		ok, err := u.covers(ctx, p, line, paths)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			eligible = append(eligible, i)
+		}
+	}
+	return eligible, nil
+}
+
+func (u *PromotionUsecase) covers(ctx context.Context, p *model.Promotion, line model.Product, paths map[string][]string) (bool, error) {
+	if !p.Scoped() || slices.Contains(p.ProductIDs, line.ProductID) {
+		return true, nil
+	}
+	if len(p.CategoryIDs) == 0 || line.CategoryID == "" {
+		return false, nil
+	}
+	path, ok := paths[line.CategoryID]
+	if !ok {
+		var err error
+		if path, err = u.catalog.CategoryPath(ctx, line.CategoryID); err != nil {
+			return false, fmt.Errorf("%w: %v", ErrCatalogUnavailable, err)
+		}
+		paths[line.CategoryID] = path
+	}
+	for _, id := range path {
+		if slices.Contains(p.CategoryIDs, id) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Redeem counts the order placed by userID against the usage limits of the
+// promotions applied to it; if any is used up, none is counted.
+func (u *PromotionUsecase) Redeem(ctx context.Context, applied []*model.Promotion, userID string) error {
+	for i, p := range applied {
+		err := u.repo.Redeem(ctx, p, userID)
+		if err == nil {
+			continue
+		}
+		u.Unredeem(ctx, applied[:i], userID)
+		if errors.Is(err, repository.ErrPromotionUsedUp) {
+			return fmt.Errorf("%w: %s has been used up", ErrPromotionNotApplicable, p.Description)
+		}
+		return err
+	}
+	return nil
+}
+
+// Unredeem takes back the uses counted by Redeem for an order that was not
+// placed or was cancelled.
+func (u *PromotionUsecase) Unredeem(ctx context.Context, applied []*model.Promotion, userID string) {
+	for _, p := range applied {
+		if err := u.repo.Unredeem(ctx, p, userID); err != nil {
+			slog.WarnContext(ctx, "failed to take back promotion use", "promotion_id", p.ID, "error", err)
+		}
+	}
+}
+
+// orderPromotions returns the promotions an order's discounts came from, as
+// Redeem and Unredeem need them.
+func orderPromotions(order *model.Order) []*model.Promotion {
+	var applied []*model.Promotion
+	seen := make(map[string]bool)
+	for _, a := range order.Adjustments {
+		if a.PromotionID == "" || seen[a.PromotionID] {
+			continue
+		}
+		seen[a.PromotionID] = true
+		applied = append(applied, &model.Promotion{ID: a.PromotionID})
+	}
+	return applied
+}
@@ -0,0 +1,244 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"time"
+
+	queue "order-service/internal/events"
+	"order-service/internal/model"
+	"order-service/internal/payments"
+	"order-service/internal/pricing"
+	"order-service/internal/repository"
+
+	"golang/pkg/cache"
+	"golang/pkg/money"
+)
+
+var (
+	// ErrOrderNotReturnable means the order is not COMPLETED.
+	ErrOrderNotReturnable = errors.New("only completed orders can be returned")
+	// ErrOrderNotPaid means the order has no payment to refund a return
+	// through.
+	ErrOrderNotPaid = errors.New("only orders paid online can be returned")
+	// ErrTooManyReturned means a return asked for more units of a line than
+	// were ordered and not returned yet.
+	ErrTooManyReturned = errors.New("more units returned than are left on the order")
+	// ErrReturnConflict means the order changed while the return was made,
+	// e.g. by another return of the same lines; the return can be retried.
+	ErrReturnConflict = errors.New("order changed during the return, try again")
+	// ErrInvalidReturn means the return names no order, user or items, or
+	// an item without a product or quantity.
+	ErrInvalidReturn = errors.New("invalid return")
+)
+
+type ReturnUsecase struct {
+	repo      repository.OrderRepository
+	publisher queue.Publisher
+	payments  payments.Payments
+	cache     cache.Cache
+}
+
+func NewReturnUsecase(repo repository.OrderRepository, publisher queue.Publisher, p payments.Payments, c cache.Cache) *ReturnUsecase {
+	return &ReturnUsecase{repo: repo, publisher: publisher, payments: p, cache: c}
+}
+
+// CreateReturn takes back units of a paid, COMPLETED order of the user. Items
+// name order lines by product and SKU. Each unit is refunded what it was
+// charged after discounts, with its tax; the last units of a line get what
+// rounding left over, so returning a whole line refunds exactly what it cost.
+//
+// The return is recorded before its refund is made. A refund payment-service
+// could not be reached for stays PENDING and is retried by RetryRefunds; an
+// order.returned event that does not go out is left to PublishPending.
+func (u *ReturnUsecase) CreateReturn(ctx context.Context, orderID, userID string, items []model.ReturnItem, reason string) (*model.Return, error) {
+	if orderID == "" || userID == "" || len(items) == 0 {
+		return nil, fmt.Errorf("%w: order, user and items are required", ErrInvalidReturn)
+	}
+	order, err := u.repo.FindByID(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.UserID != userID {
+		return nil, repository.ErrOrderNotFound
+	}
+	if order.Status != "COMPLETED" {
+		return nil, ErrOrderNotReturnable
+	}
+	if order.PaymentID == "" {
+		return nil, ErrOrderNotPaid
+	}
+
+	ret, err := returnLines(order, items)
+	if err != nil {
+		return nil, err
+	}
+	ret.Reason = reason
+	ret.CreatedAt = time.Now()
+	ret.RefundStatus = model.RefundPending
+	if ret.Refund.Amount == 0 {
+		ret.RefundStatus = model.RefundDone
+	}
+
+	added, err := u.repo.AddReturn(ctx, order, ret)
+	if err != nil {
+		return nil, err
+	}
+	if !added {
+		return nil, ErrReturnConflict
+	}
+	_ = u.cache.Delete(ctx, orderCacheKey(order.ID), userOrdersCacheKey(order.UserID))
+
+	u.publish(ctx, order, ret)
+
+	if ret.RefundStatus == model.RefundPending {
+		u.refund(ctx, order, ret)
+	}
+	return ret, nil
+}
+
+// returnLines works out which lines of order items return and what they
+// refund. Units of a product ordered on several lines are taken from the
+// first lines that have some left.
+func returnLines(order *model.Order, items []model.ReturnItem) (*model.Return, error) {
+	totals, err := pricing.LineTotals(order)
+	if err != nil {
+		return nil, err
+	}
+
+	returning := make([]int, len(order.Products))
+	for _, item := range items {
+		if item.ProductID == "" || item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: items need a product and a positive quantity", ErrInvalidReturn)
+		}
+		left := item.Quantity
+		for i, line := range order.Products {
+			if line.ProductID != item.ProductID || line.SKU != item.SKU {
+				continue
+			}
+			n := min(left, line.Quantity-line.Returned-returning[i])
+			if n > 0 {
+				returning[i] += n
+				left -= n
+			}
+		}
+		if left > 0 {
+			return nil, ErrTooManyReturned
+		}
+	}
+
+	currency := order.Total.Currency
+	ret := &model.Return{}
+	var refund int64
+	for i, n := range returning {
+		if n == 0 {
+			continue
+		}
+		line := order.Products[i]
+		amount := unitsShare(totals[i], line.Returned+n, line.Quantity) - unitsShare(totals[i], line.Returned, line.Quantity)
+		ret.Items = append(ret.Items, model.ReturnItem{
+			Line:      i,
+			ProductID: line.ProductID,
+			SKU:       line.SKU,
+			Quantity:  n,
+			Refund:    money.New(amount, currency),
+		})
+		refund += amount
+	}
+	ret.Refund = money.New(refund, currency)
+	return ret, nil
+}
+
+// unitsShare returns what the first n of a line's quantity units cost of its
+// total, rounded down.
+func unitsShare(total int64, n, quantity int) int64 {
+	share := new(big.Int).Mul(big.NewInt(total), big.NewInt(int64(n)))
+	return share.Quo(share, big.NewInt(int64(quantity))).Int64()
+}
+
+// refund makes the refund of ret through the order's payment and records
+// the outcome. It reports whether the refund was settled either way.
+func (u *ReturnUsecase) refund(ctx context.Context, order *model.Order, ret *model.Return) bool {
+	reason := ret.Reason
+	if reason == "" {
+		reason = "order return"
+	}
+	// The return's ID keys the refund, so retries never refund it twice.
+	err := u.payments.Refund(ctx, order.PaymentID, ret.Refund, ret.ID, reason)
+	switch {
+	case err == nil:
+		ret.RefundStatus = model.RefundDone
+	case errors.Is(err, payments.ErrRefundRejected):
+		slog.WarnContext(ctx, "refund rejected", "order_id", order.ID, "return_id", ret.ID, "payment_id", order.PaymentID, "error", err)
+		ret.RefundStatus = model.RefundFailed
+	default:
+		slog.WarnContext(ctx, "refund failed, will retry", "order_id", order.ID, "return_id", ret.ID, "payment_id", order.PaymentID, "error", err)
+		return false
+	}
+
+	if err := u.repo.SetRefundStatus(ctx, order.ID, ret.ID, ret.RefundStatus); err != nil {
+		slog.ErrorContext(ctx, "failed to record refund", "order_id", order.ID, "return_id", ret.ID, "status", ret.RefundStatus, "error", err)
+		return false
+	}
+	_ = u.cache.Delete(ctx, orderCacheKey(order.ID), userOrdersCacheKey(order.UserID))
+	return true
+}
+
+// publish announces ret so that its units are restocked, and records that it
+// did. It reports whether the event went out; inventory-service restocks
+// each return once however often it is announced.
+func (u *ReturnUsecase) publish(ctx context.Context, order *model.Order, ret *model.Return) bool {
+	if u.publisher == nil {
+		return false
+	}
+	if err := u.publisher.PublishOrderReturned(ctx, order, ret); err != nil {
+		slog.ErrorContext(ctx, "failed to publish event", "subject", "order.returned", "order_id", order.ID, "return_id", ret.ID, "error", err)
+		return false
+	}
+	ret.Published = true
+	if err := u.repo.MarkReturnPublished(ctx, order.ID, ret.ID); err != nil {
+		slog.WarnContext(ctx, "failed to record published event", "subject", "order.returned", "order_id", order.ID, "return_id", ret.ID, "error", err)
+	}
+	return true
+}
+
+// PublishPending publishes the order.returned events that did not go out when
+// their returns were made and returns how many it published.
+func (u *ReturnUsecase) PublishPending(ctx context.Context) (int, error) {
+	orders, err := u.repo.FindUnpublishedReturns(ctx)
+	if err != nil {
+		return 0, err
+	}
+	published := 0
+	for _, order := range orders {
+		for i := range order.Returns {
+			ret := &order.Returns[i]
+			if !ret.Published && u.publish(ctx, order, ret) {
+				published++
+			}
+		}
+	}
+	return published, nil
+}
+
+// RetryRefunds makes the refunds of returns still PENDING and returns how
+// many it settled.
+func (u *ReturnUsecase) RetryRefunds(ctx context.Context) (int, error) {
+	orders, err := u.repo.FindPendingRefunds(ctx)
+	if err != nil {
+		return 0, err
+	}
+	settled := 0
+	for _, order := range orders {
+		for i := range order.Returns {
+			ret := &order.Returns[i]
+			if ret.RefundStatus == model.RefundPending && u.refund(ctx, order, ret) {
+				settled++
+			}
+		}
+	}
+	return settled, nil
+}
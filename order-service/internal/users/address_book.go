@@ -0,0 +1,58 @@
+package users
+
+import (
+	"context"
+	"errors"
+
+	pbaddress "order-service/internal/pb/address"
+	pb "order-service/internal/pb/user"
+
+	"golang/pkg/address"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrAddressNotFound is returned by AddressBook.GetAddress for addresses that
+// do not exist or belong to another user.
+var ErrAddressNotFound = errors.New("address not found")
+
+// AddressBook looks up the saved addresses of users in user-service.
+type AddressBook interface {
+	GetAddress(ctx context.Context, userID, id string) (address.Address, error)
+}
+
+type GRPCAddressBook struct {
+	client pb.AddressServiceClient
+}
+
+func NewGRPCAddressBook(conn grpc.ClientConnInterface) *GRPCAddressBook {
+	return &GRPCAddressBook{client: pb.NewAddressServiceClient(conn)}
+}
+
+func (b *GRPCAddressBook) GetAddress(ctx context.Context, userID, id string) (address.Address, error) {
+	resp, err := b.client.GetAddress(ctx, &pb.GetAddressRequest{UserId: userID, Id: id})
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.NotFound, codes.InvalidArgument:
+		return address.Address{}, ErrAddressNotFound
+	default:
+		return address.Address{}, err
+	}
+	return addressFromProto(resp.GetAddress()), nil
+}
+
+// addressFromProto converts a user-service address.
+func addressFromProto(a *pbaddress.Address) address.Address {
+	return address.Address{
+		Name:       a.GetName(),
+		Line1:      a.GetLine1(),
+		Line2:      a.GetLine2(),
+		City:       a.GetCity(),
+		Region:     a.GetRegion(),
+		PostalCode: a.GetPostalCode(),
+		Country:    a.GetCountry(),
+		Phone:      a.GetPhone(),
+	}
+}
@@ -0,0 +1,40 @@
+package users
+
+import (
+	"context"
+	"errors"
+
+	pb "order-service/internal/pb/user"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrUserNotFound is returned by Directory.Email for unknown users.
+var ErrUserNotFound = errors.New("user not found")
+
+// Directory looks up the contact details of users in user-service.
+type Directory interface {
+	Email(ctx context.Context, userID string) (string, error)
+}
+
+type GRPCDirectory struct {
+	client pb.UserServiceClient
+}
+
+func NewGRPCDirectory(conn grpc.ClientConnInterface) *GRPCDirectory {
+	return &GRPCDirectory{client: pb.NewUserServiceClient(conn)}
+}
+
+func (d *GRPCDirectory) Email(ctx context.Context, userID string) (string, error) {
+	resp, err := d.client.GetUserProfile(ctx, &pb.UserID{Id: userID})
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.NotFound, codes.InvalidArgument:
+		return "", ErrUserNotFound
+	default:
+		return "", err
+	}
+	return resp.GetEmail(), nil
+}
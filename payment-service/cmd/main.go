@@ -0,0 +1,137 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"time"
+
+	"payment-service/config"
+	queue "payment-service/internal/events"
+	"payment-service/internal/handler"
+	"payment-service/internal/orders"
+	"payment-service/internal/pb"
+	"payment-service/internal/provider"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+
+	"golang/pkg/logger"
+	"golang/pkg/telemetry"
+
+	"github.com/nats-io/nats.go"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	cfg := config.Load()
+	logger.Init("payment-service", cfg.LogLevel, cfg.LogFormat)
+
+	metricsHandler, shutdownMetrics, err := telemetry.InitMetrics("payment-service")
+	if err != nil {
+		logger.Fatal("metrics init failed", "error", err)
+	}
+	defer shutdownMetrics(cfg.Ctx)
+	telemetry.ServeMetrics(cfg.MetricsPort, metricsHandler)
+
+	shutdownTracing, err := telemetry.InitTracing(cfg.Ctx, "payment-service", cfg.TracesExporter, cfg.OTLPEndpoint)
+	if err != nil {
+		logger.Fatal("tracing init failed", "error", err)
+	}
+	defer shutdownTracing(cfg.Ctx)
+
+	// MongoDB
+	client, err := mongo.Connect(cfg.Ctx, options.Client().ApplyURI(cfg.MongoURI).SetMonitor(otelmongo.NewMonitor()))
+	if err != nil {
+		logger.Fatal("mongodb connection failed", "error", err)
+	}
+	defer client.Disconnect(cfg.Ctx)
+
+	// NATS
+	nc, err := nats.Connect(cfg.NATSURL)
+	if err != nil {
+		logger.Fatal("nats connection failed", "error", err)
+	}
+	defer nc.Close()
+
+	// order-service, to look up the orders to pay
+	orderConn, err := grpc.NewClient(cfg.OrderService,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithChainUnaryInterceptor(logger.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		logger.Fatal("order client init failed", "error", err)
+	}
+	defer orderConn.Close()
+
+	// Only the fake provider exists so far; it charges no one.
+	fake := provider.NewFake(cfg.FakeOutcome, cfg.FakeDelay)
+	slog.Info("using fake payment provider", "outcome", cfg.FakeOutcome, "delay", cfg.FakeDelay.String())
+
+	publisher, err := queue.NewNATSPublisher(nc)
+	if err != nil {
+		logger.Fatal("failed to create nats publisher", "error", err)
+	}
+
+	db := client.Database(cfg.MongoDBName)
+	paymentUsecase := usecase.NewPaymentUsecase(
+		repository.NewMongoPaymentRepository(db.Collection("payments")),
+		fake,
+		orders.NewGRPCOrders(orderConn),
+		publisher,
+	)
+
+	// Fail payments the provider never reported on.
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := paymentUsecase.ExpireStale(cfg.Ctx, cfg.PaymentTimeout)
+			if err != nil {
+				slog.Error("expiring stale payments failed", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.Info("expired stale payments", "count", n, "timeout", cfg.PaymentTimeout.String())
+			}
+		}
+	}()
+
+	// Publish the outcome of payments whose event did not go out.
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			n, err := paymentUsecase.PublishPending(cfg.Ctx)
+			if err != nil {
+				slog.Error("publishing pending payment events failed", "error", err)
+				continue
+			}
+			if n > 0 {
+				slog.Info("published pending payment events", "count", n)
+			}
+		}
+	}()
+
+	lis, err := net.Listen("tcp", ":"+cfg.Port)
+	if err != nil {
+		logger.Fatal("listen failed", "error", err)
+	}
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			logger.UnaryServerInterceptor(),
+			telemetry.UnaryServerInterceptor("payment-service"),
+		),
+	)
+	pb.RegisterPaymentServiceServer(grpcServer, handler.NewPaymentHandler(paymentUsecase))
+
+	slog.Info("payment service listening", "port", cfg.Port)
+	if err := grpcServer.Serve(lis); err != nil {
+		logger.Fatal("grpc serve failed", "error", err)
+	}
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"time"
+
+	"payment-service/internal/provider"
+
+	"github.com/joho/godotenv"
+)
+
+type Config struct {
+	Ctx            context.Context
+	MongoURI       string
+	MongoDBName    string
+	Port           string
+	NATSURL        string
+	OrderService   string // order-service gRPC address, to look up orders to pay
+	MetricsPort    string
+	TracesExporter string
+	OTLPEndpoint   string
+	LogLevel       string
+	LogFormat      string
+	Provider       string           // payment provider; only "fake" exists so far
+	FakeOutcome    provider.Outcome // fake: what confirmed payments do by default
+	FakeDelay      time.Duration    // fake: how long payments take to end
+	PaymentTimeout time.Duration    // how long a payment may be PROCESSING before it fails
+}
+
+func Load() *Config {
+	if err := godotenv.Load("../.env"); err != nil {
+		slog.Info("no .env file found, using environment variables directly")
+	}
+
+	providerName := getEnvWithDefault("PAYMENT_PROVIDER", "fake")
+	if providerName != "fake" {
+		slog.Error("invalid PAYMENT_PROVIDER, want fake", "value", providerName)
+		os.Exit(1)
+	}
+	outcome, err := provider.ParseOutcome(getEnvWithDefault("FAKE_PAYMENT_OUTCOME", "succeed"))
+	if err != nil {
+		slog.Error("invalid FAKE_PAYMENT_OUTCOME", "error", err)
+		os.Exit(1)
+	}
+
+	return &Config{
+		Ctx:            context.TODO(),
+		MongoURI:       getEnv("MONGO_URI"),
+		MongoDBName:    getEnv("MONGO_DB"),
+		Port:           getEnv("PORT"),
+		NATSURL:        getEnv("NATS_URL"),
+		OrderService:   getEnvWithDefault("ORDER_SERVICE", "localhost:50052"),
+		MetricsPort:    getEnvWithDefault("METRICS_PORT", "9094"),
+		TracesExporter: getEnvWithDefault("TRACES_EXPORTER", "none"),
+		OTLPEndpoint:   getEnvWithDefault("OTLP_ENDPOINT", "localhost:4317"),
+		LogLevel:       getEnvWithDefault("LOG_LEVEL", "info"),
+		LogFormat:      getEnvWithDefault("LOG_FORMAT", "json"),
+		Provider:       providerName,
+		FakeOutcome:    outcome,
+		FakeDelay:      getDuration("FAKE_PAYMENT_DELAY", 2*time.Second),
+		PaymentTimeout: getDuration("PAYMENT_TIMEOUT", 15*time.Minute),
+	}
+}
+
+func getEnv(key string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		slog.Error("environment variable is not set", "key", key)
+		os.Exit(1)
+	}
+	return value
+}
+
+func getEnvWithDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func getDuration(key string, defaultValue time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		slog.Error("invalid duration", "key", key, "value", v, "error", err)
+		os.Exit(1)
+	}
+	return d
+}
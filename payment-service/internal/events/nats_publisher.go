@@ -0,0 +1,103 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"payment-service/internal/model"
+
+	"golang/pkg/logger"
+
+	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("payment-service/events")
+
+// Subjects the outcome of payments is published on.
+const (
+	PaymentSucceeded = "payment.succeeded"
+	PaymentFailed    = "payment.failed"
+)
+
+// PaymentsStream is the JetStream stream keeping payment events until
+// order-service has handled them.
+const PaymentsStream = "PAYMENTS"
+
+type Publisher interface {
+	// PublishPayment announces that a payment ended, on payment.succeeded
+	// or payment.failed.
+	PublishPayment(ctx context.Context, p *model.Payment) error
+}
+
+type NATSPublisher struct {
+	js nats.JetStreamContext
+}
+
+// NewNATSPublisher creates the publisher and the payments stream, unless it
+// exists.
+func NewNATSPublisher(nc *nats.Conn) (*NATSPublisher, error) {
+	js, err := nc.JetStream()
+	if err != nil {
+		return nil, err
+	}
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     PaymentsStream,
+		Subjects: []string{"payment.>"},
+		Storage:  nats.FileStorage,
+	})
+	if err != nil && !errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		return nil, err
+	}
+	return &NATSPublisher{js: js}, nil
+}
+
+func (p *NATSPublisher) PublishPayment(ctx context.Context, payment *model.Payment) error {
+	subject := PaymentFailed
+	if payment.Status == model.StatusSucceeded {
+		subject = PaymentSucceeded
+	}
+	ctx, span := tracer.Start(ctx, subject+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "nats"),
+			attribute.String("messaging.destination.name", subject),
+		),
+	)
+	defer span.End()
+
+	data, err := json.Marshal(model.PaymentEvent{
+		PaymentID: payment.ID,
+		OrderID:   payment.OrderID,
+		UserID:    payment.UserID,
+		Amount:    payment.Amount.Amount,
+		Currency:  payment.Amount.Currency,
+		Reason:    payment.FailureReason,
+	})
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+	// Carry the trace context and request ID to the consumers in the message headers
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(msg.Header))
+	if id := logger.RequestID(ctx); id != "" {
+		msg.Header.Set(logger.RequestIDHeader, id)
+	}
+
+	slog.InfoContext(ctx, "publishing event", "subject", subject, "payment_id", payment.ID, "order_id", payment.OrderID)
+	// The stream acknowledges only what it stored.
+	if _, err := p.js.PublishMsg(msg, nats.AckWait(20*time.Second)); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"payment-service/internal/model"
+	"payment-service/internal/orders"
+	"payment-service/internal/pb"
+	pbmoney "payment-service/internal/pb/money"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+
+	"golang/pkg/money"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type PaymentHandler struct {
+	pb.UnimplementedPaymentServiceServer
+	usecase *usecase.PaymentUsecase
+}
+
+func NewPaymentHandler(u *usecase.PaymentUsecase) *PaymentHandler {
+	return &PaymentHandler{usecase: u}
+}
+
+func (h *PaymentHandler) CreatePaymentIntent(ctx context.Context, req *pb.CreatePaymentIntentRequest) (*pb.Payment, error) {
+	p, err := h.usecase.CreatePaymentIntent(ctx, req.OrderId, req.UserId)
+	if err != nil {
+		return nil, paymentError(err)
+	}
+	return paymentToProto(p), nil
+}
+
+func (h *PaymentHandler) ConfirmPayment(ctx context.Context, req *pb.ConfirmPaymentRequest) (*pb.Payment, error) {
+	p, err := h.usecase.ConfirmPayment(ctx, req.Id, req.UserId, req.PaymentMethod)
+	if err != nil {
+		return nil, paymentError(err)
+	}
+	return paymentToProto(p), nil
+}
+
+func (h *PaymentHandler) GetPayment(ctx context.Context, req *pb.GetPaymentRequest) (*pb.Payment, error) {
+	p, err := h.usecase.GetPayment(ctx, req.Id, req.UserId)
+	if err != nil {
+		return nil, paymentError(err)
+	}
+	return paymentToProto(p), nil
+}
+
+func (h *PaymentHandler) GetOrderPayment(ctx context.Context, req *pb.GetOrderPaymentRequest) (*pb.Payment, error) {
+	p, err := h.usecase.GetOrderPayment(ctx, req.OrderId, req.UserId)
+	if err != nil {
+		return nil, paymentError(err)
+	}
+	return paymentToProto(p), nil
+}
+
+func (h *PaymentHandler) RefundPayment(ctx context.Context, req *pb.RefundPaymentRequest) (*pb.Payment, error) {
+	amount := money.New(req.GetAmount().GetAmount(), req.GetAmount().GetCurrency())
+	p, err := h.usecase.RefundPayment(ctx, req.Id, amount, req.Reference, req.Reason)
+	if err != nil {
+		return nil, paymentError(err)
+	}
+	return paymentToProto(p), nil
+}
+
+func paymentError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrPaymentNotFound), errors.Is(err, orders.ErrOrderNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, usecase.ErrOrderNotPayable), errors.Is(err, usecase.ErrNotConfirmable),
+		errors.Is(err, usecase.ErrNotRefundable), errors.Is(err, usecase.ErrRefundTooLarge):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, usecase.ErrOrdersUnavailable), errors.Is(err, usecase.ErrProviderUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+func moneyToProto(m money.Money) *pbmoney.Money {
+	if m == (money.Money{}) {
+		return nil
+	}
+	return &pbmoney.Money{Currency: m.Currency, Amount: m.Amount}
+}
+
+func paymentToProto(p *model.Payment) *pb.Payment {
+	resp := &pb.Payment{
+		Id:            p.ID,
+		OrderId:       p.OrderID,
+		UserId:        p.UserID,
+		Amount:        moneyToProto(p.Amount),
+		Status:        p.Status,
+		Provider:      p.Provider,
+		ProviderRef:   p.ProviderRef,
+		FailureReason: p.FailureReason,
+		CreatedAt:     timestamppb.New(p.CreatedAt),
+		UpdatedAt:     timestamppb.New(p.UpdatedAt),
+		Refunded:      moneyToProto(p.Refunded),
+	}
+	for _, r := range p.Refunds {
+		resp.Refunds = append(resp.Refunds, &pb.Refund{
+			Reference:   r.Reference,
+			Amount:      moneyToProto(r.Amount),
+			Reason:      r.Reason,
+			ProviderRef: r.ProviderRef,
+			CreatedAt:   timestamppb.New(r.CreatedAt),
+		})
+	}
+	return resp
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"golang/pkg/money"
+)
+
+// Payment statuses. A payment is created REQUIRES_CONFIRMATION, is
+// PROCESSING while the provider charges it, and ends SUCCEEDED or FAILED.
+const (
+	StatusRequiresConfirmation = "REQUIRES_CONFIRMATION"
+	StatusProcessing           = "PROCESSING"
+	StatusSucceeded            = "SUCCEEDED"
+	StatusFailed               = "FAILED"
+)
+
+// Payment is a payment intent: the charge of one order's total.
+type Payment struct {
+	ID            string
+	OrderID       string
+	UserID        string
+	Amount        money.Money
+	Status        string
+	Provider      string // name of the provider charging it
+	ProviderRef   string // the provider's ID for it
+	FailureReason string
+	Refunded      money.Money // sum of Refunds
+	Refunds       []Refund
+	Published     bool // whether the event of its outcome went out
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// Refund gives back part of a succeeded payment.
+type Refund struct {
+	Reference   string // what the refund is for, e.g. an order return
+	Amount      money.Money
+	Reason      string
+	ProviderRef string // the provider's ID for the refund
+	CreatedAt   time.Time
+}
+
+// RefundFor returns the payment's refund with the given reference, or nil.
+func (p *Payment) RefundFor(reference string) *Refund {
+	for i := range p.Refunds {
+		if p.Refunds[i].Reference == reference {
+			return &p.Refunds[i]
+		}
+	}
+	return nil
+}
+
+// Final reports whether the payment has succeeded or failed.
+func (p *Payment) Final() bool {
+	return p.Status == StatusSucceeded || p.Status == StatusFailed
+}
+
+// PaymentEvent is published on payment.succeeded and payment.failed when a
+// payment ends.
+type PaymentEvent struct {
+	PaymentID string `json:"payment_id"`
+	OrderID   string `json:"order_id"`
+	UserID    string `json:"user_id"`
+	Amount    int64  `json:"amount"` // in minor units of Currency
+	Currency  string `json:"currency"`
+	Reason    string `json:"reason,omitempty"` // why the payment failed
+}
@@ -0,0 +1,57 @@
+// Package orders looks up the orders payments are for in order-service.
+package orders
+
+import (
+	"context"
+	"errors"
+
+	pb "payment-service/internal/pb/order"
+
+	"golang/pkg/money"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrOrderNotFound is returned by Orders.GetOrder for orders that do not
+// exist.
+var ErrOrderNotFound = errors.New("order not found")
+
+// Order is what a payment needs to know of an order.
+type Order struct {
+	ID     string
+	UserID string
+	Total  money.Money
+	Status string
+}
+
+// Orders looks up orders.
+type Orders interface {
+	GetOrder(ctx context.Context, id string) (*Order, error)
+}
+
+type GRPCOrders struct {
+	client pb.OrderServiceClient
+}
+
+func NewGRPCOrders(conn grpc.ClientConnInterface) *GRPCOrders {
+	return &GRPCOrders{client: pb.NewOrderServiceClient(conn)}
+}
+
+func (o *GRPCOrders) GetOrder(ctx context.Context, id string) (*Order, error) {
+	resp, err := o.client.GetOrder(ctx, &pb.GetOrderRequest{Id: id})
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.NotFound, codes.InvalidArgument:
+		return nil, ErrOrderNotFound
+	default:
+		return nil, err
+	}
+	return &Order{
+		ID:     resp.Id,
+		UserID: resp.UserId,
+		Total:  money.New(resp.GetTotal().GetAmount(), resp.GetTotal().GetCurrency()),
+		Status: resp.Status,
+	}, nil
+}
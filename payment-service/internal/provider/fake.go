@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"payment-service/internal/model"
+
+	"golang/pkg/money"
+)
+
+// Outcome is what the fake provider does with a confirmed payment.
+type Outcome string
+
+const (
+	Succeed Outcome = "succeed"
+	Fail    Outcome = "fail"
+	Timeout Outcome = "timeout" // never reports on the payment
+)
+
+// ParseOutcome parses "succeed", "fail" or "timeout".
+func ParseOutcome(s string) (Outcome, error) {
+	switch o := Outcome(strings.ToLower(s)); o {
+	case Succeed, Fail, Timeout:
+		return o, nil
+	}
+	return "", fmt.Errorf("unknown fake payment outcome %q, want succeed, fail or timeout", s)
+}
+
+// Payment methods that tell the fake provider what to do with a payment,
+// whatever its default outcome.
+const (
+	MethodSucceed = "fake_succeed"
+	MethodFail    = "fake_fail"
+	MethodTimeout = "fake_timeout"
+)
+
+// intentPrefix starts the references of the fake's intents. The fake keeps
+// no record of them, so they survive restarts.
+const intentPrefix = "fake_pi_"
+
+// Fake is a provider for local development and tests that charges no one.
+// Confirmed payments end, after a delay, with the outcome their payment
+// method names, or with the default outcome for any other method.
+type Fake struct {
+	outcome Outcome
+	delay   time.Duration
+
+	mu      sync.Mutex
+	refunds map[string]string // refund IDs by key
+	cb      Callback
+}
+
+func NewFake(outcome Outcome, delay time.Duration) *Fake {
+	return &Fake{outcome: outcome, delay: delay, refunds: map[string]string{}}
+}
+
+func (f *Fake) Name() string { return "fake" }
+
+func (f *Fake) CreateIntent(_ context.Context, p *model.Payment) (string, error) {
+	return newID(intentPrefix)
+}
+
+func (f *Fake) Confirm(ctx context.Context, ref, method string) error {
+	if !strings.HasPrefix(ref, intentPrefix) {
+		return ErrUnknownIntent
+	}
+	f.mu.Lock()
+	cb := f.cb
+	f.mu.Unlock()
+
+	outcome := f.outcome
+	switch method {
+	case MethodSucceed:
+		outcome = Succeed
+	case MethodFail:
+		outcome = Fail
+	case MethodTimeout:
+		outcome = Timeout
+	}
+	if outcome == Timeout || cb == nil {
+		return nil
+	}
+
+	// Report like a webhook would: later, and outside the confirming request.
+	ctx = context.WithoutCancel(ctx)
+	time.AfterFunc(f.delay, func() {
+		e := Event{Ref: ref, Succeeded: outcome == Succeed}
+		if !e.Succeeded {
+			e.Reason = "card declined"
+		}
+		cb(ctx, e)
+	})
+	return nil
+}
+
+// Refund always succeeds for intents the fake created.
+func (f *Fake) Refund(_ context.Context, ref string, _ money.Money, key string) (string, error) {
+	if !strings.HasPrefix(ref, intentPrefix) {
+		return "", ErrUnknownIntent
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if id, ok := f.refunds[key]; ok {
+		return id, nil
+	}
+	id, err := newID("fake_re_")
+	if err != nil {
+		return "", err
+	}
+	f.refunds[key] = id
+	return id, nil
+}
+
+func (f *Fake) Listen(cb Callback) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cb = cb
+}
+
+func newID(prefix string) (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,47 @@
+// Package provider is the interface to the payment providers that charge
+// customers, and a fake one for local development.
+package provider
+
+import (
+	"context"
+	"errors"
+
+	"payment-service/internal/model"
+
+	"golang/pkg/money"
+)
+
+// ErrUnknownIntent is returned for references the provider never issued.
+var ErrUnknownIntent = errors.New("unknown payment intent")
+
+// Event is a provider's report that a payment ended, as a real provider
+// would send it to a webhook.
+type Event struct {
+	Ref       string // the provider's ID for the payment
+	Succeeded bool
+	Reason    string // why the payment failed
+}
+
+// Callback receives the events of a provider.
+type Callback func(ctx context.Context, e Event)
+
+// PaymentProvider charges payments. Charges are asynchronous: Confirm only
+// starts one, and its outcome arrives later at the callback given to Listen.
+// A provider may never report on a payment; the caller times such payments
+// out.
+type PaymentProvider interface {
+	// Name identifies the provider on stored payments.
+	Name() string
+	// CreateIntent registers p with the provider and returns the provider's
+	// reference for it.
+	CreateIntent(ctx context.Context, p *model.Payment) (string, error)
+	// Confirm starts charging the intent ref with a payment method, e.g. a
+	// card token.
+	Confirm(ctx context.Context, ref, method string) error
+	// Refund gives amount of the succeeded payment ref back and returns the
+	// provider's ID for the refund. Refunds with the same key are made once.
+	Refund(ctx context.Context, ref string, amount money.Money, key string) (string, error)
+	// Listen sets the callback events are delivered to. It is called once,
+	// before the first Confirm.
+	Listen(cb Callback)
+}
@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"payment-service/internal/model"
+
+	"golang/pkg/money"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// paymentDocument is the stored shape of a payment. Amount is in minor units
+// of Currency.
+type paymentDocument struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	OrderID       string             `bson:"order_id"`
+	UserID        string             `bson:"user_id"`
+	Amount        int64              `bson:"amount"`
+	Currency      string             `bson:"currency"`
+	Status        string             `bson:"status"`
+	Provider      string             `bson:"provider"`
+	ProviderRef   string             `bson:"provider_ref"`
+	FailureReason string             `bson:"failure_reason,omitempty"`
+	Refunded      int64              `bson:"refunded,omitempty"`
+	Refunds       []refundDocument   `bson:"refunds,omitempty"`
+	Unpublished   bool               `bson:"unpublished,omitempty"`
+	CreatedAt     time.Time          `bson:"created_at"`
+	UpdatedAt     time.Time          `bson:"updated_at"`
+}
+
+type refundDocument struct {
+	Reference   string    `bson:"reference"`
+	Amount      int64     `bson:"amount"`
+	Reason      string    `bson:"reason,omitempty"`
+	ProviderRef string    `bson:"provider_ref"`
+	CreatedAt   time.Time `bson:"created_at"`
+}
+
+func toPaymentDocument(p *model.Payment) paymentDocument {
+	return paymentDocument{
+		OrderID:       p.OrderID,
+		UserID:        p.UserID,
+		Amount:        p.Amount.Amount,
+		Currency:      p.Amount.Currency,
+		Status:        p.Status,
+		Provider:      p.Provider,
+		ProviderRef:   p.ProviderRef,
+		FailureReason: p.FailureReason,
+		CreatedAt:     p.CreatedAt,
+		UpdatedAt:     p.UpdatedAt,
+	}
+}
+
+func (d paymentDocument) toModel() *model.Payment {
+	p := &model.Payment{
+		ID:            d.ID.Hex(),
+		OrderID:       d.OrderID,
+		UserID:        d.UserID,
+		Amount:        money.New(d.Amount, d.Currency),
+		Status:        d.Status,
+		Provider:      d.Provider,
+		ProviderRef:   d.ProviderRef,
+		FailureReason: d.FailureReason,
+		Published:     !d.Unpublished,
+		CreatedAt:     d.CreatedAt,
+		UpdatedAt:     d.UpdatedAt,
+	}
+	if d.Refunded != 0 {
+		p.Refunded = money.New(d.Refunded, d.Currency)
+	}
+	for _, r := range d.Refunds {
+		p.Refunds = append(p.Refunds, model.Refund{
+			Reference:   r.Reference,
+			Amount:      money.New(r.Amount, d.Currency),
+			Reason:      r.Reason,
+			ProviderRef: r.ProviderRef,
+			CreatedAt:   r.CreatedAt,
+		})
+	}
+	return p
+}
+
+// openStatuses are the statuses of payments that have not failed.
+var openStatuses = []string{model.StatusRequiresConfirmation, model.StatusProcessing, model.StatusSucceeded}
+
+type MongoPaymentRepository struct {
+	coll *mongo.Collection
+}
+
+func NewMongoPaymentRepository(coll *mongo.Collection) *MongoPaymentRepository {
+	// One payment per order that has not failed, so an order cannot be
+	// charged twice by concurrent requests.
+	coll.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{
+			Keys: bson.D{{Key: "order_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(
+				bson.M{"status": bson.M{"$in": openStatuses}},
+			),
+		},
+	)
+	coll.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{Keys: bson.D{{Key: "provider", Value: 1}, {Key: "provider_ref", Value: 1}}},
+	)
+	coll.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{Keys: bson.D{{Key: "status", Value: 1}, {Key: "updated_at", Value: 1}}},
+	)
+	coll.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "unpublished", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	)
+	return &MongoPaymentRepository{coll: coll}
+}
+
+func (r *MongoPaymentRepository) Create(ctx context.Context, p *model.Payment) (string, error) {
+	res, err := r.coll.InsertOne(ctx, toPaymentDocument(p))
+	if mongo.IsDuplicateKeyError(err) {
+		return "", ErrOrderHasPayment
+	}
+	if err != nil {
+		return "", err
+	}
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+func (r *MongoPaymentRepository) findOne(ctx context.Context, filter bson.M, opts ...*options.FindOneOptions) (*model.Payment, error) {
+	var d paymentDocument
+	err := r.coll.FindOne(ctx, filter, opts...).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrPaymentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.toModel(), nil
+}
+
+// FindByID looks up a payment. IDs that are not ObjectIDs name no payment.
+func (r *MongoPaymentRepository) FindByID(ctx context.Context, id string) (*model.Payment, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrPaymentNotFound
+	}
+	return r.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (r *MongoPaymentRepository) FindByProviderRef(ctx context.Context, provider, ref string) (*model.Payment, error) {
+	return r.findOne(ctx, bson.M{"provider": provider, "provider_ref": ref})
+}
+
+func (r *MongoPaymentRepository) FindByOrder(ctx context.Context, orderID string) (*model.Payment, error) {
+	p, err := r.findOne(ctx, bson.M{"order_id": orderID, "status": bson.M{"$in": openStatuses}})
+	if err != ErrPaymentNotFound {
+		return p, err
+	}
+	return r.findOne(ctx, bson.M{"order_id": orderID}, options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+}
+
+func (r *MongoPaymentRepository) Transition(ctx context.Context, id, from, to, reason string) (bool, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, ErrPaymentNotFound
+	}
+	set := bson.M{"status": to, "updated_at": time.Now().UTC()}
+	if reason != "" {
+		set["failure_reason"] = reason
+	}
+	if to == model.StatusSucceeded || to == model.StatusFailed {
+		// Its event is owed until MarkPublished.
+		set["unpublished"] = true
+	}
+	res, err := r.coll.UpdateOne(ctx, bson.M{"_id": oid, "status": from}, bson.M{"$set": set})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
+
+func (r *MongoPaymentRepository) MarkPublished(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrPaymentNotFound
+	}
+	_, err = r.coll.UpdateOne(ctx, bson.M{"_id": oid}, bson.M{"$unset": bson.M{"unpublished": ""}})
+	return err
+}
+
+func (r *MongoPaymentRepository) FindUnpublished(ctx context.Context) ([]*model.Payment, error) {
+	return r.find(ctx, bson.M{"unpublished": true})
+}
+
+func (r *MongoPaymentRepository) AddRefund(ctx context.Context, id string, refund model.Refund) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrPaymentNotFound
+	}
+	res, err := r.coll.UpdateOne(ctx,
+		bson.M{
+			"_id":               oid,
+			"status":            model.StatusSucceeded,
+			"currency":          refund.Amount.Currency,
+			"refunds.reference": bson.M{"$ne": refund.Reference},
+			"$expr": bson.M{"$lte": bson.A{
+				bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$refunded", 0}}, refund.Amount.Amount}},
+				"$amount",
+			}},
+		},
+		bson.M{
+			"$inc": bson.M{"refunded": refund.Amount.Amount},
+			"$push": bson.M{"refunds": refundDocument{
+				Reference:   refund.Reference,
+				Amount:      refund.Amount.Amount,
+				Reason:      refund.Reason,
+				ProviderRef: refund.ProviderRef,
+				CreatedAt:   refund.CreatedAt,
+			}},
+			"$set": bson.M{"updated_at": time.Now().UTC()},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrRefundRejected
+	}
+	return nil
+}
+
+func (r *MongoPaymentRepository) FindStale(ctx context.Context, status string, t time.Time) ([]*model.Payment, error) {
+	return r.find(ctx, bson.M{"status": status, "updated_at": bson.M{"$lt": t}})
+}
+
+func (r *MongoPaymentRepository) find(ctx context.Context, filter bson.M) ([]*model.Payment, error) {
+	cursor, err := r.coll.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []paymentDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	payments := make([]*model.Payment, 0, len(docs))
+	for _, d := range docs {
+		payments = append(payments, d.toModel())
+	}
+	return payments, nil
+}
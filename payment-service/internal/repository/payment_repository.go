@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"payment-service/internal/model"
+)
+
+var (
+	// ErrPaymentNotFound is returned for payments that do not exist.
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrOrderHasPayment is returned by Create when the order already has a
+	// payment that has not failed.
+	ErrOrderHasPayment = errors.New("order already has a payment")
+	// ErrRefundRejected is returned by AddRefund when the payment has not
+	// succeeded, already has a refund with the reference, or has too little
+	// left to refund.
+	ErrRefundRejected = errors.New("refund rejected")
+)
+
+// PaymentRepository stores payments. An order has at most one payment that
+// has not failed.
+type PaymentRepository interface {
+	Create(ctx context.Context, p *model.Payment) (string, error)
+	FindByID(ctx context.Context, id string) (*model.Payment, error)
+	FindByProviderRef(ctx context.Context, provider, ref string) (*model.Payment, error)
+	// FindByOrder returns the order's payment that has not failed, or else
+	// its latest failed one.
+	FindByOrder(ctx context.Context, orderID string) (*model.Payment, error)
+	// Transition moves a payment in status from to status to, recording
+	// reason, and reports whether it did; it changes nothing if the payment
+	// is in another status. Payments moved to SUCCEEDED or FAILED are
+	// unpublished until MarkPublished.
+	Transition(ctx context.Context, id, from, to, reason string) (bool, error)
+	MarkPublished(ctx context.Context, id string) error
+	// FindUnpublished returns the ended payments whose event has not gone
+	// out.
+	FindUnpublished(ctx context.Context) ([]*model.Payment, error)
+	// AddRefund records a refund of a SUCCEEDED payment whose refunds with
+	// r added stay within its amount, and that has no refund with
+	// r.Reference yet.
+	AddRefund(ctx context.Context, id string, r model.Refund) error
+	// FindStale returns payments that have been in status since before t.
+	FindStale(ctx context.Context, status string, t time.Time) ([]*model.Payment, error)
+}
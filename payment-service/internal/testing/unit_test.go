@@ -0,0 +1,369 @@
+package testing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"payment-service/internal/model"
+	"payment-service/internal/orders"
+	"payment-service/internal/provider"
+	"payment-service/internal/repository"
+	"payment-service/internal/usecase"
+
+	"golang/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// 🔧 Mock repository
+type MockPaymentRepository struct {
+	mock.Mock
+}
+
+func (m *MockPaymentRepository) Create(ctx context.Context, p *model.Payment) (string, error) {
+	args := m.Called(ctx, p)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockPaymentRepository) FindByID(ctx context.Context, id string) (*model.Payment, error) {
+	args := m.Called(ctx, id)
+	p, _ := args.Get(0).(*model.Payment)
+	return p, args.Error(1)
+}
+
+func (m *MockPaymentRepository) FindByProviderRef(ctx context.Context, provider, ref string) (*model.Payment, error) {
+	args := m.Called(ctx, provider, ref)
+	p, _ := args.Get(0).(*model.Payment)
+	return p, args.Error(1)
+}
+
+func (m *MockPaymentRepository) FindByOrder(ctx context.Context, orderID string) (*model.Payment, error) {
+	args := m.Called(ctx, orderID)
+	p, _ := args.Get(0).(*model.Payment)
+	return p, args.Error(1)
+}
+
+func (m *MockPaymentRepository) Transition(ctx context.Context, id, from, to, reason string) (bool, error) {
+	args := m.Called(ctx, id, from, to, reason)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockPaymentRepository) MarkPublished(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepository) FindUnpublished(ctx context.Context) ([]*model.Payment, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]*model.Payment), args.Error(1)
+}
+
+func (m *MockPaymentRepository) AddRefund(ctx context.Context, id string, r model.Refund) error {
+	args := m.Called(ctx, id, r)
+	return args.Error(0)
+}
+
+func (m *MockPaymentRepository) FindStale(ctx context.Context, status string, t time.Time) ([]*model.Payment, error) {
+	args := m.Called(ctx, status, t)
+	return args.Get(0).([]*model.Payment), args.Error(1)
+}
+
+// 🔧 Mock order lookup
+type MockOrders struct {
+	mock.Mock
+}
+
+func (m *MockOrders) GetOrder(ctx context.Context, id string) (*orders.Order, error) {
+	args := m.Called(ctx, id)
+	o, _ := args.Get(0).(*orders.Order)
+	return o, args.Error(1)
+}
+
+// 🔧 Mock publisher
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) PublishPayment(ctx context.Context, p *model.Payment) error {
+	args := m.Called(ctx, p)
+	return args.Error(0)
+}
+
+func pendingOrder() *orders.Order {
+	return &orders.Order{ID: "order1", UserID: "user1", Total: money.New(2599, "USD"), Status: "PENDING"}
+}
+
+func TestFakeProvider(t *testing.T) {
+	fake := provider.NewFake(provider.Succeed, 0)
+	events := make(chan provider.Event, 3)
+	fake.Listen(func(_ context.Context, e provider.Event) { events <- e })
+
+	var refs []string
+	for range 3 {
+		ref, err := fake.CreateIntent(context.Background(), &model.Payment{})
+		assert.NoError(t, err)
+		refs = append(refs, ref)
+	}
+	assert.NoError(t, fake.Confirm(context.Background(), refs[0], "tok_visa"))
+	assert.Equal(t, provider.Event{Ref: refs[0], Succeeded: true}, <-events)
+
+	assert.NoError(t, fake.Confirm(context.Background(), refs[1], provider.MethodFail))
+	e := <-events
+	assert.False(t, e.Succeeded)
+	assert.NotEmpty(t, e.Reason)
+
+	// A payment that times out is never reported on.
+	assert.NoError(t, fake.Confirm(context.Background(), refs[2], provider.MethodTimeout))
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	assert.ErrorIs(t, fake.Confirm(context.Background(), "pi_other", provider.MethodSucceed), provider.ErrUnknownIntent)
+
+	_, err := provider.ParseOutcome("explode")
+	assert.Error(t, err)
+}
+
+func TestCreatePaymentIntent(t *testing.T) {
+	repo := new(MockPaymentRepository)
+	o := new(MockOrders)
+	uc := usecase.NewPaymentUsecase(repo, provider.NewFake(provider.Succeed, 0), o, new(MockPublisher))
+
+	o.On("GetOrder", mock.Anything, "order1").Return(pendingOrder(), nil)
+	repo.On("FindByOrder", mock.Anything, "order1").Return(nil, repository.ErrPaymentNotFound)
+	repo.On("Create", mock.Anything, mock.MatchedBy(func(p *model.Payment) bool {
+		return p.OrderID == "order1" && p.Status == model.StatusRequiresConfirmation && p.ProviderRef != ""
+	})).Return("pay1", nil)
+
+	p, err := uc.CreatePaymentIntent(context.Background(), "order1", "user1")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "pay1", p.ID)
+	assert.Equal(t, money.New(2599, "USD"), p.Amount)
+	assert.Equal(t, "fake", p.Provider)
+	repo.AssertExpectations(t)
+}
+
+func TestCreatePaymentIntent_ReturnsOpenPayment(t *testing.T) {
+	repo := new(MockPaymentRepository)
+	o := new(MockOrders)
+	uc := usecase.NewPaymentUsecase(repo, provider.NewFake(provider.Succeed, 0), o, new(MockPublisher))
+
+	existing := &model.Payment{ID: "pay1", OrderID: "order1", UserID: "user1", Status: model.StatusProcessing}
+	o.On("GetOrder", mock.Anything, "order1").Return(pendingOrder(), nil)
+	repo.On("FindByOrder", mock.Anything, "order1").Return(existing, nil)
+
+	p, err := uc.CreatePaymentIntent(context.Background(), "order1", "user1")
+
+	assert.NoError(t, err)
+	assert.Same(t, existing, p)
+
+	// A failed payment is not retried: it cancels its order.
+	existing.Status = model.StatusFailed
+	p, err = uc.CreatePaymentIntent(context.Background(), "order1", "user1")
+
+	assert.NoError(t, err)
+	assert.Same(t, existing, p)
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestCreatePaymentIntent_Rejected(t *testing.T) {
+	repo := new(MockPaymentRepository)
+	o := new(MockOrders)
+	uc := usecase.NewPaymentUsecase(repo, provider.NewFake(provider.Succeed, 0), o, new(MockPublisher))
+
+	completed := pendingOrder()
+	completed.ID, completed.Status = "order2", "COMPLETED"
+	o.On("GetOrder", mock.Anything, "order1").Return(pendingOrder(), nil)
+	o.On("GetOrder", mock.Anything, "order2").Return(completed, nil)
+	o.On("GetOrder", mock.Anything, "order3").Return(nil, errors.New("connection refused"))
+	repo.On("FindByOrder", mock.Anything, "order2").Return(nil, repository.ErrPaymentNotFound)
+
+	// Other users' orders are not found.
+	_, err := uc.CreatePaymentIntent(context.Background(), "order1", "user2")
+	assert.ErrorIs(t, err, orders.ErrOrderNotFound)
+
+	_, err = uc.CreatePaymentIntent(context.Background(), "order2", "user1")
+	assert.ErrorIs(t, err, usecase.ErrOrderNotPayable)
+
+	_, err = uc.CreatePaymentIntent(context.Background(), "order3", "user1")
+	assert.ErrorIs(t, err, usecase.ErrOrdersUnavailable)
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestConfirmPayment_PublishesOutcome(t *testing.T) {
+	repo := new(MockPaymentRepository)
+	pub := new(MockPublisher)
+	fake := provider.NewFake(provider.Fail, 0)
+	uc := usecase.NewPaymentUsecase(repo, fake, new(MockOrders), pub)
+
+	ref, _ := fake.CreateIntent(context.Background(), &model.Payment{})
+	p := &model.Payment{ID: "pay1", OrderID: "order1", UserID: "user1", Status: model.StatusRequiresConfirmation, Provider: "fake", ProviderRef: ref}
+	repo.On("FindByID", mock.Anything, "pay1").Return(p, nil)
+	repo.On("Transition", mock.Anything, "pay1", model.StatusRequiresConfirmation, model.StatusProcessing, "").Return(true, nil)
+	repo.On("FindByProviderRef", mock.Anything, "fake", ref).Return(&model.Payment{ID: "pay1", OrderID: "order1", Status: model.StatusProcessing}, nil)
+	repo.On("Transition", mock.Anything, "pay1", model.StatusProcessing, model.StatusSucceeded, "").Return(true, nil)
+	repo.On("MarkPublished", mock.Anything, "pay1").Return(nil)
+	published := make(chan *model.Payment, 1)
+	pub.On("PublishPayment", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		published <- args.Get(1).(*model.Payment)
+	})
+
+	// The payment method overrides the provider's default of failing.
+	got, err := uc.ConfirmPayment(context.Background(), "pay1", "user1", provider.MethodSucceed)
+	assert.NoError(t, err)
+	assert.Equal(t, model.StatusProcessing, got.Status)
+
+	select {
+	case p := <-published:
+		assert.Equal(t, model.StatusSucceeded, p.Status)
+		assert.Equal(t, "order1", p.OrderID)
+	case <-time.After(time.Second):
+		t.Fatal("payment outcome was not published")
+	}
+
+	// Another user cannot confirm the payment.
+	_, err = uc.ConfirmPayment(context.Background(), "pay1", "user2", provider.MethodSucceed)
+	assert.ErrorIs(t, err, repository.ErrPaymentNotFound)
+}
+
+func TestConfirmPayment_AlreadyConfirmed(t *testing.T) {
+	repo := new(MockPaymentRepository)
+	uc := usecase.NewPaymentUsecase(repo, provider.NewFake(provider.Succeed, 0), new(MockOrders), new(MockPublisher))
+
+	repo.On("FindByID", mock.Anything, "pay1").Return(&model.Payment{ID: "pay1", UserID: "user1", Status: model.StatusSucceeded}, nil)
+	repo.On("Transition", mock.Anything, "pay1", model.StatusRequiresConfirmation, model.StatusProcessing, "").Return(false, nil)
+
+	_, err := uc.ConfirmPayment(context.Background(), "pay1", "user1", provider.MethodSucceed)
+	assert.ErrorIs(t, err, usecase.ErrNotConfirmable)
+}
+
+func TestHandleProviderEvent_IgnoresDuplicates(t *testing.T) {
+	repo := new(MockPaymentRepository)
+	pub := new(MockPublisher)
+	uc := usecase.NewPaymentUsecase(repo, provider.NewFake(provider.Succeed, 0), new(MockOrders), pub)
+
+	repo.On("FindByProviderRef", mock.Anything, "fake", "ref1").Return(&model.Payment{ID: "pay1", Status: model.StatusSucceeded}, nil)
+	repo.On("Transition", mock.Anything, "pay1", model.StatusProcessing, model.StatusSucceeded, "").Return(false, nil)
+
+	uc.HandleProviderEvent(context.Background(), provider.Event{Ref: "ref1", Succeeded: true})
+
+	pub.AssertNotCalled(t, "PublishPayment", mock.Anything, mock.Anything)
+}
+
+func TestExpireStale(t *testing.T) {
+	repo := new(MockPaymentRepository)
+	pub := new(MockPublisher)
+	uc := usecase.NewPaymentUsecase(repo, provider.NewFake(provider.Timeout, 0), new(MockOrders), pub)
+
+	stale := []*model.Payment{
+		{ID: "pay1", OrderID: "order1", Status: model.StatusProcessing},
+		{ID: "pay2", OrderID: "order2", Status: model.StatusProcessing},
+		{ID: "pay3", OrderID: "order3", Status: model.StatusProcessing},
+	}
+	repo.On("FindStale", mock.Anything, model.StatusProcessing, mock.Anything).Return(stale, nil)
+	repo.On("Transition", mock.Anything, "pay1", model.StatusProcessing, model.StatusFailed, "payment timed out").Return(true, nil)
+	repo.On("MarkPublished", mock.Anything, "pay1").Return(nil)
+	pub.On("PublishPayment", mock.Anything, mock.MatchedBy(func(p *model.Payment) bool {
+		return p.ID == "pay1" && p.Status == model.StatusFailed && p.FailureReason == "payment timed out"
+	})).Return(nil)
+	// An error expiring one payment does not stop the others.
+	repo.On("Transition", mock.Anything, "pay2", model.StatusProcessing, model.StatusFailed, "payment timed out").Return(false, errors.New("connection reset"))
+	// An event that does not go out is left to PublishPending.
+	repo.On("Transition", mock.Anything, "pay3", model.StatusProcessing, model.StatusFailed, "payment timed out").Return(true, nil)
+	pub.On("PublishPayment", mock.Anything, mock.MatchedBy(func(p *model.Payment) bool { return p.ID == "pay3" })).Return(errors.New("no responders"))
+
+	n, err := uc.ExpireStale(context.Background(), 15*time.Minute)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	pub.AssertExpectations(t)
+	repo.AssertExpectations(t)
+	repo.AssertNotCalled(t, "MarkPublished", mock.Anything, "pay3")
+}
+
+func TestPublishPending(t *testing.T) {
+	repo := new(MockPaymentRepository)
+	pub := new(MockPublisher)
+	uc := usecase.NewPaymentUsecase(repo, provider.NewFake(provider.Succeed, 0), new(MockOrders), pub)
+
+	unpublished := []*model.Payment{
+		{ID: "pay1", OrderID: "order1", Status: model.StatusSucceeded},
+		{ID: "pay2", OrderID: "order2", Status: model.StatusFailed},
+	}
+	repo.On("FindUnpublished", mock.Anything).Return(unpublished, nil)
+	pub.On("PublishPayment", mock.Anything, unpublished[0]).Return(nil)
+	pub.On("PublishPayment", mock.Anything, unpublished[1]).Return(errors.New("no responders"))
+	repo.On("MarkPublished", mock.Anything, "pay1").Return(nil)
+
+	n, err := uc.PublishPending(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, unpublished[0].Published)
+	assert.False(t, unpublished[1].Published)
+	repo.AssertExpectations(t)
+}
+
+func succeededPayment() *model.Payment {
+	return &model.Payment{
+		ID:          "pay1",
+		OrderID:     "order1",
+		UserID:      "user1",
+		Amount:      money.New(2599, "USD"),
+		Refunded:    money.New(599, "USD"),
+		Status:      model.StatusSucceeded,
+		Provider:    "fake",
+		ProviderRef: "fake_pi_1",
+		Refunds:     []model.Refund{{Reference: "return1", Amount: money.New(599, "USD")}},
+	}
+}
+
+func TestRefundPayment(t *testing.T) {
+	repo := new(MockPaymentRepository)
+	uc := usecase.NewPaymentUsecase(repo, provider.NewFake(provider.Succeed, 0), new(MockOrders), new(MockPublisher))
+
+	refunded := succeededPayment()
+	refunded.Refunded = money.New(2599, "USD")
+	repo.On("FindByID", mock.Anything, "pay1").Return(succeededPayment(), nil).Once()
+	repo.On("AddRefund", mock.Anything, "pay1", mock.MatchedBy(func(r model.Refund) bool {
+		return r.Reference == "return2" && r.Amount == money.New(2000, "USD") && r.ProviderRef != ""
+	})).Return(nil)
+	repo.On("FindByID", mock.Anything, "pay1").Return(refunded, nil).Once()
+
+	p, err := uc.RefundPayment(context.Background(), "pay1", money.New(2000, "USD"), "return2", "damaged")
+
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(2599, "USD"), p.Refunded)
+	repo.AssertExpectations(t)
+}
+
+func TestRefundPayment_Rejected(t *testing.T) {
+	repo := new(MockPaymentRepository)
+	uc := usecase.NewPaymentUsecase(repo, provider.NewFake(provider.Succeed, 0), new(MockOrders), new(MockPublisher))
+
+	failed := succeededPayment()
+	failed.ID, failed.Status = "pay2", model.StatusFailed
+	repo.On("FindByID", mock.Anything, "pay1").Return(succeededPayment(), nil)
+	repo.On("FindByID", mock.Anything, "pay2").Return(failed, nil)
+
+	// Refunding a reference again changes nothing.
+	p, err := uc.RefundPayment(context.Background(), "pay1", money.New(599, "USD"), "return1", "")
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(599, "USD"), p.Refunded)
+
+	_, err = uc.RefundPayment(context.Background(), "pay1", money.New(2001, "USD"), "return2", "")
+	assert.ErrorIs(t, err, usecase.ErrRefundTooLarge)
+
+	_, err = uc.RefundPayment(context.Background(), "pay1", money.New(100, "EUR"), "return2", "")
+	assert.Error(t, err)
+
+	_, err = uc.RefundPayment(context.Background(), "pay2", money.New(100, "USD"), "return2", "")
+	assert.ErrorIs(t, err, usecase.ErrNotRefundable)
+	repo.AssertNotCalled(t, "AddRefund", mock.Anything, mock.Anything, mock.Anything)
+}
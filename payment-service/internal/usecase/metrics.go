@@ -0,0 +1,16 @@
+package usecase
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Instruments are created from the global meter, which delegates to the
+// provider installed by telemetry.InitMetrics once it is configured.
+var meter = otel.Meter("payment-service")
+
+// paymentsFinished counts payments that succeeded or failed, by status.
+var paymentsFinished, _ = meter.Int64Counter(
+	"payments.finished",
+	metric.WithDescription("Number of payments that succeeded or failed"),
+)
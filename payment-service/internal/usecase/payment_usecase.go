@@ -0,0 +1,325 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	queue "payment-service/internal/events"
+	"payment-service/internal/model"
+	"payment-service/internal/orders"
+	"payment-service/internal/provider"
+	"payment-service/internal/repository"
+
+	"golang/pkg/money"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var (
+	// ErrOrderNotPayable is returned for orders that are not PENDING.
+	ErrOrderNotPayable = errors.New("only pending orders can be paid")
+	// ErrNothingToPay is returned for orders whose total is not positive.
+	ErrNothingToPay = errors.New("order has nothing to pay")
+	// ErrNotConfirmable is returned when confirming a payment that was
+	// already confirmed.
+	ErrNotConfirmable = errors.New("payment was already confirmed")
+	// ErrNotRefundable is returned for refunds of payments that have not
+	// succeeded.
+	ErrNotRefundable = errors.New("only succeeded payments can be refunded")
+	// ErrRefundTooLarge is returned for refunds of more than is left of a
+	// payment.
+	ErrRefundTooLarge = errors.New("refund exceeds what is left of the payment")
+	// ErrOrdersUnavailable means the order to pay could not be looked up.
+	ErrOrdersUnavailable = errors.New("order service unavailable")
+	// ErrProviderUnavailable means the payment provider could not be reached.
+	ErrProviderUnavailable = errors.New("payment provider unavailable")
+)
+
+// timeoutReason is the failure reason of payments the provider never
+// reported on.
+const timeoutReason = "payment timed out"
+
+type PaymentUsecase struct {
+	repo      repository.PaymentRepository
+	provider  provider.PaymentProvider
+	orders    orders.Orders
+	publisher queue.Publisher
+}
+
+// NewPaymentUsecase creates the payment usecase and has it receive the
+// provider's events.
+func NewPaymentUsecase(repo repository.PaymentRepository, p provider.PaymentProvider, o orders.Orders, publisher queue.Publisher) *PaymentUsecase {
+	u := &PaymentUsecase{repo: repo, provider: p, orders: o, publisher: publisher}
+	p.Listen(u.HandleProviderEvent)
+	return u
+}
+
+// CreatePaymentIntent creates the payment of a user's PENDING order, for the
+// order's total. An order has one payment: once it has one, that one is
+// returned. A payment that fails cancels its order, so it is not retried.
+func (u *PaymentUsecase) CreatePaymentIntent(ctx context.Context, orderID, userID string) (*model.Payment, error) {
+	if orderID == "" || userID == "" {
+		return nil, errors.New("order_id and user_id are required")
+	}
+	order, err := u.orders.GetOrder(ctx, orderID)
+	if errors.Is(err, orders.ErrOrderNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOrdersUnavailable, err)
+	}
+	if order.UserID != userID {
+		return nil, orders.ErrOrderNotFound
+	}
+
+	existing, err := u.repo.FindByOrder(ctx, orderID)
+	if err == nil {
+		return existing, nil
+	}
+	if err != nil && !errors.Is(err, repository.ErrPaymentNotFound) {
+		return nil, err
+	}
+	if order.Status != "PENDING" {
+		return nil, ErrOrderNotPayable
+	}
+	if order.Total.Amount <= 0 {
+		return nil, ErrNothingToPay
+	}
+
+	now := time.Now().UTC()
+	p := &model.Payment{
+		OrderID:   orderID,
+		UserID:    userID,
+		Amount:    order.Total,
+		Status:    model.StatusRequiresConfirmation,
+		Provider:  u.provider.Name(),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if p.ProviderRef, err = u.provider.CreateIntent(ctx, p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	p.ID, err = u.repo.Create(ctx, p)
+	if errors.Is(err, repository.ErrOrderHasPayment) {
+		// A concurrent request created it first.
+		return u.repo.FindByOrder(ctx, orderID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "payment intent created", "payment_id", p.ID, "order_id", orderID, "amount", p.Amount.String())
+	return p, nil
+}
+
+// GetPayment returns a payment. When userID is set, other users' payments
+// are not found.
+func (u *PaymentUsecase) GetPayment(ctx context.Context, id, userID string) (*model.Payment, error) {
+	if id == "" {
+		return nil, errors.New("id is required")
+	}
+	p, err := u.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if userID != "" && p.UserID != userID {
+		return nil, repository.ErrPaymentNotFound
+	}
+	return p, nil
+}
+
+// GetOrderPayment returns the payment of an order, as FindByOrder picks it.
+// When userID is set, other users' payments are not found.
+func (u *PaymentUsecase) GetOrderPayment(ctx context.Context, orderID, userID string) (*model.Payment, error) {
+	if orderID == "" {
+		return nil, errors.New("order_id is required")
+	}
+	p, err := u.repo.FindByOrder(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if userID != "" && p.UserID != userID {
+		return nil, repository.ErrPaymentNotFound
+	}
+	return p, nil
+}
+
+// ConfirmPayment starts charging a payment with a payment method. The
+// payment is PROCESSING until the provider reports how it ended.
+func (u *PaymentUsecase) ConfirmPayment(ctx context.Context, id, userID, method string) (*model.Payment, error) {
+	if method == "" {
+		return nil, errors.New("payment_method is required")
+	}
+	p, err := u.GetPayment(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := u.repo.Transition(ctx, p.ID, model.StatusRequiresConfirmation, model.StatusProcessing, "")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotConfirmable
+	}
+	if err := u.provider.Confirm(ctx, p.ProviderRef, method); err != nil {
+		// The charge did not start, so the payment can be confirmed again.
+		if _, rerr := u.repo.Transition(ctx, p.ID, model.StatusProcessing, model.StatusRequiresConfirmation, ""); rerr != nil {
+			slog.ErrorContext(ctx, "failed to reopen payment", "payment_id", p.ID, "error", rerr)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	p.Status = model.StatusProcessing
+	return p, nil
+}
+
+// RefundPayment gives amount of a succeeded payment back. The reference names
+// what the refund is for; refunding a reference again returns the payment
+// as it is, so failed calls can be retried.
+func (u *PaymentUsecase) RefundPayment(ctx context.Context, id string, amount money.Money, reference, reason string) (*model.Payment, error) {
+	if reference == "" {
+		return nil, errors.New("reference is required")
+	}
+	if amount.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+	p, err := u.GetPayment(ctx, id, "")
+	if err != nil {
+		return nil, err
+	}
+	if p.RefundFor(reference) != nil {
+		return p, nil
+	}
+	if p.Status != model.StatusSucceeded {
+		return nil, ErrNotRefundable
+	}
+	if amount.Currency != p.Amount.Currency {
+		return nil, fmt.Errorf("refund must be in %s", p.Amount.Currency)
+	}
+	if amount.Amount > p.Amount.Amount-p.Refunded.Amount {
+		return nil, ErrRefundTooLarge
+	}
+
+	providerRef, err := u.provider.Refund(ctx, p.ProviderRef, amount, p.ID+"/"+reference)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProviderUnavailable, err)
+	}
+	err = u.repo.AddRefund(ctx, p.ID, model.Refund{
+		Reference:   reference,
+		Amount:      amount,
+		Reason:      reason,
+		ProviderRef: providerRef,
+		CreatedAt:   time.Now().UTC(),
+	})
+	if errors.Is(err, repository.ErrRefundRejected) {
+		// A concurrent refund was recorded first.
+		if p, err = u.repo.FindByID(ctx, p.ID); err != nil {
+			return nil, err
+		}
+		if p.RefundFor(reference) != nil {
+			return p, nil
+		}
+		slog.ErrorContext(ctx, "refund made by the provider exceeds the payment", "payment_id", p.ID, "reference", reference, "provider_ref", providerRef)
+		return nil, ErrRefundTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "payment refunded", "payment_id", p.ID, "order_id", p.OrderID, "amount", amount.String(), "reference", reference)
+	return u.repo.FindByID(ctx, p.ID)
+}
+
+// HandleProviderEvent ends the payment a provider event is about.
+func (u *PaymentUsecase) HandleProviderEvent(ctx context.Context, e provider.Event) {
+	p, err := u.repo.FindByProviderRef(ctx, u.provider.Name(), e.Ref)
+	if err != nil {
+		slog.ErrorContext(ctx, "provider event for unknown payment", "provider", u.provider.Name(), "ref", e.Ref, "error", err)
+		return
+	}
+	if err := u.finish(ctx, p, e.Succeeded, e.Reason); err != nil {
+		slog.ErrorContext(ctx, "failed to finish payment", "payment_id", p.ID, "error", err)
+	}
+}
+
+// ExpireStale fails payments that have been PROCESSING for longer than
+// timeout without the provider reporting on them, and returns how many it
+// failed.
+func (u *PaymentUsecase) ExpireStale(ctx context.Context, timeout time.Duration) (int, error) {
+	stale, err := u.repo.FindStale(ctx, model.StatusProcessing, time.Now().UTC().Add(-timeout))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, p := range stale {
+		if err := u.finish(ctx, p, false, timeoutReason); err != nil {
+			slog.ErrorContext(ctx, "failed to expire payment", "payment_id", p.ID, "error", err)
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
+// PublishPending publishes the events of ended payments that did not go out
+// when they ended, and returns how many it published.
+func (u *PaymentUsecase) PublishPending(ctx context.Context) (int, error) {
+	payments, err := u.repo.FindUnpublished(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, p := range payments {
+		if err := u.publish(ctx, p); err != nil {
+			slog.WarnContext(ctx, "failed to publish payment event", "payment_id", p.ID, "order_id", p.OrderID, "error", err)
+			continue
+		}
+		n++
+	}
+	return n, nil
+}
+
+// finish moves a PROCESSING payment to SUCCEEDED or FAILED and announces it.
+// Payments that are not PROCESSING are left alone, so duplicate events
+// change nothing. An announcement that fails is left to PublishPending.
+func (u *PaymentUsecase) finish(ctx context.Context, p *model.Payment, succeeded bool, reason string) error {
+	to := model.StatusFailed
+	if succeeded {
+		to, reason = model.StatusSucceeded, ""
+	}
+	ok, err := u.repo.Transition(ctx, p.ID, model.StatusProcessing, to, reason)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		if succeeded && p.Status == model.StatusFailed {
+			// The provider charged a payment after it timed out here.
+			slog.ErrorContext(ctx, "failed payment was charged and needs a refund", "payment_id", p.ID, "order_id", p.OrderID)
+		} else {
+			slog.WarnContext(ctx, "provider event for a payment that is not processing ignored", "payment_id", p.ID, "status", p.Status)
+		}
+		return nil
+	}
+
+	p.Status, p.FailureReason = to, reason
+	paymentsFinished.Add(ctx, 1, metric.WithAttributes(attribute.String("status", to)))
+	slog.InfoContext(ctx, "payment finished", "payment_id", p.ID, "order_id", p.OrderID, "status", to, "reason", reason)
+	if err := u.publish(ctx, p); err != nil {
+		slog.WarnContext(ctx, "failed to publish payment event, will retry", "payment_id", p.ID, "order_id", p.OrderID, "error", err)
+	}
+	return nil
+}
+
+// publish announces how p ended and records that it did.
+func (u *PaymentUsecase) publish(ctx context.Context, p *model.Payment) error {
+	if err := u.publisher.PublishPayment(ctx, p); err != nil {
+		return err
+	}
+	if err := u.repo.MarkPublished(ctx, p.ID); err != nil {
+		// Published again later, which consumers ignore.
+		return err
+	}
+	p.Published = true
+	return nil
+}
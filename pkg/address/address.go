@@ -0,0 +1,134 @@
+// Package address represents postal addresses and checks that they carry
+// what the post of their country needs to deliver to them.
+package address
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalid is wrapped by the errors of Validate.
+var ErrInvalid = errors.New("invalid address")
+
+// maxFieldLength caps every field, in bytes.
+const maxFieldLength = 200
+
+// Address is a postal address.
+type Address struct {
+	Name       string `json:"name"` // recipient
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2,omitempty"`
+	City       string `json:"city"`
+	Region     string `json:"region,omitempty"` // state, province or prefecture
+	PostalCode string `json:"postal_code,omitempty"`
+	Country    string `json:"country"` // ISO 3166-1 alpha-2 code, e.g. "US"
+	Phone      string `json:"phone,omitempty"`
+}
+
+// country is what addresses in one country need besides a name, a street
+// line, a city and the country.
+type country struct {
+	// region is set where the region is required and written as the code
+	// of an ISO 3166-2 subdivision, e.g. "CA" for California.
+	region bool
+	// postalCode matches the country's postal codes; nil where they are
+	// optional.
+	postalCode *regexp.Regexp
+}
+
+var countries = map[string]country{
+	"AU": {region: true, postalCode: regexp.MustCompile(`^\d{4}$`)},
+	"CA": {region: true, postalCode: regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`)},
+	"DE": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"FR": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"GB": {postalCode: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`)},
+	"KZ": {postalCode: regexp.MustCompile(`^(\d{6}|[A-Z]\d{2}[A-Z]\d[A-Z]\d)$`)},
+	"RU": {postalCode: regexp.MustCompile(`^\d{6}$`)},
+	"US": {region: true, postalCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`)},
+}
+
+var (
+	countryPattern     = regexp.MustCompile(`^[A-Z]{2}$`)
+	subdivisionPattern = regexp.MustCompile(`^[A-Z0-9]{1,3}$`)
+	phonePattern       = regexp.MustCompile(`^\+?[0-9 ()\-]{5,20}$`)
+)
+
+// Normalize returns a with surrounding spaces trimmed and the country, and
+// the region and postal code where the country uses codes, in upper case.
+func (a Address) Normalize() Address {
+	for _, f := range a.fields() {
+		*f.value = strings.TrimSpace(*f.value)
+	}
+	a.Country = strings.ToUpper(a.Country)
+	a.PostalCode = strings.ToUpper(a.PostalCode)
+	if countries[a.Country].region {
+		a.Region = strings.ToUpper(a.Region)
+	}
+	return a
+}
+
+// Validate checks a normalized address: every address needs a name, a
+// street line, a city and a country, and the countries that require them
+// also need a region or a valid postal code.
+func (a Address) Validate() error {
+	for _, f := range a.fields() {
+		if len(*f.value) > maxFieldLength {
+			return fmt.Errorf("%w: %s must be at most %d characters", ErrInvalid, f.name, maxFieldLength)
+		}
+	}
+	for _, f := range []struct{ name, value string }{
+		{"name", a.Name}, {"line1", a.Line1}, {"city", a.City}, {"country", a.Country},
+	} {
+		if f.value == "" {
+			return fmt.Errorf("%w: %s is required", ErrInvalid, f.name)
+		}
+	}
+	if !countryPattern.MatchString(a.Country) {
+		return fmt.Errorf("%w: country must be an ISO 3166-1 alpha-2 code such as US", ErrInvalid)
+	}
+	if a.Phone != "" && !phonePattern.MatchString(a.Phone) {
+		return fmt.Errorf("%w: phone %q is not a phone number", ErrInvalid, a.Phone)
+	}
+
+	rules, ok := countries[a.Country]
+	if !ok {
+		return nil
+	}
+	if rules.region && !subdivisionPattern.MatchString(a.Region) {
+		return fmt.Errorf("%w: region must be the code of a %s subdivision, e.g. a state", ErrInvalid, a.Country)
+	}
+	if rules.postalCode != nil {
+		if a.PostalCode == "" {
+			return fmt.Errorf("%w: postal_code is required in %s", ErrInvalid, a.Country)
+		}
+		if !rules.postalCode.MatchString(a.PostalCode) {
+			return fmt.Errorf("%w: %q is not a %s postal code", ErrInvalid, a.PostalCode, a.Country)
+		}
+	}
+	return nil
+}
+
+// Subdivision returns the ISO 3166 code of where the address is: its
+// country and region, e.g. "US-CA", where the region is a subdivision code,
+// and otherwise its country.
+func (a Address) Subdivision() string {
+	if countries[a.Country].region && subdivisionPattern.MatchString(a.Region) {
+		return a.Country + "-" + a.Region
+	}
+	return a.Country
+}
+
+func (a *Address) fields() []struct {
+	name  string
+	value *string
+} {
+	return []struct {
+		name  string
+		value *string
+	}{
+		{"name", &a.Name}, {"line1", &a.Line1}, {"line2", &a.Line2}, {"city", &a.City},
+		{"region", &a.Region}, {"postal_code", &a.PostalCode}, {"country", &a.Country}, {"phone", &a.Phone},
+	}
+}
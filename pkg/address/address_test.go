@@ -0,0 +1,90 @@
+package address_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"golang/pkg/address"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validUS() address.Address {
+	return address.Address{
+		Name:       "Ada Lovelace",
+		Line1:      "1 Infinite Loop",
+		City:       "Cupertino",
+		Region:     "CA",
+		PostalCode: "95014",
+		Country:    "US",
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	a := address.Address{
+		Name:       " Ada ",
+		Line1:      "1 Main St ",
+		City:       "Toronto",
+		Region:     "on",
+		PostalCode: "m5v 2t6",
+		Country:    " ca",
+	}.Normalize()
+	assert.Equal(t, "Ada", a.Name)
+	assert.Equal(t, "1 Main St", a.Line1)
+	assert.Equal(t, "CA", a.Country)
+	assert.Equal(t, "ON", a.Region)
+	assert.Equal(t, "M5V 2T6", a.PostalCode)
+	assert.NoError(t, a.Validate())
+
+	// Regions are kept as written where the country has no region codes.
+	a = address.Address{Region: "Greater London", Country: "gb"}.Normalize()
+	assert.Equal(t, "Greater London", a.Region)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, validUS().Validate())
+
+	cases := map[string]func(a *address.Address){
+		"missing name":         func(a *address.Address) { a.Name = "" },
+		"missing line1":        func(a *address.Address) { a.Line1 = "" },
+		"missing city":         func(a *address.Address) { a.City = "" },
+		"missing country":      func(a *address.Address) { a.Country = "" },
+		"bad country":          func(a *address.Address) { a.Country = "USA" },
+		"missing US region":    func(a *address.Address) { a.Region = "" },
+		"US region not a code": func(a *address.Address) { a.Region = "California" },
+		"missing US zip":       func(a *address.Address) { a.PostalCode = "" },
+		"bad US zip":           func(a *address.Address) { a.PostalCode = "9501" },
+		"bad phone":            func(a *address.Address) { a.Phone = "call me" },
+		"too long":             func(a *address.Address) { a.Line2 = strings.Repeat("x", 201) },
+	}
+	for name, change := range cases {
+		a := validUS()
+		change(&a)
+		err := a.Validate()
+		assert.True(t, errors.Is(err, address.ErrInvalid), "%s: %v", name, err)
+	}
+
+	zip4 := validUS()
+	zip4.PostalCode = "95014-2083"
+	zip4.Phone = "+1 (408) 996-1010"
+	assert.NoError(t, zip4.Validate())
+}
+
+func TestValidate_CountryRules(t *testing.T) {
+	// No region needed in Germany, but a five-digit postal code is.
+	de := address.Address{Name: "Max", Line1: "Unter den Linden 1", City: "Berlin", PostalCode: "10117", Country: "DE"}
+	assert.NoError(t, de.Validate())
+	de.PostalCode = "ABC"
+	assert.Error(t, de.Validate())
+
+	// Countries without rules only need the common fields.
+	ie := address.Address{Name: "Sean", Line1: "1 Main St", City: "Galway", Country: "IE"}
+	assert.NoError(t, ie.Validate())
+}
+
+func TestSubdivision(t *testing.T) {
+	assert.Equal(t, "US-CA", validUS().Subdivision())
+	assert.Equal(t, "GB", address.Address{Region: "Kent", Country: "GB"}.Subdivision())
+	assert.Equal(t, "IE", address.Address{Region: "G", Country: "IE"}.Subdivision())
+}
@@ -0,0 +1,54 @@
+// Package blob stores opaque objects, such as uploaded images, under string
+// keys. It defines the BlobStore interface implemented by a local-filesystem
+// and an S3-compatible backend.
+package blob
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// ErrNotFound is returned by BlobStore.Get when nothing is stored under key.
+var ErrNotFound = errors.New("blob: not found")
+
+// BlobStore is a flat key/value store for objects served over HTTP.
+type BlobStore interface {
+	// Put stores data under key, replacing any previous object.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns the object stored under key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes the object under key. A missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the address clients fetch the object from.
+	URL(key string) string
+}
+
+// validKey reports whether key is a relative slash-separated path without
+// empty, "." or ".." segments, so that it cannot escape the store's root.
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key {
+		return false
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "." || seg == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// escapeKey URL-escapes every segment of key.
+func escapeKey(key string) string {
+	segs := strings.Split(key, "/")
+	for i, s := range segs {
+		segs[i] = url.PathEscape(s)
+	}
+	return strings.Join(segs, "/")
+}
+
+func invalidKey(key string) error {
+	return errors.New("blob: invalid key " + key)
+}
@@ -0,0 +1,64 @@
+package blob_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang/pkg/blob"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore_PutGetDelete(t *testing.T) {
+	ctx := context.Background()
+	s, err := blob.NewFileStore(t.TempDir(), "http://media.test/media/")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Put(ctx, "products/p1/a b.jpg", []byte("jpeg"), "image/jpeg"))
+	data, err := s.Get(ctx, "products/p1/a b.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, "jpeg", string(data))
+	assert.Equal(t, "http://media.test/media/products/p1/a%20b.jpg", s.URL("products/p1/a b.jpg"))
+
+	require.NoError(t, s.Delete(ctx, "products/p1/a b.jpg"))
+	_, err = s.Get(ctx, "products/p1/a b.jpg")
+	assert.ErrorIs(t, err, blob.ErrNotFound)
+	assert.NoError(t, s.Delete(ctx, "products/p1/a b.jpg"))
+}
+
+func TestFileStore_RejectsKeysOutsideRoot(t *testing.T) {
+	ctx := context.Background()
+	s, err := blob.NewFileStore(t.TempDir(), "http://media.test")
+	require.NoError(t, err)
+
+	for _, key := range []string{"", "/etc/passwd", "../x", "a/../../x", "a//b", "a/./b"} {
+		assert.Error(t, s.Put(ctx, key, []byte("x"), ""), key)
+	}
+}
+
+func TestFileStore_Handler(t *testing.T) {
+	ctx := context.Background()
+	s, err := blob.NewFileStore(t.TempDir(), "http://media.test")
+	require.NoError(t, err)
+	require.NoError(t, s.Put(ctx, "products/p1/img.png", []byte("png"), "image/png"))
+
+	srv := httptest.NewServer(http.StripPrefix("/media", s.Handler()))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/media/products/p1/img.png")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "png", string(body))
+
+	// Directories are not listed.
+	resp, err = http.Get(srv.URL + "/media/products/p1/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,238 @@
+// Package cache is the caching layer shared by the services. It defines the
+// Cache interface implemented by a Redis and an in-process LRU backend, and
+// GetOrLoad, which adds stampede protection, negative caching, jittered TTLs
+// and hit/miss metrics on top of any backend.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"reflect"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrMiss is returned by Cache.Get when the key is not cached.
+var ErrMiss = errors.New("cache: miss")
+
+// ErrNotFound is matched by the errors GetOrLoad returns for cached "not
+// found" results.
+var ErrNotFound = errors.New("cache: not found")
+
+// notFoundError is a cached "not found" result. It keeps the message of the
+// error it was cached for.
+type notFoundError struct {
+	msg string
+}
+
+func (e *notFoundError) Error() string { return e.msg }
+
+func (e *notFoundError) Is(target error) bool { return target == ErrNotFound }
+
+// Cache is a byte-oriented key/value store with per-key expiry.
+type Cache interface {
+	// Get returns the value stored under key, or ErrMiss.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the given keys. Missing keys are ignored.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Swapper is a Cache that can also replace a value atomically, for callers
+// that read, modify and write back a key and must not overwrite a concurrent
+// write.
+type Swapper interface {
+	Cache
+	// CompareAndSwap stores value under key for ttl if key still holds old,
+	// or, when old is nil, if key is missing. It reports whether it stored.
+	CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error)
+}
+
+// Entries are stored with a one byte tag so that cached "not found" results
+// can be told apart from values.
+const (
+	tagValue    byte = 'v'
+	tagNotFound byte = 'n'
+)
+
+// DefaultJitter is the fraction by which TTLs are randomly spread so that keys
+// written together do not all expire together.
+const DefaultJitter = 0.1
+
+type options struct {
+	jitter      float64
+	isNotFound  func(error) bool
+	negativeTTL time.Duration
+}
+
+// Option configures GetOrLoad.
+type Option func(*options)
+
+// WithJitter overrides DefaultJitter. Zero disables jitter.
+func WithJitter(fraction float64) Option {
+	return func(o *options) { o.jitter = fraction }
+}
+
+// WithNegativeCaching caches load errors matching isNotFound for ttl, so that
+// repeated lookups of missing IDs do not all reach the database. Cached
+// misses are returned as errors matching ErrNotFound that carry the original
+// error message.
+func WithNegativeCaching(isNotFound func(error) bool, ttl time.Duration) Option {
+	return func(o *options) {
+		o.isNotFound = isNotFound
+		o.negativeTTL = ttl
+	}
+}
+
+// loads deduplicates concurrent loads of the same key and type within the
+// process. Loads share the encoded value, which every caller decodes.
+var loads singleflight.Group
+
+// GetOrLoad returns the value cached under key, or calls load, caches its
+// result for ttl and returns it. Concurrent callers asking for the same
+// missing key share a single call to load, but each gets its own copy of the
+// value. Backend failures are logged and treated as misses, so an
+// unavailable cache only costs latency.
+func GetOrLoad[T any](ctx context.Context, c Cache, key string, ttl time.Duration, load func(context.Context) (T, error), opts ...Option) (T, error) {
+	o := options{jitter: DefaultJitter}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if v, err, ok := lookup[T](ctx, c, key); ok {
+		return v, err
+	}
+
+	// The type is part of the load's key: callers reading the same key as
+	// different types must not be handed each other's values.
+	res, err, _ := loads.Do(reflect.TypeFor[T]().String()+":"+key, func() (any, error) {
+		// Waiters share this load, so it must not be cut short by the
+		// first caller going away.
+		ctx := context.WithoutCancel(ctx)
+		v, err := load(ctx)
+		if err != nil {
+			if o.isNotFound != nil && o.isNotFound(err) {
+				store(ctx, c, key, append([]byte{tagNotFound}, err.Error()...), jitter(o.negativeTTL, o.jitter))
+			}
+			return nil, err
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		store(ctx, c, key, append([]byte{tagValue}, data...), jitter(ttl, o.jitter))
+		return data, nil
+	})
+	var v T
+	if err != nil {
+		return v, err
+	}
+	if err := json.Unmarshal(res.([]byte), &v); err != nil {
+		return v, err
+	}
+	return v, nil
+}
+
+// Set stores v under key for ttl in the format GetOrLoad reads, e.g. to write
+// through the result of a committed update instead of deleting the key.
+func Set[T any](ctx context.Context, c Cache, key string, v T, ttl time.Duration, opts ...Option) error {
+	o := options{jitter: DefaultJitter}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return set(ctx, c, key, v, ttl, o)
+}
+
+func set[T any](ctx context.Context, c Cache, key string, v T, ttl time.Duration, o options) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.Set(ctx, key, append([]byte{tagValue}, data...), jitter(ttl, o.jitter))
+}
+
+// SetIfNewer stores v under key for ttl like Set, unless the key holds a value
+// that newer reports v is not newer than. The read and the write go through
+// CompareAndSwap, so a slow writer cannot replace a later value it did not
+// see. It reports whether v was stored; false also means another writer
+// changed the key in between.
+func SetIfNewer[T any](ctx context.Context, s Swapper, key string, v T, ttl time.Duration, newer func(cached T) bool, opts ...Option) (bool, error) {
+	o := options{jitter: DefaultJitter}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return false, err
+	}
+
+	old, err := s.Get(ctx, key)
+	switch {
+	case errors.Is(err, ErrMiss):
+		old = nil
+	case err != nil:
+		return false, err
+	case len(old) > 0 && old[0] == tagValue:
+		var cached T
+		if err := json.Unmarshal(old[1:], &cached); err == nil && !newer(cached) {
+			return false, nil
+		}
+	}
+	return s.CompareAndSwap(ctx, key, old, append([]byte{tagValue}, data...), jitter(ttl, o.jitter))
+}
+
+// lookup reads key from the cache. ok is false when the caller should load
+// the value from the source.
+func lookup[T any](ctx context.Context, c Cache, key string) (v T, err error, ok bool) {
+	data, err := c.Get(ctx, key)
+	switch {
+	case errors.Is(err, ErrMiss):
+		recordLookup(ctx, key, resultMiss)
+		return v, nil, false
+	case err != nil:
+		slog.WarnContext(ctx, "cache lookup failed, loading from source", "key", key, "error", err)
+		recordLookup(ctx, key, resultError)
+		return v, nil, false
+	case len(data) == 0:
+		recordLookup(ctx, key, resultMiss)
+		return v, nil, false
+	}
+
+	switch data[0] {
+	case tagNotFound:
+		recordLookup(ctx, key, resultHit)
+		return v, &notFoundError{msg: string(data[1:])}, true
+	case tagValue:
+		if err := json.Unmarshal(data[1:], &v); err != nil {
+			slog.WarnContext(ctx, "failed to unmarshal cache value", "key", key, "error", err)
+			recordLookup(ctx, key, resultMiss)
+			return v, nil, false
+		}
+		recordLookup(ctx, key, resultHit)
+		return v, nil, true
+	default:
+		// Written by an older version of the service; reload and overwrite.
+		recordLookup(ctx, key, resultMiss)
+		return v, nil, false
+	}
+}
+
+func store(ctx context.Context, c Cache, key string, data []byte, ttl time.Duration) {
+	if err := c.Set(ctx, key, data, ttl); err != nil {
+		slog.WarnContext(ctx, "failed to set cache key", "key", key, "error", err)
+	}
+}
+
+// jitter spreads ttl uniformly by ±fraction.
+func jitter(ttl time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 || ttl <= 0 {
+		return ttl
+	}
+	spread := float64(ttl) * fraction
+	return ttl + time.Duration((rand.Float64()*2-1)*spread)
+}
@@ -0,0 +1,291 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang/pkg/cache"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	Name string
+}
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(2)
+
+	_ = c.Set(ctx, "a", []byte("1"), 0)
+	_ = c.Set(ctx, "b", []byte("2"), 0)
+	_, _ = c.Get(ctx, "a") // a is now more recent than b
+	_ = c.Set(ctx, "c", []byte("3"), 0)
+
+	_, err := c.Get(ctx, "b")
+	assert.ErrorIs(t, err, cache.ErrMiss)
+	v, err := c.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), v)
+}
+
+func TestLRU_Expires(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+
+	_ = c.Set(ctx, "a", []byte("1"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	_, err := c.Get(ctx, "a")
+	assert.ErrorIs(t, err, cache.ErrMiss)
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	backends := map[string]cache.Swapper{
+		"lru":   cache.NewLRU(10),
+		"redis": cache.NewRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()})),
+	}
+	for name, c := range backends {
+		t.Run(name, func(t *testing.T) {
+			ok, err := c.CompareAndSwap(ctx, "a", nil, []byte("1"), time.Minute)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+
+			// Another writer got there first.
+			ok, err = c.CompareAndSwap(ctx, "a", nil, []byte("2"), time.Minute)
+			assert.NoError(t, err)
+			assert.False(t, ok)
+			ok, err = c.CompareAndSwap(ctx, "a", []byte("0"), []byte("2"), time.Minute)
+			assert.NoError(t, err)
+			assert.False(t, ok)
+
+			ok, err = c.CompareAndSwap(ctx, "a", []byte("1"), []byte("2"), time.Minute)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			v, err := c.Get(ctx, "a")
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("2"), v)
+		})
+	}
+}
+
+func TestGetOrLoad_CachesValue(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+	var calls int
+	load := func(context.Context) (*item, error) {
+		calls++
+		return &item{Name: "widget"}, nil
+	}
+
+	first, err := cache.GetOrLoad(ctx, c, "item:1", time.Minute, load)
+	assert.NoError(t, err)
+	second, err := cache.GetOrLoad(ctx, c, "item:1", time.Minute, load)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "widget", first.Name)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, calls)
+}
+
+func TestGetOrLoad_SharesConcurrentLoads(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := func(context.Context) (item, error) {
+		calls.Add(1)
+		<-release
+		return item{Name: "widget"}, nil
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.GetOrLoad(ctx, c, "item:stampede", time.Minute, load)
+			assert.NoError(t, err)
+			assert.Equal(t, "widget", v.Name)
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+func TestGetOrLoad_CallersGetTheirOwnCopy(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+	release := make(chan struct{})
+	load := func(context.Context) (*item, error) {
+		<-release
+		return &item{Name: "widget"}, nil
+	}
+
+	results := make([]*item, 2)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.GetOrLoad(ctx, c, "item:shared", time.Minute, load)
+			assert.NoError(t, err)
+			results[i] = v
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	results[0].Name = "changed"
+	assert.Equal(t, "widget", results[1].Name)
+}
+
+func TestGetOrLoad_SeparatesTypesOfTheSameKey(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		v, err := cache.GetOrLoad(ctx, c, "item:1", time.Minute, func(context.Context) (item, error) {
+			<-release
+			return item{Name: "widget"}, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "widget", v.Name)
+	}()
+	go func() {
+		defer wg.Done()
+		v, err := cache.GetOrLoad(ctx, c, "item:1", time.Minute, func(context.Context) (string, error) {
+			<-release
+			return "widget", nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "widget", v)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+}
+
+func TestGetOrLoad_NegativeCaching(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+	notFound := errors.New("item not found")
+	var calls int
+	load := func(context.Context) (*item, error) {
+		calls++
+		return nil, notFound
+	}
+	opt := cache.WithNegativeCaching(func(err error) bool { return errors.Is(err, notFound) }, time.Minute)
+
+	_, err := cache.GetOrLoad(ctx, c, "item:missing", time.Minute, load, opt)
+	assert.ErrorIs(t, err, notFound)
+	_, err = cache.GetOrLoad(ctx, c, "item:missing", time.Minute, load, opt)
+	assert.EqualError(t, err, "item not found")
+	assert.ErrorIs(t, err, cache.ErrNotFound)
+
+	assert.Equal(t, 1, calls)
+}
+
+func TestGetOrLoad_DoesNotCacheOtherErrors(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+	var calls int
+	load := func(context.Context) (*item, error) {
+		calls++
+		return nil, errors.New("connection refused")
+	}
+
+	_, _ = cache.GetOrLoad(ctx, c, "item:flaky", time.Minute, load)
+	_, _ = cache.GetOrLoad(ctx, c, "item:flaky", time.Minute, load)
+
+	assert.Equal(t, 2, calls)
+}
+
+type brokenCache struct{}
+
+func (brokenCache) Get(context.Context, string) ([]byte, error) {
+	return nil, errors.New("redis down")
+}
+func (brokenCache) Set(context.Context, string, []byte, time.Duration) error {
+	return errors.New("redis down")
+}
+func (brokenCache) Delete(context.Context, ...string) error { return errors.New("redis down") }
+
+func TestGetOrLoad_FallsBackWhenBackendFails(t *testing.T) {
+	v, err := cache.GetOrLoad(context.Background(), brokenCache{}, "item:1", time.Minute,
+		func(context.Context) (item, error) { return item{Name: "widget"}, nil })
+
+	assert.NoError(t, err)
+	assert.Equal(t, "widget", v.Name)
+}
+
+func TestSet_IsReadByGetOrLoad(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+
+	assert.NoError(t, cache.Set(ctx, c, "item:1", item{Name: "fresh"}, time.Minute))
+
+	v, err := cache.GetOrLoad(ctx, c, "item:1", time.Minute, func(context.Context) (item, error) {
+		t.Fatal("load must not be called for a written-through key")
+		return item{}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "fresh", v.Name)
+}
+
+func TestSetIfNewer(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+	newer := func(v item) func(item) bool {
+		return func(cached item) bool { return v.Name > cached.Name }
+	}
+
+	stored, err := cache.SetIfNewer(ctx, c, "item:1", item{Name: "b"}, time.Minute, newer(item{Name: "b"}))
+	assert.NoError(t, err)
+	assert.True(t, stored)
+
+	// An older write arriving late leaves the newer value in place.
+	stored, err = cache.SetIfNewer(ctx, c, "item:1", item{Name: "a"}, time.Minute, newer(item{Name: "a"}))
+	assert.NoError(t, err)
+	assert.False(t, stored)
+
+	stored, err = cache.SetIfNewer(ctx, c, "item:1", item{Name: "c"}, time.Minute, newer(item{Name: "c"}))
+	assert.NoError(t, err)
+	assert.True(t, stored)
+
+	v, err := cache.GetOrLoad(ctx, c, "item:1", time.Minute, func(context.Context) (item, error) {
+		t.Fatal("load must not be called for a written-through key")
+		return item{}, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "c", v.Name)
+}
+
+func TestSetIfNewer_ReplacesCachedNotFound(t *testing.T) {
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+	_, err := cache.GetOrLoad(ctx, c, "item:1", time.Minute, func(context.Context) (item, error) {
+		return item{}, errors.New("item not found")
+	}, cache.WithNegativeCaching(func(error) bool { return true }, time.Minute))
+	assert.Error(t, err)
+
+	stored, err := cache.SetIfNewer(ctx, c, "item:1", item{Name: "created"}, time.Minute, func(item) bool { return false })
+	assert.NoError(t, err)
+	assert.True(t, stored)
+}
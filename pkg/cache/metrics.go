@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"context"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Instruments are created from the global meter, which delegates to the
+// provider the service installs at startup.
+var meter = otel.Meter("golang/pkg/cache")
+
+var (
+	cacheHits, _ = meter.Int64Counter(
+		"cache.hits",
+		metric.WithDescription("Number of cache lookups served from the cache"),
+	)
+	cacheMisses, _ = meter.Int64Counter(
+		"cache.misses",
+		metric.WithDescription("Number of cache lookups that fell through to the source"),
+	)
+	cacheErrors, _ = meter.Int64Counter(
+		"cache.errors",
+		metric.WithDescription("Number of cache lookups that failed and fell through to the source"),
+	)
+)
+
+type lookupResult int
+
+const (
+	resultHit lookupResult = iota
+	resultMiss
+	resultError
+)
+
+// recordLookup counts a lookup labeled by the key prefix (e.g. "product" for
+// "product:<id>"). The hit ratio is hits / (hits + misses + errors).
+func recordLookup(ctx context.Context, key string, result lookupResult) {
+	prefix, _, _ := strings.Cut(key, ":")
+	attrs := metric.WithAttributes(attribute.String("cache", prefix))
+	switch result {
+	case resultHit:
+		cacheHits.Add(ctx, 1, attrs)
+	case resultMiss:
+		cacheMisses.Add(ctx, 1, attrs)
+	case resultError:
+		cacheErrors.Add(ctx, 1, attrs)
+	}
+}
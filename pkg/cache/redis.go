@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Cache backed by Redis, shared by all replicas of a service.
+type Redis struct {
+	client redis.UniversalClient
+}
+
+// NewRedis wraps an existing client. The caller owns the client and is
+// responsible for instrumenting and closing it.
+func NewRedis(client redis.UniversalClient) *Redis {
+	return &Redis{client: client}
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	return data, err
+}
+
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// compareAndSwap sets KEYS[1] to ARGV[3] with a TTL of ARGV[4] milliseconds
+// (none if 0) if it holds ARGV[2], or, when ARGV[1] is "0", if it is missing.
+var compareAndSwap = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if ARGV[1] == "0" then
+	if current then return 0 end
+elseif current ~= ARGV[2] then
+	return 0
+end
+if ARGV[4] == "0" then
+	redis.call("SET", KEYS[1], ARGV[3])
+else
+	redis.call("SET", KEYS[1], ARGV[3], "PX", ARGV[4])
+end
+return 1
+`)
+
+func (r *Redis) CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error) {
+	exists := "1"
+	if old == nil {
+		exists = "0"
+	}
+	stored, err := compareAndSwap.Run(ctx, r.client, []string{key}, exists, old, value, max(ttl.Milliseconds(), 0)).Int()
+	return stored == 1, err
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Version is a generation counter for a group of cached entries, such as all
+// product listings. Building keys from the current version means a single
+// Bump invalidates every entry of the group without deleting them one by one;
+// the old entries simply stop being read and expire.
+type Version interface {
+	// Current returns the version to build keys from.
+	Current(ctx context.Context) int64
+	// Bump moves the group to a new version.
+	Bump(ctx context.Context) error
+}
+
+// LocalVersion is a Version held in process memory, for single-replica
+// deployments and tests.
+type LocalVersion struct {
+	n atomic.Int64
+}
+
+func NewLocalVersion() *LocalVersion {
+	return &LocalVersion{}
+}
+
+func (v *LocalVersion) Current(context.Context) int64 {
+	return v.n.Load()
+}
+
+func (v *LocalVersion) Bump(context.Context) error {
+	v.n.Add(1)
+	return nil
+}
+
+// RedisVersion is a Version shared by all replicas. The counter lives in Redis
+// and every bump is announced on a pub/sub channel, so replicas running
+// Subscribe switch to the new version at once without reading the counter on
+// every lookup. Replicas that are not subscribed read the counter from Redis.
+type RedisVersion struct {
+	client     redis.UniversalClient
+	key        string
+	channel    string
+	current    atomic.Int64
+	subscribed atomic.Bool
+}
+
+// NewRedisVersion creates the version stored under "<name>:version" and
+// announced on "<name>:version:bumped".
+func NewRedisVersion(client redis.UniversalClient, name string) *RedisVersion {
+	return &RedisVersion{
+		client:  client,
+		key:     name + ":version",
+		channel: name + ":version:bumped",
+	}
+}
+
+func (v *RedisVersion) Current(ctx context.Context) int64 {
+	if v.subscribed.Load() {
+		return v.current.Load()
+	}
+	n, err := v.load(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to read cache version", "key", v.key, "error", err)
+		return v.current.Load()
+	}
+	v.observe(n)
+	return v.current.Load()
+}
+
+func (v *RedisVersion) Bump(ctx context.Context) error {
+	n, err := v.client.Incr(ctx, v.key).Result()
+	if err != nil {
+		return err
+	}
+	v.observe(n)
+	return v.client.Publish(ctx, v.channel, n).Err()
+}
+
+// Subscribe follows bumps made by other replicas until ctx is done. While the
+// subscription is down Current falls back to reading the counter, and after
+// every (re)subscription the counter is re-read to catch up on missed bumps.
+func (v *RedisVersion) Subscribe(ctx context.Context) error {
+	ps := v.client.Subscribe(ctx, v.channel)
+	defer ps.Close()
+	defer v.subscribed.Store(false)
+
+	for {
+		msg, err := ps.Receive(ctx)
+		if err != nil {
+			v.subscribed.Store(false)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			slog.WarnContext(ctx, "cache version subscription interrupted", "channel", v.channel, "error", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			n, err := v.load(ctx)
+			if err != nil {
+				slog.WarnContext(ctx, "failed to read cache version", "key", v.key, "error", err)
+				continue
+			}
+			v.observe(n)
+			v.subscribed.Store(true)
+		case *redis.Message:
+			n, err := strconv.ParseInt(m.Payload, 10, 64)
+			if err != nil {
+				slog.WarnContext(ctx, "ignoring malformed cache version", "channel", v.channel, "payload", m.Payload)
+				continue
+			}
+			v.observe(n)
+		}
+	}
+}
+
+func (v *RedisVersion) load(ctx context.Context) (int64, error) {
+	n, err := v.client.Get(ctx, v.key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// observe moves the local copy forward; versions never go back.
+func (v *RedisVersion) observe(n int64) {
+	for {
+		cur := v.current.Load()
+		if n <= cur || v.current.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
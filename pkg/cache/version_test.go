@@ -0,0 +1,41 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang/pkg/cache"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisVersion_PropagatesBumpsToSubscribers(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	replicaA := cache.NewRedisVersion(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "products")
+	replicaB := cache.NewRedisVersion(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "products")
+	go replicaB.Subscribe(ctx)
+
+	assert.NoError(t, replicaA.Bump(ctx))
+	assert.Equal(t, int64(1), replicaA.Current(ctx))
+	assert.Eventually(t, func() bool { return replicaB.Current(ctx) == 1 }, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, replicaA.Bump(ctx))
+	assert.Eventually(t, func() bool { return replicaB.Current(ctx) == 2 }, time.Second, 10*time.Millisecond)
+}
+
+func TestRedisVersion_ReadsCounterWhenNotSubscribed(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	replicaA := cache.NewRedisVersion(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "products")
+	replicaB := cache.NewRedisVersion(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "products")
+
+	assert.NoError(t, replicaA.Bump(ctx))
+	assert.Equal(t, int64(1), replicaB.Current(ctx))
+}
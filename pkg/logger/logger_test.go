@@ -0,0 +1,44 @@
+package logger_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"golang/pkg/logger"
+)
+
+func TestValidRequestID(t *testing.T) {
+	assert.True(t, logger.ValidRequestID("3f2a9c1e-req_1.2"))
+	assert.True(t, logger.ValidRequestID(logger.NewRequestID()))
+
+	assert.False(t, logger.ValidRequestID(""))
+	assert.False(t, logger.ValidRequestID(strings.Repeat("a", 129)))
+	assert.False(t, logger.ValidRequestID("id\nforged=line"))
+	assert.False(t, logger.ValidRequestID("id with spaces"))
+}
+
+func TestUnaryServerInterceptor_RequestID(t *testing.T) {
+	interceptor := logger.UnaryServerInterceptor()
+	info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Call"}
+	requestID := func(sent string) string {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(logger.RequestIDMetadataKey, sent))
+		var got string
+		_, _ = interceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			got = logger.RequestID(ctx)
+			return nil, nil
+		})
+		return got
+	}
+
+	assert.Equal(t, "req-1", requestID("req-1"))
+
+	// An ID the caller made up is replaced rather than logged.
+	got := requestID("req-1\ninjected")
+	assert.NotEqual(t, "req-1\ninjected", got)
+	assert.True(t, logger.ValidRequestID(got))
+}
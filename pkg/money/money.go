@@ -0,0 +1,189 @@
+// Package money represents monetary amounts exactly, as an integer number of
+// minor units (cents, tiyn) of an ISO 4217 currency, so that sums and
+// products never pick up the rounding errors of binary floating point.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrCurrencyMismatch is returned when amounts in different currencies
+	// are combined.
+	ErrCurrencyMismatch = errors.New("money: currency mismatch")
+	// ErrOverflow is returned when a result does not fit in int64 minor units.
+	ErrOverflow = errors.New("money: amount out of range")
+)
+
+// Money is an amount of a currency. The zero value is a zero amount without
+// a currency, which Add and Sub treat as zero of the other operand's
+// currency.
+type Money struct {
+	Currency string `json:"currency"` // ISO 4217 code, e.g. "USD"
+	Amount   int64  `json:"amount"`   // in minor units, see Exponent
+}
+
+// New returns amount minor units of currency.
+func New(amount int64, currency string) Money {
+	return Money{Currency: currency, Amount: amount}
+}
+
+// exponents lists the currencies whose minor unit is not a hundredth.
+var exponents = map[string]int{
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0,
+	"KRW": 0, "PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0,
+	"XAF": 0, "XOF": 0, "XPF": 0,
+}
+
+// Exponent returns the number of decimal places of currency's minor unit:
+// 2 for most currencies, 0 for e.g. JPY, 3 for e.g. KWD.
+func Exponent(currency string) int {
+	if e, ok := exponents[currency]; ok {
+		return e
+	}
+	return 2
+}
+
+// ValidCurrency reports whether code looks like an ISO 4217 code: three
+// upper-case letters.
+func ValidCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse reads a decimal amount in major units, e.g. "10.5" or "-3", exactly.
+// It rejects more decimals than the currency's minor unit has.
+func Parse(s, currency string) (Money, error) {
+	exp := Exponent(currency)
+	sign := int64(1)
+	rest := s
+	if strings.HasPrefix(rest, "-") {
+		sign, rest = -1, rest[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(rest, ".")
+	if whole == "" || !digits(whole) || (hasFrac && (frac == "" || !digits(frac))) {
+		return Money{}, fmt.Errorf("money: invalid amount %q", s)
+	}
+	if len(frac) > exp {
+		return Money{}, fmt.Errorf("money: %s has more than %d decimals for %s", s, exp, currency)
+	}
+	units, err := strconv.ParseInt(whole+frac+strings.Repeat("0", exp-len(frac)), 10, 64)
+	if err != nil {
+		return Money{}, ErrOverflow
+	}
+	return Money{Currency: currency, Amount: sign * units}, nil
+}
+
+func digits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// FromFloat converts an amount in major units, rounding half away from zero
+// to the nearest minor unit. It is meant for migrating legacy float values;
+// new amounts should come from Parse or New.
+func FromFloat(f float64, currency string) (Money, error) {
+	units := math.Round(f * math.Pow10(Exponent(currency)))
+	if math.IsNaN(units) || units >= math.MaxInt64 || units < math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return Money{Currency: currency, Amount: int64(units)}, nil
+}
+
+func (m Money) sameCurrency(o Money) (string, error) {
+	switch {
+	case m.Currency == o.Currency:
+		return m.Currency, nil
+	case m == Money{}:
+		return o.Currency, nil
+	case o == Money{}:
+		return m.Currency, nil
+	}
+	return "", fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency, o.Currency)
+}
+
+// Add returns m+o.
+func (m Money) Add(o Money) (Money, error) {
+	currency, err := m.sameCurrency(o)
+	if err != nil {
+		return Money{}, err
+	}
+	sum := m.Amount + o.Amount
+	if (o.Amount > 0 && sum < m.Amount) || (o.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return Money{Currency: currency, Amount: sum}, nil
+}
+
+// Sub returns m-o.
+func (m Money) Sub(o Money) (Money, error) {
+	if o.Amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(Money{Currency: o.Currency, Amount: -o.Amount})
+}
+
+// Mul returns m×n, e.g. a unit price times a quantity.
+func (m Money) Mul(n int64) (Money, error) {
+	if n != 0 && m.Amount != 0 {
+		p := m.Amount * n
+		if p/n != m.Amount || (m.Amount == -1 && n == math.MinInt64) || (n == -1 && m.Amount == math.MinInt64) {
+			return Money{}, ErrOverflow
+		}
+		return Money{Currency: m.Currency, Amount: p}, nil
+	}
+	return Money{Currency: m.Currency}, nil
+}
+
+// IsZero reports whether the amount is zero, whatever the currency.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsNegative reports whether the amount is below zero.
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Decimal formats the amount in major units with all the currency's
+// decimals, e.g. "10.50" or "-0.05".
+func (m Money) Decimal() string {
+	exp := Exponent(m.Currency)
+	sign := ""
+	u := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign, u = "-", uint64(-(m.Amount+1))+1
+	}
+	s := strconv.FormatUint(u, 10)
+	if exp == 0 {
+		return sign + s
+	}
+	if len(s) <= exp {
+		s = strings.Repeat("0", exp-len(s)+1) + s
+	}
+	return sign + s[:len(s)-exp] + "." + s[len(s)-exp:]
+}
+
+// String formats m as e.g. "10.50 USD".
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency
+}
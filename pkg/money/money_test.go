@@ -0,0 +1,98 @@
+package money_test
+
+import (
+	"math"
+	"testing"
+
+	"golang/pkg/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in       string
+		currency string
+		want     int64
+	}{
+		{"10.5", "USD", 1050},
+		{"10.50", "USD", 1050},
+		{"0.07", "USD", 7},
+		{"-3", "USD", -300},
+		{"1500", "JPY", 1500},
+		{"1.234", "KWD", 1234},
+	}
+	for _, c := range cases {
+		m, err := money.Parse(c.in, c.currency)
+		if assert.NoError(t, err, c.in) {
+			assert.Equal(t, money.New(c.want, c.currency), m, c.in)
+		}
+	}
+
+	for _, in := range []string{"", "1.", ".5", "1.005", "1e3", "abc", "1,5", "--1", "99999999999999999999"} {
+		_, err := money.Parse(in, "USD")
+		assert.Error(t, err, in)
+	}
+	_, err := money.Parse("1.5", "JPY")
+	assert.Error(t, err)
+}
+
+func TestFromFloat_RoundsToMinorUnits(t *testing.T) {
+	m, err := money.FromFloat(0.1+0.2, "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(30), m.Amount)
+
+	m, err = money.FromFloat(19.99, "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1999), m.Amount)
+
+	m, err = money.FromFloat(-0.125, "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-13), m.Amount)
+
+	_, err = money.FromFloat(math.NaN(), "USD")
+	assert.ErrorIs(t, err, money.ErrOverflow)
+	_, err = money.FromFloat(1e20, "USD")
+	assert.ErrorIs(t, err, money.ErrOverflow)
+}
+
+func TestArithmetic(t *testing.T) {
+	sum, err := money.Money{}.Add(money.New(10, "USD"))
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(10, "USD"), sum)
+
+	sum, err = sum.Add(money.New(20, "USD"))
+	assert.NoError(t, err)
+	assert.Equal(t, money.New(30, "USD"), sum)
+
+	diff, err := sum.Sub(money.New(45, "USD"))
+	assert.NoError(t, err)
+	assert.True(t, diff.IsNegative())
+
+	_, err = sum.Add(money.New(1, "EUR"))
+	assert.ErrorIs(t, err, money.ErrCurrencyMismatch)
+
+	line, err := money.New(1999, "USD").Mul(3)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5997), line.Amount)
+
+	_, err = money.New(math.MaxInt64, "USD").Add(money.New(1, "USD"))
+	assert.ErrorIs(t, err, money.ErrOverflow)
+	_, err = money.New(math.MaxInt64/2+1, "USD").Mul(2)
+	assert.ErrorIs(t, err, money.ErrOverflow)
+}
+
+func TestFormatting(t *testing.T) {
+	assert.Equal(t, "10.50 USD", money.New(1050, "USD").String())
+	assert.Equal(t, "-0.05", money.New(-5, "USD").Decimal())
+	assert.Equal(t, "0.00", money.New(0, "USD").Decimal())
+	assert.Equal(t, "1500 JPY", money.New(1500, "JPY").String())
+	assert.Equal(t, "0.001 KWD", money.New(1, "KWD").String())
+	assert.Equal(t, "-92233720368547758.08", money.New(math.MinInt64, "USD").Decimal())
+}
+
+func TestValidCurrency(t *testing.T) {
+	assert.True(t, money.ValidCurrency("KZT"))
+	assert.False(t, money.ValidCurrency("usd"))
+	assert.False(t, money.ValidCurrency("US"))
+}
@@ -0,0 +1,43 @@
+package telemetry
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor records the RED metrics (rate, errors, duration) of
+// every unary RPC, labeled by full method name and status code. serviceName
+// names the meter.
+func UnaryServerInterceptor(serviceName string) grpc.UnaryServerInterceptor {
+	meter := otel.Meter(serviceName)
+	requestCounter, _ := meter.Int64Counter(
+		"rpc.server.requests",
+		metric.WithDescription("Total number of gRPC requests"),
+		metric.WithUnit("{request}"),
+	)
+	requestDuration, _ := meter.Float64Histogram(
+		"rpc.server.duration",
+		metric.WithDescription("Duration of gRPC requests"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(DurationBuckets...),
+	)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+
+		attrs := metric.WithAttributes(
+			attribute.String("method", info.FullMethod),
+			attribute.String("code", status.Code(err).String()),
+		)
+		requestCounter.Add(ctx, 1, attrs)
+		requestDuration.Record(ctx, time.Since(start).Seconds(), attrs)
+		return resp, err
+	}
+}
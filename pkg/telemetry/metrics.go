@@ -0,0 +1,66 @@
+// Package telemetry sets up the metrics and tracing shared by the services:
+// a Prometheus scrape endpoint backed by the OpenTelemetry meter provider, an
+// interceptor recording the RED metrics of every gRPC call and the global
+// tracer provider.
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// DurationBuckets are the histogram boundaries (in seconds) used for request latency.
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// InitMetrics configures the global meter provider with a Prometheus exporter
+// and returns the handler that serves the scrape endpoint together with a
+// shutdown function that flushes the provider.
+func InitMetrics(serviceName string) (http.Handler, func(context.Context) error, error) {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	exporter, err := otelprom.New(otelprom.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(exporter),
+		sdkmetric.WithResource(res),
+	)
+	otel.SetMeterProvider(provider)
+
+	handler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+	return handler, provider.Shutdown, nil
+}
+
+// ServeMetrics exposes handler on /metrics at the given port in a background goroutine.
+func ServeMetrics(port string, handler http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	go func() {
+		if err := http.ListenAndServe(":"+port, mux); err != nil && err != http.ErrServerClosed {
+			slog.Error("metrics server failed", "error", err)
+		}
+	}()
+}
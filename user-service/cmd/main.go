@@ -1,8 +1,7 @@
 package main
 
 import (
-	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"os"
 
@@ -12,46 +11,79 @@ import (
 	"user-service/internal/repository"
 	"user-service/internal/usecase"
 
+	"golang/pkg/cache"
+	"golang/pkg/logger"
+	"golang/pkg/telemetry"
+
+	"github.com/redis/go-redis/extra/redisotel/v9"
+	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 )
 
 func main() {
 	cfg := config.Load()
+	logger.Init("user-service", cfg.LogLevel, cfg.LogFormat)
 
+	// ✅ Принудительно подгружаем JWT_SECRET из окружения
 	cfg.JWTSecret = os.Getenv("JWT_SECRET")
 	if cfg.JWTSecret == "" {
-		log.Fatal("JWT_SECRET is not set")
+		logger.Fatal("JWT_SECRET environment variable is not set")
 	}
 
-	// ✅ Принудительно подгружаем JWT_SECRET из окружения
-	cfg.JWTSecret = os.Getenv("JWT_SECRET")
-	if cfg.JWTSecret == "" {
-		log.Fatal("JWT_SECRET environment variable is not set")
+	metricsHandler, shutdownMetrics, err := telemetry.InitMetrics("user-service")
+	if err != nil {
+		logger.Fatal("metrics init failed", "error", err)
 	}
+	defer shutdownMetrics(cfg.Ctx)
+	telemetry.ServeMetrics(cfg.MetricsPort, metricsHandler)
 
-	client, err := mongo.Connect(cfg.Ctx, options.Client().ApplyURI(cfg.MongoURI))
+	shutdownTracing, err := telemetry.InitTracing(cfg.Ctx, "user-service", cfg.TracesExporter, cfg.OTLPEndpoint)
 	if err != nil {
-		log.Fatalf("Mongo connect error: %v", err)
+		logger.Fatal("tracing init failed", "error", err)
+	}
+	defer shutdownTracing(cfg.Ctx)
+
+	rdb := redis.NewClient(&redis.Options{Addr: cfg.RedisAddr})
+	defer rdb.Close()
+	if err := redisotel.InstrumentTracing(rdb); err != nil {
+		logger.Fatal("redis tracing instrumentation failed", "error", err)
+	}
+
+	client, err := mongo.Connect(cfg.Ctx, options.Client().ApplyURI(cfg.MongoURI).SetMonitor(otelmongo.NewMonitor()))
+	if err != nil {
+		logger.Fatal("mongodb connect failed", "error", err)
 	}
 	defer client.Disconnect(cfg.Ctx)
 
 	col := client.Database(cfg.MongoDBName).Collection("users")
 	userRepo := repository.NewMongoUserRepository(col)
-	userUC := usecase.NewUserUsecase(userRepo)
+	userUC := usecase.NewUserUsecase(userRepo, cache.NewRedis(rdb))
 	userHandler := handler.NewUserHandler(userUC, cfg.JWTSecret)
 
+	addressRepo := repository.NewMongoAddressRepository(client.Database(cfg.MongoDBName).Collection("addresses"))
+	addressHandler := handler.NewAddressHandler(usecase.NewAddressUsecase(addressRepo))
+
 	lis, err := net.Listen("tcp", ":"+cfg.Port)
 	if err != nil {
-		log.Fatalf("Listen error: %v", err)
+		logger.Fatal("listen failed", "error", err)
 	}
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(
+			logger.UnaryServerInterceptor(),
+			telemetry.UnaryServerInterceptor("user-service"),
+		),
+	)
 	pb.RegisterUserServiceServer(grpcServer, userHandler)
+	pb.RegisterAddressServiceServer(grpcServer, addressHandler)
 
-	fmt.Printf("UserService running on :%s\n", cfg.Port)
+	slog.Info("user service listening", "port", cfg.Port)
 	if err := grpcServer.Serve(lis); err != nil {
-		log.Fatalf("Serve error: %v", err)
+		logger.Fatal("serve failed", "error", err)
 	}
 }
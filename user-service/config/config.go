@@ -3,33 +3,44 @@ package config
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"os"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Ctx         context.Context
-	Port        string
-	MongoURI    string
-	MongoDBName string
-	JWTSecret   string
-	RedisAddr   string // жаңа өріс
+	Ctx            context.Context
+	Port           string
+	MongoURI       string
+	MongoDBName    string
+	JWTSecret      string
+	RedisAddr      string // жаңа өріс
+	MetricsPort    string
+	TracesExporter string
+	OTLPEndpoint   string
+	LogLevel       string
+	LogFormat      string
 }
 
 func Load() *Config {
 	// Попытка загрузить .env из рабочей директории
 	if err := godotenv.Load("../.env"); err != nil {
-		log.Println("⚠️ .env file not found, using environment variables")
+		slog.Warn(".env file not found, using environment variables")
 	}
 
 	return &Config{
-		Ctx:         context.Background(),
-		Port:        getEnv("PORT", "50051"),
-		MongoURI:    getEnv("MONGO_URI", "mongodb://localhost:27017"),
-		MongoDBName: getEnv("MONGO_DB", "users_db"), // NEW
-		JWTSecret:   getEnv("JWT_SECRET", "your-secret-key"),
+		Ctx:            context.Background(),
+		Port:           getEnv("PORT", "50051"),
+		MongoURI:       getEnv("MONGO_URI", "mongodb://localhost:27017"),
+		MongoDBName:    getEnv("MONGO_DB", "users_db"), // NEW
+		JWTSecret:      getEnv("JWT_SECRET", "your-secret-key"),
+		RedisAddr:      getEnv("REDIS_ADDR", "localhost:6379"),
+		MetricsPort:    getEnv("METRICS_PORT", "9091"),
+		TracesExporter: getEnv("TRACES_EXPORTER", "none"),
+		OTLPEndpoint:   getEnv("OTLP_ENDPOINT", "localhost:4317"),
+		LogLevel:       getEnv("LOG_LEVEL", "info"),
+		LogFormat:      getEnv("LOG_FORMAT", "json"),
 	}
 }
 
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"user-service/internal/model"
+	pb "user-service/internal/pb"
+	"user-service/internal/repository"
+	"user-service/internal/usecase"
+
+	"golang/pkg/address"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type AddressHandler struct {
+	pb.UnimplementedAddressServiceServer
+	uc *usecase.AddressUsecase
+}
+
+func NewAddressHandler(uc *usecase.AddressUsecase) *AddressHandler {
+	return &AddressHandler{uc: uc}
+}
+
+func (h *AddressHandler) CreateAddress(ctx context.Context, req *pb.CreateAddressRequest) (*pb.SavedAddress, error) {
+	a, err := h.uc.CreateAddress(ctx, &model.Address{
+		UserID:    req.UserId,
+		Address:   addressFromProto(req.Address),
+		IsDefault: req.IsDefault,
+	})
+	if err != nil {
+		return nil, addressError(err)
+	}
+	return savedAddressToProto(a), nil
+}
+
+func (h *AddressHandler) GetAddress(ctx context.Context, req *pb.GetAddressRequest) (*pb.SavedAddress, error) {
+	a, err := h.uc.GetAddress(ctx, req.UserId, req.Id)
+	if err != nil {
+		return nil, addressError(err)
+	}
+	return savedAddressToProto(a), nil
+}
+
+func (h *AddressHandler) ListAddresses(ctx context.Context, req *pb.ListAddressesRequest) (*pb.ListAddressesResponse, error) {
+	addresses, err := h.uc.ListAddresses(ctx, req.UserId)
+	if err != nil {
+		return nil, addressError(err)
+	}
+	resp := &pb.ListAddressesResponse{}
+	for _, a := range addresses {
+		resp.Addresses = append(resp.Addresses, savedAddressToProto(a))
+	}
+	return resp, nil
+}
+
+func (h *AddressHandler) UpdateAddress(ctx context.Context, req *pb.UpdateAddressRequest) (*pb.SavedAddress, error) {
+	a, err := h.uc.UpdateAddress(ctx, &model.Address{
+		ID:        req.Id,
+		UserID:    req.UserId,
+		Address:   addressFromProto(req.Address),
+		IsDefault: req.IsDefault,
+	})
+	if err != nil {
+		return nil, addressError(err)
+	}
+	return savedAddressToProto(a), nil
+}
+
+func (h *AddressHandler) DeleteAddress(ctx context.Context, req *pb.DeleteAddressRequest) (*pb.DeleteAddressResponse, error) {
+	if err := h.uc.DeleteAddress(ctx, req.UserId, req.Id); err != nil {
+		return nil, addressError(err)
+	}
+	return &pb.DeleteAddressResponse{Message: "Address deleted"}, nil
+}
+
+func (h *AddressHandler) SetDefaultAddress(ctx context.Context, req *pb.SetDefaultAddressRequest) (*pb.SavedAddress, error) {
+	a, err := h.uc.SetDefaultAddress(ctx, req.UserId, req.Id)
+	if err != nil {
+		return nil, addressError(err)
+	}
+	return savedAddressToProto(a), nil
+}
+
+func addressError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrAddressNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, usecase.ErrTooManyAddresses):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+func addressFromProto(a *pb.Address) address.Address {
+	return address.Address{
+		Name:       a.GetName(),
+		Line1:      a.GetLine1(),
+		Line2:      a.GetLine2(),
+		City:       a.GetCity(),
+		Region:     a.GetRegion(),
+		PostalCode: a.GetPostalCode(),
+		Country:    a.GetCountry(),
+		Phone:      a.GetPhone(),
+	}
+}
+
+func savedAddressToProto(a *model.Address) *pb.SavedAddress {
+	return &pb.SavedAddress{
+		Id:     a.ID,
+		UserId: a.UserID,
+		Address: &pb.Address{
+			Name:       a.Address.Name,
+			Line1:      a.Address.Line1,
+			Line2:      a.Address.Line2,
+			City:       a.Address.City,
+			Region:     a.Address.Region,
+			PostalCode: a.Address.PostalCode,
+			Country:    a.Address.Country,
+			Phone:      a.Address.Phone,
+		},
+		IsDefault: a.IsDefault,
+	}
+}
@@ -2,7 +2,10 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"math"
 	"regexp"
+	"strconv"
 	"time"
 
 	"user-service/internal/model"
@@ -10,7 +13,9 @@ import (
 	"user-service/internal/usecase"
 
 	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -71,6 +76,13 @@ func (h *UserHandler) AuthenticateUser(ctx context.Context, req *pb.AuthRequest)
 
 	user, err := h.uc.AuthenticateUser(ctx, req.Username, req.Password)
 	if err != nil {
+		var locked *usecase.AccountLockedError
+		if errors.As(err, &locked) {
+			// Tell the gateway how long to ask the client to wait.
+			retryAfter := int(math.Ceil(time.Until(locked.Until).Seconds()))
+			_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(retryAfter)))
+			return nil, status.Error(codes.ResourceExhausted, "account temporarily locked due to failed login attempts")
+		}
 		switch err.Error() {
 		case "user not found":
 			return nil, status.Error(codes.NotFound, "user not found")
@@ -82,9 +94,10 @@ func (h *UserHandler) AuthenticateUser(ctx context.Context, req *pb.AuthRequest)
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub": user.ID,
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
-		"iat": time.Now().Unix(),
+		"sub":  user.ID,
+		"role": user.Role,
+		"exp":  time.Now().Add(time.Hour * 24).Unix(),
+		"iat":  time.Now().Unix(),
 	})
 	tokenString, err := token.SignedString([]byte(h.jwtSecret))
 	if err != nil {
@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+
+	"golang/pkg/address"
+)
+
+// Address is an entry of a user's address book.
+type Address struct {
+	ID        string
+	UserID    string
+	Address   address.Address
+	IsDefault bool
+	CreatedAt time.Time
+}
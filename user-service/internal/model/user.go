@@ -1,9 +1,17 @@
 // internal/model/user.go
 package model
 
+// Roles carried in the JWT. Everyone registers as a customer; staff accounts
+// are promoted by setting role in the users collection.
+const (
+    RoleCustomer = "customer"
+    RoleStaff    = "staff"
+)
+
 type User struct {
     ID       string `json:"id"`
     Username string `json:"username"`
     Password string `json:"password"`
     Email    string `json:"email"`
+    Role     string `json:"role"`
 }
\ No newline at end of file
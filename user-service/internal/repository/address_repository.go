@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"user-service/internal/model"
+)
+
+// ErrAddressNotFound is returned for addresses that do not exist or belong to
+// another user.
+var ErrAddressNotFound = errors.New("address not found")
+
+// AddressRepository stores address books. Every lookup is scoped to the user
+// who owns the address.
+type AddressRepository interface {
+	Create(ctx context.Context, a *model.Address) (string, error)
+	FindByID(ctx context.Context, userID, id string) (*model.Address, error)
+	// ListByUser returns the user's addresses, the default first and the
+	// others oldest first.
+	ListByUser(ctx context.Context, userID string) ([]*model.Address, error)
+	CountByUser(ctx context.Context, userID string) (int64, error)
+	// Update replaces the postal address of an entry.
+	Update(ctx context.Context, a *model.Address) error
+	Delete(ctx context.Context, userID, id string) error
+	// SetDefault makes the address the user's default and unsets any other.
+	SetDefault(ctx context.Context, userID, id string) error
+}
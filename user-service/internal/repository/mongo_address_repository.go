@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"user-service/internal/model"
+
+	"golang/pkg/address"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// addressDocument is the stored shape of an address book entry.
+type addressDocument struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	UserID     string             `bson:"user_id"`
+	Name       string             `bson:"name"`
+	Line1      string             `bson:"line1"`
+	Line2      string             `bson:"line2,omitempty"`
+	City       string             `bson:"city"`
+	Region     string             `bson:"region,omitempty"`
+	PostalCode string             `bson:"postal_code,omitempty"`
+	Country    string             `bson:"country"`
+	Phone      string             `bson:"phone,omitempty"`
+	IsDefault  bool               `bson:"is_default"`
+	CreatedAt  time.Time          `bson:"created_at"`
+}
+
+func toAddressDocument(a *model.Address) addressDocument {
+	return addressDocument{
+		UserID:     a.UserID,
+		Name:       a.Address.Name,
+		Line1:      a.Address.Line1,
+		Line2:      a.Address.Line2,
+		City:       a.Address.City,
+		Region:     a.Address.Region,
+		PostalCode: a.Address.PostalCode,
+		Country:    a.Address.Country,
+		Phone:      a.Address.Phone,
+		IsDefault:  a.IsDefault,
+		CreatedAt:  a.CreatedAt,
+	}
+}
+
+func (d addressDocument) toModel() *model.Address {
+	return &model.Address{
+		ID:     d.ID.Hex(),
+		UserID: d.UserID,
+		Address: address.Address{
+			Name:       d.Name,
+			Line1:      d.Line1,
+			Line2:      d.Line2,
+			City:       d.City,
+			Region:     d.Region,
+			PostalCode: d.PostalCode,
+			Country:    d.Country,
+			Phone:      d.Phone,
+		},
+		IsDefault: d.IsDefault,
+		CreatedAt: d.CreatedAt,
+	}
+}
+
+type MongoAddressRepository struct {
+	coll *mongo.Collection
+}
+
+func NewMongoAddressRepository(coll *mongo.Collection) *MongoAddressRepository {
+	// Address books are read by user, default first.
+	coll.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "is_default", Value: -1}, {Key: "created_at", Value: 1}}},
+	)
+	return &MongoAddressRepository{coll: coll}
+}
+
+func (r *MongoAddressRepository) Create(ctx context.Context, a *model.Address) (string, error) {
+	res, err := r.coll.InsertOne(ctx, toAddressDocument(a))
+	if err != nil {
+		return "", err
+	}
+	return res.InsertedID.(primitive.ObjectID).Hex(), nil
+}
+
+// byID filters for the user's address with the given ID. IDs that are not
+// ObjectIDs name no address.
+func byID(userID, id string) (bson.M, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrAddressNotFound
+	}
+	return bson.M{"_id": oid, "user_id": userID}, nil
+}
+
+func (r *MongoAddressRepository) FindByID(ctx context.Context, userID, id string) (*model.Address, error) {
+	filter, err := byID(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	var d addressDocument
+	err = r.coll.FindOne(ctx, filter).Decode(&d)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrAddressNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d.toModel(), nil
+}
+
+func (r *MongoAddressRepository) ListByUser(ctx context.Context, userID string) ([]*model.Address, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "is_default", Value: -1}, {Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.coll.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []addressDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	addresses := make([]*model.Address, 0, len(docs))
+	for _, d := range docs {
+		addresses = append(addresses, d.toModel())
+	}
+	return addresses, nil
+}
+
+func (r *MongoAddressRepository) CountByUser(ctx context.Context, userID string) (int64, error) {
+	return r.coll.CountDocuments(ctx, bson.M{"user_id": userID})
+}
+
+func (r *MongoAddressRepository) Update(ctx context.Context, a *model.Address) error {
+	filter, err := byID(a.UserID, a.ID)
+	if err != nil {
+		return err
+	}
+	d := toAddressDocument(a)
+	res, err := r.coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+		"name":        d.Name,
+		"line1":       d.Line1,
+		"line2":       d.Line2,
+		"city":        d.City,
+		"region":      d.Region,
+		"postal_code": d.PostalCode,
+		"country":     d.Country,
+		"phone":       d.Phone,
+	}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrAddressNotFound
+	}
+	return nil
+}
+
+func (r *MongoAddressRepository) Delete(ctx context.Context, userID, id string) error {
+	filter, err := byID(userID, id)
+	if err != nil {
+		return err
+	}
+	res, err := r.coll.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrAddressNotFound
+	}
+	return nil
+}
+
+func (r *MongoAddressRepository) SetDefault(ctx context.Context, userID, id string) error {
+	filter, err := byID(userID, id)
+	if err != nil {
+		return err
+	}
+	res, err := r.coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"is_default": true}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrAddressNotFound
+	}
+	// Between the two writes the user briefly has two defaults; listings
+	// still put one of them first.
+	_, err = r.coll.UpdateMany(ctx,
+		bson.M{"user_id": userID, "_id": bson.M{"$ne": filter["_id"]}, "is_default": true},
+		bson.M{"$set": bson.M{"is_default": false}},
+	)
+	return err
+}
@@ -4,6 +4,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 	"user-service/internal/model"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -12,8 +13,46 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// userDocument is the stored shape of a user.
+type userDocument struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Username string             `bson:"username"`
+	Password string             `bson:"password"`
+	Email    string             `bson:"email"`
+	Role     string             `bson:"role,omitempty"`
+}
+
+func (u userDocument) toModel() *model.User {
+	role := u.Role
+	if role == "" {
+		// Users created before roles existed.
+		role = model.RoleCustomer
+	}
+	return &model.User{
+		ID:       u.ID.Hex(),
+		Username: u.Username,
+		Password: u.Password,
+		Email:    u.Email,
+		Role:     role,
+	}
+}
+
+// loginAttemptsTTL is how long failed login state is kept after the last
+// failure. It outlives the longest lockout.
+const loginAttemptsTTL = 24 * time.Hour
+
+// loginAttemptDocument is the failed login state for a username, whether or
+// not an account with that name exists.
+type loginAttemptDocument struct {
+	Username     string    `bson:"_id"`
+	FailedLogins int       `bson:"failed_logins"`
+	LockedUntil  time.Time `bson:"locked_until,omitempty"`
+	LastFailedAt time.Time `bson:"last_failed_at"`
+}
+
 type MongoUserRepository struct {
-	coll *mongo.Collection
+	coll     *mongo.Collection
+	attempts *mongo.Collection
 }
 
 func (r *MongoUserRepository) Collection() {
@@ -32,11 +71,24 @@ func NewMongoUserRepository(coll *mongo.Collection) *MongoUserRepository {
 			Options: options.Index().SetUnique(true),
 		},
 	)
-	return &MongoUserRepository{coll: coll}
+	// failed logins live next to users and expire once they go quiet
+	attempts := coll.Database().Collection("login_attempts")
+	attempts.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "last_failed_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(loginAttemptsTTL.Seconds())),
+		},
+	)
+	return &MongoUserRepository{coll: coll, attempts: attempts}
 }
 
 func (r *MongoUserRepository) Create(ctx context.Context, user *model.User) (string, error) {
-	obj := bson.M{"username": user.Username, "password": user.Password, "email": user.Email}
+	role := user.Role
+	if role == "" {
+		role = model.RoleCustomer
+	}
+	obj := bson.M{"username": user.Username, "password": user.Password, "email": user.Email, "role": role}
 	res, err := r.coll.InsertOne(ctx, obj)
 	if err != nil {
 		if we, ok := err.(mongo.WriteException); ok {
@@ -57,12 +109,7 @@ func (r *MongoUserRepository) FindByID(ctx context.Context, id string) (*model.U
 	if err != nil {
 		return nil, errors.New("invalid id format")
 	}
-	var u struct {
-		ID       primitive.ObjectID `bson:"_id"`
-		Username string             `bson:"username"`
-		Password string             `bson:"password"`
-		Email    string             `bson:"email"`
-	}
+	var u userDocument
 	err = r.coll.FindOne(ctx, bson.M{"_id": oid}).Decode(&u)
 	if err == mongo.ErrNoDocuments {
 		return nil, errors.New("user not found")
@@ -70,16 +117,11 @@ func (r *MongoUserRepository) FindByID(ctx context.Context, id string) (*model.U
 	if err != nil {
 		return nil, err
 	}
-	return &model.User{ID: u.ID.Hex(), Username: u.Username, Password: u.Password, Email: u.Email}, nil
+	return u.toModel(), nil
 }
 
 func (r *MongoUserRepository) FindByUsername(ctx context.Context, username string) (*model.User, error) {
-	var u struct {
-		ID       primitive.ObjectID `bson:"_id"`
-		Username string             `bson:"username"`
-		Password string             `bson:"password"`
-		Email    string             `bson:"email"`
-	}
+	var u userDocument
 	err := r.coll.FindOne(ctx, bson.M{"username": username}).Decode(&u)
 	if err == mongo.ErrNoDocuments {
 		return nil, errors.New("user not found")
@@ -87,5 +129,43 @@ func (r *MongoUserRepository) FindByUsername(ctx context.Context, username strin
 	if err != nil {
 		return nil, err
 	}
-	return &model.User{ID: u.ID.Hex(), Username: u.Username, Password: u.Password, Email: u.Email}, nil
+	return u.toModel(), nil
+}
+
+func (r *MongoUserRepository) LoginAttempts(ctx context.Context, username string) (int, time.Time, error) {
+	var a loginAttemptDocument
+	err := r.attempts.FindOne(ctx, bson.M{"_id": username}).Decode(&a)
+	if err == mongo.ErrNoDocuments {
+		return 0, time.Time{}, nil
+	}
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return a.FailedLogins, a.LockedUntil, nil
+}
+
+func (r *MongoUserRepository) RecordFailedLogin(ctx context.Context, username string) (int, error) {
+	var a loginAttemptDocument
+	err := r.attempts.FindOneAndUpdate(ctx,
+		bson.M{"_id": username},
+		bson.M{
+			"$inc": bson.M{"failed_logins": 1},
+			"$set": bson.M{"last_failed_at": time.Now()},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&a)
+	if err != nil {
+		return 0, err
+	}
+	return a.FailedLogins, nil
+}
+
+func (r *MongoUserRepository) LockUntil(ctx context.Context, username string, until time.Time) error {
+	_, err := r.attempts.UpdateOne(ctx, bson.M{"_id": username}, bson.M{"$set": bson.M{"locked_until": until}})
+	return err
+}
+
+func (r *MongoUserRepository) ResetFailedLogins(ctx context.Context, username string) error {
+	_, err := r.attempts.DeleteOne(ctx, bson.M{"_id": username})
+	return err
 }
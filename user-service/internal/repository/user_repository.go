@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"time"
 	"user-service/internal/model"
 )
 
@@ -10,5 +11,18 @@ type UserRepository interface {
 	FindByID(ctx context.Context, id string) (*model.User, error)
 	FindByUsername(ctx context.Context, username string) (*model.User, error)
 	Cleanup(ctx context.Context) error
+
+	// Failed logins are tracked per username, including names with no
+	// account, so that lockouts do not reveal which accounts exist.
+
+	// LoginAttempts returns the consecutive failed logins for a username and
+	// when its lock ends, or the zero time if it is not locked.
+	LoginAttempts(ctx context.Context, username string) (int, time.Time, error)
+	// RecordFailedLogin increments the consecutive failed login counter and returns its new value.
+	RecordFailedLogin(ctx context.Context, username string) (int, error)
+	// LockUntil locks the username for logins until the given time.
+	LockUntil(ctx context.Context, username string, until time.Time) error
+	// ResetFailedLogins clears the failed login counter and any lock.
+	ResetFailedLogins(ctx context.Context, username string) error
 }
 
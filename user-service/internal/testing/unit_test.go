@@ -2,13 +2,21 @@ package testing
 
 import (
     "context"
+    "errors"
     "testing"
+    "time"
 
     "github.com/stretchr/testify/assert"
     "github.com/stretchr/testify/mock"
 
     "user-service/internal/model"
+    "user-service/internal/repository"
     "user-service/internal/usecase"
+
+    "golang/pkg/address"
+    "golang/pkg/cache"
+
+    "golang.org/x/crypto/bcrypt"
 )
 
 // 🔧 Mock репозиторий
@@ -44,11 +52,30 @@ func (m *MockUserRepository) FindByID(ctx context.Context, id string) (*model.Us
     return user.(*model.User), args.Error(1)
 }
 
+func (m *MockUserRepository) LoginAttempts(ctx context.Context, username string) (int, time.Time, error) {
+    args := m.Called(ctx, username)
+    return args.Int(0), args.Get(1).(time.Time), args.Error(2)
+}
+
+func (m *MockUserRepository) RecordFailedLogin(ctx context.Context, username string) (int, error) {
+    args := m.Called(ctx, username)
+    return args.Int(0), args.Error(1)
+}
+
+func (m *MockUserRepository) LockUntil(ctx context.Context, username string, until time.Time) error {
+    args := m.Called(ctx, username, until)
+    return args.Error(0)
+}
+
+func (m *MockUserRepository) ResetFailedLogins(ctx context.Context, username string) error {
+    args := m.Called(ctx, username)
+    return args.Error(0)
+}
 
 // 🧪 Unit test
 func TestCreateUser(t *testing.T) {
     mockRepo := new(MockUserRepository)
-    uc := usecase.NewUserUsecase(mockRepo)
+    uc := usecase.NewUserUsecase(mockRepo, cache.NewLRU(100))
 
     testUser := &model.User{
         ID:       "1",
@@ -68,3 +95,227 @@ func TestCreateUser(t *testing.T) {
     assert.Equal(t, "1", id)
     mockRepo.AssertExpectations(t)
 }
+
+func hashedUser(t *testing.T) *model.User {
+    hash, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+    assert.NoError(t, err)
+    return &model.User{
+        ID:       "1",
+        Username: "Alice",
+        Email:    "alice@example.com",
+        Password: string(hash),
+    }
+}
+
+func TestAuthenticateUser_WrongPasswordCountsFailure(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    uc := usecase.NewUserUsecase(mockRepo, cache.NewLRU(100))
+
+    mockRepo.On("LoginAttempts", mock.Anything, "Alice").Return(0, time.Time{}, nil)
+    mockRepo.On("FindByUsername", mock.Anything, "Alice").Return(hashedUser(t), nil)
+    mockRepo.On("RecordFailedLogin", mock.Anything, "Alice").Return(1, nil)
+
+    _, err := uc.AuthenticateUser(context.Background(), "Alice", "wrong")
+
+    assert.EqualError(t, err, "incorrect password")
+    mockRepo.AssertNotCalled(t, "LockUntil", mock.Anything, mock.Anything, mock.Anything)
+    mockRepo.AssertExpectations(t)
+}
+
+func TestAuthenticateUser_LocksAfterRepeatedFailures(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    uc := usecase.NewUserUsecase(mockRepo, cache.NewLRU(100))
+
+    mockRepo.On("LoginAttempts", mock.Anything, "Alice").Return(6, time.Time{}, nil)
+    mockRepo.On("FindByUsername", mock.Anything, "Alice").Return(hashedUser(t), nil)
+    mockRepo.On("RecordFailedLogin", mock.Anything, "Alice").Return(7, nil)
+    mockRepo.On("LockUntil", mock.Anything, "Alice", mock.AnythingOfType("time.Time")).Return(nil)
+
+    start := time.Now()
+    _, err := uc.AuthenticateUser(context.Background(), "Alice", "wrong")
+
+    var locked *usecase.AccountLockedError
+    assert.True(t, errors.As(err, &locked))
+    // Seventh failure: 1m doubled twice.
+    assert.WithinDuration(t, start.Add(4*time.Minute), locked.Until, 5*time.Second)
+    mockRepo.AssertExpectations(t)
+}
+
+func TestAuthenticateUser_LockedAccountRejectsCorrectPassword(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    uc := usecase.NewUserUsecase(mockRepo, cache.NewLRU(100))
+
+    mockRepo.On("LoginAttempts", mock.Anything, "Alice").Return(5, time.Now().Add(time.Minute), nil)
+
+    _, err := uc.AuthenticateUser(context.Background(), "Alice", "password123")
+
+    var locked *usecase.AccountLockedError
+    assert.True(t, errors.As(err, &locked))
+    mockRepo.AssertNotCalled(t, "FindByUsername", mock.Anything, mock.Anything)
+    mockRepo.AssertNotCalled(t, "RecordFailedLogin", mock.Anything, mock.Anything)
+    mockRepo.AssertNotCalled(t, "ResetFailedLogins", mock.Anything, mock.Anything)
+}
+
+func TestAuthenticateUser_SuccessResetsFailures(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    uc := usecase.NewUserUsecase(mockRepo, cache.NewLRU(100))
+
+    mockRepo.On("LoginAttempts", mock.Anything, "Alice").Return(5, time.Now().Add(-time.Minute), nil)
+    mockRepo.On("FindByUsername", mock.Anything, "Alice").Return(hashedUser(t), nil)
+    mockRepo.On("ResetFailedLogins", mock.Anything, "Alice").Return(nil)
+
+    user, err := uc.AuthenticateUser(context.Background(), "Alice", "password123")
+
+    assert.NoError(t, err)
+    assert.Equal(t, "1", user.ID)
+    mockRepo.AssertExpectations(t)
+}
+
+func TestAuthenticateUser_UnknownUsernameFailsLikeWrongPassword(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    uc := usecase.NewUserUsecase(mockRepo, cache.NewLRU(100))
+
+    mockRepo.On("LoginAttempts", mock.Anything, "Mallory").Return(0, time.Time{}, nil)
+    mockRepo.On("FindByUsername", mock.Anything, "Mallory").Return(nil, errors.New("user not found"))
+    mockRepo.On("RecordFailedLogin", mock.Anything, "Mallory").Return(1, nil)
+
+    _, err := uc.AuthenticateUser(context.Background(), "Mallory", "password123")
+
+    assert.EqualError(t, err, "incorrect password")
+    mockRepo.AssertExpectations(t)
+}
+
+func TestAuthenticateUser_UnknownUsernameLocksOut(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    uc := usecase.NewUserUsecase(mockRepo, cache.NewLRU(100))
+
+    mockRepo.On("LoginAttempts", mock.Anything, "Mallory").Return(4, time.Time{}, nil)
+    mockRepo.On("FindByUsername", mock.Anything, "Mallory").Return(nil, errors.New("user not found"))
+    mockRepo.On("RecordFailedLogin", mock.Anything, "Mallory").Return(5, nil)
+    mockRepo.On("LockUntil", mock.Anything, "Mallory", mock.AnythingOfType("time.Time")).Return(nil)
+
+    _, err := uc.AuthenticateUser(context.Background(), "Mallory", "password123")
+
+    var locked *usecase.AccountLockedError
+    assert.True(t, errors.As(err, &locked))
+    mockRepo.AssertExpectations(t)
+}
+
+// Mock address repository
+type MockAddressRepository struct {
+    mock.Mock
+}
+
+func (m *MockAddressRepository) Create(ctx context.Context, a *model.Address) (string, error) {
+    args := m.Called(ctx, a)
+    return args.String(0), args.Error(1)
+}
+
+func (m *MockAddressRepository) FindByID(ctx context.Context, userID, id string) (*model.Address, error) {
+    args := m.Called(ctx, userID, id)
+    a := args.Get(0)
+    if a == nil {
+        return nil, args.Error(1)
+    }
+    return a.(*model.Address), args.Error(1)
+}
+
+func (m *MockAddressRepository) ListByUser(ctx context.Context, userID string) ([]*model.Address, error) {
+    args := m.Called(ctx, userID)
+    return args.Get(0).([]*model.Address), args.Error(1)
+}
+
+func (m *MockAddressRepository) CountByUser(ctx context.Context, userID string) (int64, error) {
+    args := m.Called(ctx, userID)
+    return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAddressRepository) Update(ctx context.Context, a *model.Address) error {
+    return m.Called(ctx, a).Error(0)
+}
+
+func (m *MockAddressRepository) Delete(ctx context.Context, userID, id string) error {
+    return m.Called(ctx, userID, id).Error(0)
+}
+
+func (m *MockAddressRepository) SetDefault(ctx context.Context, userID, id string) error {
+    return m.Called(ctx, userID, id).Error(0)
+}
+
+func homeAddress() address.Address {
+    return address.Address{
+        Name:       "Alice",
+        Line1:      "1 Main St",
+        City:       "Springfield",
+        Region:     "il",
+        PostalCode: "62701",
+        Country:    "us",
+    }
+}
+
+func TestCreateAddress_FirstBecomesDefault(t *testing.T) {
+    mockRepo := new(MockAddressRepository)
+    uc := usecase.NewAddressUsecase(mockRepo)
+
+    mockRepo.On("CountByUser", mock.Anything, "1").Return(int64(0), nil)
+    mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.Address")).Return("a1", nil)
+    mockRepo.On("SetDefault", mock.Anything, "1", "a1").Return(nil)
+
+    a, err := uc.CreateAddress(context.Background(), &model.Address{UserID: "1", Address: homeAddress()})
+
+    assert.NoError(t, err)
+    assert.Equal(t, "a1", a.ID)
+    assert.True(t, a.IsDefault)
+    // Stored normalized.
+    assert.Equal(t, "US", a.Address.Country)
+    assert.Equal(t, "IL", a.Address.Region)
+    mockRepo.AssertExpectations(t)
+}
+
+func TestCreateAddress_RejectsInvalidAddress(t *testing.T) {
+    mockRepo := new(MockAddressRepository)
+    uc := usecase.NewAddressUsecase(mockRepo)
+
+    a := homeAddress()
+    a.PostalCode = ""
+    _, err := uc.CreateAddress(context.Background(), &model.Address{UserID: "1", Address: a})
+
+    assert.True(t, errors.Is(err, address.ErrInvalid))
+    mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestCreateAddress_FullAddressBook(t *testing.T) {
+    mockRepo := new(MockAddressRepository)
+    uc := usecase.NewAddressUsecase(mockRepo)
+
+    mockRepo.On("CountByUser", mock.Anything, "1").Return(int64(20), nil)
+
+    _, err := uc.CreateAddress(context.Background(), &model.Address{UserID: "1", Address: homeAddress()})
+
+    assert.ErrorIs(t, err, usecase.ErrTooManyAddresses)
+    mockRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestDeleteAddress_PromotesNextDefault(t *testing.T) {
+    mockRepo := new(MockAddressRepository)
+    uc := usecase.NewAddressUsecase(mockRepo)
+
+    mockRepo.On("FindByID", mock.Anything, "1", "a1").Return(&model.Address{ID: "a1", UserID: "1", IsDefault: true}, nil)
+    mockRepo.On("Delete", mock.Anything, "1", "a1").Return(nil)
+    mockRepo.On("ListByUser", mock.Anything, "1").Return([]*model.Address{{ID: "a2", UserID: "1"}, {ID: "a3", UserID: "1"}}, nil)
+    mockRepo.On("SetDefault", mock.Anything, "1", "a2").Return(nil)
+
+    assert.NoError(t, uc.DeleteAddress(context.Background(), "1", "a1"))
+    mockRepo.AssertExpectations(t)
+}
+
+func TestGetAddress_OtherUsersAddressNotFound(t *testing.T) {
+    mockRepo := new(MockAddressRepository)
+    uc := usecase.NewAddressUsecase(mockRepo)
+
+    mockRepo.On("FindByID", mock.Anything, "2", "a1").Return(nil, repository.ErrAddressNotFound)
+
+    _, err := uc.GetAddress(context.Background(), "2", "a1")
+
+    assert.ErrorIs(t, err, repository.ErrAddressNotFound)
+}
@@ -8,6 +8,8 @@ import (
     "user-service/internal/repository"
     "user-service/internal/usecase"
 
+    "golang/pkg/cache"
+
     "github.com/stretchr/testify/assert"
     "go.mongodb.org/mongo-driver/mongo"
     "go.mongodb.org/mongo-driver/mongo/options"
@@ -49,7 +51,7 @@ func cleanupCollection(t *testing.T) {
 func TestIntegration(t *testing.T) {
     cleanupCollection(t) // Тазалау — тестті таза бастау үшін
 
-    uc := usecase.NewUserUsecase(userRepo)
+    uc := usecase.NewUserUsecase(userRepo, cache.NewLRU(100))
 
     user := &model.User{
         Username: "integration_user",
@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"user-service/internal/model"
+	"user-service/internal/repository"
+)
+
+// maxAddresses caps the size of an address book.
+const maxAddresses = 20
+
+// ErrTooManyAddresses is returned when a full address book gets another
+// address.
+var ErrTooManyAddresses = fmt.Errorf("an address book holds at most %d addresses", maxAddresses)
+
+type AddressUsecase struct {
+	repo repository.AddressRepository
+}
+
+func NewAddressUsecase(repo repository.AddressRepository) *AddressUsecase {
+	return &AddressUsecase{repo: repo}
+}
+
+// CreateAddress adds a validated address to a user's address book. The first
+// address, or one marked IsDefault, becomes the default.
+func (u *AddressUsecase) CreateAddress(ctx context.Context, a *model.Address) (*model.Address, error) {
+	if a.UserID == "" {
+		return nil, errors.New("user_id is required")
+	}
+	a.Address = a.Address.Normalize()
+	if err := a.Address.Validate(); err != nil {
+		return nil, err
+	}
+	count, err := u.repo.CountByUser(ctx, a.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxAddresses {
+		return nil, ErrTooManyAddresses
+	}
+
+	makeDefault := a.IsDefault || count == 0
+	a.IsDefault = false
+	a.CreatedAt = time.Now().UTC()
+	if a.ID, err = u.repo.Create(ctx, a); err != nil {
+		return nil, err
+	}
+	if makeDefault {
+		if err := u.repo.SetDefault(ctx, a.UserID, a.ID); err != nil {
+			return nil, err
+		}
+		a.IsDefault = true
+	}
+	return a, nil
+}
+
+func (u *AddressUsecase) GetAddress(ctx context.Context, userID, id string) (*model.Address, error) {
+	if userID == "" || id == "" {
+		return nil, errors.New("user_id and id are required")
+	}
+	return u.repo.FindByID(ctx, userID, id)
+}
+
+func (u *AddressUsecase) ListAddresses(ctx context.Context, userID string) ([]*model.Address, error) {
+	if userID == "" {
+		return nil, errors.New("user_id is required")
+	}
+	return u.repo.ListByUser(ctx, userID)
+}
+
+// UpdateAddress replaces the postal address of an entry, and makes it the
+// default if IsDefault is set. Clearing IsDefault leaves the default alone:
+// a user with addresses always has a default.
+func (u *AddressUsecase) UpdateAddress(ctx context.Context, a *model.Address) (*model.Address, error) {
+	if a.UserID == "" || a.ID == "" {
+		return nil, errors.New("user_id and id are required")
+	}
+	a.Address = a.Address.Normalize()
+	if err := a.Address.Validate(); err != nil {
+		return nil, err
+	}
+	if err := u.repo.Update(ctx, a); err != nil {
+		return nil, err
+	}
+	if a.IsDefault {
+		if err := u.repo.SetDefault(ctx, a.UserID, a.ID); err != nil {
+			return nil, err
+		}
+	}
+	return u.repo.FindByID(ctx, a.UserID, a.ID)
+}
+
+// DeleteAddress removes an address. If it was the default, the oldest
+// remaining address takes its place.
+func (u *AddressUsecase) DeleteAddress(ctx context.Context, userID, id string) error {
+	a, err := u.GetAddress(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if err := u.repo.Delete(ctx, userID, id); err != nil {
+		return err
+	}
+	if !a.IsDefault {
+		return nil
+	}
+	rest, err := u.repo.ListByUser(ctx, userID)
+	if err != nil || len(rest) == 0 {
+		return err
+	}
+	return u.repo.SetDefault(ctx, userID, rest[0].ID)
+}
+
+func (u *AddressUsecase) SetDefaultAddress(ctx context.Context, userID, id string) (*model.Address, error) {
+	if userID == "" || id == "" {
+		return nil, errors.New("user_id and id are required")
+	}
+	if err := u.repo.SetDefault(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	return u.repo.FindByID(ctx, userID, id)
+}
@@ -5,20 +5,67 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"strings"
 	"time"
 	"user-service/internal/model"
-	"user-service/internal/redis"
 	"user-service/internal/repository"
 
+	"golang/pkg/cache"
+
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	userCacheTTL     = 10 * time.Minute
+	notFoundCacheTTL = 30 * time.Second
+)
+
+// Usernames are locked after maxFailedLogins consecutive failed logins. The
+// lock starts at baseLockout and doubles with every further failure, up to
+// maxLockout. A successful login resets the counter.
+const (
+	maxFailedLogins = 5
+	baseLockout     = time.Minute
+	maxLockout      = time.Hour
+)
+
+// dummyHash is compared against when the username does not exist.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+
+// AccountLockedError is returned by AuthenticateUser while an account is
+// locked out after repeated failed logins.
+type AccountLockedError struct {
+	Until time.Time
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("account locked until %s", e.Until.UTC().Format(time.RFC3339))
+}
+
+// lockoutDuration returns how long to lock an account after the given number
+// of consecutive failures, or zero if it should not be locked yet.
+func lockoutDuration(failures int) time.Duration {
+	if failures < maxFailedLogins {
+		return 0
+	}
+	d := baseLockout
+	for i := maxFailedLogins; i < failures && d < maxLockout; i++ {
+		d *= 2
+	}
+	if d > maxLockout {
+		d = maxLockout
+	}
+	return d
+}
+
 type UserUsecase struct {
-	repo repository.UserRepository
+	repo  repository.UserRepository
+	cache cache.Cache
 }
 
-func NewUserUsecase(repo repository.UserRepository) *UserUsecase {
-	return &UserUsecase{repo: repo}
+func NewUserUsecase(repo repository.UserRepository, c cache.Cache) *UserUsecase {
+	return &UserUsecase{repo: repo, cache: c}
 }
 
 func (u *UserUsecase) CreateUser(ctx context.Context, user *model.User) (string, error) {
@@ -39,26 +86,15 @@ func (u *UserUsecase) GetUserByID(ctx context.Context, id string) (*model.User,
 		return nil, errors.New("id is required")
 	}
 
-	// 1. Redis-ке сұрау
-	cacheKey := fmt.Sprintf("user:%s", id)
-	cachedUser, err := redis.GetFromCache[model.User](cacheKey)
-	if err != nil {
-		return nil, err
-	}
-	if cachedUser != nil {
-		return cachedUser, nil
-	}
-
-	// 2. Реподан алу
-	user, err := u.repo.FindByID(ctx, id)
-	if err != nil {
-		return nil, err
-	}
-
-	// 3. Redis-ке жазу
-	_ = redis.SetToCache(cacheKey, user, 10*time.Minute)
-
-	return user, nil
+	// Кэштен сұрау, болмаса реподан алып кэшке жазу
+	return cache.GetOrLoad(ctx, u.cache, fmt.Sprintf("user:%s", id), userCacheTTL,
+		func(ctx context.Context) (*model.User, error) {
+			return u.repo.FindByID(ctx, id)
+		},
+		cache.WithNegativeCaching(func(err error) bool {
+			return strings.Contains(err.Error(), "not found")
+		}, notFoundCacheTTL),
+	)
 }
 
 func (u *UserUsecase) GetUserByUsername(ctx context.Context, username string) (*model.User, error) {
@@ -73,15 +109,49 @@ func (u *UserUsecase) AuthenticateUser(ctx context.Context, username, password s
 		return nil, errors.New("username and password are required")
 	}
 
-	user, err := u.repo.FindByUsername(ctx, username)
+	// Locked usernames are rejected without checking the password so that
+	// guesses made during the lock reveal nothing.
+	failures, lockedUntil, err := u.repo.LoginAttempts(ctx, username)
 	if err != nil {
-		return nil, errors.New("user not found")
+		return nil, err
+	}
+	if lockedUntil.After(time.Now()) {
+		return nil, &AccountLockedError{Until: lockedUntil}
+	}
+
+	// Unknown usernames are checked against a dummy hash and counted like
+	// wrong passwords, so they take as long and lock out the same way.
+	hash := dummyHash
+	user, err := u.repo.FindByUsername(ctx, username)
+	switch {
+	case err == nil:
+		hash = []byte(user.Password)
+	case !strings.Contains(err.Error(), "not found"):
+		return nil, err
 	}
 
 	// ✅ сравниваем введённый пароль с хэшем из базы
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || user == nil {
+		failures, err := u.repo.RecordFailedLogin(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+		if d := lockoutDuration(failures); d > 0 {
+			until := time.Now().Add(d)
+			if err := u.repo.LockUntil(ctx, username, until); err != nil {
+				return nil, err
+			}
+			slog.WarnContext(ctx, "login locked after failed attempts", "failures", failures, "locked_until", until)
+			return nil, &AccountLockedError{Until: until}
+		}
 		return nil, errors.New("incorrect password")
 	}
 
+	if failures > 0 {
+		if err := u.repo.ResetFailedLogins(ctx, username); err != nil {
+			return nil, err
+		}
+	}
+
 	return user, nil
 }